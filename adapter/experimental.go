@@ -12,6 +12,8 @@ import (
 	"github.com/sagernet/sing-dns"
 	N "github.com/sagernet/sing/common/network"
 	"github.com/sagernet/sing/common/rw"
+
+	mdns "github.com/miekg/dns"
 )
 
 type ClashServer interface {
@@ -34,6 +36,10 @@ type CacheFile interface {
 	StoreRDRC() bool
 	dns.RDRCStore
 
+	StoreDNS() bool
+	LoadDNSCache() []SavedDNSMessage
+	SaveDNSCache(messages []SavedDNSMessage) error
+
 	LoadMode() string
 	StoreMode(mode string) error
 	LoadSelected(group string) string
@@ -42,17 +48,34 @@ type CacheFile interface {
 	StoreGroupExpand(group string, expand bool) error
 	LoadRuleSet(tag string) *SavedRuleSet
 	SaveRuleSet(tag string, set *SavedRuleSet) error
+	LoadOutboundProvider(tag string) *SavedOutboundProvider
+	SaveOutboundProvider(tag string, provider *SavedOutboundProvider) error
+	LoadUserUsage(user string) *SavedUserUsage
+	SaveUserUsage(usages map[string]*SavedUserUsage) error
+}
+
+type SavedDNSMessage struct {
+	Message  *mdns.Msg
+	ExpireAt time.Time
+}
+
+type SavedUserUsage struct {
+	DailyStart   time.Time
+	DailyBytes   int64
+	MonthlyStart time.Time
+	MonthlyBytes int64
 }
 
 type SavedRuleSet struct {
-	Content     []byte
-	LastUpdated time.Time
-	LastEtag    string
+	Content      []byte
+	LastUpdated  time.Time
+	LastEtag     string
+	LastModified string
 }
 
 func (s *SavedRuleSet) MarshalBinary() ([]byte, error) {
 	var buffer bytes.Buffer
-	err := binary.Write(&buffer, binary.BigEndian, uint8(1))
+	err := binary.Write(&buffer, binary.BigEndian, uint8(2))
 	if err != nil {
 		return nil, err
 	}
@@ -69,6 +92,10 @@ func (s *SavedRuleSet) MarshalBinary() ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	err = rw.WriteVString(&buffer, s.LastModified)
+	if err != nil {
+		return nil, err
+	}
 	return buffer.Bytes(), nil
 }
 
@@ -98,9 +125,25 @@ func (s *SavedRuleSet) UnmarshalBinary(data []byte) error {
 	if err != nil {
 		return err
 	}
+	if version >= 2 {
+		s.LastModified, err = rw.ReadVString(reader)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+type SavedOutboundProvider SavedRuleSet
+
+func (s *SavedOutboundProvider) MarshalBinary() ([]byte, error) {
+	return (*SavedRuleSet)(s).MarshalBinary()
+}
+
+func (s *SavedOutboundProvider) UnmarshalBinary(data []byte) error {
+	return (*SavedRuleSet)(s).UnmarshalBinary(data)
+}
+
 type Tracker interface {
 	Leave()
 }
@@ -132,3 +175,22 @@ type V2RayStatsService interface {
 	RoutedConnection(inbound string, outbound string, user string, conn net.Conn) net.Conn
 	RoutedPacketConnection(inbound string, outbound string, user string, conn N.PacketConn) N.PacketConn
 }
+
+type MetricsServer interface {
+	Service
+	RoutedConnection(ctx context.Context, conn net.Conn, metadata InboundContext, outbound string) (net.Conn, Tracker)
+	RoutedPacketConnection(ctx context.Context, conn N.PacketConn, metadata InboundContext, outbound string) (N.PacketConn, Tracker)
+	DNSQuery(server string, duration time.Duration, err error)
+	DNSCacheHit()
+}
+
+type AccessLogger interface {
+	Service
+	RoutedConnection(ctx context.Context, conn net.Conn, metadata InboundContext, matchedRule Rule, outbound Outbound) (net.Conn, FinishTracker)
+	RoutedPacketConnection(ctx context.Context, conn N.PacketConn, metadata InboundContext, matchedRule Rule, outbound Outbound) (N.PacketConn, FinishTracker)
+}
+
+// FinishTracker is notified with the error that ended a routed connection.
+type FinishTracker interface {
+	Finish(err error)
+}
@@ -6,6 +6,7 @@ import (
 	"net/netip"
 
 	"github.com/sagernet/sing-box/common/process"
+	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
 	M "github.com/sagernet/sing/common/metadata"
 	N "github.com/sagernet/sing/common/network"
@@ -24,6 +25,21 @@ type InjectableInbound interface {
 	NewPacketConnection(ctx context.Context, conn N.PacketConn, metadata InboundContext) error
 }
 
+type UserManagedInbound interface {
+	Inbound
+	Users() InboundUsers
+}
+
+// InboundUsers changes users of a multi-user inbound at runtime. Users are
+// exchanged as their JSON configuration, and addressed by name.
+type InboundUsers interface {
+	List() []any
+	Add(content []byte) error
+	Update(name string, content []byte) error
+	Remove(name string) error
+	Reset(content []byte) error
+}
+
 type InboundContext struct {
 	Inbound     string
 	InboundType string
@@ -46,6 +62,7 @@ type InboundContext struct {
 	SourceGeoIPCode      string
 	GeoIPCode            string
 	ProcessInfo          *process.Info
+	WIFIState            *WIFIState
 	QueryType            uint16
 	FakeIP               bool
 
@@ -68,6 +85,33 @@ func (c *InboundContext) ResetRuleCache() {
 	c.DestinationPortMatch = false
 }
 
+// LogContextFields returns connection fields of the inbound context for JSON log entries.
+func LogContextFields(ctx context.Context) []log.Field {
+	metadata := ContextFrom(ctx)
+	if metadata == nil {
+		return nil
+	}
+	var fields []log.Field
+	appendField := func(key string, value string) {
+		if value != "" {
+			fields = append(fields, log.Field{Key: key, Value: value})
+		}
+	}
+	appendField("inbound", metadata.Inbound)
+	appendField("inbound_type", metadata.InboundType)
+	appendField("network", metadata.Network)
+	if metadata.Source.IsValid() {
+		appendField("source", metadata.Source.String())
+	}
+	if metadata.Destination.IsValid() {
+		appendField("destination", metadata.Destination.String())
+	}
+	appendField("domain", metadata.Domain)
+	appendField("user", metadata.User)
+	appendField("outbound", metadata.Outbound)
+	return fields
+}
+
 type inboundContextKey struct{}
 
 func WithContext(ctx context.Context, inboundContext *InboundContext) context.Context {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net"
 
+	"github.com/sagernet/sing/common"
 	N "github.com/sagernet/sing/common/network"
 )
 
@@ -18,3 +19,39 @@ type Outbound interface {
 	NewConnection(ctx context.Context, conn net.Conn, metadata InboundContext) error
 	NewPacketConnection(ctx context.Context, conn N.PacketConn, metadata InboundContext) error
 }
+
+// OutboundChain is an outbound that connects through other outbounds, listed
+// from the entry hop to the exit hop.
+type OutboundChain interface {
+	Outbound
+	Hops() []string
+}
+
+// OutboundPath returns the tag of the outbound followed by tags of the outbounds
+// it uses, following group selections and expanding chains from the exit hop to
+// the entry hop.
+func OutboundPath(router Router, tag string) []string {
+	var path []string
+	appendOutboundPath(router, tag, &path)
+	return path
+}
+
+func appendOutboundPath(router Router, tag string, path *[]string) {
+	if common.Contains(*path, tag) {
+		return
+	}
+	*path = append(*path, tag)
+	outbound, loaded := router.Outbound(tag)
+	if !loaded {
+		return
+	}
+	switch outbound := outbound.(type) {
+	case OutboundGroup:
+		appendOutboundPath(router, outbound.Now(), path)
+	case OutboundChain:
+		hops := outbound.Hops()
+		for i := len(hops) - 1; i >= 0; i-- {
+			appendOutboundPath(router, hops[i], path)
+		}
+	}
+}
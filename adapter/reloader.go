@@ -0,0 +1,10 @@
+package adapter
+
+import "github.com/sagernet/sing-box/option"
+
+type Reloader interface {
+	Reload(options option.Options) error
+	// ReloadKeepInbounds reloads like Reload, but refuses options that add
+	// or change inbounds.
+	ReloadKeepInbounds(options option.Options) error
+}
@@ -0,0 +1,27 @@
+package adapter
+
+// RouteTrace explains how the router decides a connection, without
+// connecting to it.
+type RouteTrace struct {
+	Rules    []RouteTraceRule `json:"rules"`
+	DNS      *RouteTraceDNS   `json:"dns,omitempty"`
+	Action   string           `json:"action"`
+	Outbound string           `json:"outbound,omitempty"`
+}
+
+type RouteTraceRule struct {
+	Index   int      `json:"index"`
+	Rule    string   `json:"rule"`
+	Action  string   `json:"action"`
+	Matched bool     `json:"matched"`
+	Items   []string `json:"items,omitempty"`
+	Note    string   `json:"note,omitempty"`
+}
+
+type RouteTraceDNS struct {
+	Domain    string `json:"domain"`
+	RuleIndex int    `json:"rule_index"`
+	Rule      string `json:"rule,omitempty"`
+	Server    string `json:"server"`
+	FakeIP    bool   `json:"fakeip,omitempty"`
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"net/netip"
+	"time"
 
 	"github.com/sagernet/sing-box/common/geoip"
 	"github.com/sagernet/sing-dns"
@@ -24,13 +25,18 @@ type Router interface {
 	Outbound(tag string) (Outbound, bool)
 	DefaultOutbound(network string) (Outbound, error)
 
+	OutboundProviders() []OutboundProvider
+	OutboundProvider(tag string) (OutboundProvider, bool)
+
 	FakeIPStore() FakeIPStore
 
 	ConnectionRouter
+	TraceRoute(ctx context.Context, metadata InboundContext) (*RouteTrace, error)
 
 	GeoIPReader() *geoip.Reader
 	LoadGeosite(code string) (Rule, error)
 
+	RuleSets() []RuleSet
 	RuleSet(tag string) (RuleSet, bool)
 
 	NeedWIFIState() bool
@@ -58,6 +64,12 @@ type Router interface {
 	V2RayServer() V2RayServer
 	SetV2RayServer(server V2RayServer)
 
+	MetricsServer() MetricsServer
+	SetMetricsServer(server MetricsServer)
+
+	AccessLogger() AccessLogger
+	SetAccessLogger(logger AccessLogger)
+
 	ResetNetwork() error
 }
 
@@ -79,6 +91,12 @@ type Rule interface {
 	Type() string
 	UpdateGeosite() error
 	Outbound() string
+	Action() RuleAction
+	String() string
+}
+
+type RuleAction interface {
+	Type() string
 	String() string
 }
 
@@ -92,9 +110,15 @@ type DNSRule interface {
 }
 
 type RuleSet interface {
+	Tag() string
+	Type() string
 	StartContext(ctx context.Context, startContext RuleSetStartContext) error
 	PostStart() error
 	Metadata() RuleSetMetadata
+	RuleCount() int
+	UpdatedAt() time.Time
+	LastError() error
+	Update(ctx context.Context) error
 	Close() error
 	HeadlessRule
 }
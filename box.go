@@ -6,18 +6,24 @@ import (
 	"io"
 	"os"
 	"runtime/debug"
+	"sync"
 	"time"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/common/taskmonitor"
+	"github.com/sagernet/sing-box/common/userlimit"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/experimental"
+	"github.com/sagernet/sing-box/experimental/accesslog"
 	"github.com/sagernet/sing-box/experimental/cachefile"
 	"github.com/sagernet/sing-box/experimental/libbox/platform"
+	"github.com/sagernet/sing-box/experimental/metrics"
+	"github.com/sagernet/sing-box/experimental/userapi"
 	"github.com/sagernet/sing-box/inbound"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
 	"github.com/sagernet/sing-box/outbound"
+	"github.com/sagernet/sing-box/provider"
 	"github.com/sagernet/sing-box/route"
 	"github.com/sagernet/sing/common"
 	E "github.com/sagernet/sing/common/exceptions"
@@ -29,16 +35,22 @@ import (
 var _ adapter.Service = (*Box)(nil)
 
 type Box struct {
-	createdAt    time.Time
-	router       adapter.Router
-	inbounds     []adapter.Inbound
-	outbounds    []adapter.Outbound
-	logFactory   log.Factory
-	logger       log.ContextLogger
-	preServices1 map[string]adapter.Service
-	preServices2 map[string]adapter.Service
-	postServices map[string]adapter.Service
-	done         chan struct{}
+	createdAt         time.Time
+	ctx               context.Context
+	options           option.Options
+	platformInterface platform.Interface
+	router            *route.Router
+	reloadAccess      sync.Mutex
+	inbounds          []adapter.Inbound
+	outbounds         []adapter.Outbound
+	outboundProviders []adapter.OutboundProvider
+	logFactory        log.Factory
+	logger            log.ContextLogger
+	preServices1      map[string]adapter.Service
+	preServices2      map[string]adapter.Service
+	postServices      map[string]adapter.Service
+	userServer        *userapi.Server
+	done              chan struct{}
 }
 
 type Options struct {
@@ -81,10 +93,13 @@ func New(options Options) (*Box, error) {
 		DefaultWriter:  defaultLogWriter,
 		BaseTime:       createdAt,
 		PlatformWriter: options.PlatformLogWriter,
+		ContextFields:  []func(ctx context.Context) []log.Field{adapter.LogContextFields, outbound.LogContextFields},
 	})
 	if err != nil {
 		return nil, E.Cause(err, "create log factory")
 	}
+	userLimiter := userlimit.NewManager(ctx, logFactory.NewLogger("user-limit"))
+	service.MustRegisterPtr(ctx, userLimiter)
 	router, err := route.NewRouter(
 		ctx,
 		logFactory,
@@ -138,7 +153,18 @@ func New(options Options) (*Box, error) {
 		}
 		outbounds = append(outbounds, out)
 	}
-	err = router.Initialize(inbounds, outbounds, func() adapter.Outbound {
+	outboundProviders := make([]adapter.OutboundProvider, 0, len(options.Providers))
+	for i, providerOptions := range options.Providers {
+		if providerOptions.Tag == "" {
+			return nil, E.New("parse provider[", i, "]: missing tag")
+		}
+		outboundProvider, err := provider.New(ctx, router, logFactory, providerOptions)
+		if err != nil {
+			return nil, E.Cause(err, "parse provider[", i, "]")
+		}
+		outboundProviders = append(outboundProviders, outboundProvider)
+	}
+	err = router.Initialize(inbounds, outbounds, outboundProviders, func() adapter.Outbound {
 		out, oErr := outbound.New(ctx, router, logFactory.NewLogger("outbound/direct"), "direct", option.Outbound{Type: "direct", Tag: "default"})
 		common.Must(oErr)
 		outbounds = append(outbounds, out)
@@ -156,6 +182,7 @@ func New(options Options) (*Box, error) {
 	preServices1 := make(map[string]adapter.Service)
 	preServices2 := make(map[string]adapter.Service)
 	postServices := make(map[string]adapter.Service)
+	postServices["user limit"] = userLimiter
 	if needCacheFile {
 		cacheFile := service.FromContext[adapter.CacheFile](ctx)
 		if cacheFile == nil {
@@ -182,18 +209,49 @@ func New(options Options) (*Box, error) {
 		router.SetV2RayServer(v2rayServer)
 		preServices2["v2ray api"] = v2rayServer
 	}
-	return &Box{
-		router:       router,
-		inbounds:     inbounds,
-		outbounds:    outbounds,
-		createdAt:    createdAt,
-		logFactory:   logFactory,
-		logger:       logFactory.Logger(),
-		preServices1: preServices1,
-		preServices2: preServices2,
-		postServices: postServices,
-		done:         make(chan struct{}),
-	}, nil
+	if experimentalOptions.Metrics != nil {
+		metricsServer, err := metrics.NewServer(ctx, router, logFactory.NewLogger("metrics"), common.PtrValueOrDefault(experimentalOptions.Metrics))
+		if err != nil {
+			return nil, E.Cause(err, "create metrics server")
+		}
+		router.SetMetricsServer(metricsServer)
+		preServices2["metrics"] = metricsServer
+	}
+	if experimentalOptions.AccessLog != nil {
+		accessLogger, err := accesslog.NewLogger(ctx, router, logFactory.NewLogger("access-log"), common.PtrValueOrDefault(experimentalOptions.AccessLog))
+		if err != nil {
+			return nil, E.Cause(err, "create access log")
+		}
+		router.SetAccessLogger(accessLogger)
+		preServices2["access log"] = accessLogger
+	}
+	var userServer *userapi.Server
+	if experimentalOptions.UserAPI != nil {
+		userServer, err = userapi.NewServer(ctx, logFactory.NewLogger("user-api"), inbounds, common.PtrValueOrDefault(experimentalOptions.UserAPI))
+		if err != nil {
+			return nil, E.Cause(err, "create user api server")
+		}
+		preServices2["user api"] = userServer
+	}
+	instance := &Box{
+		ctx:               ctx,
+		options:           options.Options,
+		platformInterface: options.PlatformInterface,
+		router:            router,
+		inbounds:          inbounds,
+		outbounds:         outbounds,
+		outboundProviders: outboundProviders,
+		createdAt:         createdAt,
+		logFactory:        logFactory,
+		logger:            logFactory.Logger(),
+		preServices1:      preServices1,
+		preServices2:      preServices2,
+		postServices:      postServices,
+		userServer:        userServer,
+		done:              make(chan struct{}),
+	}
+	service.MustRegister[adapter.Reloader](ctx, instance)
+	return instance, nil
 }
 
 func (s *Box) PreStart() error {
@@ -262,6 +320,16 @@ func (s *Box) preStart() error {
 			}
 		}
 	}
+	for serviceName, service := range s.postServices {
+		if preService, isPreService := service.(adapter.PreStarter); isPreService {
+			monitor.Start("pre-start ", serviceName)
+			err := preService.PreStart()
+			monitor.Finish()
+			if err != nil {
+				return E.Cause(err, "pre-start ", serviceName)
+			}
+		}
+	}
 	err = s.router.PreStart()
 	if err != nil {
 		return E.Cause(err, "pre-start router")
@@ -270,6 +338,14 @@ func (s *Box) preStart() error {
 	if err != nil {
 		return err
 	}
+	for _, outboundProvider := range s.outboundProviders {
+		monitor.Start("initialize provider/", outboundProvider.Type(), "[", outboundProvider.Tag(), "]")
+		err = outboundProvider.Start()
+		monitor.Finish()
+		if err != nil {
+			return E.Cause(err, "initialize provider/", outboundProvider.Type(), "[", outboundProvider.Tag(), "]")
+		}
+	}
 	return s.router.Start()
 }
 
@@ -320,6 +396,12 @@ func (s *Box) postStart() error {
 			}
 		}
 	}
+	for _, outboundProvider := range s.outboundProviders {
+		err := outboundProvider.PostStart()
+		if err != nil {
+			return E.Cause(err, "post-start provider/", outboundProvider.Tag())
+		}
+	}
 
 	return s.router.PostStart()
 }
@@ -354,6 +436,13 @@ func (s *Box) Close() error {
 		})
 		monitor.Finish()
 	}
+	for _, outboundProvider := range s.outboundProviders {
+		monitor.Start("close provider/", outboundProvider.Type(), "[", outboundProvider.Tag(), "]")
+		errors = E.Append(errors, outboundProvider.Close(), func(err error) error {
+			return E.Cause(err, "close provider/", outboundProvider.Type(), "[", outboundProvider.Tag(), "]")
+		})
+		monitor.Finish()
+	}
 	monitor.Start("close router")
 	if err := common.Close(s.router); err != nil {
 		errors = E.Append(errors, err, func(err error) error {
@@ -383,6 +472,17 @@ func (s *Box) Close() error {
 	return errors
 }
 
+// ReopenLog reopens log files after they were moved by an external tool.
+func (s *Box) ReopenLog() error {
+	var errors error
+	for _, logWriter := range []any{s.logFactory, s.router.AccessLogger()} {
+		if reopener, isReopener := logWriter.(interface{ Reopen() error }); isReopener {
+			errors = E.Errors(errors, reopener.Reopen())
+		}
+	}
+	return errors
+}
+
 func (s *Box) Router() adapter.Router {
 	return s.router
 }
@@ -0,0 +1,43 @@
+package box
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChainHops(t *testing.T) {
+	const outbounds = `
+    {"type": "direct", "tag": "direct"},
+    {"type": "shadowsocks", "tag": "ss", "server": "127.0.0.1", "server_port": 8388, "method": "aes-128-gcm", "password": "password"},
+    {"type": "shadowsocks", "tag": "ss-mux", "server": "127.0.0.1", "server_port": 8388, "method": "aes-128-gcm", "password": "password", "multiplex": {"enabled": true}},
+    {"type": "selector", "tag": "group", "outbounds": ["ss", "ss-mux"], "default": "ss"},
+    {"type": "selector", "tag": "group-direct", "outbounds": ["ss", "direct"], "default": "ss"},`
+	for _, testCase := range []struct {
+		hops string
+		err  string
+	}{
+		{`["direct", "ss"]`, ""},
+		{`["ss-mux", "ss"]`, ""},
+		{`["group", "ss"]`, ""},
+		{`["direct", "ss-mux"]`, "outbound 1: outbound ss-mux with multiplex can only be used as the entry of a chain"},
+		{`["direct", "group"]`, "outbound 1: outbound ss-mux with multiplex can only be used as the entry of a chain"},
+		{`["ss", "direct"]`, "outbound 1: direct outbound direct can only be used as the entry of a chain"},
+		{`["ss", "group-direct"]`, "outbound 1: direct outbound direct can only be used as the entry of a chain"},
+	} {
+		instance, err := New(Options{Options: parseReloadOptions(t, `{
+  "log": {"disabled": true},
+  "outbounds": [`+outbounds+`
+    {"type": "chain", "tag": "chain", "outbounds": `+testCase.hops+`}
+  ]
+}`)})
+		require.NoError(t, err)
+		err = instance.Start()
+		instance.Close()
+		if testCase.err == "" {
+			require.NoError(t, err, testCase.hops)
+		} else {
+			require.ErrorContains(t, err, testCase.err, testCase.hops)
+		}
+	}
+}
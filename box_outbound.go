@@ -12,10 +12,14 @@ import (
 )
 
 func (s *Box) startOutbounds() error {
+	return s.startOutboundList(s.outbounds, make(map[string]bool))
+}
+
+func (s *Box) startOutboundList(outboundList []adapter.Outbound, started map[string]bool) error {
 	monitor := taskmonitor.New(s.logger, C.DefaultStartTimeout)
 	outboundTags := make(map[adapter.Outbound]string)
 	outbounds := make(map[string]adapter.Outbound)
-	for i, outboundToStart := range s.outbounds {
+	for i, outboundToStart := range outboundList {
 		var outboundTag string
 		if outboundToStart.Tag() == "" {
 			outboundTag = F.ToString(i)
@@ -28,11 +32,10 @@ func (s *Box) startOutbounds() error {
 		outboundTags[outboundToStart] = outboundTag
 		outbounds[outboundTag] = outboundToStart
 	}
-	started := make(map[string]bool)
 	for {
 		canContinue := false
 	startOne:
-		for _, outboundToStart := range s.outbounds {
+		for _, outboundToStart := range outboundList {
 			outboundTag := outboundTags[outboundToStart]
 			if started[outboundTag] {
 				continue
@@ -54,13 +57,13 @@ func (s *Box) startOutbounds() error {
 				}
 			}
 		}
-		if len(started) == len(s.outbounds) {
+		if len(started) == len(outboundList) {
 			break
 		}
 		if canContinue {
 			continue
 		}
-		currentOutbound := common.Find(s.outbounds, func(it adapter.Outbound) bool {
+		currentOutbound := common.Find(outboundList, func(it adapter.Outbound) bool {
 			return !started[outboundTags[it]]
 		})
 		var lintOutbound func(oTree []string, oCurrent adapter.Outbound) error
@@ -0,0 +1,482 @@
+package box
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/inbound"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/outbound"
+	"github.com/sagernet/sing-box/provider"
+	"github.com/sagernet/sing-box/route"
+	"github.com/sagernet/sing-box/transport/sip003"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	"github.com/sagernet/sing/common/json"
+)
+
+func (s *Box) Reload(options option.Options) error {
+	return s.reload(options, false)
+}
+
+func (s *Box) ReloadKeepInbounds(options option.Options) error {
+	return s.reload(options, true)
+}
+
+func (s *Box) reload(options option.Options, keepInbounds bool) error {
+	s.reloadAccess.Lock()
+	defer s.reloadAccess.Unlock()
+	select {
+	case <-s.done:
+		return os.ErrClosed
+	default:
+	}
+	err := checkReloadOptions(s.options, options)
+	if err != nil {
+		return err
+	}
+	routeOptions := common.PtrValueOrDefault(options.Route)
+	dnsOptions := common.PtrValueOrDefault(options.DNS)
+
+	lastInbounds := make(map[string]adapter.Inbound)
+	lastInboundOptions := make(map[string][]byte)
+	lastInboundKeys := make(reloadKeys)
+	for i, inboundOptions := range s.options.Inbounds {
+		content, err := json.Marshal(inboundOptions)
+		if err != nil {
+			return err
+		}
+		key := lastInboundKeys.key(inboundOptions.Tag, content)
+		lastInbounds[key] = s.inbounds[i]
+		lastInboundOptions[key] = content
+	}
+	lastOutbounds := make(map[string]adapter.Outbound)
+	lastOutboundOptions := make(map[string][]byte)
+	lastOutboundKeys := make(reloadKeys)
+	for i, outboundOptions := range s.options.Outbounds {
+		content, err := json.Marshal(&outboundOptions)
+		if err != nil {
+			return err
+		}
+		key := lastOutboundKeys.key(outboundOptions.Tag, content)
+		lastOutbounds[key] = s.outbounds[i]
+		lastOutboundOptions[key] = content
+	}
+	internalOutbounds := s.outbounds[len(s.options.Outbounds):]
+	lastProviders := make(map[string]adapter.OutboundProvider)
+	lastProviderOptions := make(map[string][]byte)
+	for i, providerOptions := range s.options.Providers {
+		lastProviders[providerOptions.Tag] = s.outboundProviders[i]
+		lastProviderOptions[providerOptions.Tag], err = json.Marshal(providerOptions)
+		if err != nil {
+			return err
+		}
+	}
+
+	// reloadedOutbounds has tags of outbounds which are recreated or removed
+	reloadedOutbounds := make(map[string]bool)
+	for _, lastOutbound := range lastOutbounds {
+		reloadedOutbounds[lastOutbound.Tag()] = true
+	}
+	outboundKeys := make([]string, 0, len(options.Outbounds))
+	newOutboundKeys := make(reloadKeys)
+	for i, outboundOptions := range options.Outbounds {
+		tag := optionsTag(outboundOptions.Tag, i)
+		content, err := json.Marshal(&outboundOptions)
+		if err != nil {
+			return err
+		}
+		key := newOutboundKeys.key(outboundOptions.Tag, content)
+		outboundKeys = append(outboundKeys, key)
+		// untagged outbounds are addressed by their index, which must not change
+		if lastOutbound, loaded := lastOutbounds[key]; loaded && lastOutbound.Tag() == tag && bytes.Equal(lastOutboundOptions[key], content) {
+			delete(reloadedOutbounds, tag)
+		} else {
+			reloadedOutbounds[tag] = true
+		}
+	}
+	reloadedProviders := make(map[string]bool)
+	for tag := range lastProviders {
+		reloadedProviders[tag] = true
+	}
+	for _, providerOptions := range options.Providers {
+		content, err := json.Marshal(providerOptions)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(lastProviderOptions[providerOptions.Tag], content) {
+			delete(reloadedProviders, providerOptions.Tag)
+		} else {
+			reloadedProviders[providerOptions.Tag] = true
+		}
+	}
+	for {
+		var changed bool
+		for i, outboundOptions := range options.Outbounds {
+			tag := optionsTag(outboundOptions.Tag, i)
+			if reloadedOutbounds[tag] {
+				continue
+			}
+			if common.Any(lastOutbounds[outboundKeys[i]].Dependencies(), func(it string) bool {
+				return reloadedOutbounds[it]
+			}) || common.Any(outboundProviderTags(outboundOptions), func(it string) bool {
+				return reloadedProviders[it]
+			}) {
+				reloadedOutbounds[tag] = true
+				changed = true
+			}
+		}
+		for _, providerOptions := range options.Providers {
+			if reloadedProviders[providerOptions.Tag] {
+				continue
+			}
+			if providerOptions.Type == C.ProviderTypeRemote && reloadedOutbounds[providerOptions.RemoteOptions.DownloadDetour] {
+				reloadedProviders[providerOptions.Tag] = true
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	var (
+		inbounds          = make([]adapter.Inbound, 0, len(options.Inbounds))
+		outbounds         = make([]adapter.Outbound, 0, len(options.Outbounds))
+		outboundProviders = make([]adapter.OutboundProvider, 0, len(options.Providers))
+		createdInbounds   []adapter.Inbound
+		createdOutbounds  []adapter.Outbound
+		createdProviders  []adapter.OutboundProvider
+		startedOutbounds  = make(map[string]bool)
+	)
+	closeCreated := func() {
+		for _, in := range createdInbounds {
+			common.Close(in)
+		}
+		for _, outboundProvider := range createdProviders {
+			common.Close(outboundProvider)
+		}
+		for _, out := range createdOutbounds {
+			common.Close(out)
+		}
+	}
+	newInboundKeys := make(reloadKeys)
+	for i, inboundOptions := range options.Inbounds {
+		tag := optionsTag(inboundOptions.Tag, i)
+		content, err := json.Marshal(inboundOptions)
+		if err != nil {
+			closeCreated()
+			return err
+		}
+		key := newInboundKeys.key(inboundOptions.Tag, content)
+		if lastInbound, loaded := lastInbounds[key]; loaded && bytes.Equal(lastInboundOptions[key], content) {
+			inbounds = append(inbounds, lastInbound)
+			continue
+		}
+		if keepInbounds {
+			closeCreated()
+			return E.New("inbound[", i, "] can not be added or changed by this reload")
+		}
+		if inboundOptions.Type == C.TypeShadowsocks && inboundOptions.ShadowsocksOptions.Plugin == sip003.ExternalServerPlugin {
+			closeCreated()
+			return E.Cause(route.ErrRestartRequired, "external plugin of inbound[", i, "] changed")
+		}
+		in, err := inbound.New(
+			s.ctx,
+			s.router,
+			s.logFactory.NewLogger(F.ToString("inbound/", inboundOptions.Type, "[", tag, "]")),
+			inboundOptions,
+			s.platformInterface,
+		)
+		if err != nil {
+			closeCreated()
+			return E.Cause(err, "parse inbound[", i, "]")
+		}
+		inbounds = append(inbounds, in)
+		createdInbounds = append(createdInbounds, in)
+	}
+	for i, outboundOptions := range options.Outbounds {
+		tag := optionsTag(outboundOptions.Tag, i)
+		if !reloadedOutbounds[tag] {
+			outbounds = append(outbounds, lastOutbounds[outboundKeys[i]])
+			startedOutbounds[tag] = true
+			continue
+		}
+		out, err := outbound.New(
+			s.ctx,
+			s.router,
+			s.logFactory.NewLogger(F.ToString("outbound/", outboundOptions.Type, "[", tag, "]")),
+			tag,
+			outboundOptions)
+		if err != nil {
+			closeCreated()
+			return E.Cause(err, "parse outbound[", i, "]")
+		}
+		outbounds = append(outbounds, out)
+		createdOutbounds = append(createdOutbounds, out)
+	}
+	for i, providerOptions := range options.Providers {
+		if !reloadedProviders[providerOptions.Tag] {
+			outboundProviders = append(outboundProviders, lastProviders[providerOptions.Tag])
+			continue
+		}
+		if providerOptions.Tag == "" {
+			closeCreated()
+			return E.New("parse provider[", i, "]: missing tag")
+		}
+		outboundProvider, err := provider.New(s.ctx, s.router, s.logFactory, providerOptions)
+		if err != nil {
+			closeCreated()
+			return E.Cause(err, "parse provider[", i, "]")
+		}
+		outboundProviders = append(outboundProviders, outboundProvider)
+		createdProviders = append(createdProviders, outboundProvider)
+	}
+
+	var usedInternalOutbounds []adapter.Outbound
+	defaultOutbound := func() adapter.Outbound {
+		var out adapter.Outbound
+		if len(internalOutbounds) > 0 {
+			out = internalOutbounds[0]
+			startedOutbounds[out.Tag()] = true
+		} else {
+			out = common.Must1(outbound.New(s.ctx, s.router, s.logFactory.NewLogger("outbound/direct"), "direct", option.Outbound{Type: "direct", Tag: "default"}))
+			createdOutbounds = append(createdOutbounds, out)
+		}
+		usedInternalOutbounds = append(usedInternalOutbounds, out)
+		return out
+	}
+	err = s.router.UpdateOutbounds(routeOptions.Final, inbounds, outbounds, outboundProviders, defaultOutbound)
+	if err != nil {
+		closeCreated()
+		return err
+	}
+	outbounds = append(outbounds, usedInternalOutbounds...)
+	err = s.startReloaded(routeOptions, dnsOptions, outbounds, startedOutbounds, createdProviders, reloadedOutbounds)
+	if err != nil {
+		rollbackErr := s.router.UpdateOutbounds(common.PtrValueOrDefault(s.options.Route).Final, s.inbounds, s.outbounds, s.outboundProviders, defaultOutbound)
+		if rollbackErr != nil {
+			s.logger.Error(E.Cause(rollbackErr, "rollback outbounds"))
+		}
+		closeCreated()
+		return err
+	}
+
+	var closedInbounds []adapter.Inbound
+	for _, in := range s.inbounds {
+		if common.Contains(inbounds, in) {
+			continue
+		}
+		closedInbounds = append(closedInbounds, in)
+		err = in.Close()
+		if err != nil {
+			s.logger.Error(E.Cause(err, "close inbound/", in.Type(), "[", in.Tag(), "]"))
+		}
+	}
+	err = s.startInbounds(inbounds, createdInbounds)
+	if err != nil {
+		for _, in := range createdInbounds {
+			common.Close(in)
+		}
+		createdInbounds = nil
+		s.rollbackInbounds(closedInbounds, defaultOutbound, reloadedOutbounds)
+		closeCreated()
+		return err
+	}
+	for _, outboundProvider := range s.outboundProviders {
+		if common.Contains(outboundProviders, outboundProvider) {
+			continue
+		}
+		err = outboundProvider.Close()
+		if err != nil {
+			s.logger.Error(E.Cause(err, "close provider/", outboundProvider.Type(), "[", outboundProvider.Tag(), "]"))
+		}
+	}
+	for _, out := range s.outbounds {
+		if common.Contains(outbounds, out) {
+			continue
+		}
+		err = common.Close(out)
+		if err != nil {
+			s.logger.Error(E.Cause(err, "close outbound/", out.Type(), "[", out.Tag(), "]"))
+		}
+	}
+	for _, out := range createdOutbounds {
+		if lateOutbound, isLateOutbound := out.(adapter.PostStarter); isLateOutbound {
+			err = lateOutbound.PostStart()
+			if err != nil {
+				s.logger.Error(E.Cause(err, "post-start outbound/", out.Tag()))
+			}
+		}
+	}
+	for _, outboundProvider := range createdProviders {
+		err = outboundProvider.PostStart()
+		if err != nil {
+			s.logger.Error(E.Cause(err, "post-start provider/", outboundProvider.Tag()))
+		}
+	}
+	s.inbounds = inbounds
+	s.outbounds = outbounds
+	s.outboundProviders = outboundProviders
+	s.options = options
+	s.logger.Info("reloaded: ", len(createdInbounds), " inbounds, ", len(createdOutbounds), " outbounds and ", len(createdProviders), " providers recreated")
+	return nil
+}
+
+func (s *Box) startInbounds(inbounds []adapter.Inbound, createdInbounds []adapter.Inbound) error {
+	if s.userServer != nil {
+		// users of recreated inbounds are reset from the users file before they start
+		err := s.userServer.UpdateInbounds(inbounds)
+		if err != nil {
+			return E.Cause(err, "update user api")
+		}
+	}
+	for i, in := range inbounds {
+		if !common.Contains(createdInbounds, in) {
+			continue
+		}
+		err := in.Start()
+		if err != nil {
+			return E.Cause(err, "initialize inbound/", in.Type(), "[", optionsTag(in.Tag(), i), "]")
+		}
+	}
+	return nil
+}
+
+// rollbackInbounds restores the last inbounds, outbounds and route after
+// inbounds failed to start on reload. Inbounds closed by the reload are
+// recreated with the last options.
+func (s *Box) rollbackInbounds(closedInbounds []adapter.Inbound, defaultOutbound func() adapter.Outbound, reloadedOutbounds map[string]bool) {
+	inbounds := make([]adapter.Inbound, len(s.inbounds))
+	copy(inbounds, s.inbounds)
+	var restoredInbounds []adapter.Inbound
+	for i, in := range inbounds {
+		if !common.Contains(closedInbounds, in) {
+			continue
+		}
+		inboundOptions := s.options.Inbounds[i]
+		tag := optionsTag(inboundOptions.Tag, i)
+		restoredInbound, err := inbound.New(
+			s.ctx,
+			s.router,
+			s.logFactory.NewLogger(F.ToString("inbound/", inboundOptions.Type, "[", tag, "]")),
+			inboundOptions,
+			s.platformInterface,
+		)
+		if err != nil {
+			s.logger.Error(E.Cause(err, "rollback inbound/", inboundOptions.Type, "[", tag, "]"))
+			continue
+		}
+		inbounds[i] = restoredInbound
+		restoredInbounds = append(restoredInbounds, restoredInbound)
+	}
+	routeOptions := common.PtrValueOrDefault(s.options.Route)
+	err := s.router.UpdateOutbounds(routeOptions.Final, inbounds, s.outbounds, s.outboundProviders, defaultOutbound)
+	if err != nil {
+		s.logger.Error(E.Cause(err, "rollback outbounds"))
+	}
+	err = s.router.Reload(routeOptions, common.PtrValueOrDefault(s.options.DNS), reloadedOutbounds)
+	if err != nil {
+		s.logger.Error(E.Cause(err, "rollback route"))
+	}
+	err = s.startInbounds(inbounds, restoredInbounds)
+	if err != nil {
+		s.logger.Error(E.Cause(err, "rollback inbounds"))
+	}
+	s.inbounds = inbounds
+}
+
+func (s *Box) startReloaded(routeOptions option.RouteOptions, dnsOptions option.DNSOptions, outbounds []adapter.Outbound, startedOutbounds map[string]bool, createdProviders []adapter.OutboundProvider, reloadedOutbounds map[string]bool) error {
+	err := s.startOutboundList(outbounds, startedOutbounds)
+	if err != nil {
+		return err
+	}
+	for _, outboundProvider := range createdProviders {
+		err = outboundProvider.Start()
+		if err != nil {
+			return E.Cause(err, "initialize provider/", outboundProvider.Type(), "[", outboundProvider.Tag(), "]")
+		}
+	}
+	return s.router.Reload(routeOptions, dnsOptions, reloadedOutbounds)
+}
+
+func checkReloadOptions(options option.Options, newOptions option.Options) error {
+	routeOptions := common.PtrValueOrDefault(options.Route)
+	newRouteOptions := common.PtrValueOrDefault(newOptions.Route)
+	routeOptions.Rules, newRouteOptions.Rules = nil, nil
+	routeOptions.RuleSet, newRouteOptions.RuleSet = nil, nil
+	routeOptions.Final, newRouteOptions.Final = "", ""
+	dnsOptions := common.PtrValueOrDefault(options.DNS)
+	newDNSOptions := common.PtrValueOrDefault(newOptions.DNS)
+	dnsOptions.Servers, newDNSOptions.Servers = nil, nil
+	dnsOptions.Rules, newDNSOptions.Rules = nil, nil
+	dnsOptions.Final, newDNSOptions.Final = "", ""
+	dnsOptions.ClientSubnet, newDNSOptions.ClientSubnet = nil, nil
+	for _, item := range []struct {
+		name     string
+		value    any
+		newValue any
+	}{
+		{"log", options.Log, newOptions.Log},
+		{"ntp", options.NTP, newOptions.NTP},
+		{"experimental", options.Experimental, newOptions.Experimental},
+		{"route", routeOptions, newRouteOptions},
+		{"dns", dnsOptions, newDNSOptions},
+	} {
+		content, err := json.Marshal(item.value)
+		if err != nil {
+			return err
+		}
+		newContent, err := json.Marshal(item.newValue)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(content, newContent) {
+			return E.Cause(route.ErrRestartRequired, item.name, " options changed")
+		}
+	}
+	return nil
+}
+
+// reloadKeys matches inbounds and outbounds of the last and the new options on
+// reload. Tagged ones are matched by tag, untagged ones by a hash of their
+// options, so that reordering them does not recreate them.
+type reloadKeys map[string]int
+
+func (k reloadKeys) key(tag string, content []byte) string {
+	if tag != "" {
+		return tag
+	}
+	hash := sha256.Sum256(content)
+	key := hex.EncodeToString(hash[:])
+	index := k[key]
+	k[key]++
+	return F.ToString("#", key, "-", index)
+}
+
+func optionsTag(tag string, index int) string {
+	if tag != "" {
+		return tag
+	}
+	return F.ToString(index)
+}
+
+func outboundProviderTags(options option.Outbound) []string {
+	switch options.Type {
+	case C.TypeSelector:
+		return options.SelectorOptions.Providers
+	case C.TypeURLTest:
+		return options.URLTestOptions.Providers
+	case C.TypeFallback:
+		return options.FallbackOptions.Providers
+	case C.TypeLoadBalance:
+		return options.LoadBalanceOptions.Providers
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,261 @@
+package box
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/route"
+	F "github.com/sagernet/sing/common/format"
+	"github.com/sagernet/sing/common/json"
+	M "github.com/sagernet/sing/common/metadata"
+
+	"github.com/stretchr/testify/require"
+)
+
+func parseReloadOptions(t *testing.T, content string) option.Options {
+	options, err := json.UnmarshalExtended[option.Options]([]byte(content))
+	require.NoError(t, err)
+	return options
+}
+
+const reloadTestOutbounds = `
+  "log": {"disabled": true},
+  "outbounds": [
+    {"type": "direct", "tag": "a"},
+    {"type": "direct", "tag": "b"}
+  ],`
+
+func traceRoute(t *testing.T, instance *Box, domain string) *adapter.RouteTrace {
+	trace, err := instance.Router().TraceRoute(context.Background(), adapter.InboundContext{
+		Destination: M.Socksaddr{Fqdn: domain, Port: 443},
+	})
+	require.NoError(t, err)
+	return trace
+}
+
+func TestReloadRoute(t *testing.T) {
+	instance, err := New(Options{Options: parseReloadOptions(t, `{`+reloadTestOutbounds+`
+  "dns": {
+    "servers": [{"tag": "dns-a", "address": "local"}]
+  },
+  "route": {
+    "rule_set": [{"type": "inline", "tag": "set-a", "rules": [{"domain": "example.com"}]}],
+    "rules": [{"rule_set": "set-a", "outbound": "a"}],
+    "final": "b"
+  }
+}`)})
+	require.NoError(t, err)
+	require.NoError(t, instance.Start())
+	defer instance.Close()
+	require.Equal(t, "a", traceRoute(t, instance, "example.com").Outbound)
+
+	err = instance.Reload(parseReloadOptions(t, `{`+reloadTestOutbounds+`
+  "dns": {
+    "servers": [{"tag": "dns-a", "address": "local"}, {"tag": "dns-b", "address": "local"}],
+    "rules": [{"domain": "example.org", "server": "dns-b"}]
+  },
+  "route": {
+    "rule_set": [{"type": "inline", "tag": "set-b", "rules": [{"domain": "example.org"}]}],
+    "rules": [{"rule_set": "set-b", "outbound": "a"}],
+    "final": "b"
+  }
+}`))
+	require.NoError(t, err)
+	_, loaded := instance.Router().RuleSet("set-a")
+	require.False(t, loaded)
+	require.Equal(t, "b", traceRoute(t, instance, "example.com").Outbound)
+	trace := traceRoute(t, instance, "example.org")
+	require.Equal(t, "a", trace.Outbound)
+	require.Equal(t, "dns-b", trace.DNS.Server)
+}
+
+func TestReloadRollback(t *testing.T) {
+	instance, err := New(Options{Options: parseReloadOptions(t, `{`+reloadTestOutbounds+`
+  "route": {
+    "rule_set": [{"type": "inline", "tag": "set-a", "rules": [{"domain": "example.com"}]}],
+    "rules": [{"rule_set": "set-a", "outbound": "a"}],
+    "final": "b"
+  }
+}`)})
+	require.NoError(t, err)
+	require.NoError(t, instance.Start())
+	defer instance.Close()
+
+	for _, content := range []string{`{` + reloadTestOutbounds + `
+  "route": {
+    "rules": [{"domain": "example.com", "outbound": "missing"}],
+    "final": "b"
+  }
+}`, `{` + reloadTestOutbounds + `
+  "route": {
+    "rule_set": [{"type": "local", "tag": "set-b", "format": "source", "path": "/nonexistent/rule-set.json"}],
+    "rules": [{"rule_set": "set-b", "outbound": "b"}],
+    "final": "a"
+  }
+}`, `{` + reloadTestOutbounds + `
+  "route": {
+    "rule_set": [{"type": "inline", "tag": "set-b", "rules": [{"domain": "example.com"}]}],
+    "rules": [{"rule_set": "set-b", "action": "resolve", "server": "missing"}],
+    "final": "b"
+  }
+}`} {
+		require.Error(t, instance.Reload(parseReloadOptions(t, content)))
+		_, loaded := instance.Router().RuleSet("set-a")
+		require.True(t, loaded)
+		_, loaded = instance.Router().RuleSet("set-b")
+		require.False(t, loaded)
+		require.Equal(t, "a", traceRoute(t, instance, "example.com").Outbound)
+		require.Equal(t, "b", traceRoute(t, instance, "example.org").Outbound)
+	}
+}
+
+func TestReloadProviderOutboundRule(t *testing.T) {
+	providerPath := filepath.Join(t.TempDir(), "provider.json")
+	require.NoError(t, os.WriteFile(providerPath, []byte(`{"outbounds":[{"type":"shadowsocks","tag":"ss","server":"127.0.0.1","server_port":8388,"method":"aes-128-gcm","password":"password"}]}`), 0o644))
+	providers := `
+  "providers": [{"type": "local", "tag": "p", "path": "` + providerPath + `"}],`
+	instance, err := New(Options{Options: parseReloadOptions(t, `{`+reloadTestOutbounds+providers+`
+  "route": {"final": "b"}
+}`)})
+	require.NoError(t, err)
+	require.NoError(t, instance.Start())
+	defer instance.Close()
+	outboundProvider, loaded := instance.Router().OutboundProvider("p")
+	require.True(t, loaded)
+	require.Len(t, outboundProvider.Outbounds(), 1)
+	providerOutbound := outboundProvider.Outbounds()[0].Tag()
+
+	// rules can not use outbounds of providers on start, nor on reload
+	err = instance.Reload(parseReloadOptions(t, `{`+reloadTestOutbounds+providers+`
+  "route": {
+    "rules": [{"domain": "example.com", "outbound": "`+providerOutbound+`"}],
+    "final": "b"
+  }
+}`))
+	require.ErrorContains(t, err, "outbound not found for rule[0]")
+}
+
+func TestResolveServerNotFound(t *testing.T) {
+	_, err := New(Options{Options: parseReloadOptions(t, `{`+reloadTestOutbounds+`
+  "route": {
+    "rules": [{"domain": "example.com", "action": "resolve", "server": "missing"}],
+    "final": "b"
+  }
+}`)})
+	require.ErrorContains(t, err, "DNS server not found for rule[0]: missing")
+}
+
+func TestReloadExternalPlugin(t *testing.T) {
+	shadowsocksInbound := func(plugin string) string {
+		return `
+  "inbounds": [{"type": "shadowsocks", "listen": "127.0.0.1", "method": "aes-128-gcm", "password": "password", ` + plugin + `}],`
+	}
+	// external plugins are enabled by the plugin name only
+	_, err := New(Options{Options: parseReloadOptions(t, `{`+reloadTestOutbounds+shadowsocksInbound(`"plugin": "/bin/true"`)+`
+  "route": {"final": "b"}
+}`)})
+	require.ErrorContains(t, err, "plugin not found: /bin/true")
+
+	instance, err := New(Options{Options: parseReloadOptions(t, `{`+reloadTestOutbounds+`
+  "route": {"final": "b"}
+}`)})
+	require.NoError(t, err)
+	require.NoError(t, instance.Start())
+	defer instance.Close()
+	err = instance.Reload(parseReloadOptions(t, `{`+reloadTestOutbounds+shadowsocksInbound(`"plugin": "external", "plugin_path": "/bin/true"`)+`
+  "route": {"final": "b"}
+}`))
+	require.ErrorIs(t, err, route.ErrRestartRequired)
+	require.Empty(t, instance.inbounds)
+}
+
+func TestReloadKeepInbounds(t *testing.T) {
+	inbounds := `
+  "inbounds": [{"type": "mixed", "tag": "in", "listen": "127.0.0.1"}],`
+	instance, err := New(Options{Options: parseReloadOptions(t, `{`+reloadTestOutbounds+inbounds+`
+  "route": {"final": "b"}
+}`)})
+	require.NoError(t, err)
+	require.NoError(t, instance.Start())
+	defer instance.Close()
+	lastInbound := instance.inbounds[0]
+	require.NoError(t, instance.ReloadKeepInbounds(parseReloadOptions(t, `{`+reloadTestOutbounds+inbounds+`
+  "route": {"final": "a"}
+}`)))
+	require.Equal(t, "a", traceRoute(t, instance, "example.com").Outbound)
+	require.Equal(t, lastInbound, instance.inbounds[0])
+
+	err = instance.ReloadKeepInbounds(parseReloadOptions(t, `{`+reloadTestOutbounds+`
+  "inbounds": [{"type": "mixed", "tag": "in", "listen": "127.0.0.1"}, {"type": "mixed", "tag": "in2", "listen": "127.0.0.1"}],
+  "route": {"final": "b"}
+}`))
+	require.ErrorContains(t, err, "inbound[1] can not be added or changed")
+	require.Equal(t, []adapter.Inbound{lastInbound}, instance.inbounds)
+	require.Equal(t, "a", traceRoute(t, instance, "example.com").Outbound)
+}
+
+func TestReloadUntagged(t *testing.T) {
+	instance, err := New(Options{Options: parseReloadOptions(t, `{`+reloadTestOutbounds+`
+  "inbounds": [
+    {"type": "mixed", "listen": "127.0.0.1"},
+    {"type": "mixed", "listen": "127.0.0.1", "sniff": true}
+  ],
+  "route": {"final": "b"}
+}`)})
+	require.NoError(t, err)
+	require.NoError(t, instance.Start())
+	defer instance.Close()
+	lastInbounds := instance.inbounds
+
+	// untagged inbounds are kept when reordered
+	require.NoError(t, instance.Reload(parseReloadOptions(t, `{`+reloadTestOutbounds+`
+  "inbounds": [
+    {"type": "mixed", "listen": "127.0.0.1", "sniff": true},
+    {"type": "mixed", "listen": "127.0.0.1"}
+  ],
+  "route": {"final": "b"}
+}`)))
+	require.Equal(t, []adapter.Inbound{lastInbounds[1], lastInbounds[0]}, instance.inbounds)
+}
+
+func TestReloadInboundRollback(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	freeListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := M.SocksaddrFromNet(freeListener.Addr()).Port
+	freeListener.Close()
+	inbound := func(options string) string {
+		return `{"type": "mixed", "tag": "in", "listen": "127.0.0.1", "listen_port": ` + F.ToString(port) + options + `}`
+	}
+	instance, err := New(Options{Options: parseReloadOptions(t, `{`+reloadTestOutbounds+`
+  "inbounds": [`+inbound("")+`],
+  "route": {"final": "b"}
+}`)})
+	require.NoError(t, err)
+	require.NoError(t, instance.Start())
+	defer instance.Close()
+
+	// the changed inbound starts, but the added one fails to listen
+	err = instance.Reload(parseReloadOptions(t, `{`+reloadTestOutbounds+`
+  "inbounds": [
+    `+inbound(`, "sniff": true`)+`,
+    {"type": "mixed", "tag": "in2", "listen": "127.0.0.1", "listen_port": `+F.ToString(M.SocksaddrFromNet(listener.Addr()).Port)+`}
+  ],
+  "route": {"final": "a"}
+}`))
+	require.ErrorContains(t, err, "initialize inbound/mixed[in2]")
+	require.Len(t, instance.inbounds, 1)
+	require.Equal(t, "in", instance.inbounds[0].Tag())
+	require.Equal(t, "b", traceRoute(t, instance, "example.com").Outbound)
+	conn, err := net.Dial("tcp", F.ToString("127.0.0.1:", port))
+	require.NoError(t, err)
+	conn.Close()
+	require.Len(t, instance.options.Inbounds, 1)
+}
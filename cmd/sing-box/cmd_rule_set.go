@@ -1,6 +1,15 @@
 package main
 
 import (
+	"bytes"
+	"io"
+	"os"
+
+	"github.com/sagernet/sing-box/common/srs"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/json"
+
 	"github.com/spf13/cobra"
 )
 
@@ -12,3 +21,35 @@ var commandRuleSet = &cobra.Command{
 func init() {
 	mainCommand.AddCommand(commandRuleSet)
 }
+
+// readRuleSet reads a source or binary rule-set, detected by the magic bytes
+// of the binary format.
+func readRuleSet(sourcePath string, recovery bool) (option.PlainRuleSet, error) {
+	var (
+		content []byte
+		err     error
+	)
+	if sourcePath == "stdin" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(sourcePath)
+	}
+	if err != nil {
+		return option.PlainRuleSet{}, err
+	}
+	if bytes.HasPrefix(content, srs.MagicBytes[:]) {
+		return srs.Read(bytes.NewReader(content), recovery)
+	}
+	plainRuleSet, err := json.UnmarshalExtended[option.PlainRuleSetCompat](content)
+	if err != nil {
+		return option.PlainRuleSet{}, err
+	}
+	return plainRuleSet.Upgrade(), nil
+}
+
+func ruleSetVersion(ruleSet option.PlainRuleSet) int {
+	if ruleSet.RequiresVersion2() {
+		return C.RuleSetVersion2
+	}
+	return C.RuleSetVersion1
+}
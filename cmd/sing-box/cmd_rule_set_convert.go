@@ -0,0 +1,90 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sagernet/sing-box/common/ruleconv"
+	"github.com/sagernet/sing-box/common/srs"
+	"github.com/sagernet/sing-box/log"
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagRuleSetConvertType   string
+	flagRuleSetConvertOutput string
+)
+
+const flagRuleSetConvertDefaultOutput = "<file_name>.json"
+
+var commandRuleSetConvert = &cobra.Command{
+	Use:   "convert [source-path]",
+	Short: "Convert rule list of other formats to rule-set",
+	Long: "Convert rule list of other formats to rule-set.\n\n" +
+		"Supported types: clash-domain, clash-ipcidr, clash-classical, adguard, hosts, dnsmasq.\n" +
+		"The output is written as binary if the output path ends with .srs.",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := convertRuleSet(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	commandRuleSet.AddCommand(commandRuleSetConvert)
+	commandRuleSetConvert.Flags().StringVarP(&flagRuleSetConvertType, "type", "t", "", "Source type")
+	commandRuleSetConvert.Flags().StringVarP(&flagRuleSetConvertOutput, "output", "o", flagRuleSetConvertDefaultOutput, "Output file")
+}
+
+func convertRuleSet(sourcePath string) error {
+	if !ruleconv.IsSupported(flagRuleSetConvertType) {
+		return E.New("unknown source type: ", flagRuleSetConvertType)
+	}
+	var (
+		content []byte
+		err     error
+	)
+	if sourcePath == "stdin" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(sourcePath)
+	}
+	if err != nil {
+		return err
+	}
+	plainRuleSet, unsupported, err := ruleconv.Convert(flagRuleSetConvertType, content)
+	if err != nil {
+		return err
+	}
+	for _, entry := range unsupported {
+		log.Warn("skip ", entry)
+	}
+	var outputPath string
+	if flagRuleSetConvertOutput != flagRuleSetConvertDefaultOutput {
+		outputPath = flagRuleSetConvertOutput
+	} else if sourcePath == "stdin" {
+		outputPath = "stdout"
+	} else {
+		outputPath = strings.TrimSuffix(sourcePath, ".txt")
+		outputPath = strings.TrimSuffix(strings.TrimSuffix(outputPath, ".yaml"), ".yml") + ".json"
+	}
+	if !strings.HasSuffix(outputPath, ".srs") {
+		return writeRuleSetSource(outputPath, plainRuleSet)
+	}
+	outputFile, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	err = srs.Write(outputFile, plainRuleSet)
+	if err != nil {
+		outputFile.Close()
+		os.Remove(outputPath)
+		return err
+	}
+	return outputFile.Close()
+}
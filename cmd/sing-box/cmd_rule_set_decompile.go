@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/json"
+
+	"github.com/spf13/cobra"
+)
+
+var flagRuleSetDecompileOutput string
+
+const flagRuleSetDecompileDefaultOutput = "<file_name>.json"
+
+var commandRuleSetDecompile = &cobra.Command{
+	Use:   "decompile [binary-path]",
+	Short: "Decompile rule-set binary to json",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := decompileRuleSet(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	commandRuleSet.AddCommand(commandRuleSetDecompile)
+	commandRuleSetDecompile.Flags().StringVarP(&flagRuleSetDecompileOutput, "output", "o", flagRuleSetDecompileDefaultOutput, "Output file")
+}
+
+func decompileRuleSet(sourcePath string) error {
+	plainRuleSet, err := readRuleSet(sourcePath, true)
+	if err != nil {
+		return err
+	}
+	var outputPath string
+	if flagRuleSetDecompileOutput == flagRuleSetDecompileDefaultOutput {
+		if strings.HasSuffix(sourcePath, ".srs") {
+			outputPath = sourcePath[:len(sourcePath)-4] + ".json"
+		} else {
+			outputPath = sourcePath + ".json"
+		}
+	} else {
+		outputPath = flagRuleSetDecompileOutput
+	}
+	return writeRuleSetSource(outputPath, plainRuleSet)
+}
+
+func writeRuleSetSource(outputPath string, plainRuleSet option.PlainRuleSet) error {
+	var outputFile *os.File
+	if outputPath == "stdout" {
+		outputFile = os.Stdout
+	} else {
+		var err error
+		outputFile, err = os.Create(outputPath)
+		if err != nil {
+			return err
+		}
+		defer outputFile.Close()
+	}
+	encoder := json.NewEncoder(outputFile)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(option.PlainRuleSetCompat{
+		Version: ruleSetVersion(plainRuleSet),
+		Options: plainRuleSet,
+	})
+}
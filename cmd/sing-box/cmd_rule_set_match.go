@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/route"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	M "github.com/sagernet/sing/common/metadata"
+
+	"github.com/spf13/cobra"
+)
+
+var commandRuleSetMatch = &cobra.Command{
+	Use:   "match <rule-set-path> <domain|ip>",
+	Short: "Print rules of a rule-set matching a domain or an IP address",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := matchRuleSet(args[0], args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	commandRuleSet.AddCommand(commandRuleSetMatch)
+}
+
+func matchRuleSet(sourcePath string, destination string) error {
+	plainRuleSet, err := readRuleSet(sourcePath, true)
+	if err != nil {
+		return err
+	}
+	var metadata adapter.InboundContext
+	if address := M.ParseAddr(destination); address.IsValid() {
+		metadata.Destination = M.SocksaddrFrom(address, 0)
+	} else {
+		metadata.Domain = destination
+		metadata.Destination = M.Socksaddr{Fqdn: destination}
+	}
+	for i, ruleOptions := range plainRuleSet.Rules {
+		if requiresRouter(ruleOptions) {
+			log.Warn("rules[", i, "]: skipped, clash_mode, wifi_ssid and wifi_bssid can not be matched offline")
+			continue
+		}
+		rule, err := route.NewHeadlessRule(nil, ruleOptions)
+		if err != nil {
+			return E.Cause(err, "parse rules[", i, "]")
+		}
+		metadata.ResetRuleCache()
+		if rule.Match(&metadata) {
+			os.Stdout.WriteString(F.ToString("match rules[", i, "]: ", rule, "\n"))
+		}
+	}
+	return nil
+}
+
+func requiresRouter(rule option.HeadlessRule) bool {
+	switch rule.Type {
+	case C.RuleTypeLogical:
+		return common.Any(rule.LogicalOptions.Rules, requiresRouter)
+	default:
+		return rule.DefaultOptions.ClashMode != "" || len(rule.DefaultOptions.WIFISSID) > 0 || len(rule.DefaultOptions.WIFIBSSID) > 0
+	}
+}
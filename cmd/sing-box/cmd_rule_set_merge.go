@@ -0,0 +1,203 @@
+package main
+
+import (
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sagernet/sing-box/common/srs"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+
+	"github.com/spf13/cobra"
+	"go4.org/netipx"
+)
+
+var commandRuleSetMerge = &cobra.Command{
+	Use:   "merge <output-path> <source-path>...",
+	Short: "Merge source or binary rule-sets",
+	Long: "Merge source or binary rule-sets.\n\n" +
+		"Duplicate rules are removed, and rules which only contain domain, domain_suffix, domain_keyword, domain_regex and ip_cidr are merged into one rule. " +
+		"The output is written as binary if the output path ends with .srs.",
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := mergeRuleSet(args[0], args[1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	commandRuleSet.AddCommand(commandRuleSetMerge)
+}
+
+func mergeRuleSet(outputPath string, sourcePaths []string) error {
+	var ruleSets []option.PlainRuleSet
+	for _, sourcePath := range sourcePaths {
+		plainRuleSet, err := readRuleSet(sourcePath, true)
+		if err != nil {
+			return E.Cause(err, "read ", sourcePath)
+		}
+		ruleSets = append(ruleSets, plainRuleSet)
+	}
+	plainRuleSet, err := mergeRuleSets(ruleSets)
+	if err != nil {
+		return err
+	}
+	if !strings.HasSuffix(outputPath, ".srs") {
+		return writeRuleSetSource(outputPath, plainRuleSet)
+	}
+	outputFile, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	err = srs.Write(outputFile, plainRuleSet)
+	if err != nil {
+		outputFile.Close()
+		os.Remove(outputPath)
+		return err
+	}
+	return outputFile.Close()
+}
+
+func mergeRuleSets(ruleSets []option.PlainRuleSet) (option.PlainRuleSet, error) {
+	var (
+		result       option.PlainRuleSet
+		addressRule  option.DefaultHeadlessRule
+		addressIndex = -1
+		ruleKeys     = make(map[string]bool)
+	)
+	for _, ruleSet := range ruleSets {
+		for _, rule := range ruleSet.Rules {
+			if isDestinationAddressRule(rule) {
+				if addressIndex == -1 {
+					addressIndex = len(result.Rules)
+					result.Rules = append(result.Rules, option.HeadlessRule{})
+				}
+				addressRule.Domain = append(addressRule.Domain, rule.DefaultOptions.Domain...)
+				addressRule.DomainSuffix = append(addressRule.DomainSuffix, rule.DefaultOptions.DomainSuffix...)
+				addressRule.DomainKeyword = append(addressRule.DomainKeyword, rule.DefaultOptions.DomainKeyword...)
+				addressRule.DomainRegex = append(addressRule.DomainRegex, rule.DefaultOptions.DomainRegex...)
+				addressRule.IPCIDR = append(addressRule.IPCIDR, rule.DefaultOptions.IPCIDR...)
+				continue
+			}
+			content, err := json.Marshal(rule)
+			if err != nil {
+				return option.PlainRuleSet{}, err
+			}
+			if ruleKeys[string(content)] {
+				continue
+			}
+			ruleKeys[string(content)] = true
+			result.Rules = append(result.Rules, rule)
+		}
+	}
+	if addressIndex != -1 {
+		addressRule.Domain, addressRule.DomainSuffix = mergeDomains(addressRule.Domain, addressRule.DomainSuffix)
+		addressRule.DomainKeyword = sortedUniq(addressRule.DomainKeyword)
+		addressRule.DomainRegex = sortedUniq(addressRule.DomainRegex)
+		ipCIDR, err := mergeIPCIDR(addressRule.IPCIDR)
+		if err != nil {
+			return option.PlainRuleSet{}, err
+		}
+		addressRule.IPCIDR = ipCIDR
+		result.Rules[addressIndex] = option.HeadlessRule{
+			Type:           C.RuleTypeDefault,
+			DefaultOptions: addressRule,
+		}
+	}
+	return result, nil
+}
+
+// isDestinationAddressRule reports whether the rule only contains destination
+// address items, which match if any of them matches, so that such rules can be
+// merged into one.
+func isDestinationAddressRule(rule option.HeadlessRule) bool {
+	if rule.Type != C.RuleTypeDefault || rule.DefaultOptions.Invert {
+		return false
+	}
+	options := rule.DefaultOptions
+	options.Domain = nil
+	options.DomainSuffix = nil
+	options.DomainKeyword = nil
+	options.DomainRegex = nil
+	options.IPCIDR = nil
+	options.DomainMatcher = nil
+	options.IPSet = nil
+	return !options.IsValid()
+}
+
+// mergeDomains removes duplicate items and items covered by a domain_suffix.
+// A domain_suffix with leading dot only matches subdomains.
+func mergeDomains(domains []string, domainSuffix []string) ([]string, []string) {
+	suffixes := make(map[string]bool)
+	for _, suffix := range domainSuffix {
+		suffixes[suffix] = true
+	}
+	coveredByParent := func(name string) bool {
+		for index := strings.IndexByte(name, '.'); index != -1; index = strings.IndexByte(name, '.') {
+			name = name[index+1:]
+			if suffixes[name] || suffixes["."+name] {
+				return true
+			}
+		}
+		return false
+	}
+	var mergedSuffix []string
+	for suffix := range suffixes {
+		if strings.HasPrefix(suffix, ".") {
+			if suffixes[suffix[1:]] || coveredByParent(suffix[1:]) {
+				continue
+			}
+		} else if coveredByParent(suffix) {
+			continue
+		}
+		mergedSuffix = append(mergedSuffix, suffix)
+	}
+	var mergedDomains []string
+	for _, domain := range sortedUniq(domains) {
+		if suffixes[domain] || coveredByParent(domain) {
+			continue
+		}
+		mergedDomains = append(mergedDomains, domain)
+	}
+	sort.Strings(mergedSuffix)
+	return mergedDomains, mergedSuffix
+}
+
+func mergeIPCIDR(prefixStrings []string) ([]string, error) {
+	if len(prefixStrings) == 0 {
+		return nil, nil
+	}
+	var builder netipx.IPSetBuilder
+	for _, prefixString := range prefixStrings {
+		prefix, err := netip.ParsePrefix(prefixString)
+		if err == nil {
+			builder.AddPrefix(prefix)
+			continue
+		}
+		addr, addrErr := netip.ParseAddr(prefixString)
+		if addrErr == nil {
+			builder.Add(addr)
+			continue
+		}
+		return nil, E.Cause(err, "parse ip_cidr ", prefixString)
+	}
+	ipSet, err := builder.IPSet()
+	if err != nil {
+		return nil, err
+	}
+	return common.Map(ipSet.Prefixes(), netip.Prefix.String), nil
+}
+
+func sortedUniq(values []string) []string {
+	values = common.Uniq(values)
+	sort.Strings(values)
+	return values
+}
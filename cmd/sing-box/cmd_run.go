@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"io"
 	"os"
 	"os/signal"
@@ -16,6 +17,8 @@ import (
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/route"
+	"github.com/sagernet/sing/common"
 	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/json"
 	"github.com/sagernet/sing/common/json/badjson"
@@ -122,10 +125,10 @@ func readConfigAndMerge() (option.Options, error) {
 	return mergedOptions, nil
 }
 
-func create() (*box.Box, context.CancelFunc, error) {
+func readOptions() (option.Options, error) {
 	options, err := readConfigAndMerge()
 	if err != nil {
-		return nil, nil, err
+		return option.Options{}, err
 	}
 	if disableColor {
 		if options.Log == nil {
@@ -133,6 +136,14 @@ func create() (*box.Box, context.CancelFunc, error) {
 		}
 		options.Log.DisableColor = true
 	}
+	return options, nil
+}
+
+func create() (*box.Box, context.CancelFunc, error) {
+	options, err := readOptions()
+	if err != nil {
+		return nil, nil, err
+	}
 	ctx, cancel := context.WithCancel(globalCtx)
 	instance, err := box.New(box.Options{
 		Context: ctx,
@@ -168,7 +179,7 @@ func create() (*box.Box, context.CancelFunc, error) {
 
 func run() error {
 	osSignals := make(chan os.Signal, 1)
-	signal.Notify(osSignals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	signal.Notify(osSignals, append([]os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP}, reopenLogSignals...)...)
 	defer signal.Stop(osSignals)
 	for {
 		instance, cancel, err := create()
@@ -178,12 +189,29 @@ func run() error {
 		runtimeDebug.FreeOSMemory()
 		for {
 			osSignal := <-osSignals
+			if common.Contains(reopenLogSignals, osSignal) {
+				err = instance.ReopenLog()
+				if err != nil {
+					log.Error(E.Cause(err, "reopen log"))
+				}
+				continue
+			}
 			if osSignal == syscall.SIGHUP {
 				err = check()
 				if err != nil {
 					log.Error(E.Cause(err, "reload service"))
 					continue
 				}
+				err = reload(instance)
+				if err == nil {
+					runtimeDebug.FreeOSMemory()
+					continue
+				}
+				if !errors.Is(err, route.ErrRestartRequired) {
+					log.Error(E.Cause(err, "reload service"))
+					continue
+				}
+				log.Info("restarting service: ", err)
 			}
 			cancel()
 			closeCtx, closed := context.WithCancel(context.Background())
@@ -198,6 +226,14 @@ func run() error {
 	}
 }
 
+func reload(instance *box.Box) error {
+	options, err := readOptions()
+	if err != nil {
+		return err
+	}
+	return instance.Reload(options)
+}
+
 func closeMonitor(ctx context.Context) {
 	time.Sleep(C.DefaultStopFatalTimeout)
 	select {
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/process"
+	"github.com/sagernet/sing-box/log"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	M "github.com/sagernet/sing/common/metadata"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	commandRouteTestFlagInbound     string
+	commandRouteTestFlagNetwork     string
+	commandRouteTestFlagSource      string
+	commandRouteTestFlagDomain      string
+	commandRouteTestFlagProtocol    string
+	commandRouteTestFlagProcess     string
+	commandRouteTestFlagProcessUser string
+	commandRouteTestFlagUser        string
+	commandRouteTestFlagWIFISSID    string
+	commandRouteTestFlagWIFIBSSID   string
+	commandRouteTestFlagJSON        bool
+)
+
+var commandRouteTest = &cobra.Command{
+	Use:   "route-test <destination>",
+	Short: "Explain the route of a connection without connecting",
+	Long: "Explain the route of a connection without connecting.\n\n" +
+		"The destination is a domain or an IP address with the port, such as example.com:443.\n" +
+		"Inbounds are not started, processes are not searched, and sniff and resolve actions are skipped.",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := routeTest(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	commandRouteTest.Flags().StringVarP(&commandRouteTestFlagInbound, "inbound", "i", "", "Inbound tag")
+	commandRouteTest.Flags().StringVarP(&commandRouteTestFlagNetwork, "network", "n", "tcp", "Network type")
+	commandRouteTest.Flags().StringVarP(&commandRouteTestFlagSource, "source", "s", "", "Source address")
+	commandRouteTest.Flags().StringVar(&commandRouteTestFlagDomain, "domain", "", "Sniffed domain")
+	commandRouteTest.Flags().StringVar(&commandRouteTestFlagProtocol, "protocol", "", "Sniffed protocol")
+	commandRouteTest.Flags().StringVar(&commandRouteTestFlagProcess, "process", "", "Process name or path")
+	commandRouteTest.Flags().StringVar(&commandRouteTestFlagProcessUser, "process-user", "", "User of the process")
+	commandRouteTest.Flags().StringVarP(&commandRouteTestFlagUser, "user", "u", "", "Authenticated user")
+	commandRouteTest.Flags().StringVar(&commandRouteTestFlagWIFISSID, "wifi-ssid", "", "WIFI SSID")
+	commandRouteTest.Flags().StringVar(&commandRouteTestFlagWIFIBSSID, "wifi-bssid", "", "WIFI BSSID")
+	commandRouteTest.Flags().BoolVar(&commandRouteTestFlagJSON, "json", false, "Print in JSON")
+	commandTools.AddCommand(commandRouteTest)
+}
+
+func routeTest(destination string) error {
+	metadata := adapter.InboundContext{
+		Inbound:     commandRouteTestFlagInbound,
+		Network:     commandRouteTestFlagNetwork,
+		Destination: M.ParseSocksaddr(destination),
+		Domain:      commandRouteTestFlagDomain,
+		Protocol:    commandRouteTestFlagProtocol,
+		User:        commandRouteTestFlagUser,
+	}
+	if !metadata.Destination.IsValid() {
+		return E.New("invalid destination: ", destination)
+	}
+	if commandRouteTestFlagSource != "" {
+		metadata.Source = M.ParseSocksaddr(commandRouteTestFlagSource)
+		if !metadata.Source.IsIP() {
+			return E.New("invalid source: ", commandRouteTestFlagSource)
+		}
+	}
+	if commandRouteTestFlagProcess != "" || commandRouteTestFlagProcessUser != "" {
+		metadata.ProcessInfo = &process.Info{
+			ProcessPath: commandRouteTestFlagProcess,
+			User:        commandRouteTestFlagProcessUser,
+			UserId:      -1,
+		}
+	}
+	if commandRouteTestFlagWIFISSID != "" || commandRouteTestFlagWIFIBSSID != "" {
+		metadata.WIFIState = &adapter.WIFIState{
+			SSID:  commandRouteTestFlagWIFISSID,
+			BSSID: commandRouteTestFlagWIFIBSSID,
+		}
+	}
+	instance, err := createPreStartedClient()
+	if err != nil {
+		return err
+	}
+	defer instance.Close()
+	trace, err := instance.Router().TraceRoute(context.Background(), metadata)
+	if err != nil {
+		return err
+	}
+	if commandRouteTestFlagJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(trace)
+	}
+	var output strings.Builder
+	for _, rule := range trace.Rules {
+		if rule.Matched {
+			output.WriteString("match")
+		} else {
+			output.WriteString("skip")
+		}
+		output.WriteString(F.ToString("[", rule.Index, "] ", rule.Rule, " => ", rule.Action, "\n"))
+		if len(rule.Items) > 0 {
+			output.WriteString("  matched items: " + strings.Join(rule.Items, " ") + "\n")
+		}
+		if rule.Note != "" {
+			output.WriteString("  note: " + rule.Note + "\n")
+		}
+	}
+	if trace.DNS != nil {
+		output.WriteString("dns: " + trace.DNS.Domain + " => ")
+		if trace.DNS.Rule != "" {
+			output.WriteString(F.ToString("match[", trace.DNS.RuleIndex, "] ", trace.DNS.Rule, " => "))
+		}
+		output.WriteString(trace.DNS.Server)
+		if trace.DNS.FakeIP {
+			output.WriteString(" (fakeip)")
+		}
+		output.WriteString("\n")
+	}
+	output.WriteString("action: " + trace.Action + "\n")
+	if trace.Outbound != "" {
+		output.WriteString("outbound: " + trace.Outbound + "\n")
+	}
+	_, err = os.Stdout.WriteString(output.String())
+	return err
+}
@@ -0,0 +1,50 @@
+package dialer
+
+import (
+	"context"
+	"net"
+
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+type detourOverrideKey struct{}
+
+// ContextWithDetourOverride returns a context in which the next outbound dialer
+// created by New dials through detour instead of its own detour. The override
+// is not passed to detour itself.
+func ContextWithDetourOverride(ctx context.Context, detour N.Dialer) context.Context {
+	return context.WithValue(ctx, detourOverrideKey{}, detour)
+}
+
+func detourOverrideFromContext(ctx context.Context) (context.Context, N.Dialer) {
+	detour, _ := ctx.Value(detourOverrideKey{}).(N.Dialer)
+	if detour == nil {
+		return ctx, nil
+	}
+	return context.WithValue(ctx, detourOverrideKey{}, nil), detour
+}
+
+type overridableDialer struct {
+	N.Dialer
+}
+
+func (d *overridableDialer) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	ctx, detour := detourOverrideFromContext(ctx)
+	if detour != nil {
+		return detour.DialContext(ctx, network, destination)
+	}
+	return d.Dialer.DialContext(ctx, network, destination)
+}
+
+func (d *overridableDialer) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	ctx, detour := detourOverrideFromContext(ctx)
+	if detour != nil {
+		return detour.ListenPacket(ctx, destination)
+	}
+	return d.Dialer.ListenPacket(ctx, destination)
+}
+
+func (d *overridableDialer) Upstream() any {
+	return d.Dialer
+}
@@ -34,5 +34,5 @@ func New(router adapter.Router, options option.DialerOptions) (N.Dialer, error)
 			domainStrategy,
 			time.Duration(options.FallbackDelay))
 	}
-	return dialer, nil
+	return &overridableDialer{dialer}, nil
 }
@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Bucket is a token bucket measured in bytes. Taking more tokens than
+// available puts the bucket in debt, which later callers wait for as well.
+type Bucket struct {
+	access sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func NewBucket(bytesPerSecond int64, burst int64) *Bucket {
+	if burst <= 0 {
+		burst = bytesPerSecond
+	}
+	return &Bucket{
+		rate:   float64(bytesPerSecond),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Take removes n tokens and returns how long the caller should wait before
+// using them.
+func (b *Bucket) Take(n int) time.Duration {
+	b.access.Lock()
+	defer b.access.Unlock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+func take(buckets []*Bucket, n int) time.Duration {
+	var delay time.Duration
+	for _, bucket := range buckets {
+		if bucketDelay := bucket.Take(n); bucketDelay > delay {
+			delay = bucketDelay
+		}
+	}
+	return delay
+}
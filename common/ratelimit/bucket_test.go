@@ -0,0 +1,40 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketTake(t *testing.T) {
+	t.Parallel()
+	bucket := NewBucket(1000, 0)
+	require.Zero(t, bucket.Take(1000))
+	// taking more than available puts the bucket in debt
+	delay := bucket.Take(500)
+	require.InDelta(t, 500*time.Millisecond, delay, float64(50*time.Millisecond))
+	delay = bucket.Take(500)
+	require.InDelta(t, time.Second, delay, float64(50*time.Millisecond))
+
+	bucket = NewBucket(1000, 0)
+	bucket.Take(1000)
+	bucket.last = bucket.last.Add(-10 * time.Second)
+	// tokens are refilled up to the burst
+	require.Zero(t, bucket.Take(1000))
+	require.NotZero(t, bucket.Take(100))
+
+	bucket = NewBucket(1000, 2000)
+	require.Zero(t, bucket.Take(2000))
+	require.NotZero(t, bucket.Take(100))
+}
+
+func TestTake(t *testing.T) {
+	t.Parallel()
+	slow := NewBucket(100, 0)
+	fast := NewBucket(1000, 0)
+	require.Zero(t, take([]*Bucket{slow, fast}, 100))
+	delay := take([]*Bucket{slow, fast}, 100)
+	require.InDelta(t, time.Second, delay, float64(50*time.Millisecond))
+	require.Zero(t, take(nil, 100))
+}
@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing/common/bufio"
+	N "github.com/sagernet/sing/common/network"
+)
+
+// waiter sleeps for the bucket delay, returning early once the connection is closed.
+type waiter struct {
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func newWaiter() waiter {
+	return waiter{done: make(chan struct{})}
+}
+
+func (w *waiter) wait(buckets []*Bucket, n int) error {
+	if len(buckets) == 0 || n <= 0 {
+		return nil
+	}
+	delay := take(buckets, n)
+	if delay == 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-w.done:
+		return net.ErrClosed
+	}
+}
+
+func (w *waiter) close() {
+	w.closeOnce.Do(func() {
+		close(w.done)
+	})
+}
+
+func (w *waiter) countFuncs(buckets []*Bucket) []N.CountFunc {
+	if len(buckets) == 0 {
+		return nil
+	}
+	return []N.CountFunc{func(n int64) {
+		w.wait(buckets, int(n))
+	}}
+}
+
+// Conn limits the bytes read from and written to the connection, waiting for
+// tokens in bufio counter functions after each read or write.
+type Conn struct {
+	N.ExtendedConn
+	waiter
+}
+
+func NewConn(conn net.Conn, readBuckets []*Bucket, writeBuckets []*Bucket) *Conn {
+	limitedConn := &Conn{waiter: newWaiter()}
+	limitedConn.ExtendedConn = bufio.NewCounterConn(conn, limitedConn.countFuncs(readBuckets), limitedConn.countFuncs(writeBuckets))
+	return limitedConn
+}
+
+func (c *Conn) Close() error {
+	c.close()
+	return c.ExtendedConn.Close()
+}
+
+func (c *Conn) Upstream() any {
+	return c.ExtendedConn
+}
+
+type PacketConn struct {
+	N.PacketConn
+	waiter
+}
+
+func NewPacketConn(conn N.PacketConn, readBuckets []*Bucket, writeBuckets []*Bucket) *PacketConn {
+	limitedConn := &PacketConn{waiter: newWaiter()}
+	limitedConn.PacketConn = bufio.NewCounterPacketConn(conn, limitedConn.countFuncs(readBuckets), limitedConn.countFuncs(writeBuckets))
+	return limitedConn
+}
+
+func (c *PacketConn) Close() error {
+	c.close()
+	return c.PacketConn.Close()
+}
+
+func (c *PacketConn) Upstream() any {
+	return c.PacketConn
+}
@@ -0,0 +1,135 @@
+package ruleconv
+
+import (
+	"net/netip"
+	"strings"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+)
+
+// convertAdGuard converts DNS filtering rules in the AdGuard / Adblock Plus
+// syntax. Exception rules are excluded from the blocking rules by an inverted
+// rule.
+func convertAdGuard(content []byte) (option.PlainRuleSet, []UnsupportedEntry, error) {
+	lines, err := readLines(content)
+	if err != nil {
+		return option.PlainRuleSet{}, nil, err
+	}
+	var (
+		blockRule     option.DefaultHeadlessRule
+		exceptionRule option.DefaultHeadlessRule
+		unsupported   []UnsupportedEntry
+	)
+	for _, it := range lines {
+		line := it.content
+		if strings.HasPrefix(line, "!") || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[") {
+			continue
+		}
+		if fields := strings.Fields(line); len(fields) > 1 {
+			if _, err := netip.ParseAddr(fields[0]); err == nil {
+				for _, name := range fields[1:] {
+					if strings.HasPrefix(name, "#") {
+						break
+					}
+					if !isHostsLocalName(name) {
+						blockRule.Domain = append(blockRule.Domain, strings.ToLower(name))
+					}
+				}
+				continue
+			}
+		}
+		for _, separator := range []string{"##", "#@#", "#?#", "#$#", "#%#"} {
+			if strings.Contains(line, separator) {
+				unsupported = append(unsupported, UnsupportedEntry{it.line, it.content, "cosmetic rule"})
+				line = ""
+				break
+			}
+		}
+		if line == "" {
+			continue
+		}
+		rule := &blockRule
+		if strings.HasPrefix(line, "@@") {
+			rule = &exceptionRule
+			line = line[2:]
+		}
+		reason := appendAdGuardRule(rule, line)
+		if reason != "" {
+			unsupported = append(unsupported, UnsupportedEntry{it.line, it.content, reason})
+		}
+	}
+	blockRule = uniqRule(blockRule)
+	if !blockRule.IsValid() {
+		return option.PlainRuleSet{}, unsupported, nil
+	}
+	exceptionRule = uniqRule(exceptionRule)
+	if !exceptionRule.IsValid() {
+		return newRules(blockRule), unsupported, nil
+	}
+	exceptionRule.Invert = true
+	return option.PlainRuleSet{
+		Rules: []option.HeadlessRule{{
+			Type: C.RuleTypeLogical,
+			LogicalOptions: option.LogicalHeadlessRule{
+				Mode: C.LogicalTypeAnd,
+				Rules: []option.HeadlessRule{
+					{Type: C.RuleTypeDefault, DefaultOptions: blockRule},
+					{Type: C.RuleTypeDefault, DefaultOptions: exceptionRule},
+				},
+			},
+		}},
+	}, unsupported, nil
+}
+
+// appendAdGuardRule appends the pattern of a basic rule to the headless rule,
+// or returns the reason why it is not supported.
+func appendAdGuardRule(rule *option.DefaultHeadlessRule, line string) string {
+	if len(line) > 1 && strings.HasPrefix(line, "/") && strings.HasSuffix(line, "/") {
+		rule.DomainRegex = append(rule.DomainRegex, line[1:len(line)-1])
+		return ""
+	}
+	pattern := line
+	if index := strings.LastIndexByte(line, '$'); index != -1 {
+		pattern = line[:index]
+		for _, modifier := range strings.Split(line[index+1:], ",") {
+			if modifier != "important" {
+				return "unsupported modifier " + modifier
+			}
+		}
+	}
+	pattern = strings.ToLower(pattern)
+	switch {
+	case strings.HasPrefix(pattern, "||"):
+		name := strings.TrimSuffix(strings.TrimSuffix(pattern[2:], "|"), "^")
+		if !isDomain(name) {
+			return "unsupported pattern"
+		}
+		if strings.Contains(name, "*") {
+			rule.DomainRegex = append(rule.DomainRegex, `(^|\.)`+wildcardRegex(name, ".*")+"$")
+		} else {
+			rule.DomainSuffix = append(rule.DomainSuffix, name)
+		}
+	case strings.HasPrefix(pattern, "|"):
+		name := strings.TrimSuffix(strings.TrimSuffix(pattern[1:], "|"), "^")
+		if !isDomain(name) {
+			return "unsupported pattern"
+		}
+		if strings.Contains(name, "*") {
+			rule.DomainRegex = append(rule.DomainRegex, "^"+wildcardRegex(name, ".*")+"$")
+		} else {
+			rule.Domain = append(rule.Domain, name)
+		}
+	default:
+		name := strings.TrimSuffix(pattern, "^")
+		if !isDomain(name) || !strings.Contains(name, ".") {
+			return "unsupported pattern"
+		}
+		if strings.Contains(name, "*") {
+			rule.DomainRegex = append(rule.DomainRegex, wildcardRegex(name, ".*"))
+		} else {
+			rule.DomainSuffix = append(rule.DomainSuffix, name)
+		}
+	}
+	return ""
+}
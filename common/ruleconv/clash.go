@@ -0,0 +1,224 @@
+package ruleconv
+
+import (
+	"bytes"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	N "github.com/sagernet/sing/common/network"
+
+	"gopkg.in/yaml.v3"
+)
+
+// readClashPayload reads entries of a Clash rule provider, either in the YAML
+// format with a payload list or in the text format with an entry per line.
+func readClashPayload(content []byte) ([]entry, error) {
+	isYAML := false
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("payload:")) {
+			isYAML = true
+			break
+		}
+	}
+	if !isYAML {
+		entries, err := readLines(content)
+		if err != nil {
+			return nil, err
+		}
+		var payload []entry
+		for _, it := range entries {
+			if strings.HasPrefix(it.content, "#") {
+				continue
+			}
+			payload = append(payload, it)
+		}
+		return payload, nil
+	}
+	var provider struct {
+		Payload yaml.Node `yaml:"payload"`
+	}
+	err := yaml.Unmarshal(content, &provider)
+	if err != nil {
+		return nil, E.Cause(err, "parse clash rule provider")
+	}
+	var payload []entry
+	for _, node := range provider.Payload.Content {
+		if node.Kind != yaml.ScalarNode {
+			return nil, E.New("line ", node.Line, ": invalid payload entry")
+		}
+		value := strings.TrimSpace(node.Value)
+		if value == "" {
+			continue
+		}
+		payload = append(payload, entry{node.Line, value})
+	}
+	return payload, nil
+}
+
+func convertClashDomain(content []byte) (option.PlainRuleSet, []UnsupportedEntry, error) {
+	payload, err := readClashPayload(content)
+	if err != nil {
+		return option.PlainRuleSet{}, nil, err
+	}
+	var (
+		rule        option.DefaultHeadlessRule
+		unsupported []UnsupportedEntry
+	)
+	for _, it := range payload {
+		name := strings.ToLower(it.content)
+		switch {
+		case strings.HasPrefix(name, "+.") && isDomain(name[2:]) && !strings.Contains(name[2:], "*"):
+			rule.DomainSuffix = append(rule.DomainSuffix, name[2:])
+		case strings.HasPrefix(name, ".") && isDomain(name[1:]) && !strings.Contains(name[1:], "*"):
+			rule.DomainSuffix = append(rule.DomainSuffix, name)
+		case isDomain(name) && strings.Contains(name, "*"):
+			// Wildcard * matches exactly one label.
+			rule.DomainRegex = append(rule.DomainRegex, "^"+wildcardRegex(name, `[^.]+`)+"$")
+		case isDomain(name):
+			rule.Domain = append(rule.Domain, name)
+		default:
+			unsupported = append(unsupported, UnsupportedEntry{it.line, it.content, "invalid domain"})
+		}
+	}
+	return newRules(rule), unsupported, nil
+}
+
+func convertClashIPCIDR(content []byte) (option.PlainRuleSet, []UnsupportedEntry, error) {
+	payload, err := readClashPayload(content)
+	if err != nil {
+		return option.PlainRuleSet{}, nil, err
+	}
+	var (
+		rule        option.DefaultHeadlessRule
+		unsupported []UnsupportedEntry
+	)
+	for _, it := range payload {
+		prefix, err := parsePrefix(it.content)
+		if err != nil {
+			unsupported = append(unsupported, UnsupportedEntry{it.line, it.content, err.Error()})
+			continue
+		}
+		rule.IPCIDR = append(rule.IPCIDR, prefix)
+	}
+	return newRules(rule), unsupported, nil
+}
+
+// convertClashClassical converts a classical rule provider. Its rules match if
+// any of them matches, so items which a headless rule would require to match
+// together are placed in separate rules.
+func convertClashClassical(content []byte) (option.PlainRuleSet, []UnsupportedEntry, error) {
+	payload, err := readClashPayload(content)
+	if err != nil {
+		return option.PlainRuleSet{}, nil, err
+	}
+	var (
+		destinationRule option.DefaultHeadlessRule
+		sourceIPRule    option.DefaultHeadlessRule
+		portRule        option.DefaultHeadlessRule
+		sourcePortRule  option.DefaultHeadlessRule
+		processNameRule option.DefaultHeadlessRule
+		processPathRule option.DefaultHeadlessRule
+		networkRule     option.DefaultHeadlessRule
+		unsupported     []UnsupportedEntry
+	)
+	for _, it := range payload {
+		params := strings.Split(it.content, ",")
+		if len(params) < 2 {
+			unsupported = append(unsupported, UnsupportedEntry{it.line, it.content, "invalid rule"})
+			continue
+		}
+		ruleType := strings.ToUpper(strings.TrimSpace(params[0]))
+		value := strings.TrimSpace(params[1])
+		switch ruleType {
+		case "DOMAIN":
+			destinationRule.Domain = append(destinationRule.Domain, strings.ToLower(value))
+		case "DOMAIN-SUFFIX":
+			destinationRule.DomainSuffix = append(destinationRule.DomainSuffix, strings.ToLower(value))
+		case "DOMAIN-KEYWORD":
+			destinationRule.DomainKeyword = append(destinationRule.DomainKeyword, strings.ToLower(value))
+		case "DOMAIN-REGEX":
+			destinationRule.DomainRegex = append(destinationRule.DomainRegex, value)
+		case "IP-CIDR", "IP-CIDR6", "SRC-IP-CIDR":
+			prefix, err := parsePrefix(value)
+			if err != nil {
+				unsupported = append(unsupported, UnsupportedEntry{it.line, it.content, err.Error()})
+				continue
+			}
+			if ruleType == "SRC-IP-CIDR" {
+				sourceIPRule.SourceIPCIDR = append(sourceIPRule.SourceIPCIDR, prefix)
+			} else {
+				destinationRule.IPCIDR = append(destinationRule.IPCIDR, prefix)
+			}
+		case "DST-PORT", "SRC-PORT":
+			rule := &portRule
+			if ruleType == "SRC-PORT" {
+				rule = &sourcePortRule
+			}
+			err = appendClashPort(rule, ruleType == "SRC-PORT", value)
+			if err != nil {
+				unsupported = append(unsupported, UnsupportedEntry{it.line, it.content, err.Error()})
+			}
+		case "PROCESS-NAME":
+			processNameRule.ProcessName = append(processNameRule.ProcessName, value)
+		case "PROCESS-PATH":
+			processPathRule.ProcessPath = append(processPathRule.ProcessPath, value)
+		case "NETWORK":
+			network := strings.ToLower(value)
+			if network != N.NetworkTCP && network != N.NetworkUDP {
+				unsupported = append(unsupported, UnsupportedEntry{it.line, it.content, "unknown network"})
+				continue
+			}
+			networkRule.Network = append(networkRule.Network, network)
+		default:
+			unsupported = append(unsupported, UnsupportedEntry{it.line, it.content, "unsupported rule type " + ruleType})
+		}
+	}
+	return newRules(destinationRule, sourceIPRule, portRule, sourcePortRule, processNameRule, processPathRule, networkRule), unsupported, nil
+}
+
+func appendClashPort(rule *option.DefaultHeadlessRule, isSource bool, value string) error {
+	for _, portString := range strings.Split(value, "/") {
+		if from, to, isRange := strings.Cut(portString, "-"); isRange {
+			fromPort, err := strconv.ParseUint(from, 10, 16)
+			if err != nil {
+				return E.Cause(err, "parse port range")
+			}
+			toPort, err := strconv.ParseUint(to, 10, 16)
+			if err != nil {
+				return E.Cause(err, "parse port range")
+			}
+			portRange := strconv.FormatUint(fromPort, 10) + ":" + strconv.FormatUint(toPort, 10)
+			if isSource {
+				rule.SourcePortRange = append(rule.SourcePortRange, portRange)
+			} else {
+				rule.PortRange = append(rule.PortRange, portRange)
+			}
+			continue
+		}
+		port, err := strconv.ParseUint(portString, 10, 16)
+		if err != nil {
+			return E.Cause(err, "parse port")
+		}
+		if isSource {
+			rule.SourcePort = append(rule.SourcePort, uint16(port))
+		} else {
+			rule.Port = append(rule.Port, uint16(port))
+		}
+	}
+	return nil
+}
+
+func parsePrefix(value string) (string, error) {
+	prefix, err := netip.ParsePrefix(value)
+	if err == nil {
+		return prefix.String(), nil
+	}
+	addr, addrErr := netip.ParseAddr(value)
+	if addrErr == nil {
+		return netip.PrefixFrom(addr, addr.BitLen()).String(), nil
+	}
+	return "", E.Cause(err, "parse ip_cidr")
+}
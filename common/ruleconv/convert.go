@@ -0,0 +1,126 @@
+package ruleconv
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strings"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+)
+
+// UnsupportedEntry is an entry of a rule list which can not be converted.
+type UnsupportedEntry struct {
+	Line    int
+	Content string
+	Reason  string
+}
+
+func (e UnsupportedEntry) String() string {
+	return F.ToString("line ", e.Line, ": ", e.Content, ": ", e.Reason)
+}
+
+// IsSupported reports whether the format is a rule list format Convert accepts.
+func IsSupported(format string) bool {
+	switch format {
+	case C.RuleSetFormatClashDomain, C.RuleSetFormatClashIPCIDR, C.RuleSetFormatClashClassical,
+		C.RuleSetFormatAdGuard, C.RuleSetFormatHosts, C.RuleSetFormatDnsmasq:
+		return true
+	default:
+		return false
+	}
+}
+
+// Convert converts a rule list in the format to a rule-set. Entries which can
+// not be converted are skipped and returned.
+func Convert(format string, content []byte) (option.PlainRuleSet, []UnsupportedEntry, error) {
+	switch format {
+	case C.RuleSetFormatClashDomain:
+		return convertClashDomain(content)
+	case C.RuleSetFormatClashIPCIDR:
+		return convertClashIPCIDR(content)
+	case C.RuleSetFormatClashClassical:
+		return convertClashClassical(content)
+	case C.RuleSetFormatAdGuard:
+		return convertAdGuard(content)
+	case C.RuleSetFormatHosts:
+		return convertHosts(content)
+	case C.RuleSetFormatDnsmasq:
+		return convertDnsmasq(content)
+	default:
+		return option.PlainRuleSet{}, nil, E.New("unknown rule list format: ", format)
+	}
+}
+
+type entry struct {
+	line    int
+	content string
+}
+
+func readLines(content []byte) ([]entry, error) {
+	var entries []entry
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		entries = append(entries, entry{lineNumber, line})
+	}
+	return entries, scanner.Err()
+}
+
+func newRules(rules ...option.DefaultHeadlessRule) option.PlainRuleSet {
+	var ruleSet option.PlainRuleSet
+	for _, rule := range rules {
+		rule = uniqRule(rule)
+		if !rule.IsValid() {
+			continue
+		}
+		ruleSet.Rules = append(ruleSet.Rules, option.HeadlessRule{
+			Type:           C.RuleTypeDefault,
+			DefaultOptions: rule,
+		})
+	}
+	return ruleSet
+}
+
+func uniqRule(rule option.DefaultHeadlessRule) option.DefaultHeadlessRule {
+	rule.Domain = uniq(rule.Domain)
+	rule.DomainSuffix = uniq(rule.DomainSuffix)
+	rule.DomainKeyword = uniq(rule.DomainKeyword)
+	rule.DomainRegex = uniq(rule.DomainRegex)
+	rule.IPCIDR = uniq(rule.IPCIDR)
+	rule.SourceIPCIDR = uniq(rule.SourceIPCIDR)
+	rule.ProcessName = uniq(rule.ProcessName)
+	rule.ProcessPath = uniq(rule.ProcessPath)
+	rule.Network = uniq(rule.Network)
+	return rule
+}
+
+// uniq keeps nil lists nil, which DefaultHeadlessRule.IsValid relies on.
+func uniq[T comparable](values []T) []T {
+	if len(values) == 0 {
+		return nil
+	}
+	return common.Uniq(values)
+}
+
+var domainPattern = regexp.MustCompile(`^[a-z0-9_*]([a-z0-9_*-]*[a-z0-9_*])?(\.[a-z0-9_*]([a-z0-9_*-]*[a-z0-9_*])?)*$`)
+
+// isDomain reports whether name is a lower-case domain, in which labels may
+// contain the wildcard *.
+func isDomain(name string) bool {
+	return name != "" && len(name) <= 253 && domainPattern.MatchString(name)
+}
+
+// wildcardRegex converts a domain with the wildcard * to a regular expression,
+// with wildcard matching the pattern.
+func wildcardRegex(name string, wildcard string) string {
+	return strings.Join(common.Map(strings.Split(name, "*"), regexp.QuoteMeta), wildcard)
+}
@@ -0,0 +1,82 @@
+package ruleconv
+
+import (
+	"testing"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertClashDomain(t *testing.T) {
+	t.Parallel()
+	ruleSet, unsupported, err := Convert(C.RuleSetFormatClashDomain, []byte(`payload:
+  - '+.example.com'
+  - '.example.net'
+  - '*.example.org'
+  - 'sagernet.org'
+  - 'invalid domain'
+`))
+	require.NoError(t, err)
+	require.Equal(t, []UnsupportedEntry{{6, "invalid domain", "invalid domain"}}, unsupported)
+	require.Equal(t, newRules(option.DefaultHeadlessRule{
+		Domain:       []string{"sagernet.org"},
+		DomainSuffix: []string{"example.com", ".example.net"},
+		DomainRegex:  []string{`^[^.]+\.example\.org$`},
+	}), ruleSet)
+}
+
+func TestConvertClashClassical(t *testing.T) {
+	t.Parallel()
+	ruleSet, unsupported, err := Convert(C.RuleSetFormatClashClassical, []byte(`DOMAIN-SUFFIX,example.com
+IP-CIDR,10.0.0.0/8,no-resolve
+DST-PORT,8000-9000
+GEOIP,CN
+`))
+	require.NoError(t, err)
+	require.Len(t, unsupported, 1)
+	require.Equal(t, newRules(option.DefaultHeadlessRule{
+		DomainSuffix: []string{"example.com"},
+		IPCIDR:       []string{"10.0.0.0/8"},
+	}, option.DefaultHeadlessRule{
+		PortRange: []string{"8000:9000"},
+	}), ruleSet)
+}
+
+func TestConvertAdGuard(t *testing.T) {
+	t.Parallel()
+	ruleSet, unsupported, err := Convert(C.RuleSetFormatAdGuard, []byte(`! comment
+||ads.example.com^
+|exact.example.com^
+@@||good.ads.example.com^
+example.com##.banner
+||third-party.com^$third-party
+`))
+	require.NoError(t, err)
+	require.Len(t, unsupported, 2)
+	require.Len(t, ruleSet.Rules, 1)
+	logicalRule := ruleSet.Rules[0].LogicalOptions
+	require.Equal(t, C.LogicalTypeAnd, logicalRule.Mode)
+	require.Equal(t, option.DefaultHeadlessRule{
+		Domain:       []string{"exact.example.com"},
+		DomainSuffix: []string{"ads.example.com"},
+	}, logicalRule.Rules[0].DefaultOptions)
+	require.Equal(t, option.DefaultHeadlessRule{
+		DomainSuffix: []string{"good.ads.example.com"},
+		Invert:       true,
+	}, logicalRule.Rules[1].DefaultOptions)
+}
+
+func TestConvertDnsmasq(t *testing.T) {
+	t.Parallel()
+	ruleSet, unsupported, err := Convert(C.RuleSetFormatDnsmasq, []byte(`server=/example.com/example.net/1.1.1.1
+address=/*.example.org/0.0.0.0
+cache-size=100
+`))
+	require.NoError(t, err)
+	require.Len(t, unsupported, 1)
+	require.Equal(t, newRules(option.DefaultHeadlessRule{
+		DomainSuffix: []string{"example.com", "example.net", ".example.org"},
+	}), ruleSet)
+}
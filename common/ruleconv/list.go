@@ -0,0 +1,101 @@
+package ruleconv
+
+import (
+	"net/netip"
+	"strings"
+
+	"github.com/sagernet/sing-box/option"
+)
+
+func isHostsLocalName(name string) bool {
+	switch strings.ToLower(name) {
+	case "localhost", "localhost.localdomain", "local", "broadcasthost", "0.0.0.0",
+		"ip6-localhost", "ip6-loopback", "ip6-localnet", "ip6-mcastprefix",
+		"ip6-allnodes", "ip6-allrouters", "ip6-allhosts":
+		return true
+	default:
+		return false
+	}
+}
+
+// convertHosts converts host names of a hosts file to domain items, other than
+// the names of the local host.
+func convertHosts(content []byte) (option.PlainRuleSet, []UnsupportedEntry, error) {
+	lines, err := readLines(content)
+	if err != nil {
+		return option.PlainRuleSet{}, nil, err
+	}
+	var (
+		rule        option.DefaultHeadlessRule
+		unsupported []UnsupportedEntry
+	)
+	for _, it := range lines {
+		line, _, _ := strings.Cut(it.content, "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if _, err := netip.ParseAddr(fields[0]); err != nil || len(fields) < 2 {
+			unsupported = append(unsupported, UnsupportedEntry{it.line, it.content, "invalid hosts entry"})
+			continue
+		}
+		for _, name := range fields[1:] {
+			name = strings.ToLower(name)
+			if isHostsLocalName(name) {
+				continue
+			}
+			if !isDomain(name) || strings.Contains(name, "*") {
+				unsupported = append(unsupported, UnsupportedEntry{it.line, it.content, "invalid host name " + name})
+				continue
+			}
+			rule.Domain = append(rule.Domain, name)
+		}
+	}
+	return newRules(rule), unsupported, nil
+}
+
+// convertDnsmasq converts the domains of dnsmasq options in the
+// option=/domain/.../value syntax, which match the domains and their
+// subdomains.
+func convertDnsmasq(content []byte) (option.PlainRuleSet, []UnsupportedEntry, error) {
+	lines, err := readLines(content)
+	if err != nil {
+		return option.PlainRuleSet{}, nil, err
+	}
+	var (
+		rule        option.DefaultHeadlessRule
+		unsupported []UnsupportedEntry
+	)
+	for _, it := range lines {
+		if strings.HasPrefix(it.content, "#") {
+			continue
+		}
+		key, value, _ := strings.Cut(it.content, "=")
+		switch strings.TrimSpace(key) {
+		case "server", "local", "address", "ipset", "nftset":
+		default:
+			unsupported = append(unsupported, UnsupportedEntry{it.line, it.content, "unsupported option"})
+			continue
+		}
+		value = strings.TrimSpace(value)
+		parts := strings.Split(value, "/")
+		if !strings.HasPrefix(value, "/") || len(parts) < 3 {
+			unsupported = append(unsupported, UnsupportedEntry{it.line, it.content, "missing domain"})
+			continue
+		}
+		for _, name := range parts[1 : len(parts)-1] {
+			name = strings.ToLower(name)
+			switch {
+			case name == "":
+				unsupported = append(unsupported, UnsupportedEntry{it.line, it.content, "unqualified names"})
+			case strings.HasPrefix(name, "*.") && isDomain(name[2:]) && !strings.Contains(name[2:], "*"):
+				rule.DomainSuffix = append(rule.DomainSuffix, name[1:])
+			case isDomain(name) && !strings.Contains(name, "*"):
+				rule.DomainSuffix = append(rule.DomainSuffix, name)
+			default:
+				unsupported = append(unsupported, UnsupportedEntry{it.line, it.content, "invalid domain " + name})
+			}
+		}
+	}
+	return newRules(rule), unsupported, nil
+}
@@ -38,6 +38,19 @@ const (
 	ruleItemFinal uint8 = 0xFF
 )
 
+// rule items of RuleSetVersion2, kept apart from the IDs of upstream rule
+// items so that those are refused as unknown
+const (
+	ruleItemInbound uint8 = 0x80 + iota
+	ruleItemAuthUser
+	ruleItemProtocol
+	ruleItemSourceIPIsPrivate
+	ruleItemIPIsPrivate
+	ruleItemUser
+	ruleItemUserID
+	ruleItemClashMode
+)
+
 func Read(reader io.Reader, recovery bool) (ruleSet option.PlainRuleSet, err error) {
 	var magicBytes [3]byte
 	_, err = io.ReadFull(reader, magicBytes[:])
@@ -53,7 +66,7 @@ func Read(reader io.Reader, recovery bool) (ruleSet option.PlainRuleSet, err err
 	if err != nil {
 		return ruleSet, err
 	}
-	if version != 1 {
+	if version < C.RuleSetVersion1 || version > C.RuleSetVersion2 {
 		return ruleSet, E.New("unsupported version: ", version)
 	}
 	zReader, err := zlib.NewReader(reader)
@@ -80,7 +93,11 @@ func Write(writer io.Writer, ruleSet option.PlainRuleSet) error {
 	if err != nil {
 		return err
 	}
-	err = binary.Write(writer, binary.BigEndian, uint8(1))
+	version := uint8(C.RuleSetVersion1)
+	if ruleSet.RequiresVersion2() {
+		version = C.RuleSetVersion2
+	}
+	err = binary.Write(writer, binary.BigEndian, version)
 	if err != nil {
 		return err
 	}
@@ -158,6 +175,9 @@ func readDefaultRule(reader io.Reader, recovery bool) (rule option.DefaultHeadle
 				return
 			}
 			rule.DomainMatcher = matcher
+			if recovery {
+				rule.Domain, rule.DomainSuffix, err = recoverDomain(matcher)
+			}
 		case ruleItemDomainKeyword:
 			rule.DomainKeyword, err = readRuleItemString(reader)
 		case ruleItemDomainRegex:
@@ -196,6 +216,29 @@ func readDefaultRule(reader io.Reader, recovery bool) (rule option.DefaultHeadle
 			rule.WIFISSID, err = readRuleItemString(reader)
 		case ruleItemWIFIBSSID:
 			rule.WIFIBSSID, err = readRuleItemString(reader)
+		case ruleItemInbound:
+			rule.Inbound, err = readRuleItemString(reader)
+		case ruleItemAuthUser:
+			rule.AuthUser, err = readRuleItemString(reader)
+		case ruleItemProtocol:
+			rule.Protocol, err = readRuleItemString(reader)
+		case ruleItemSourceIPIsPrivate:
+			rule.SourceIPIsPrivate = true
+		case ruleItemIPIsPrivate:
+			rule.IPIsPrivate = true
+		case ruleItemUser:
+			rule.User, err = readRuleItemString(reader)
+		case ruleItemUserID:
+			var rawUserID []uint32
+			rawUserID, err = readRuleItemUint32(reader)
+			if err != nil {
+				return
+			}
+			rule.UserID = common.Map(rawUserID, func(it uint32) int32 {
+				return int32(it)
+			})
+		case ruleItemClashMode:
+			rule.ClashMode, err = rw.ReadVString(reader)
 		case ruleItemFinal:
 			err = binary.Read(reader, binary.BigEndian, &rule.Invert)
 			return
@@ -316,6 +359,60 @@ func writeDefaultRule(writer io.Writer, rule option.DefaultHeadlessRule) error {
 			return err
 		}
 	}
+	if len(rule.Inbound) > 0 {
+		err = writeRuleItemString(writer, ruleItemInbound, rule.Inbound)
+		if err != nil {
+			return err
+		}
+	}
+	if len(rule.AuthUser) > 0 {
+		err = writeRuleItemString(writer, ruleItemAuthUser, rule.AuthUser)
+		if err != nil {
+			return err
+		}
+	}
+	if len(rule.Protocol) > 0 {
+		err = writeRuleItemString(writer, ruleItemProtocol, rule.Protocol)
+		if err != nil {
+			return err
+		}
+	}
+	if rule.SourceIPIsPrivate {
+		err = binary.Write(writer, binary.BigEndian, ruleItemSourceIPIsPrivate)
+		if err != nil {
+			return err
+		}
+	}
+	if rule.IPIsPrivate {
+		err = binary.Write(writer, binary.BigEndian, ruleItemIPIsPrivate)
+		if err != nil {
+			return err
+		}
+	}
+	if len(rule.User) > 0 {
+		err = writeRuleItemString(writer, ruleItemUser, rule.User)
+		if err != nil {
+			return err
+		}
+	}
+	if len(rule.UserID) > 0 {
+		err = writeRuleItemUint32(writer, ruleItemUserID, common.Map(rule.UserID, func(it int32) uint32 {
+			return uint32(it)
+		}))
+		if err != nil {
+			return err
+		}
+	}
+	if rule.ClashMode != "" {
+		err = binary.Write(writer, binary.BigEndian, ruleItemClashMode)
+		if err != nil {
+			return err
+		}
+		err = rw.WriteVString(writer, rule.ClashMode)
+		if err != nil {
+			return err
+		}
+	}
 	err = binary.Write(writer, binary.BigEndian, ruleItemFinal)
 	if err != nil {
 		return err
@@ -393,6 +490,39 @@ func writeRuleItemUint16(writer io.Writer, itemType uint8, value []uint16) error
 	return nil
 }
 
+func readRuleItemUint32(reader io.Reader) ([]uint32, error) {
+	length, err := rw.ReadUVariant(reader)
+	if err != nil {
+		return nil, err
+	}
+	value := make([]uint32, length)
+	for i := uint64(0); i < length; i++ {
+		err = binary.Read(reader, binary.BigEndian, &value[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return value, nil
+}
+
+func writeRuleItemUint32(writer io.Writer, itemType uint8, value []uint32) error {
+	err := binary.Write(writer, binary.BigEndian, itemType)
+	if err != nil {
+		return err
+	}
+	err = rw.WriteUVariant(writer, uint64(len(value)))
+	if err != nil {
+		return err
+	}
+	for _, item := range value {
+		err = binary.Write(writer, binary.BigEndian, item)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func writeRuleItemCIDR(writer io.Writer, itemType uint8, value []string) error {
 	var builder netipx.IPSetBuilder
 	for i, prefixString := range value {
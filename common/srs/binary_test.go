@@ -0,0 +1,117 @@
+package srs
+
+import (
+	"bytes"
+	"compress/zlib"
+	"testing"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteVersion2(t *testing.T) {
+	t.Parallel()
+	extendedRule := option.DefaultHeadlessRule{
+		Inbound:           []string{"mixed-in"},
+		AuthUser:          []string{"user"},
+		Protocol:          []string{C.ProtocolTLS},
+		SourceIPIsPrivate: true,
+		IPIsPrivate:       true,
+		User:              []string{"root"},
+		UserID:            []int32{0, 1000},
+		ClashMode:         "direct",
+		Invert:            true,
+	}
+	ruleSet := option.PlainRuleSet{
+		Rules: []option.HeadlessRule{{
+			Type:           C.RuleTypeDefault,
+			DefaultOptions: extendedRule,
+		}, {
+			Type: C.RuleTypeLogical,
+			LogicalOptions: option.LogicalHeadlessRule{
+				Mode: C.LogicalTypeOr,
+				Rules: []option.HeadlessRule{{
+					Type: C.RuleTypeDefault,
+					DefaultOptions: option.DefaultHeadlessRule{
+						Network: []string{"udp"},
+					},
+				}, {
+					Type: C.RuleTypeDefault,
+					DefaultOptions: option.DefaultHeadlessRule{
+						Protocol: []string{C.ProtocolQUIC},
+						User:     []string{"nobody"},
+					},
+				}},
+			},
+		}},
+	}
+	var buffer bytes.Buffer
+	require.NoError(t, Write(&buffer, ruleSet))
+	require.Equal(t, uint8(C.RuleSetVersion2), buffer.Bytes()[len(MagicBytes)])
+	decoded, err := Read(&buffer, true)
+	require.NoError(t, err)
+	require.Equal(t, ruleSet, decoded)
+}
+
+func writeRawRuleSet(t *testing.T, version uint8, items ...byte) []byte {
+	var buffer bytes.Buffer
+	buffer.Write(MagicBytes[:])
+	buffer.WriteByte(version)
+	zWriter := zlib.NewWriter(&buffer)
+	_, err := zWriter.Write(append([]byte{1, 0}, items...))
+	require.NoError(t, err)
+	require.NoError(t, zWriter.Close())
+	return buffer.Bytes()
+}
+
+func TestReadUnknown(t *testing.T) {
+	t.Parallel()
+	// an upstream version 2 rule-set with the adguard_domain item 16
+	_, err := Read(bytes.NewReader(writeRawRuleSet(t, C.RuleSetVersion2, 16)), false)
+	require.ErrorContains(t, err, "unknown rule item type: 16")
+	_, err = Read(bytes.NewReader(writeRawRuleSet(t, 3, ruleItemFinal, 0)), false)
+	require.ErrorContains(t, err, "unsupported version")
+	_, err = Read(bytes.NewReader(writeRawRuleSet(t, C.RuleSetVersion1, 16)), false)
+	require.ErrorContains(t, err, "unknown rule item type: 16")
+	_, err = Read(bytes.NewReader(writeRawRuleSet(t, C.RuleSetVersion1, ruleItemFinal, 0)), false)
+	require.NoError(t, err)
+}
+
+func TestWriteVersion1(t *testing.T) {
+	t.Parallel()
+	ruleSet := option.PlainRuleSet{
+		Rules: []option.HeadlessRule{{
+			Type: C.RuleTypeDefault,
+			DefaultOptions: option.DefaultHeadlessRule{
+				Network: []string{"tcp"},
+			},
+		}},
+	}
+	var buffer bytes.Buffer
+	require.NoError(t, Write(&buffer, ruleSet))
+	require.Equal(t, uint8(C.RuleSetVersion1), buffer.Bytes()[len(MagicBytes)])
+}
+
+func TestRecoverDomain(t *testing.T) {
+	t.Parallel()
+	ruleSet := option.PlainRuleSet{
+		Rules: []option.HeadlessRule{{
+			Type: C.RuleTypeDefault,
+			DefaultOptions: option.DefaultHeadlessRule{
+				Domain:       []string{"example.org", "mail.example.com", "例子.测试"},
+				DomainSuffix: []string{".example.net", "example.com", "sagernet.org"},
+				IPCIDR:       []string{"10.0.0.0/8"},
+			},
+		}},
+	}
+	var buffer bytes.Buffer
+	require.NoError(t, Write(&buffer, ruleSet))
+	decoded, err := Read(&buffer, true)
+	require.NoError(t, err)
+	rule := decoded.Rules[0].DefaultOptions
+	require.Equal(t, option.Listable[string]{"example.org", "mail.example.com", "例子.测试"}, rule.Domain)
+	require.Equal(t, option.Listable[string]{".example.net", "example.com", "sagernet.org"}, rule.DomainSuffix)
+	require.Equal(t, option.Listable[string]{"10.0.0.0/8"}, rule.IPCIDR)
+}
@@ -0,0 +1,139 @@
+package srs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sagernet/sing/common/domain"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/rw"
+)
+
+// prefixLabel marks a domain suffix key in domain.Matcher.
+const prefixLabel = '\r'
+
+// recoverDomain returns the domain and domain_suffix items of a matcher by
+// walking its serialized succinct trie, whose keys are reversed domains.
+func recoverDomain(matcher *domain.Matcher) (domains []string, domainSuffix []string, err error) {
+	var buffer bytes.Buffer
+	err = matcher.Write(&buffer)
+	if err != nil {
+		return
+	}
+	keys, err := readMatcherKeys(&buffer)
+	if err != nil {
+		return
+	}
+	exactDomains := make(map[string]bool)
+	var suffixes []string
+	for _, key := range keys {
+		if strings.HasSuffix(key, string(rune(prefixLabel))) {
+			suffixes = append(suffixes, reverseDomain(key[:len(key)-1]))
+		} else {
+			exactDomains[reverseDomain(key)] = true
+		}
+	}
+	for _, suffix := range suffixes {
+		// A domain_suffix without leading dot is stored as the domain itself
+		// and its subdomains.
+		if rootDomain := strings.TrimPrefix(suffix, "."); rootDomain != suffix && exactDomains[rootDomain] {
+			delete(exactDomains, rootDomain)
+			domainSuffix = append(domainSuffix, rootDomain)
+		} else {
+			domainSuffix = append(domainSuffix, suffix)
+		}
+	}
+	for exactDomain := range exactDomains {
+		domains = append(domains, exactDomain)
+	}
+	sort.Strings(domains)
+	sort.Strings(domainSuffix)
+	return
+}
+
+func readMatcherKeys(reader io.Reader) ([]string, error) {
+	var version uint8
+	err := binary.Read(reader, binary.BigEndian, &version)
+	if err != nil {
+		return nil, err
+	}
+	leaves, err := readMatcherBitmap(reader)
+	if err != nil {
+		return nil, err
+	}
+	labelBitmap, err := readMatcherBitmap(reader)
+	if err != nil {
+		return nil, err
+	}
+	labelsLength, err := rw.ReadUVariant(reader)
+	if err != nil {
+		return nil, err
+	}
+	labels := make([]byte, labelsLength)
+	_, err = io.ReadFull(reader, labels)
+	if err != nil {
+		return nil, err
+	}
+	// Nodes are stored in breadth-first order: each node has a zero bit for
+	// every child, in the order of the labels, followed by a one bit.
+	parents := []int{-1}
+	nodeLabels := []byte{0}
+	for bitIndex, nodeID := 0, 0; nodeID < len(parents); bitIndex++ {
+		if bitIndex>>6 >= len(labelBitmap) {
+			return nil, E.New("invalid domain matcher")
+		}
+		if labelBitmap[bitIndex>>6]&(1<<uint(bitIndex&63)) != 0 {
+			nodeID++
+			continue
+		}
+		labelIndex := len(parents) - 1
+		if labelIndex >= len(labels) {
+			return nil, E.New("invalid domain matcher")
+		}
+		parents = append(parents, nodeID)
+		nodeLabels = append(nodeLabels, labels[labelIndex])
+	}
+	var keys []string
+	for nodeID := 1; nodeID < len(parents); nodeID++ {
+		if nodeID>>6 >= len(leaves) || leaves[nodeID>>6]&(1<<uint(nodeID&63)) == 0 {
+			continue
+		}
+		var key []byte
+		for current := nodeID; current > 0; current = parents[current] {
+			key = append(key, nodeLabels[current])
+		}
+		for i, j := 0, len(key)-1; i < j; i, j = i+1, j-1 {
+			key[i], key[j] = key[j], key[i]
+		}
+		keys = append(keys, string(key))
+	}
+	return keys, nil
+}
+
+func readMatcherBitmap(reader io.Reader) ([]uint64, error) {
+	length, err := rw.ReadUVariant(reader)
+	if err != nil {
+		return nil, err
+	}
+	bitmap := make([]uint64, length)
+	err = binary.Read(reader, binary.BigEndian, bitmap)
+	if err != nil {
+		return nil, err
+	}
+	return bitmap, nil
+}
+
+func reverseDomain(domain string) string {
+	l := len(domain)
+	b := make([]byte, l)
+	for i := 0; i < l; {
+		r, n := utf8.DecodeRuneInString(domain[i:])
+		i += n
+		utf8.EncodeRune(b[l-i:], r)
+	}
+	return string(b)
+}
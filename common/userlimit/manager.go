@@ -0,0 +1,500 @@
+package userlimit
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/ratelimit"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/service"
+)
+
+const (
+	usageSaveInterval = time.Minute
+	// quotaCheckBytes is the most traffic counted before quotas are checked.
+	quotaCheckBytes = 1 << 20
+)
+
+// Manager enforces limits of named users, shared by all inbounds that
+// authenticate the same user name.
+type Manager struct {
+	ctx       context.Context
+	logger    log.ContextLogger
+	access    sync.Mutex
+	users     map[string]*user
+	cacheFile adapter.CacheFile
+	done      chan struct{}
+}
+
+func NewManager(ctx context.Context, logger log.ContextLogger) *Manager {
+	return &Manager{
+		ctx:    ctx,
+		logger: logger,
+		users:  make(map[string]*user),
+		done:   make(chan struct{}),
+	}
+}
+
+// Register adds the limits of users of an inbound. The manager from the context
+// is used, and nothing is done if there is none.
+func Register(ctx context.Context, inbound string, name string, options option.UserLimitOptions) error {
+	manager := service.PtrFromContext[Manager](ctx)
+	if manager == nil || options.IsEmpty() {
+		return nil
+	}
+	if name == "" {
+		return E.New("user limits require a user name")
+	}
+	return manager.Register(inbound, name, options)
+}
+
+// Check returns the error Register would return, without registering.
+func Check(ctx context.Context, inbound string, name string, options option.UserLimitOptions) error {
+	manager := service.PtrFromContext[Manager](ctx)
+	if manager == nil || options.IsEmpty() {
+		return nil
+	}
+	if name == "" {
+		return E.New("user limits require a user name")
+	}
+	return manager.Check(inbound, name, options)
+}
+
+// Unregister removes the limits of a user of an inbound, which are dropped
+// once no inbound has the user.
+func Unregister(ctx context.Context, inbound string, name string) {
+	manager := service.PtrFromContext[Manager](ctx)
+	if manager == nil || name == "" {
+		return
+	}
+	manager.Unregister(inbound, name)
+}
+
+func (m *Manager) Register(inbound string, name string, options option.UserLimitOptions) error {
+	m.access.Lock()
+	defer m.access.Unlock()
+	err := m.check(inbound, name, options)
+	if err != nil {
+		return err
+	}
+	if existing, loaded := m.users[name]; loaded {
+		existing.inbounds[inbound] = true
+		existing.update(options)
+		return nil
+	}
+	limitedUser := newUser(name, inbound, options)
+	if m.cacheFile != nil {
+		limitedUser.restore(m.cacheFile.LoadUserUsage(name))
+	}
+	m.users[name] = limitedUser
+	return nil
+}
+
+func (m *Manager) Check(inbound string, name string, options option.UserLimitOptions) error {
+	m.access.Lock()
+	defer m.access.Unlock()
+	return m.check(inbound, name, options)
+}
+
+func (m *Manager) check(inbound string, name string, options option.UserLimitOptions) error {
+	existing, loaded := m.users[name]
+	if !loaded || existing.options == options {
+		return nil
+	}
+	for existingInbound := range existing.inbounds {
+		if existingInbound != inbound {
+			return E.New("conflicting limits for user ", name, " in inbound ", existingInbound)
+		}
+	}
+	return nil
+}
+
+func (m *Manager) Unregister(inbound string, name string) {
+	m.access.Lock()
+	existing, loaded := m.users[name]
+	if !loaded {
+		m.access.Unlock()
+		return
+	}
+	delete(existing.inbounds, inbound)
+	if len(existing.inbounds) > 0 {
+		m.access.Unlock()
+		return
+	}
+	delete(m.users, name)
+	cacheFile := m.cacheFile
+	m.access.Unlock()
+	if cacheFile == nil {
+		return
+	}
+	if usage := existing.usage(); usage != nil {
+		err := cacheFile.SaveUserUsage(map[string]*adapter.SavedUserUsage{name: usage})
+		if err != nil {
+			m.logger.Warn("save user usage: ", err)
+		}
+	}
+}
+
+// PreStart restores usage of users from the cache file, before inbounds
+// accept connections.
+func (m *Manager) PreStart() error {
+	cacheFile := service.FromContext[adapter.CacheFile](m.ctx)
+	if cacheFile == nil {
+		return nil
+	}
+	m.access.Lock()
+	m.cacheFile = cacheFile
+	for name, limitedUser := range m.users {
+		limitedUser.restore(cacheFile.LoadUserUsage(name))
+	}
+	m.access.Unlock()
+	return nil
+}
+
+func (m *Manager) Start() error {
+	go m.loopSave()
+	return nil
+}
+
+func (m *Manager) loopSave() {
+	ticker := time.NewTicker(usageSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.save()
+		case <-m.done:
+			return
+		}
+	}
+}
+
+func (m *Manager) save() {
+	m.access.Lock()
+	cacheFile := m.cacheFile
+	usages := make(map[string]*adapter.SavedUserUsage)
+	for name, limitedUser := range m.users {
+		if usage := limitedUser.usage(); usage != nil {
+			usages[name] = usage
+		}
+	}
+	m.access.Unlock()
+	if cacheFile == nil || len(usages) == 0 {
+		return
+	}
+	err := cacheFile.SaveUserUsage(usages)
+	if err != nil {
+		m.logger.Warn("save user usage: ", err)
+	}
+}
+
+func (m *Manager) Close() error {
+	select {
+	case <-m.done:
+		return nil
+	default:
+		close(m.done)
+	}
+	m.save()
+	return nil
+}
+
+func (m *Manager) loadUser(name string) *user {
+	if name == "" {
+		return nil
+	}
+	m.access.Lock()
+	defer m.access.Unlock()
+	return m.users[name]
+}
+
+func (m *Manager) RoutedConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) (net.Conn, adapter.Tracker, error) {
+	limitedUser := m.loadUser(metadata.User)
+	if limitedUser == nil {
+		return conn, nopTracker{}, nil
+	}
+	tracker, err := limitedUser.join(metadata.Source.Addr, conn)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = limitedUser.checkQuota(); err != nil {
+		tracker.Leave()
+		return nil, nil, err
+	}
+	countFunc := []N.CountFunc{limitedUser.count}
+	conn = bufio.NewCounterConn(conn, countFunc, countFunc)
+	if readBuckets, writeBuckets := limitedUser.buckets(); len(readBuckets) > 0 || len(writeBuckets) > 0 {
+		conn = ratelimit.NewConn(conn, readBuckets, writeBuckets)
+	}
+	return conn, tracker, nil
+}
+
+func (m *Manager) RoutedPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) (N.PacketConn, adapter.Tracker, error) {
+	limitedUser := m.loadUser(metadata.User)
+	if limitedUser == nil {
+		return conn, nopTracker{}, nil
+	}
+	tracker, err := limitedUser.join(metadata.Source.Addr, conn)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = limitedUser.checkQuota(); err != nil {
+		tracker.Leave()
+		return nil, nil, err
+	}
+	countFunc := []N.CountFunc{limitedUser.count}
+	conn = bufio.NewCounterPacketConn(conn, countFunc, countFunc)
+	if readBuckets, writeBuckets := limitedUser.buckets(); len(readBuckets) > 0 || len(writeBuckets) > 0 {
+		conn = ratelimit.NewPacketConn(conn, readBuckets, writeBuckets)
+	}
+	return conn, tracker, nil
+}
+
+type user struct {
+	name        string
+	access      sync.Mutex
+	inbounds    map[string]bool
+	options     option.UserLimitOptions
+	upload      *ratelimit.Bucket
+	download    *ratelimit.Bucket
+	connections map[*connectionTracker]netip.Addr
+	addresses   map[netip.Addr]int
+
+	dailyStart   time.Time
+	dailyBytes   int64
+	monthlyStart time.Time
+	monthlyBytes int64
+	exceeded     bool
+
+	// pendingBytes is the traffic not yet added to the quotas, which is added
+	// once it reaches checkBytes, at most the traffic left in the quotas.
+	pendingBytes atomic.Int64
+	checkBytes   atomic.Int64
+}
+
+func newUser(name string, inbound string, options option.UserLimitOptions) *user {
+	limitedUser := &user{
+		name:        name,
+		inbounds:    map[string]bool{inbound: true},
+		connections: make(map[*connectionTracker]netip.Addr),
+		addresses:   make(map[netip.Addr]int),
+	}
+	limitedUser.update(options)
+	now := time.Now()
+	limitedUser.dailyStart = dayStart(now)
+	limitedUser.monthlyStart = monthStart(now)
+	return limitedUser
+}
+
+func (u *user) update(options option.UserLimitOptions) {
+	u.access.Lock()
+	defer u.access.Unlock()
+	if u.options.UpMbps != options.UpMbps {
+		u.upload = nil
+		if options.UpMbps > 0 {
+			u.upload = ratelimit.NewBucket(int64(options.UpMbps)*C.MbpsToBps, 0)
+		}
+	}
+	if u.options.DownMbps != options.DownMbps {
+		u.download = nil
+		if options.DownMbps > 0 {
+			u.download = ratelimit.NewBucket(int64(options.DownMbps)*C.MbpsToBps, 0)
+		}
+	}
+	u.options = options
+	u.exceeded = false
+	u.resetCheckBytes()
+}
+
+func (u *user) restore(usage *adapter.SavedUserUsage) {
+	if usage == nil {
+		return
+	}
+	u.access.Lock()
+	defer u.access.Unlock()
+	if usage.DailyStart.Equal(u.dailyStart) {
+		u.dailyBytes += usage.DailyBytes
+	}
+	if usage.MonthlyStart.Equal(u.monthlyStart) {
+		u.monthlyBytes += usage.MonthlyBytes
+	}
+	u.resetCheckBytes()
+}
+
+func (u *user) usage() *adapter.SavedUserUsage {
+	u.flush()
+	u.access.Lock()
+	defer u.access.Unlock()
+	if u.options.DailyQuota == 0 && u.options.MonthlyQuota == 0 {
+		return nil
+	}
+	return &adapter.SavedUserUsage{
+		DailyStart:   u.dailyStart,
+		DailyBytes:   u.dailyBytes,
+		MonthlyStart: u.monthlyStart,
+		MonthlyBytes: u.monthlyBytes,
+	}
+}
+
+func (u *user) buckets() (readBuckets []*ratelimit.Bucket, writeBuckets []*ratelimit.Bucket) {
+	u.access.Lock()
+	defer u.access.Unlock()
+	if u.upload != nil {
+		readBuckets = append(readBuckets, u.upload)
+	}
+	if u.download != nil {
+		writeBuckets = append(writeBuckets, u.download)
+	}
+	return
+}
+
+func (u *user) join(address netip.Addr, conn any) (*connectionTracker, error) {
+	u.access.Lock()
+	defer u.access.Unlock()
+	if u.options.MaxConnections > 0 && len(u.connections) >= u.options.MaxConnections {
+		return nil, E.New("user ", u.name, " exceeded max connections: ", u.options.MaxConnections)
+	}
+	address = address.Unmap()
+	if u.options.MaxIPs > 0 && u.addresses[address] == 0 && len(u.addresses) >= u.options.MaxIPs {
+		return nil, E.New("user ", u.name, " exceeded max IPs: ", u.options.MaxIPs)
+	}
+	tracker := &connectionTracker{user: u, conn: conn}
+	u.connections[tracker] = address
+	u.addresses[address]++
+	return tracker, nil
+}
+
+func (u *user) leave(tracker *connectionTracker) {
+	u.access.Lock()
+	defer u.access.Unlock()
+	address, loaded := u.connections[tracker]
+	if !loaded {
+		return
+	}
+	delete(u.connections, tracker)
+	u.addresses[address]--
+	if u.addresses[address] <= 0 {
+		delete(u.addresses, address)
+	}
+}
+
+func (u *user) checkQuota() error {
+	u.access.Lock()
+	connections := u.flush0(time.Now())
+	err := u.quotaError()
+	u.access.Unlock()
+	closeConnections(connections)
+	return err
+}
+
+func (u *user) quotaError() error {
+	if u.options.DailyQuota > 0 && u.dailyBytes >= int64(u.options.DailyQuota) {
+		return E.New("user ", u.name, " exceeded daily quota")
+	}
+	if u.options.MonthlyQuota > 0 && u.monthlyBytes >= int64(u.options.MonthlyQuota) {
+		return E.New("user ", u.name, " exceeded monthly quota")
+	}
+	return nil
+}
+
+func (u *user) rollover(now time.Time) {
+	if currentDay := dayStart(now); !currentDay.Equal(u.dailyStart) {
+		u.dailyStart = currentDay
+		u.dailyBytes = 0
+		u.exceeded = false
+	}
+	if currentMonth := monthStart(now); !currentMonth.Equal(u.monthlyStart) {
+		u.monthlyStart = currentMonth
+		u.monthlyBytes = 0
+		u.exceeded = false
+	}
+}
+
+// count adds traffic in both directions to the pending traffic, which is
+// added to the quotas once it may exceed them.
+func (u *user) count(n int64) {
+	if u.pendingBytes.Add(n) >= u.checkBytes.Load() {
+		u.flush()
+	}
+}
+
+// flush adds the pending traffic to the quotas, and disconnects the user once
+// a quota is exceeded.
+func (u *user) flush() {
+	u.access.Lock()
+	connections := u.flush0(time.Now())
+	u.access.Unlock()
+	closeConnections(connections)
+}
+
+func (u *user) flush0(now time.Time) []any {
+	u.rollover(now)
+	n := u.pendingBytes.Swap(0)
+	u.dailyBytes += n
+	u.monthlyBytes += n
+	u.resetCheckBytes()
+	if u.exceeded || u.quotaError() == nil {
+		return nil
+	}
+	u.exceeded = true
+	var connections []any
+	for tracker := range u.connections {
+		connections = append(connections, tracker.conn)
+	}
+	return connections
+}
+
+func (u *user) resetCheckBytes() {
+	checkBytes := int64(math.MaxInt64)
+	if u.options.DailyQuota > 0 || u.options.MonthlyQuota > 0 {
+		checkBytes = quotaCheckBytes
+		if left := int64(u.options.DailyQuota) - u.dailyBytes; u.options.DailyQuota > 0 && left > 0 && left < checkBytes {
+			checkBytes = left
+		}
+		if left := int64(u.options.MonthlyQuota) - u.monthlyBytes; u.options.MonthlyQuota > 0 && left > 0 && left < checkBytes {
+			checkBytes = left
+		}
+	}
+	u.checkBytes.Store(checkBytes)
+}
+
+func closeConnections(connections []any) {
+	if len(connections) > 0 {
+		go common.Close(connections...)
+	}
+}
+
+func dayStart(now time.Time) time.Time {
+	year, month, day := now.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+}
+
+func monthStart(now time.Time) time.Time {
+	year, month, _ := now.Date()
+	return time.Date(year, month, 1, 0, 0, 0, 0, now.Location())
+}
+
+type connectionTracker struct {
+	user *user
+	conn any
+}
+
+func (t *connectionTracker) Leave() {
+	t.user.leave(t)
+}
+
+type nopTracker struct{}
+
+func (nopTracker) Leave() {}
@@ -0,0 +1,204 @@
+package userlimit
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/service"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testConn struct {
+	closed chan struct{}
+}
+
+func newTestConn() *testConn {
+	return &testConn{closed: make(chan struct{})}
+}
+
+func (c *testConn) Close() error {
+	close(c.closed)
+	return nil
+}
+
+func TestUserRollover(t *testing.T) {
+	t.Parallel()
+	limitedUser := newUser("user", "in", option.UserLimitOptions{DailyQuota: 10, MonthlyQuota: 100})
+	now := time.Date(2024, 1, 31, 12, 0, 0, 0, time.Local)
+	limitedUser.dailyStart = dayStart(now)
+	limitedUser.monthlyStart = monthStart(now)
+	limitedUser.dailyBytes = 10
+	limitedUser.monthlyBytes = 50
+	limitedUser.exceeded = true
+
+	limitedUser.rollover(now.Add(time.Hour))
+	require.Equal(t, int64(10), limitedUser.dailyBytes)
+	require.True(t, limitedUser.exceeded)
+
+	nextDay := now.Add(12 * time.Hour)
+	limitedUser.rollover(nextDay)
+	require.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.Local), limitedUser.dailyStart)
+	require.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.Local), limitedUser.monthlyStart)
+	require.Zero(t, limitedUser.dailyBytes)
+	require.Zero(t, limitedUser.monthlyBytes)
+	require.False(t, limitedUser.exceeded)
+
+	limitedUser.monthlyBytes = 50
+	limitedUser.rollover(nextDay.Add(24 * time.Hour))
+	require.Equal(t, time.Date(2024, 2, 2, 0, 0, 0, 0, time.Local), limitedUser.dailyStart)
+	require.Equal(t, int64(50), limitedUser.monthlyBytes)
+}
+
+func TestUserQuotaError(t *testing.T) {
+	t.Parallel()
+	limitedUser := newUser("user", "in", option.UserLimitOptions{DailyQuota: 10, MonthlyQuota: 100})
+	require.NoError(t, limitedUser.quotaError())
+	limitedUser.dailyBytes = 10
+	require.ErrorContains(t, limitedUser.quotaError(), "daily quota")
+	limitedUser.dailyBytes = 0
+	limitedUser.monthlyBytes = 100
+	require.ErrorContains(t, limitedUser.quotaError(), "monthly quota")
+
+	unlimitedUser := newUser("user", "in", option.UserLimitOptions{MaxIPs: 1})
+	unlimitedUser.dailyBytes = 1 << 40
+	unlimitedUser.monthlyBytes = 1 << 40
+	require.NoError(t, unlimitedUser.quotaError())
+}
+
+func TestUserCount(t *testing.T) {
+	t.Parallel()
+	limitedUser := newUser("user", "in", option.UserLimitOptions{DailyQuota: quotaCheckBytes * 3})
+	conn := newTestConn()
+	_, err := limitedUser.join(netip.MustParseAddr("1.1.1.1"), conn)
+	require.NoError(t, err)
+
+	// traffic is added to the quota only after a threshold
+	limitedUser.count(1)
+	require.Zero(t, limitedUser.dailyBytes)
+	limitedUser.count(quotaCheckBytes)
+	require.Equal(t, int64(quotaCheckBytes+1), limitedUser.dailyBytes)
+	require.NoError(t, limitedUser.checkQuota())
+
+	// the threshold is lowered to the traffic left in the quota
+	limitedUser.count(quotaCheckBytes)
+	require.Equal(t, int64(quotaCheckBytes*2+1), limitedUser.dailyBytes)
+	require.Equal(t, int64(quotaCheckBytes-1), limitedUser.checkBytes.Load())
+	limitedUser.count(quotaCheckBytes - 2)
+	require.Equal(t, int64(quotaCheckBytes*2+1), limitedUser.dailyBytes)
+	limitedUser.count(1)
+	select {
+	case <-conn.closed:
+	case <-time.After(time.Second):
+		t.Fatal("connection not closed after exceeding quota")
+	}
+	require.ErrorContains(t, limitedUser.checkQuota(), "daily quota")
+
+	usage := limitedUser.usage()
+	require.Equal(t, int64(quotaCheckBytes*3), usage.DailyBytes)
+	require.Equal(t, int64(quotaCheckBytes*3), usage.MonthlyBytes)
+}
+
+func TestUserMaxConnections(t *testing.T) {
+	t.Parallel()
+	limitedUser := newUser("user", "in", option.UserLimitOptions{MaxConnections: 2})
+	address := netip.MustParseAddr("1.1.1.1")
+	first, err := limitedUser.join(address, nil)
+	require.NoError(t, err)
+	_, err = limitedUser.join(address, nil)
+	require.NoError(t, err)
+	_, err = limitedUser.join(address, nil)
+	require.ErrorContains(t, err, "max connections")
+	first.Leave()
+	first.Leave()
+	_, err = limitedUser.join(address, nil)
+	require.NoError(t, err)
+	_, err = limitedUser.join(address, nil)
+	require.ErrorContains(t, err, "max connections")
+}
+
+func TestUserMaxIPs(t *testing.T) {
+	t.Parallel()
+	limitedUser := newUser("user", "in", option.UserLimitOptions{MaxIPs: 1})
+	first, err := limitedUser.join(netip.MustParseAddr("1.1.1.1"), nil)
+	require.NoError(t, err)
+	second, err := limitedUser.join(netip.MustParseAddr("::ffff:1.1.1.1"), nil)
+	require.NoError(t, err)
+	_, err = limitedUser.join(netip.MustParseAddr("2.2.2.2"), nil)
+	require.ErrorContains(t, err, "max IPs")
+	first.Leave()
+	_, err = limitedUser.join(netip.MustParseAddr("2.2.2.2"), nil)
+	require.ErrorContains(t, err, "max IPs")
+	second.Leave()
+	_, err = limitedUser.join(netip.MustParseAddr("2.2.2.2"), nil)
+	require.NoError(t, err)
+}
+
+type testCacheFile struct {
+	adapter.CacheFile
+	usages map[string]*adapter.SavedUserUsage
+}
+
+func (f *testCacheFile) LoadUserUsage(user string) *adapter.SavedUserUsage {
+	return f.usages[user]
+}
+
+func (f *testCacheFile) SaveUserUsage(usages map[string]*adapter.SavedUserUsage) error {
+	f.usages = usages
+	return nil
+}
+
+func TestManagerRestore(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	cacheFile := &testCacheFile{usages: map[string]*adapter.SavedUserUsage{
+		"current": {DailyStart: dayStart(now), DailyBytes: 10, MonthlyStart: monthStart(now), MonthlyBytes: 20},
+		"expired": {DailyStart: dayStart(now).AddDate(0, 0, -1), DailyBytes: 10, MonthlyStart: monthStart(now).AddDate(0, -1, 0), MonthlyBytes: 20},
+		"late":    {DailyStart: dayStart(now), DailyBytes: 30, MonthlyStart: monthStart(now), MonthlyBytes: 40},
+	}}
+	ctx := service.ContextWithDefaultRegistry(context.Background())
+	service.MustRegister[adapter.CacheFile](ctx, cacheFile)
+	manager := NewManager(ctx, log.NewNOPFactory().Logger())
+	options := option.UserLimitOptions{DailyQuota: 100, MonthlyQuota: 100}
+	require.NoError(t, manager.Register("in", "current", options))
+	require.NoError(t, manager.Register("in", "expired", options))
+	require.NoError(t, manager.PreStart())
+	// users registered later, e.g. by the user API, are restored on register
+	require.NoError(t, manager.Register("in", "late", options))
+
+	require.Equal(t, int64(10), manager.users["current"].dailyBytes)
+	require.Equal(t, int64(20), manager.users["current"].monthlyBytes)
+	require.Zero(t, manager.users["expired"].dailyBytes)
+	require.Zero(t, manager.users["expired"].monthlyBytes)
+	require.Equal(t, int64(30), manager.users["late"].dailyBytes)
+
+	require.NoError(t, manager.Start())
+	manager.users["current"].count(5)
+	require.NoError(t, manager.Close())
+	require.Equal(t, int64(15), cacheFile.usages["current"].DailyBytes)
+	require.Equal(t, int64(25), cacheFile.usages["current"].MonthlyBytes)
+}
+
+func TestManagerUnregister(t *testing.T) {
+	t.Parallel()
+	manager := NewManager(context.Background(), log.NewNOPFactory().Logger())
+	options := option.UserLimitOptions{MaxConnections: 1}
+	require.NoError(t, manager.Register("a", "user", options))
+	require.NoError(t, manager.Register("b", "user", options))
+	require.ErrorContains(t, manager.Check("a", "user", option.UserLimitOptions{MaxConnections: 2}), "inbound b")
+	require.ErrorContains(t, manager.Register("a", "user", option.UserLimitOptions{MaxConnections: 2}), "inbound b")
+
+	// the user is kept while another inbound has it
+	manager.Unregister("b", "user")
+	require.NotNil(t, manager.loadUser("user"))
+	require.NoError(t, manager.Check("a", "user", option.UserLimitOptions{MaxConnections: 2}))
+	require.NoError(t, manager.Register("a", "user", option.UserLimitOptions{MaxConnections: 2}))
+	require.Equal(t, 2, manager.loadUser("user").options.MaxConnections)
+	manager.Unregister("a", "user")
+	require.Nil(t, manager.loadUser("user"))
+}
@@ -0,0 +1,6 @@
+package constant
+
+const (
+	ProviderTypeLocal  = "local"
+	ProviderTypeRemote = "remote"
+)
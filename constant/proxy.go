@@ -26,8 +26,18 @@ const (
 )
 
 const (
-	TypeSelector = "selector"
-	TypeURLTest  = "urltest"
+	TypeSelector    = "selector"
+	TypeURLTest     = "urltest"
+	TypeFallback    = "fallback"
+	TypeLoadBalance = "load_balance"
+	TypeChain       = "chain"
+	TypeLimiter     = "limiter"
+)
+
+const (
+	LoadBalanceStrategyRoundRobin        = "round_robin"
+	LoadBalanceStrategyConsistentHashing = "consistent_hashing"
+	LoadBalanceStrategyStickySessions    = "sticky_sessions"
 )
 
 func ProxyDisplayName(proxyType string) string {
@@ -72,6 +82,14 @@ func ProxyDisplayName(proxyType string) string {
 		return "Selector"
 	case TypeURLTest:
 		return "URLTest"
+	case TypeFallback:
+		return "Fallback"
+	case TypeLoadBalance:
+		return "LoadBalance"
+	case TypeChain:
+		return "Chain"
+	case TypeLimiter:
+		return "Limiter"
 	default:
 		return "Unknown"
 	}
@@ -5,15 +5,39 @@ const (
 	RuleTypeLogical = "logical"
 )
 
+const (
+	RuleActionTypeRoute        = "route"
+	RuleActionTypeRouteOptions = "route-options"
+	RuleActionTypeReject       = "reject"
+	RuleActionTypeHijackDNS    = "hijack-dns"
+	RuleActionTypeSniff        = "sniff"
+	RuleActionTypeResolve      = "resolve"
+)
+
+const (
+	RuleActionRejectMethodDefault         = "default"
+	RuleActionRejectMethodDrop            = "drop"
+	RuleActionRejectMethodReset           = "reset"
+	RuleActionRejectMethodICMPUnreachable = "icmp-unreachable"
+)
+
 const (
 	LogicalTypeAnd = "and"
 	LogicalTypeOr  = "or"
 )
 
 const (
-	RuleSetTypeLocal    = "local"
-	RuleSetTypeRemote   = "remote"
-	RuleSetVersion1     = 1
-	RuleSetFormatSource = "source"
-	RuleSetFormatBinary = "binary"
+	RuleSetTypeInline           = "inline"
+	RuleSetTypeLocal            = "local"
+	RuleSetTypeRemote           = "remote"
+	RuleSetVersion1             = 1
+	RuleSetVersion2             = 2
+	RuleSetFormatSource         = "source"
+	RuleSetFormatBinary         = "binary"
+	RuleSetFormatClashDomain    = "clash-domain"
+	RuleSetFormatClashIPCIDR    = "clash-ipcidr"
+	RuleSetFormatClashClassical = "clash-classical"
+	RuleSetFormatAdGuard        = "adguard"
+	RuleSetFormatHosts          = "hosts"
+	RuleSetFormatDnsmasq        = "dnsmasq"
 )
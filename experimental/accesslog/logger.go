@@ -0,0 +1,222 @@
+package accesslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/atomic"
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+	N "github.com/sagernet/sing/common/network"
+)
+
+const (
+	formatJSON = "json"
+	formatCSV  = "csv"
+)
+
+var csvHeader = []string{
+	"time", "start", "duration_ms", "network", "inbound", "inbound_type", "user", "process",
+	"source", "destination", "domain", "protocol", "rule", "outbound", "chain",
+	"upload", "download", "close_reason",
+}
+
+var _ adapter.AccessLogger = (*Logger)(nil)
+
+// Logger writes one record for each finished connection.
+type Logger struct {
+	router adapter.Router
+	logger log.Logger
+	format string
+	writer *log.FileWriter
+}
+
+type Record struct {
+	Time        time.Time `json:"time"`
+	Start       time.Time `json:"start"`
+	Duration    int64     `json:"duration_ms"`
+	Network     string    `json:"network"`
+	Inbound     string    `json:"inbound,omitempty"`
+	InboundType string    `json:"inbound_type,omitempty"`
+	User        string    `json:"user,omitempty"`
+	Process     string    `json:"process,omitempty"`
+	Source      string    `json:"source,omitempty"`
+	Destination string    `json:"destination,omitempty"`
+	Domain      string    `json:"domain,omitempty"`
+	Protocol    string    `json:"protocol,omitempty"`
+	Rule        string    `json:"rule"`
+	Outbound    string    `json:"outbound"`
+	Chain       []string  `json:"chain"`
+	Upload      int64     `json:"upload"`
+	Download    int64     `json:"download"`
+	CloseReason string    `json:"close_reason"`
+}
+
+func NewLogger(ctx context.Context, router adapter.Router, logger log.Logger, options option.AccessLogOptions) (*Logger, error) {
+	if options.Path == "" {
+		return nil, E.New("missing path")
+	}
+	fileOptions := log.FileOptions{
+		MaxSize:    int64(options.MaxSize),
+		MaxAge:     time.Duration(options.MaxAge),
+		MaxBackups: options.MaxBackups,
+		Compress:   options.Compress,
+	}
+	switch options.Format {
+	case "", formatJSON:
+		options.Format = formatJSON
+	case formatCSV:
+		fileOptions.Header = formatCSVRecord(csvHeader)
+	default:
+		return nil, E.New("unknown access log format: ", options.Format)
+	}
+	return &Logger{
+		router: router,
+		logger: logger,
+		format: options.Format,
+		writer: log.NewFileWriter(ctx, options.Path, fileOptions),
+	}, nil
+}
+
+func (l *Logger) Start() error {
+	return l.writer.Start()
+}
+
+func (l *Logger) Close() error {
+	return l.writer.Close()
+}
+
+// Reopen reopens the access log file after it was moved by an external tool.
+func (l *Logger) Reopen() error {
+	return l.writer.Reopen()
+}
+
+func (l *Logger) RoutedConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, matchedRule adapter.Rule, outbound adapter.Outbound) (net.Conn, adapter.FinishTracker) {
+	tracker := l.newTracker(metadata, matchedRule, outbound)
+	return bufio.NewInt64CounterConn(conn, []*atomic.Int64{&tracker.upload}, []*atomic.Int64{&tracker.download}), tracker
+}
+
+func (l *Logger) RoutedPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext, matchedRule adapter.Rule, outbound adapter.Outbound) (N.PacketConn, adapter.FinishTracker) {
+	tracker := l.newTracker(metadata, matchedRule, outbound)
+	return bufio.NewInt64CounterPacketConn(conn, []*atomic.Int64{&tracker.upload}, []*atomic.Int64{&tracker.download}), tracker
+}
+
+func (l *Logger) newTracker(metadata adapter.InboundContext, matchedRule adapter.Rule, outbound adapter.Outbound) *tracker {
+	record := Record{
+		Start:       time.Now(),
+		Network:     metadata.Network,
+		Inbound:     metadata.Inbound,
+		InboundType: metadata.InboundType,
+		User:        metadata.User,
+		Domain:      metadata.Domain,
+		Protocol:    metadata.Protocol,
+	}
+	if metadata.ProcessInfo != nil {
+		if metadata.ProcessInfo.ProcessPath != "" {
+			record.Process = metadata.ProcessInfo.ProcessPath
+		} else {
+			record.Process = metadata.ProcessInfo.PackageName
+		}
+	}
+	if metadata.Source.IsValid() {
+		record.Source = metadata.Source.String()
+	}
+	if metadata.Destination.IsValid() {
+		record.Destination = metadata.Destination.String()
+	}
+	if matchedRule != nil {
+		record.Rule = matchedRule.String()
+	} else {
+		record.Rule = "final"
+	}
+	record.Chain = adapter.OutboundPath(l.router, outbound.Tag())
+	record.Outbound = record.Chain[len(record.Chain)-1]
+	return &tracker{logger: l, record: record}
+}
+
+func (l *Logger) write(record *Record) {
+	var content []byte
+	if l.format == formatCSV {
+		content = formatCSVRecord([]string{
+			record.Time.Format(time.RFC3339Nano),
+			record.Start.Format(time.RFC3339Nano),
+			strconv.FormatInt(record.Duration, 10),
+			record.Network,
+			record.Inbound,
+			record.InboundType,
+			record.User,
+			record.Process,
+			record.Source,
+			record.Destination,
+			record.Domain,
+			record.Protocol,
+			record.Rule,
+			record.Outbound,
+			strings.Join(record.Chain, ">"),
+			strconv.FormatInt(record.Upload, 10),
+			strconv.FormatInt(record.Download, 10),
+			record.CloseReason,
+		})
+	} else {
+		var err error
+		content, err = json.Marshal(record)
+		if err != nil {
+			l.logger.Error("encode access log: ", err)
+			return
+		}
+		content = append(content, '\n')
+	}
+	_, err := l.writer.Write(content)
+	if err != nil {
+		l.logger.Error("write access log: ", err)
+	}
+}
+
+func formatCSVRecord(fields []string) []byte {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	writer.Write(fields)
+	writer.Flush()
+	return buffer.Bytes()
+}
+
+type tracker struct {
+	logger   *Logger
+	record   Record
+	once     sync.Once
+	upload   atomic.Int64
+	download atomic.Int64
+}
+
+func (t *tracker) Finish(err error) {
+	t.once.Do(func() {
+		record := t.record
+		record.Time = time.Now()
+		record.Duration = record.Time.Sub(record.Start).Milliseconds()
+		record.Upload = t.upload.Load()
+		record.Download = t.download.Load()
+		record.CloseReason = closeReason(err)
+		t.logger.write(&record)
+	})
+}
+
+func closeReason(err error) string {
+	switch {
+	case err == nil, E.IsClosedOrCanceled(err):
+		return "closed"
+	case E.IsTimeout(err):
+		return "timeout"
+	default:
+		return err.Error()
+	}
+}
@@ -24,12 +24,17 @@ var (
 	bucketMode     = []byte("clash_mode")
 	bucketRuleSet  = []byte("rule_set")
 
+	bucketOutboundProvider = []byte("outbound_provider")
+
 	bucketNameList = []string{
 		string(bucketSelected),
 		string(bucketExpand),
 		string(bucketMode),
 		string(bucketRuleSet),
+		string(bucketOutboundProvider),
 		string(bucketRDRC),
+		string(bucketDNS),
+		string(bucketUserUsage),
 	}
 
 	cacheIDDefault = []byte("default")
@@ -44,6 +49,7 @@ type CacheFile struct {
 	storeFakeIP       bool
 	storeRDRC         bool
 	rdrcTimeout       time.Duration
+	storeDNS          bool
 	DB                *bbolt.DB
 	saveMetadataTimer *time.Timer
 	saveFakeIPAccess  sync.RWMutex
@@ -85,6 +91,7 @@ func New(ctx context.Context, options option.CacheFileOptions) *CacheFile {
 		storeFakeIP:  options.StoreFakeIP,
 		storeRDRC:    options.StoreRDRC,
 		rdrcTimeout:  rdrcTimeout,
+		storeDNS:     options.StoreDNS,
 		saveDomain:   make(map[netip.Addr]string),
 		saveAddress4: make(map[string]netip.Addr),
 		saveAddress6: make(map[string]netip.Addr),
@@ -312,3 +319,36 @@ func (c *CacheFile) SaveRuleSet(tag string, set *adapter.SavedRuleSet) error {
 		return bucket.Put([]byte(tag), setBinary)
 	})
 }
+
+func (c *CacheFile) LoadOutboundProvider(tag string) *adapter.SavedOutboundProvider {
+	var savedProvider adapter.SavedOutboundProvider
+	err := c.DB.View(func(t *bbolt.Tx) error {
+		bucket := c.bucket(t, bucketOutboundProvider)
+		if bucket == nil {
+			return os.ErrNotExist
+		}
+		providerBinary := bucket.Get([]byte(tag))
+		if len(providerBinary) == 0 {
+			return os.ErrInvalid
+		}
+		return savedProvider.UnmarshalBinary(providerBinary)
+	})
+	if err != nil {
+		return nil
+	}
+	return &savedProvider
+}
+
+func (c *CacheFile) SaveOutboundProvider(tag string, provider *adapter.SavedOutboundProvider) error {
+	return c.DB.Batch(func(t *bbolt.Tx) error {
+		bucket, err := c.createBucket(t, bucketOutboundProvider)
+		if err != nil {
+			return err
+		}
+		providerBinary, err := provider.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(tag), providerBinary)
+	})
+}
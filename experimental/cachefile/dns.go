@@ -0,0 +1,75 @@
+package cachefile
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/sagernet/bbolt"
+	bboltErrors "github.com/sagernet/bbolt/errors"
+	"github.com/sagernet/sing-box/adapter"
+
+	mDNS "github.com/miekg/dns"
+)
+
+var bucketDNS = []byte("dns_cache")
+
+func (c *CacheFile) StoreDNS() bool {
+	return c.storeDNS
+}
+
+func (c *CacheFile) LoadDNSCache() []adapter.SavedDNSMessage {
+	var messages []adapter.SavedDNSMessage
+	c.DB.View(func(tx *bbolt.Tx) error {
+		bucket := c.bucket(tx, bucketDNS)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(key, content []byte) error {
+			if len(content) <= 8 {
+				return nil
+			}
+			var message mDNS.Msg
+			err := message.Unpack(content[8:])
+			if err != nil || len(message.Question) != 1 {
+				return nil
+			}
+			messages = append(messages, adapter.SavedDNSMessage{
+				Message:  &message,
+				ExpireAt: time.Unix(int64(binary.BigEndian.Uint64(content)), 0),
+			})
+			return nil
+		})
+	})
+	return messages
+}
+
+func (c *CacheFile) SaveDNSCache(messages []adapter.SavedDNSMessage) error {
+	return c.DB.Batch(func(tx *bbolt.Tx) error {
+		var err error
+		if c.cacheID == nil {
+			err = tx.DeleteBucket(bucketDNS)
+		} else if parent := tx.Bucket(c.cacheID); parent != nil {
+			err = parent.DeleteBucket(bucketDNS)
+		}
+		if err != nil && err != bboltErrors.ErrBucketNotFound {
+			return err
+		}
+		bucket, err := c.createBucket(tx, bucketDNS)
+		if err != nil {
+			return err
+		}
+		for _, saved := range messages {
+			question := saved.Message.Question[0]
+			content, err := saved.Message.Pack()
+			if err != nil {
+				continue
+			}
+			key := binary.BigEndian.AppendUint16([]byte(question.Name), question.Qtype)
+			err = bucket.Put(key, append(binary.BigEndian.AppendUint64(nil, uint64(saved.ExpireAt.Unix())), content...))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,54 @@
+package cachefile
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/sagernet/bbolt"
+	"github.com/sagernet/sing-box/adapter"
+)
+
+var bucketUserUsage = []byte("user_usage")
+
+func (c *CacheFile) LoadUserUsage(user string) *adapter.SavedUserUsage {
+	var usage *adapter.SavedUserUsage
+	c.DB.View(func(tx *bbolt.Tx) error {
+		bucket := c.bucket(tx, bucketUserUsage)
+		if bucket == nil {
+			return nil
+		}
+		content := bucket.Get([]byte(user))
+		if len(content) != 32 {
+			return nil
+		}
+		usage = &adapter.SavedUserUsage{
+			DailyStart:   time.Unix(int64(binary.BigEndian.Uint64(content)), 0),
+			DailyBytes:   int64(binary.BigEndian.Uint64(content[8:])),
+			MonthlyStart: time.Unix(int64(binary.BigEndian.Uint64(content[16:])), 0),
+			MonthlyBytes: int64(binary.BigEndian.Uint64(content[24:])),
+		}
+		return nil
+	})
+	return usage
+}
+
+func (c *CacheFile) SaveUserUsage(usages map[string]*adapter.SavedUserUsage) error {
+	return c.DB.Batch(func(tx *bbolt.Tx) error {
+		bucket, err := c.createBucket(tx, bucketUserUsage)
+		if err != nil {
+			return err
+		}
+		for user, usage := range usages {
+			content := make([]byte, 0, 32)
+			content = binary.BigEndian.AppendUint64(content, uint64(usage.DailyStart.Unix()))
+			content = binary.BigEndian.AppendUint64(content, uint64(usage.DailyBytes))
+			content = binary.BigEndian.AppendUint64(content, uint64(usage.MonthlyStart.Unix()))
+			content = binary.BigEndian.AppendUint64(content, uint64(usage.MonthlyBytes))
+			err = bucket.Put([]byte(user), content)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -1,9 +1,19 @@
 package clashapi
 
 import (
+	"context"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+	"github.com/sagernet/sing/service"
+	"github.com/sagernet/sing/service/filemanager"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
@@ -12,7 +22,7 @@ import (
 func configRouter(server *Server, logFactory log.Factory) http.Handler {
 	r := chi.NewRouter()
 	r.Get("/", getConfigs(server, logFactory))
-	r.Put("/", updateConfigs)
+	r.Put("/", updateConfigs(server))
 	r.Patch("/", patchConfigs(server))
 	return r
 }
@@ -63,6 +73,87 @@ func patchConfigs(server *Server) func(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func updateConfigs(w http.ResponseWriter, r *http.Request) {
-	render.NoContent(w, r)
+type updateConfigRequest struct {
+	Path    string `json:"path"`
+	Payload string `json:"payload"`
+}
+
+func updateConfigs(server *Server) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request updateConfigRequest
+		err := render.DecodeJSON(r.Body, &request)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, ErrBadRequest)
+			return
+		}
+		var content []byte
+		if request.Payload != "" {
+			content = []byte(request.Payload)
+		} else if request.Path != "" {
+			content, err = readConfig(server.ctx, request.Path)
+			if err != nil {
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, newError(err.Error()))
+				return
+			}
+		} else {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, newError("missing path or payload"))
+			return
+		}
+		options, err := json.UnmarshalExtended[option.Options](content)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, newError(err.Error()))
+			return
+		}
+		reloader := service.FromContext[adapter.Reloader](server.ctx)
+		if reloader == nil {
+			render.Status(r, http.StatusServiceUnavailable)
+			render.JSON(w, r, newError("reload unsupported"))
+			return
+		}
+		if server.secret == "" {
+			// without a secret, anyone reaching the API could open listeners
+			err = reloader.ReloadKeepInbounds(options)
+		} else {
+			err = reloader.Reload(options)
+		}
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, newError(err.Error()))
+			return
+		}
+		render.NoContent(w, r)
+	}
+}
+
+// readConfig reads a configuration file in the working directory.
+func readConfig(ctx context.Context, path string) ([]byte, error) {
+	workingDirectory := filemanager.BasePath(ctx, "")
+	if workingDirectory == "" {
+		var err error
+		workingDirectory, err = os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+	}
+	workingDirectory, err := filepath.EvalSymlinks(workingDirectory)
+	if err != nil {
+		return nil, err
+	}
+	path = filemanager.BasePath(ctx, path)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(workingDirectory, path)
+	}
+	path, err = filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, err
+	}
+	relativePath, err := filepath.Rel(workingDirectory, path)
+	if err != nil || relativePath == ".." || strings.HasPrefix(relativePath, ".."+string(filepath.Separator)) {
+		return nil, E.New("path is not in the working directory: ", path)
+	}
+	return os.ReadFile(path)
 }
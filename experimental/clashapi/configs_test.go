@@ -0,0 +1,110 @@
+package clashapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/service"
+	"github.com/sagernet/sing/service/filemanager"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testReloader struct {
+	options      []option.Options
+	keepInbounds []bool
+	err          error
+}
+
+func (r *testReloader) Reload(options option.Options) error {
+	r.options = append(r.options, options)
+	r.keepInbounds = append(r.keepInbounds, false)
+	return r.err
+}
+
+func (r *testReloader) ReloadKeepInbounds(options option.Options) error {
+	r.options = append(r.options, options)
+	r.keepInbounds = append(r.keepInbounds, true)
+	return r.err
+}
+
+func putConfigs(server *Server, body string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(body))
+	configRouter(server, nil).ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestUpdateConfigs(t *testing.T) {
+	t.Parallel()
+	reloader := &testReloader{}
+	workingDirectory := t.TempDir()
+	ctx := service.ContextWithDefaultRegistry(context.Background())
+	ctx = filemanager.WithDefault(ctx, workingDirectory, "", os.Getuid(), os.Getgid())
+	service.MustRegister[adapter.Reloader](ctx, reloader)
+	server := &Server{ctx: ctx, secret: "secret"}
+
+	response := putConfigs(server, `{"payload":"{\"outbounds\":[{\"type\":\"direct\",\"tag\":\"a\"}]}"}`)
+	require.Equal(t, http.StatusNoContent, response.Code)
+	require.Len(t, reloader.options, 1)
+	require.Equal(t, "a", reloader.options[0].Outbounds[0].Tag)
+
+	configPath := filepath.Join(workingDirectory, "config.json")
+	require.NoError(t, os.WriteFile(configPath, []byte(`{"outbounds":[{"type":"direct","tag":"b"}]}`), 0o644))
+	for _, path := range []string{"config.json", configPath} {
+		response = putConfigs(server, `{"path":"`+path+`"}`)
+		require.Equal(t, http.StatusNoContent, response.Code, path)
+		require.Equal(t, "b", reloader.options[len(reloader.options)-1].Outbounds[0].Tag)
+	}
+	require.Len(t, reloader.options, 3)
+	require.Equal(t, []bool{false, false, false}, reloader.keepInbounds)
+
+	// files outside the working directory are refused
+	outsidePath := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(outsidePath, []byte(`{}`), 0o644))
+	require.NoError(t, os.Symlink(outsidePath, filepath.Join(workingDirectory, "link.json")))
+	for _, body := range []string{
+		`invalid`,
+		`{}`,
+		`{"path":"nonexistent.json"}`,
+		`{"path":"` + outsidePath + `"}`,
+		`{"path":"../` + filepath.Base(filepath.Dir(outsidePath)) + `/config.json"}`,
+		`{"path":"link.json"}`,
+		`{"payload":"{\"outbounds\":[{\"type\":\"unknown\"}]}"}`,
+	} {
+		response = putConfigs(server, body)
+		require.Equal(t, http.StatusBadRequest, response.Code, body)
+	}
+	require.Len(t, reloader.options, 3)
+
+	reloader.err = E.New("reload failed")
+	response = putConfigs(server, `{"payload":"{}"}`)
+	require.Equal(t, http.StatusBadRequest, response.Code)
+	require.Contains(t, response.Body.String(), "reload failed")
+}
+
+func TestUpdateConfigsUnsupported(t *testing.T) {
+	t.Parallel()
+	server := &Server{ctx: service.ContextWithDefaultRegistry(context.Background())}
+	response := putConfigs(server, `{"payload":"{}"}`)
+	require.Equal(t, http.StatusServiceUnavailable, response.Code)
+}
+
+func TestUpdateConfigsWithoutSecret(t *testing.T) {
+	t.Parallel()
+	reloader := &testReloader{}
+	ctx := service.ContextWithDefaultRegistry(context.Background())
+	service.MustRegister[adapter.Reloader](ctx, reloader)
+	server := &Server{ctx: ctx}
+	response := putConfigs(server, `{"payload":"{}"}`)
+	require.Equal(t, http.StatusNoContent, response.Code)
+	require.Equal(t, []bool{true}, reloader.keepInbounds)
+}
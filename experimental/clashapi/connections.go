@@ -24,11 +24,32 @@ func connectionRouter(router adapter.Router, trafficManager *trafficontrol.Manag
 	return r
 }
 
+func parseConnectionFilter(r *http.Request) trafficontrol.Filter {
+	query := r.URL.Query()
+	return trafficontrol.Filter{
+		Host:     query.Get("host"),
+		Process:  query.Get("process"),
+		Rule:     query.Get("rule"),
+		Outbound: query.Get("outbound"),
+		User:     query.Get("user"),
+		Network:  query.Get("network"),
+	}
+}
+
 func getConnections(trafficManager *trafficontrol.Manager) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Header.Get("Upgrade") != "websocket" {
+		filter := parseConnectionFilter(r)
+		includeClosed := r.URL.Query().Get("closed") == "true"
+		takeSnapshot := func() *trafficontrol.Snapshot {
 			snapshot := trafficManager.Snapshot()
-			render.JSON(w, r, snapshot)
+			snapshot.Connections = filter.Filter(snapshot.Connections)
+			if includeClosed {
+				snapshot.ClosedConnections = filter.Filter(trafficManager.ClosedConnections())
+			}
+			return snapshot
+		}
+		if r.Header.Get("Upgrade") != "websocket" {
+			render.JSON(w, r, takeSnapshot())
 			return
 		}
 
@@ -53,8 +74,7 @@ func getConnections(trafficManager *trafficontrol.Manager) func(w http.ResponseW
 		buf := &bytes.Buffer{}
 		sendSnapshot := func() error {
 			buf.Reset()
-			snapshot := trafficManager.Snapshot()
-			if err := json.NewEncoder(buf).Encode(snapshot); err != nil {
+			if err := json.NewEncoder(buf).Encode(takeSnapshot()); err != nil {
 				return err
 			}
 			return wsutil.WriteServerText(conn, buf.Bytes())
@@ -80,7 +100,7 @@ func closeConnection(trafficManager *trafficontrol.Manager) func(w http.Response
 		snapshot := trafficManager.Snapshot()
 		for _, c := range snapshot.Connections {
 			if id == c.ID() {
-				c.Close()
+				trafficontrol.CloseByAPI(c)
 				break
 			}
 		}
@@ -90,11 +110,14 @@ func closeConnection(trafficManager *trafficontrol.Manager) func(w http.Response
 
 func closeAllConnections(router adapter.Router, trafficManager *trafficontrol.Manager) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		filter := parseConnectionFilter(r)
 		snapshot := trafficManager.Snapshot()
-		for _, c := range snapshot.Connections {
-			c.Close()
+		for _, c := range filter.Filter(snapshot.Connections) {
+			trafficontrol.CloseByAPI(c)
+		}
+		if filter.IsEmpty() {
+			router.ResetNetwork()
 		}
-		router.ResetNetwork()
 		render.NoContent(w, r)
 	}
 }
@@ -89,6 +89,9 @@ func getProxies(server *Server, router adapter.Router) func(w http.ResponseWrite
 		outbounds := common.Filter(router.Outbounds(), func(detour adapter.Outbound) bool {
 			return detour.Tag() != ""
 		})
+		for _, provider := range router.OutboundProviders() {
+			outbounds = append(outbounds, provider.Outbounds()...)
+		}
 
 		allProxies := make([]string, 0, len(outbounds))
 
@@ -4,6 +4,8 @@ import (
 	"net/http"
 
 	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/process"
+	M "github.com/sagernet/sing/common/metadata"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
@@ -12,6 +14,7 @@ import (
 func ruleRouter(router adapter.Router) http.Handler {
 	r := chi.NewRouter()
 	r.Get("/", getRules(router))
+	r.Get("/test", testRoute(router))
 	return r
 }
 
@@ -30,7 +33,7 @@ func getRules(router adapter.Router) func(w http.ResponseWriter, r *http.Request
 			rules = append(rules, Rule{
 				Type:    rule.Type(),
 				Payload: rule.String(),
-				Proxy:   rule.Outbound(),
+				Proxy:   rule.Action().String(),
 			})
 		}
 
@@ -39,3 +42,50 @@ func getRules(router adapter.Router) func(w http.ResponseWriter, r *http.Request
 		})
 	}
 }
+
+func testRoute(router adapter.Router) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		metadata := adapter.InboundContext{
+			Inbound:     query.Get("inbound"),
+			Network:     query.Get("network"),
+			Destination: M.ParseSocksaddr(query.Get("destination")),
+			Domain:      query.Get("domain"),
+			Protocol:    query.Get("protocol"),
+			User:        query.Get("user"),
+		}
+		if !metadata.Destination.IsValid() {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, newError("invalid destination"))
+			return
+		}
+		if source := query.Get("source"); source != "" {
+			metadata.Source = M.ParseSocksaddr(source)
+			if !metadata.Source.IsIP() {
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, newError("invalid source"))
+				return
+			}
+		}
+		if query.Has("process") || query.Has("process_user") {
+			metadata.ProcessInfo = &process.Info{
+				ProcessPath: query.Get("process"),
+				User:        query.Get("process_user"),
+				UserId:      -1,
+			}
+		}
+		if query.Has("wifi_ssid") || query.Has("wifi_bssid") {
+			metadata.WIFIState = &adapter.WIFIState{
+				SSID:  query.Get("wifi_ssid"),
+				BSSID: query.Get("wifi_bssid"),
+			}
+		}
+		trace, err := router.TraceRoute(r.Context(), metadata)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, newError(err.Error()))
+			return
+		}
+		render.JSON(w, r, trace)
+	}
+}
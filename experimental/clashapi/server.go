@@ -48,6 +48,7 @@ type Server struct {
 	mode           string
 	modeList       []string
 	modeUpdateHook chan<- struct{}
+	secret         string
 
 	externalController       bool
 	externalUI               string
@@ -68,6 +69,7 @@ func NewServer(ctx context.Context, router adapter.Router, logFactory log.Observ
 		},
 		trafficManager:           trafficManager,
 		modeList:                 options.ModeList,
+		secret:                   options.Secret,
 		externalController:       options.ExternalController != "",
 		externalUIDownloadURL:    options.ExternalUIDownloadURL,
 		externalUIDownloadDetour: options.ExternalUIDownloadDetour,
@@ -106,8 +108,8 @@ func NewServer(ctx context.Context, router adapter.Router, logFactory log.Observ
 		r.Mount("/proxies", proxyRouter(server, router))
 		r.Mount("/rules", ruleRouter(router))
 		r.Mount("/connections", connectionRouter(router, trafficManager))
-		r.Mount("/providers/proxies", proxyProviderRouter())
-		r.Mount("/providers/rules", ruleProviderRouter())
+		r.Mount("/providers/proxies", proxyProviderRouter(server, router))
+		r.Mount("/providers/rules", ruleProviderRouter(router))
 		r.Mount("/script", scriptRouter())
 		r.Mount("/profile", profileRouter())
 		r.Mount("/cache", cacheRouter(ctx))
@@ -267,6 +269,7 @@ func castMetadata(metadata adapter.InboundContext) trafficontrol.Metadata {
 		Host:        domain,
 		DNSMode:     "normal",
 		ProcessPath: processPath,
+		User:        metadata.User,
 	}
 }
 
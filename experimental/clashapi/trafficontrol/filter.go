@@ -0,0 +1,56 @@
+package trafficontrol
+
+import (
+	"strings"
+
+	"github.com/sagernet/sing/common"
+)
+
+// Filter selects connections by metadata. Host, process and rule match case-insensitive
+// substrings, outbound matches any outbound in the chain, empty fields match everything.
+type Filter struct {
+	Host     string
+	Process  string
+	Rule     string
+	Outbound string
+	User     string
+	Network  string
+}
+
+func (f Filter) IsEmpty() bool {
+	return f == Filter{}
+}
+
+func (f Filter) Match(t tracker) bool {
+	info := t.info()
+	if f.Host != "" && !containsFold(info.Metadata.Host, f.Host) && !containsFold(info.Metadata.DstIP.String(), f.Host) {
+		return false
+	}
+	if f.Process != "" && !containsFold(info.Metadata.ProcessPath, f.Process) {
+		return false
+	}
+	if f.Rule != "" && !containsFold(info.Rule, f.Rule) {
+		return false
+	}
+	if f.Outbound != "" && !common.Contains(info.Chain, f.Outbound) {
+		return false
+	}
+	if f.User != "" && info.Metadata.User != f.User {
+		return false
+	}
+	if f.Network != "" && info.Metadata.NetWork != f.Network {
+		return false
+	}
+	return true
+}
+
+func (f Filter) Filter(trackers []tracker) []tracker {
+	if f.IsEmpty() {
+		return trackers
+	}
+	return common.Filter(trackers, f.Match)
+}
+
+func containsFold(s string, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
@@ -2,12 +2,16 @@ package trafficontrol
 
 import (
 	"runtime"
+	"sync"
 	"time"
 
 	"github.com/sagernet/sing-box/experimental/clashapi/compatible"
 	"github.com/sagernet/sing/common/atomic"
 )
 
+// closedConnectionsSize bounds the history of closed connections kept for the API.
+const closedConnectionsSize = 512
+
 type Manager struct {
 	uploadTemp    atomic.Int64
 	downloadTemp  atomic.Int64
@@ -16,9 +20,12 @@ type Manager struct {
 	uploadTotal   atomic.Int64
 	downloadTotal atomic.Int64
 
-	connections compatible.Map[string, tracker]
-	ticker      *time.Ticker
-	done        chan struct{}
+	connections  compatible.Map[string, tracker]
+	closedAccess sync.Mutex
+	closed       []tracker
+	closedNext   int
+	ticker       *time.Ticker
+	done         chan struct{}
 	// process     *process.Process
 	memory uint64
 }
@@ -38,7 +45,29 @@ func (m *Manager) Join(c tracker) {
 }
 
 func (m *Manager) Leave(c tracker) {
-	m.connections.Delete(c.ID())
+	if _, loaded := m.connections.LoadAndDelete(c.ID()); !loaded {
+		return
+	}
+	c.info().markClosed()
+	closed := newClosedTracker(c)
+	m.closedAccess.Lock()
+	if len(m.closed) < closedConnectionsSize {
+		m.closed = append(m.closed, closed)
+	} else {
+		m.closed[m.closedNext] = closed
+	}
+	m.closedNext = (m.closedNext + 1) % closedConnectionsSize
+	m.closedAccess.Unlock()
+}
+
+// ClosedConnections returns recently closed connections, oldest first.
+func (m *Manager) ClosedConnections() []tracker {
+	m.closedAccess.Lock()
+	defer m.closedAccess.Unlock()
+	if len(m.closed) < closedConnectionsSize {
+		return append([]tracker(nil), m.closed...)
+	}
+	return append(append([]tracker(nil), m.closed[m.closedNext:]...), m.closed[:m.closedNext]...)
 }
 
 func (m *Manager) PushUploaded(size int64) {
@@ -114,8 +143,9 @@ func (m *Manager) Close() error {
 }
 
 type Snapshot struct {
-	DownloadTotal int64     `json:"downloadTotal"`
-	UploadTotal   int64     `json:"uploadTotal"`
-	Connections   []tracker `json:"connections"`
-	Memory        uint64    `json:"memory"`
+	DownloadTotal     int64     `json:"downloadTotal"`
+	UploadTotal       int64     `json:"uploadTotal"`
+	Connections       []tracker `json:"connections"`
+	ClosedConnections []tracker `json:"closedConnections,omitempty"`
+	Memory            uint64    `json:"memory"`
 }
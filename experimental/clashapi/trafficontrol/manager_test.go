@@ -0,0 +1,113 @@
+package trafficontrol
+
+import (
+	"encoding/json"
+	"net/netip"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing/common/atomic"
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTracker(manager *Manager, metadata Metadata, chain []string, rule string) *tcpTracker {
+	id, _ := uuid.NewV4()
+	tracker := &tcpTracker{
+		manager: manager,
+		trackerInfo: &trackerInfo{
+			UUID:          id,
+			Metadata:      metadata,
+			UploadTotal:   new(atomic.Int64),
+			DownloadTotal: new(atomic.Int64),
+			Start:         time.Now(),
+			Chain:         chain,
+			Rule:          rule,
+			closed:        new(trackerClosed),
+		},
+	}
+	manager.Join(tracker)
+	return tracker
+}
+
+func TestManagerClosedConnections(t *testing.T) {
+	t.Parallel()
+	manager := NewManager()
+	defer manager.Close()
+	var trackers []*tcpTracker
+	for i := 0; i < closedConnectionsSize+10; i++ {
+		tracker := newTestTracker(manager, Metadata{Host: strconv.Itoa(i)}, nil, "final")
+		tracker.UploadTotal.Store(int64(i))
+		trackers = append(trackers, tracker)
+		tracker.Leave()
+		if i == 0 {
+			require.Len(t, manager.ClosedConnections(), 1)
+		}
+	}
+	// leaving twice must not add the connection again
+	trackers[len(trackers)-1].Leave()
+	require.Zero(t, manager.Connections())
+
+	closed := manager.ClosedConnections()
+	require.Len(t, closed, closedConnectionsSize)
+	for i, it := range closed {
+		require.IsType(t, (*closedTracker)(nil), it)
+		require.Equal(t, trackers[i+10].ID(), it.ID())
+		require.Equal(t, int64(i+10), it.info().UploadTotal.Load())
+		require.NotSame(t, trackers[i+10].trackerInfo, it.info())
+	}
+
+	// the close reason may be set after leaving
+	trackers[len(trackers)-1].Finish(E.New("connection reset"))
+	content, err := json.Marshal(closed[len(closed)-1])
+	require.NoError(t, err)
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(content, &decoded))
+	require.Equal(t, "connection reset", decoded["closeReason"])
+	require.Equal(t, float64(len(trackers)-1), decoded["upload"])
+	require.NotNil(t, decoded["closedAt"])
+}
+
+func TestFilter(t *testing.T) {
+	t.Parallel()
+	manager := NewManager()
+	defer manager.Close()
+	trackers := []tracker{
+		newTestTracker(manager, Metadata{
+			NetWork:     "tcp",
+			Host:        "www.Example.com",
+			DstIP:       netip.MustParseAddr("1.1.1.1"),
+			ProcessPath: "/usr/bin/curl",
+			User:        "alice",
+		}, []string{"proxy", "select"}, "domain_suffix=example.com => select"),
+		newTestTracker(manager, Metadata{
+			NetWork: "udp",
+			DstIP:   netip.MustParseAddr("8.8.8.8"),
+		}, []string{"direct"}, "final"),
+	}
+	for _, testCase := range []struct {
+		filter  Filter
+		matched []int
+	}{
+		{Filter{}, []int{0, 1}},
+		{Filter{Host: "example.COM"}, []int{0}},
+		{Filter{Host: "8.8"}, []int{1}},
+		{Filter{Process: "CURL"}, []int{0}},
+		{Filter{Rule: "final"}, []int{1}},
+		{Filter{Outbound: "proxy"}, []int{0}},
+		{Filter{Outbound: "prox"}, nil},
+		{Filter{User: "alice"}, []int{0}},
+		{Filter{User: "Alice"}, nil},
+		{Filter{Network: "udp"}, []int{1}},
+		{Filter{Network: "tcp", Outbound: "direct"}, nil},
+	} {
+		var expected []tracker
+		for _, index := range testCase.matched {
+			expected = append(expected, trackers[index])
+		}
+		require.ElementsMatch(t, expected, testCase.filter.Filter(trackers), "%+v", testCase.filter)
+	}
+}
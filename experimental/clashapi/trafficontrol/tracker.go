@@ -3,12 +3,14 @@ package trafficontrol
 import (
 	"net"
 	"net/netip"
+	"sync"
 	"time"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing/common"
 	"github.com/sagernet/sing/common/atomic"
 	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/json"
 	N "github.com/sagernet/sing/common/network"
 
@@ -25,12 +27,14 @@ type Metadata struct {
 	Host        string     `json:"host"`
 	DNSMode     string     `json:"dnsMode"`
 	ProcessPath string     `json:"processPath"`
+	User        string     `json:"inboundUser"`
 }
 
 type tracker interface {
 	ID() string
 	Close() error
 	Leave()
+	info() *trackerInfo
 }
 
 type trackerInfo struct {
@@ -42,10 +46,20 @@ type trackerInfo struct {
 	Chain         []string      `json:"chains"`
 	Rule          string        `json:"rule"`
 	RulePayload   string        `json:"rulePayload"`
+
+	closed *trackerClosed
+}
+
+// trackerClosed is shared with the copy in the closed history, as the close
+// reason may be set after the connection left the manager.
+type trackerClosed struct {
+	access sync.Mutex
+	at     time.Time
+	reason string
 }
 
-func (t trackerInfo) MarshalJSON() ([]byte, error) {
-	return json.Marshal(map[string]any{
+func (t *trackerInfo) MarshalJSON() ([]byte, error) {
+	content := map[string]any{
 		"id":          t.UUID.String(),
 		"metadata":    t.Metadata,
 		"upload":      t.UploadTotal.Load(),
@@ -54,7 +68,92 @@ func (t trackerInfo) MarshalJSON() ([]byte, error) {
 		"chains":      t.Chain,
 		"rule":        t.Rule,
 		"rulePayload": t.RulePayload,
-	})
+	}
+	t.closed.access.Lock()
+	if !t.closed.at.IsZero() {
+		content["closedAt"] = t.closed.at
+		if t.closed.reason != "" {
+			content["closeReason"] = t.closed.reason
+		} else {
+			content["closeReason"] = "closed"
+		}
+	}
+	t.closed.access.Unlock()
+	return json.Marshal(content)
+}
+
+func (t *trackerInfo) info() *trackerInfo {
+	return t
+}
+
+// Finish records the error that ended the connection, unless a reason was
+// already set, e.g. by closing it through the API. The tracker may already be
+// in the closed history, as the connection is often closed before routing returns.
+func (t *trackerInfo) Finish(err error) {
+	switch {
+	case err == nil, E.IsClosedOrCanceled(err):
+		t.setCloseReason("closed")
+	case E.IsTimeout(err):
+		t.setCloseReason("timeout")
+	default:
+		t.setCloseReason(err.Error())
+	}
+}
+
+func (t *trackerInfo) setCloseReason(reason string) {
+	t.closed.access.Lock()
+	defer t.closed.access.Unlock()
+	if t.closed.reason == "" {
+		t.closed.reason = reason
+	}
+}
+
+// CloseByAPI closes the connection and records that it was closed on request.
+func CloseByAPI(t tracker) error {
+	t.info().setCloseReason("closed by api")
+	return t.Close()
+}
+
+func (t *trackerInfo) markClosed() {
+	t.closed.access.Lock()
+	defer t.closed.access.Unlock()
+	t.closed.at = time.Now()
+}
+
+// closedTracker is a detached copy of a closed connection kept in the history,
+// which does not reference the connection itself.
+type closedTracker struct {
+	*trackerInfo
+}
+
+func newClosedTracker(t tracker) *closedTracker {
+	info := t.info()
+	upload := new(atomic.Int64)
+	upload.Store(info.UploadTotal.Load())
+	download := new(atomic.Int64)
+	download.Store(info.DownloadTotal.Load())
+	return &closedTracker{&trackerInfo{
+		UUID:          info.UUID,
+		Metadata:      info.Metadata,
+		UploadTotal:   upload,
+		DownloadTotal: download,
+		Start:         info.Start,
+		Chain:         info.Chain,
+		Rule:          info.Rule,
+		RulePayload:   info.RulePayload,
+		closed:        info.closed,
+	}}
+}
+
+func (ct *closedTracker) ID() string {
+	return ct.UUID.String()
+}
+
+func (ct *closedTracker) Close() error {
+	return nil
+}
+
+func (ct *closedTracker) Leave() {
 }
 
 type tcpTracker struct {
@@ -91,7 +190,6 @@ func (tt *tcpTracker) WriterReplaceable() bool {
 func NewTCPTracker(conn net.Conn, manager *Manager, metadata Metadata, router adapter.Router, rule adapter.Rule) *tcpTracker {
 	uuid, _ := uuid.NewV4()
 
-	var chain []string
 	var next string
 	if rule == nil {
 		if defaultOutbound, err := router.DefaultOutbound(N.NetworkTCP); err == nil {
@@ -100,18 +198,7 @@ func NewTCPTracker(conn net.Conn, manager *Manager, metadata Metadata, router ad
 	} else {
 		next = rule.Outbound()
 	}
-	for {
-		chain = append(chain, next)
-		detour, loaded := router.Outbound(next)
-		if !loaded {
-			break
-		}
-		group, isGroup := detour.(adapter.OutboundGroup)
-		if !isGroup {
-			break
-		}
-		next = group.Now()
-	}
+	chain := adapter.OutboundPath(router, next)
 
 	upload := new(atomic.Int64)
 	download := new(atomic.Int64)
@@ -133,6 +220,7 @@ func NewTCPTracker(conn net.Conn, manager *Manager, metadata Metadata, router ad
 			Rule:          "",
 			UploadTotal:   upload,
 			DownloadTotal: download,
+			closed:        new(trackerClosed),
 		},
 	}
 
@@ -180,7 +268,6 @@ func (ut *udpTracker) WriterReplaceable() bool {
 func NewUDPTracker(conn N.PacketConn, manager *Manager, metadata Metadata, router adapter.Router, rule adapter.Rule) *udpTracker {
 	uuid, _ := uuid.NewV4()
 
-	var chain []string
 	var next string
 	if rule == nil {
 		if defaultOutbound, err := router.DefaultOutbound(N.NetworkUDP); err == nil {
@@ -189,18 +276,7 @@ func NewUDPTracker(conn N.PacketConn, manager *Manager, metadata Metadata, route
 	} else {
 		next = rule.Outbound()
 	}
-	for {
-		chain = append(chain, next)
-		detour, loaded := router.Outbound(next)
-		if !loaded {
-			break
-		}
-		group, isGroup := detour.(adapter.OutboundGroup)
-		if !isGroup {
-			break
-		}
-		next = group.Now()
-	}
+	chain := adapter.OutboundPath(router, next)
 
 	upload := new(atomic.Int64)
 	download := new(atomic.Int64)
@@ -222,6 +298,7 @@ func NewUDPTracker(conn N.PacketConn, manager *Manager, metadata Metadata, route
 			Rule:          "",
 			UploadTotal:   upload,
 			DownloadTotal: download,
+			closed:        new(trackerClosed),
 		},
 	}
 
@@ -18,8 +18,29 @@ func (c *CommandClient) ServiceReload() error {
 	if err != nil {
 		return err
 	}
+	return readReloadResult(conn)
+}
+
+func (c *CommandClient) ServiceReloadConfig(configContent string) error {
+	conn, err := c.directConnect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = binary.Write(conn, binary.BigEndian, uint8(CommandServiceReloadConfig))
+	if err != nil {
+		return err
+	}
+	err = rw.WriteVString(conn, configContent)
+	if err != nil {
+		return err
+	}
+	return readReloadResult(conn)
+}
+
+func readReloadResult(conn net.Conn) error {
 	var hasError bool
-	err = binary.Read(conn, binary.BigEndian, &hasError)
+	err := binary.Read(conn, binary.BigEndian, &hasError)
 	if err != nil {
 		return err
 	}
@@ -34,7 +55,22 @@ func (c *CommandClient) ServiceReload() error {
 }
 
 func (s *CommandServer) handleServiceReload(conn net.Conn) error {
-	rErr := s.handler.ServiceReload()
+	return writeReloadResult(conn, s.handler.ServiceReload())
+}
+
+func (s *CommandServer) handleServiceReloadConfig(conn net.Conn) error {
+	configContent, err := rw.ReadVString(conn)
+	if err != nil {
+		return err
+	}
+	service := s.service
+	if service == nil {
+		return writeReloadResult(conn, E.New("service not started"))
+	}
+	return writeReloadResult(conn, service.Reload(configContent))
+}
+
+func writeReloadResult(conn net.Conn, rErr error) error {
 	err := binary.Write(conn, binary.BigEndian, rErr != nil)
 	if err != nil {
 		return err
@@ -170,6 +170,8 @@ func (s *CommandServer) handleConnection(conn net.Conn) error {
 		return s.handleGetSystemProxyStatus(conn)
 	case CommandSetSystemProxyEnabled:
 		return s.handleSetSystemProxyEnabled(conn)
+	case CommandServiceReloadConfig:
+		return s.handleServiceReloadConfig(conn)
 	default:
 		return E.New("unknown command: ", command)
 	}
@@ -49,9 +49,12 @@ func (s *CommandServer) handleURLTest(conn net.Conn) error {
 	if !isOutboundGroup {
 		return writeError(conn, E.New("outbound is not a group: ", groupTag))
 	}
-	urlTest, isURLTest := abstractOutboundGroup.(*outbound.URLTest)
-	if isURLTest {
+	if urlTest, isURLTest := abstractOutboundGroup.(*outbound.URLTest); isURLTest {
 		go urlTest.CheckOutbounds()
+	} else if fallback, isFallback := abstractOutboundGroup.(*outbound.Fallback); isFallback {
+		go fallback.CheckOutbounds()
+	} else if loadBalance, isLoadBalance := abstractOutboundGroup.(*outbound.LoadBalance); isLoadBalance {
+		go loadBalance.CheckOutbounds()
 	} else {
 		historyStorage := service.PtrFromContext[urltest.HistoryStorage](serviceNow.ctx)
 		outbounds := common.Filter(common.Map(outboundGroup.All(), func(it string) adapter.Outbound {
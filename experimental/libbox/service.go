@@ -71,6 +71,14 @@ func (s *BoxService) Start() error {
 	return s.instance.Start()
 }
 
+func (s *BoxService) Reload(configContent string) error {
+	options, err := parseConfig(configContent)
+	if err != nil {
+		return err
+	}
+	return s.instance.Reload(options)
+}
+
 func (s *BoxService) Close() error {
 	s.cancel()
 	s.urlTestHistoryStorage.Close()
@@ -0,0 +1,210 @@
+package metrics
+
+import (
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing/common/atomic"
+)
+
+// Series beyond the limit are folded into a single series labelled otherLabelValue,
+// so that a misbehaving label can never grow the exposition without bound.
+const (
+	maxSeriesPerMetric = 1024
+	otherLabelValue    = "_other"
+)
+
+var dnsDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type series struct {
+	labelValues []string
+	value       atomic.Int64
+}
+
+type vec struct {
+	name       string
+	help       string
+	metricType string
+	labels     []string
+	access     sync.RWMutex
+	series     map[string]*series
+}
+
+func newVec(name string, help string, metricType string, labels ...string) *vec {
+	return &vec{
+		name:       name,
+		help:       help,
+		metricType: metricType,
+		labels:     labels,
+		series:     make(map[string]*series),
+	}
+}
+
+func (v *vec) with(labelValues ...string) *atomic.Int64 {
+	key := strings.Join(labelValues, "\xff")
+	v.access.RLock()
+	s, loaded := v.series[key]
+	v.access.RUnlock()
+	if loaded {
+		return &s.value
+	}
+	v.access.Lock()
+	defer v.access.Unlock()
+	s, loaded = v.series[key]
+	if loaded {
+		return &s.value
+	}
+	if len(v.series) >= maxSeriesPerMetric {
+		otherValues := make([]string, len(labelValues))
+		for i := range otherValues {
+			otherValues[i] = otherLabelValue
+		}
+		labelValues = otherValues
+		key = strings.Join(labelValues, "\xff")
+		s, loaded = v.series[key]
+		if loaded {
+			return &s.value
+		}
+	}
+	s = &series{labelValues: labelValues}
+	v.series[key] = s
+	return &s.value
+}
+
+func (v *vec) writeTo(writer io.Writer) {
+	v.access.RLock()
+	seriesList := make([]*series, 0, len(v.series))
+	for _, s := range v.series {
+		seriesList = append(seriesList, s)
+	}
+	v.access.RUnlock()
+	sort.Slice(seriesList, func(i, j int) bool {
+		return strings.Join(seriesList[i].labelValues, "\xff") < strings.Join(seriesList[j].labelValues, "\xff")
+	})
+	writeHeader(writer, v.name, v.help, v.metricType)
+	for _, s := range seriesList {
+		writeSample(writer, v.name, v.labels, s.labelValues, float64(s.value.Load()))
+	}
+}
+
+type histogramSeries struct {
+	buckets []atomic.Int64
+	count   atomic.Int64
+	sum     atomic.Int64
+}
+
+type histogramVec struct {
+	name    string
+	help    string
+	label   string
+	bounds  []float64
+	access  sync.RWMutex
+	series  map[string]*histogramSeries
+	ordered []string
+}
+
+func newHistogramVec(name string, help string, label string, bounds []float64) *histogramVec {
+	return &histogramVec{
+		name:   name,
+		help:   help,
+		label:  label,
+		bounds: bounds,
+		series: make(map[string]*histogramSeries),
+	}
+}
+
+func (h *histogramVec) observe(labelValue string, duration time.Duration) {
+	h.access.RLock()
+	s, loaded := h.series[labelValue]
+	h.access.RUnlock()
+	if !loaded {
+		h.access.Lock()
+		s, loaded = h.series[labelValue]
+		if !loaded {
+			if len(h.series) >= maxSeriesPerMetric {
+				labelValue = otherLabelValue
+				s, loaded = h.series[labelValue]
+			}
+			if !loaded {
+				s = &histogramSeries{buckets: make([]atomic.Int64, len(h.bounds))}
+				h.series[labelValue] = s
+				h.ordered = append(h.ordered, labelValue)
+				sort.Strings(h.ordered)
+			}
+		}
+		h.access.Unlock()
+	}
+	seconds := duration.Seconds()
+	for i, bound := range h.bounds {
+		if seconds <= bound {
+			s.buckets[i].Add(1)
+		}
+	}
+	s.count.Add(1)
+	s.sum.Add(int64(duration))
+}
+
+func (h *histogramVec) writeTo(writer io.Writer) {
+	h.access.RLock()
+	ordered := append([]string(nil), h.ordered...)
+	seriesMap := make(map[string]*histogramSeries, len(h.series))
+	for labelValue, s := range h.series {
+		seriesMap[labelValue] = s
+	}
+	h.access.RUnlock()
+	writeHeader(writer, h.name, h.help, "histogram")
+	for _, labelValue := range ordered {
+		s := seriesMap[labelValue]
+		for i, bound := range h.bounds {
+			writeSample(writer, h.name+"_bucket", []string{h.label, "le"}, []string{labelValue, formatFloat(bound)}, float64(s.buckets[i].Load()))
+		}
+		count := float64(s.count.Load())
+		writeSample(writer, h.name+"_bucket", []string{h.label, "le"}, []string{labelValue, "+Inf"}, count)
+		writeSample(writer, h.name+"_sum", []string{h.label}, []string{labelValue}, time.Duration(s.sum.Load()).Seconds())
+		writeSample(writer, h.name+"_count", []string{h.label}, []string{labelValue}, count)
+	}
+}
+
+func writeHeader(writer io.Writer, name string, help string, metricType string) {
+	io.WriteString(writer, "# HELP "+name+" "+help+"\n# TYPE "+name+" "+metricType+"\n")
+}
+
+func writeSample(writer io.Writer, name string, labels []string, labelValues []string, value float64) {
+	var builder strings.Builder
+	builder.WriteString(name)
+	if len(labels) > 0 {
+		builder.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				builder.WriteByte(',')
+			}
+			builder.WriteString(label)
+			builder.WriteString(`="`)
+			builder.WriteString(escapeLabelValue(labelValues[i]))
+			builder.WriteByte('"')
+		}
+		builder.WriteByte('}')
+	}
+	builder.WriteByte(' ')
+	builder.WriteString(formatFloat(value))
+	builder.WriteByte('\n')
+	io.WriteString(writer, builder.String())
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeLabelValue(value string) string {
+	return labelValueReplacer.Replace(value)
+}
+
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,253 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/urltest"
+	"github.com/sagernet/sing-box/experimental/clashapi/trafficontrol"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/atomic"
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/service"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var _ adapter.MetricsServer = (*Server)(nil)
+
+type Server struct {
+	router      adapter.Router
+	logger      log.Logger
+	httpServer  *http.Server
+	path        string
+	history     *urltest.HistoryStorage
+	ownsHistory bool
+
+	inboundConnections  *vec
+	inboundActive       *vec
+	inboundTraffic      *vec
+	outboundConnections *vec
+	outboundActive      *vec
+	outboundTraffic     *vec
+	userConnections     *vec
+	userActive          *vec
+	userTraffic         *vec
+	dnsQueries          *vec
+	dnsCacheHits        *vec
+	dnsDuration         *histogramVec
+}
+
+func NewServer(ctx context.Context, router adapter.Router, logger log.Logger, options option.MetricsOptions) (*Server, error) {
+	if options.Listen == "" {
+		return nil, E.New("missing listen address")
+	}
+	server := &Server{
+		router: router,
+		logger: logger,
+		path:   options.Path,
+
+		inboundConnections:  newVec("sing_box_inbound_connections_total", "Connections accepted by inbound.", "counter", "inbound", "network"),
+		inboundActive:       newVec("sing_box_inbound_active_connections", "Active connections by inbound.", "gauge", "inbound", "network"),
+		inboundTraffic:      newVec("sing_box_inbound_traffic_bytes_total", "Traffic by inbound.", "counter", "inbound", "direction"),
+		outboundConnections: newVec("sing_box_outbound_connections_total", "Connections routed to outbound.", "counter", "outbound", "network"),
+		outboundActive:      newVec("sing_box_outbound_active_connections", "Active connections by outbound.", "gauge", "outbound", "network"),
+		outboundTraffic:     newVec("sing_box_outbound_traffic_bytes_total", "Traffic by outbound.", "counter", "outbound", "direction"),
+		userConnections:     newVec("sing_box_user_connections_total", "Connections by authenticated user.", "counter", "user", "network"),
+		userActive:          newVec("sing_box_user_active_connections", "Active connections by authenticated user.", "gauge", "user", "network"),
+		userTraffic:         newVec("sing_box_user_traffic_bytes_total", "Traffic by authenticated user.", "counter", "user", "direction"),
+		dnsQueries:          newVec("sing_box_dns_queries_total", "DNS queries sent to upstream servers.", "counter", "server", "result"),
+		dnsCacheHits:        newVec("sing_box_dns_cache_hits_total", "DNS queries answered from cache.", "counter"),
+		dnsDuration:         newHistogramVec("sing_box_dns_query_duration_seconds", "Latency of DNS queries sent to upstream servers.", "server", dnsDurationBuckets),
+	}
+	server.dnsCacheHits.with()
+	if server.path == "" {
+		server.path = "/metrics"
+	}
+	server.history = service.PtrFromContext[urltest.HistoryStorage](ctx)
+	if server.history == nil {
+		if clashServer := router.ClashServer(); clashServer != nil {
+			server.history = clashServer.HistoryStorage()
+		} else {
+			// Share delay history with URLTest groups created later.
+			server.history = urltest.NewHistoryStorage()
+			server.ownsHistory = true
+			service.MustRegisterPtr(ctx, server.history)
+		}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(server.path, server.serveMetrics)
+	server.httpServer = &http.Server{
+		Addr:    options.Listen,
+		Handler: mux,
+	}
+	return server, nil
+}
+
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return E.Cause(err, "metrics listen error")
+	}
+	s.logger.Info("metrics listening at ", listener.Addr())
+	go func() {
+		err = s.httpServer.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("metrics serve error: ", err)
+		}
+	}()
+	return nil
+}
+
+func (s *Server) Close() error {
+	var history *urltest.HistoryStorage
+	if s.ownsHistory {
+		history = s.history
+	}
+	return common.Close(
+		common.PtrOrNil(s.httpServer),
+		common.PtrOrNil(history),
+	)
+}
+
+func (s *Server) RoutedConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, outbound string) (net.Conn, adapter.Tracker) {
+	readCounters, writeCounters, tracker := s.track(metadata, outbound, N.NetworkTCP)
+	return bufio.NewInt64CounterConn(conn, readCounters, writeCounters), tracker
+}
+
+func (s *Server) RoutedPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext, outbound string) (N.PacketConn, adapter.Tracker) {
+	readCounters, writeCounters, tracker := s.track(metadata, outbound, N.NetworkUDP)
+	return bufio.NewInt64CounterPacketConn(conn, readCounters, writeCounters), tracker
+}
+
+func (s *Server) track(metadata adapter.InboundContext, outbound string, network string) (readCounters []*atomic.Int64, writeCounters []*atomic.Int64, tracker *connectionTracker) {
+	tracker = new(connectionTracker)
+	countConnection := func(connections *vec, active *vec, traffic *vec, labelValue string) {
+		connections.with(labelValue, network).Add(1)
+		activeGauge := active.with(labelValue, network)
+		activeGauge.Add(1)
+		tracker.active = append(tracker.active, activeGauge)
+		readCounters = append(readCounters, traffic.with(labelValue, "uplink"))
+		writeCounters = append(writeCounters, traffic.with(labelValue, "downlink"))
+	}
+	if metadata.Inbound != "" {
+		countConnection(s.inboundConnections, s.inboundActive, s.inboundTraffic, metadata.Inbound)
+	}
+	if outbound != "" {
+		countConnection(s.outboundConnections, s.outboundActive, s.outboundTraffic, outbound)
+	}
+	if metadata.User != "" {
+		countConnection(s.userConnections, s.userActive, s.userTraffic, metadata.User)
+	}
+	return
+}
+
+func (s *Server) DNSQuery(server string, duration time.Duration, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	s.dnsQueries.with(server, result).Add(1)
+	s.dnsDuration.observe(server, duration)
+}
+
+func (s *Server) DNSCacheHit() {
+	s.dnsCacheHits.with().Add(1)
+}
+
+func (s *Server) serveMetrics(writer http.ResponseWriter, request *http.Request) {
+	var buffer bytes.Buffer
+	for _, metric := range []*vec{
+		s.inboundConnections, s.inboundActive, s.inboundTraffic,
+		s.outboundConnections, s.outboundActive, s.outboundTraffic,
+		s.userConnections, s.userActive, s.userTraffic,
+		s.dnsQueries, s.dnsCacheHits,
+	} {
+		metric.writeTo(&buffer)
+	}
+	s.dnsDuration.writeTo(&buffer)
+	s.writeRuleSets(&buffer)
+	s.writeGroups(&buffer)
+	if clashServer, isTracked := s.router.ClashServer().(interface {
+		TrafficManager() *trafficontrol.Manager
+	}); isTracked {
+		writeHeader(&buffer, "sing_box_tracked_connections", "Connections tracked by the Clash API.", "gauge")
+		writeSample(&buffer, "sing_box_tracked_connections", nil, nil, float64(clashServer.TrafficManager().Connections()))
+	}
+	writer.Header().Set("Content-Type", contentType)
+	writer.Write(buffer.Bytes())
+}
+
+func (s *Server) writeRuleSets(buffer *bytes.Buffer) {
+	ruleSets := s.router.RuleSets()
+	writeHeader(buffer, "sing_box_rule_set_rules", "Rule items loaded in rule-set.", "gauge")
+	for _, ruleSet := range ruleSets {
+		writeSample(buffer, "sing_box_rule_set_rules", []string{"rule_set", "type"}, []string{ruleSet.Tag(), ruleSet.Type()}, float64(ruleSet.RuleCount()))
+	}
+	writeHeader(buffer, "sing_box_rule_set_updated_timestamp_seconds", "Last successful update of rule-set.", "gauge")
+	for _, ruleSet := range ruleSets {
+		if updatedAt := ruleSet.UpdatedAt(); !updatedAt.IsZero() {
+			writeSample(buffer, "sing_box_rule_set_updated_timestamp_seconds", []string{"rule_set"}, []string{ruleSet.Tag()}, float64(updatedAt.Unix()))
+		}
+	}
+	writeHeader(buffer, "sing_box_rule_set_update_success", "Whether the last update of rule-set succeeded.", "gauge")
+	for _, ruleSet := range ruleSets {
+		var success float64
+		if ruleSet.LastError() == nil {
+			success = 1
+		}
+		writeSample(buffer, "sing_box_rule_set_update_success", []string{"rule_set"}, []string{ruleSet.Tag()}, success)
+	}
+}
+
+func (s *Server) writeGroups(buffer *bytes.Buffer) {
+	var groups []adapter.OutboundGroup
+	for _, outbound := range s.router.Outbounds() {
+		if group, isGroup := outbound.(adapter.OutboundGroup); isGroup {
+			groups = append(groups, group)
+		}
+	}
+	writeHeader(buffer, "sing_box_group_selected", "Outbound currently selected by group.", "gauge")
+	for _, group := range groups {
+		if now := group.Now(); now != "" {
+			writeSample(buffer, "sing_box_group_selected", []string{"group", "outbound"}, []string{group.Tag(), now}, 1)
+		}
+	}
+	writeHeader(buffer, "sing_box_group_member_delay_milliseconds", "Last URL test delay of group member.", "gauge")
+	for _, group := range groups {
+		for _, tag := range group.All() {
+			detour, loaded := s.router.Outbound(tag)
+			if !loaded {
+				continue
+			}
+			history := s.history.LoadURLTestHistory(adapter.OutboundTag(detour))
+			if history == nil {
+				continue
+			}
+			writeSample(buffer, "sing_box_group_member_delay_milliseconds", []string{"group", "outbound"}, []string{group.Tag(), tag}, float64(history.Delay))
+		}
+	}
+}
+
+type connectionTracker struct {
+	once   sync.Once
+	active []*atomic.Int64
+}
+
+func (t *connectionTracker) Leave() {
+	t.once.Do(func() {
+		for _, gauge := range t.active {
+			gauge.Add(-1)
+		}
+	})
+}
@@ -0,0 +1,299 @@
+package userapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+	"github.com/sagernet/sing/common/json/badjson"
+	M "github.com/sagernet/sing/common/metadata"
+	"github.com/sagernet/sing/service/filemanager"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// Server manages users of multi-user inbounds over HTTP, and optionally keeps
+// them in a users file which overrides the configuration on start.
+type Server struct {
+	ctx        context.Context
+	logger     log.Logger
+	httpServer *http.Server
+	secret     string
+	path       string
+	access     sync.RWMutex
+	inbounds   map[string]adapter.UserManagedInbound
+	tags       []string
+	saveAccess sync.Mutex
+}
+
+type httpError struct {
+	Message string `json:"message"`
+}
+
+func NewServer(ctx context.Context, logger log.Logger, inbounds []adapter.Inbound, options option.UserAPIOptions) (*Server, error) {
+	if options.Listen == "" {
+		return nil, E.New("missing listen address")
+	}
+	if options.Secret == "" && !isLoopback(options.Listen) {
+		return nil, E.New("missing secret, which is required unless listening on a loopback address")
+	}
+	server := &Server{
+		ctx:    ctx,
+		logger: logger,
+		secret: options.Secret,
+		path:   options.Path,
+	}
+	server.inbounds, server.tags = managedInbounds(inbounds)
+	if server.path != "" {
+		server.path = filemanager.BasePath(ctx, server.path)
+	}
+	chiRouter := chi.NewRouter()
+	chiRouter.Use(server.authentication)
+	chiRouter.Get("/inbounds", server.listInbounds)
+	chiRouter.Route("/inbounds/{tag}/users", func(r chi.Router) {
+		r.Use(server.findInbound)
+		r.Get("/", server.listUsers)
+		r.Post("/", server.addUser)
+		r.Put("/", server.resetUsers)
+		r.Put("/{name}", server.updateUser)
+		r.Delete("/{name}", server.removeUser)
+	})
+	server.httpServer = &http.Server{
+		Addr:    options.Listen,
+		Handler: chiRouter,
+	}
+	return server, nil
+}
+
+func managedInbounds(inbounds []adapter.Inbound) (map[string]adapter.UserManagedInbound, []string) {
+	managedInbounds := make(map[string]adapter.UserManagedInbound)
+	var tags []string
+	for _, inbound := range inbounds {
+		managedInbound, isManaged := inbound.(adapter.UserManagedInbound)
+		if !isManaged || inbound.Tag() == "" {
+			continue
+		}
+		managedInbounds[inbound.Tag()] = managedInbound
+		tags = append(tags, inbound.Tag())
+	}
+	return managedInbounds, tags
+}
+
+func (s *Server) Start() error {
+	err := s.loadUsers(nil)
+	if err != nil {
+		return E.Cause(err, "load users file")
+	}
+	listener, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return E.Cause(err, "user api listen error")
+	}
+	s.logger.Info("user api listening at ", listener.Addr())
+	go func() {
+		err = s.httpServer.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("user api serve error: ", err)
+		}
+	}()
+	return nil
+}
+
+func (s *Server) Close() error {
+	return common.Close(common.PtrOrNil(s.httpServer))
+}
+
+// UpdateInbounds replaces the inbounds after a reload, and applies the users
+// file to inbounds that were recreated.
+func (s *Server) UpdateInbounds(inbounds []adapter.Inbound) error {
+	s.access.Lock()
+	lastInbounds := s.inbounds
+	s.inbounds, s.tags = managedInbounds(inbounds)
+	s.access.Unlock()
+	err := s.loadUsers(lastInbounds)
+	if err != nil {
+		return E.Cause(err, "load users file")
+	}
+	return nil
+}
+
+// loadUsers resets users of inbounds in the users file, except of inbounds
+// that are unchanged from lastInbounds.
+func (s *Server) loadUsers(lastInbounds map[string]adapter.UserManagedInbound) error {
+	if s.path == "" {
+		return nil
+	}
+	content, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	userMap, err := json.UnmarshalExtended[map[string]json.RawMessage](content)
+	if err != nil {
+		return err
+	}
+	for tag, users := range userMap {
+		inbound, loaded := s.loadInbound(tag)
+		if !loaded {
+			s.logger.Warn("users file: inbound not found or does not support user management: ", tag)
+			continue
+		}
+		if inbound == lastInbounds[tag] {
+			continue
+		}
+		err = inbound.Users().Reset(users)
+		if err != nil {
+			return E.Cause(err, "reset users of inbound ", tag)
+		}
+	}
+	return nil
+}
+
+func (s *Server) saveUsers() error {
+	if s.path == "" {
+		return nil
+	}
+	s.saveAccess.Lock()
+	defer s.saveAccess.Unlock()
+	userMap := badjson.JSONObject{}
+	s.access.RLock()
+	for _, tag := range s.tags {
+		userMap.Put(tag, s.inbounds[tag].Users().List())
+	}
+	s.access.RUnlock()
+	buffer := new(bytes.Buffer)
+	encoder := json.NewEncoder(buffer)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(&userMap)
+	if err != nil {
+		return err
+	}
+	temporaryPath := s.path + ".tmp"
+	err = filemanager.WriteFile(s.ctx, temporaryPath, buffer.Bytes(), 0o600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(temporaryPath, s.path)
+}
+
+func (s *Server) authentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.secret != "" {
+			bearer, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+			if bearer != "Bearer" || !found || subtle.ConstantTimeCompare([]byte(token), []byte(s.secret)) != 1 {
+				writeError(w, r, http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isLoopback(listen string) bool {
+	address := M.ParseSocksaddr(listen)
+	return address.Addr.IsLoopback() || address.Fqdn == "localhost"
+}
+
+func (s *Server) loadInbound(tag string) (adapter.UserManagedInbound, bool) {
+	s.access.RLock()
+	defer s.access.RUnlock()
+	inbound, loaded := s.inbounds[tag]
+	return inbound, loaded
+}
+
+type inboundKey struct{}
+
+func (s *Server) findInbound(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inbound, loaded := s.loadInbound(chi.URLParam(r, "tag"))
+		if !loaded {
+			writeError(w, r, http.StatusNotFound, "inbound not found or does not support user management")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), inboundKey{}, inbound)))
+	})
+}
+
+func (s *Server) listInbounds(w http.ResponseWriter, r *http.Request) {
+	type inboundInfo struct {
+		Tag  string `json:"tag"`
+		Type string `json:"type"`
+	}
+	s.access.RLock()
+	inbounds := common.Map(s.tags, func(tag string) inboundInfo {
+		return inboundInfo{Tag: tag, Type: s.inbounds[tag].Type()}
+	})
+	s.access.RUnlock()
+	render.JSON(w, r, inbounds)
+}
+
+func (s *Server) listUsers(w http.ResponseWriter, r *http.Request) {
+	inbound := r.Context().Value(inboundKey{}).(adapter.UserManagedInbound)
+	render.JSON(w, r, inbound.Users().List())
+}
+
+func (s *Server) addUser(w http.ResponseWriter, r *http.Request) {
+	s.update(w, r, func(users adapter.InboundUsers, content []byte) error {
+		return users.Add(content)
+	})
+}
+
+func (s *Server) resetUsers(w http.ResponseWriter, r *http.Request) {
+	s.update(w, r, func(users adapter.InboundUsers, content []byte) error {
+		return users.Reset(content)
+	})
+}
+
+func (s *Server) updateUser(w http.ResponseWriter, r *http.Request) {
+	s.update(w, r, func(users adapter.InboundUsers, content []byte) error {
+		return users.Update(chi.URLParam(r, "name"), content)
+	})
+}
+
+func (s *Server) removeUser(w http.ResponseWriter, r *http.Request) {
+	s.update(w, r, func(users adapter.InboundUsers, content []byte) error {
+		return users.Remove(chi.URLParam(r, "name"))
+	})
+}
+
+func (s *Server) update(w http.ResponseWriter, r *http.Request, action func(users adapter.InboundUsers, content []byte) error) {
+	inbound := r.Context().Value(inboundKey{}).(adapter.UserManagedInbound)
+	content, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	err = action(inbound.Users(), content)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.logger.Info("updated users of inbound ", inbound.Tag())
+	err = s.saveUsers()
+	if err != nil {
+		s.logger.Error("save users file: ", err)
+		writeError(w, r, http.StatusInternalServerError, "save users file: "+err.Error())
+		return
+	}
+	render.NoContent(w, r)
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	render.Status(r, status)
+	render.JSON(w, r, &httpError{Message: message})
+}
@@ -0,0 +1,107 @@
+package userapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerSecret(t *testing.T) {
+	t.Parallel()
+	for _, listen := range []string{"127.0.0.1:9091", "[::1]:9091", "localhost:9091"} {
+		_, err := NewServer(context.Background(), log.NewNOPFactory().Logger(), nil, option.UserAPIOptions{Listen: listen})
+		require.NoError(t, err, listen)
+	}
+	for _, listen := range []string{":9091", "0.0.0.0:9091", "192.168.1.1:9091", "example.com:9091"} {
+		_, err := NewServer(context.Background(), log.NewNOPFactory().Logger(), nil, option.UserAPIOptions{Listen: listen})
+		require.ErrorContains(t, err, "missing secret", listen)
+	}
+
+	server, err := NewServer(context.Background(), log.NewNOPFactory().Logger(), nil, option.UserAPIOptions{Listen: ":9091", Secret: "secret"})
+	require.NoError(t, err)
+	for authorization, status := range map[string]int{
+		"":               http.StatusUnauthorized,
+		"Bearer":         http.StatusUnauthorized,
+		"Bearer secre":   http.StatusUnauthorized,
+		"Bearer secrets": http.StatusUnauthorized,
+		"Basic secret":   http.StatusUnauthorized,
+		"Bearer secret":  http.StatusOK,
+	} {
+		request := httptest.NewRequest(http.MethodGet, "/inbounds", nil)
+		if authorization != "" {
+			request.Header.Set("Authorization", authorization)
+		}
+		recorder := httptest.NewRecorder()
+		server.httpServer.Handler.ServeHTTP(recorder, request)
+		require.Equal(t, status, recorder.Code, authorization)
+	}
+}
+
+type testInbound struct {
+	adapter.Inbound
+	tag   string
+	users *testUsers
+}
+
+func newTestInbound(tag string) *testInbound {
+	return &testInbound{tag: tag, users: &testUsers{}}
+}
+
+func (h *testInbound) Type() string {
+	return "test"
+}
+
+func (h *testInbound) Tag() string {
+	return h.tag
+}
+
+func (h *testInbound) Users() adapter.InboundUsers {
+	return h.users
+}
+
+type testUsers struct {
+	adapter.InboundUsers
+	resets []string
+}
+
+func (u *testUsers) List() []any {
+	return nil
+}
+
+func (u *testUsers) Reset(content []byte) error {
+	u.resets = append(u.resets, strings.Join(strings.Fields(string(content)), ""))
+	return nil
+}
+
+func TestServerUpdateInbounds(t *testing.T) {
+	t.Parallel()
+	usersPath := filepath.Join(t.TempDir(), "users.json")
+	require.NoError(t, os.WriteFile(usersPath, []byte(`{"a": [{"name": "a"}], "b": [{"name": "b"}]}`), 0o644))
+	a, b := newTestInbound("a"), newTestInbound("b")
+	server, err := NewServer(context.Background(), log.NewNOPFactory().Logger(), []adapter.Inbound{a, b}, option.UserAPIOptions{Listen: "127.0.0.1:9091", Path: usersPath})
+	require.NoError(t, err)
+	require.NoError(t, server.loadUsers(nil))
+	require.Equal(t, []string{`[{"name":"a"}]`}, a.users.resets)
+	require.Equal(t, []string{`[{"name":"b"}]`}, b.users.resets)
+
+	// only the recreated inbound gets users of the file again
+	recreatedB := newTestInbound("b")
+	require.NoError(t, server.UpdateInbounds([]adapter.Inbound{a, recreatedB, newTestInbound("c")}))
+	require.Len(t, a.users.resets, 1)
+	require.Len(t, b.users.resets, 1)
+	require.Equal(t, []string{`[{"name":"b"}]`}, recreatedB.users.resets)
+	require.Equal(t, []string{"a", "b", "c"}, server.tags)
+	inbound, loaded := server.loadInbound("b")
+	require.True(t, loaded)
+	require.Equal(t, adapter.UserManagedInbound(recreatedB), inbound)
+}
@@ -69,26 +69,10 @@ func (a *myInboundAdapter) Start() error {
 		go a.loopTCPIn()
 	}
 	if common.Contains(a.network, N.NetworkUDP) {
-		_, err = a.ListenUDP()
+		err = a.startUDP()
 		if err != nil {
 			return err
 		}
-		a.packetOutboundClosed = make(chan struct{})
-		a.packetOutbound = make(chan *myInboundPacket)
-		if a.oobPacketHandler != nil {
-			if _, threadUnsafeHandler := common.Cast[N.ThreadUnsafeWriter](a.packetUpstream); !threadUnsafeHandler {
-				go a.loopUDPOOBIn()
-			} else {
-				go a.loopUDPOOBInThreadSafe()
-			}
-		} else {
-			if _, threadUnsafeHandler := common.Cast[N.ThreadUnsafeWriter](a.packetUpstream); !threadUnsafeHandler {
-				go a.loopUDPIn()
-			} else {
-				go a.loopUDPInThreadSafe()
-			}
-			go a.loopUDPOut()
-		}
 	}
 	if a.setSystemProxy {
 		listenPort := M.SocksaddrFromNet(a.tcpListener.Addr()).Port
@@ -113,6 +97,30 @@ func (a *myInboundAdapter) Start() error {
 	return nil
 }
 
+func (a *myInboundAdapter) startUDP() error {
+	_, err := a.ListenUDP()
+	if err != nil {
+		return err
+	}
+	a.packetOutboundClosed = make(chan struct{})
+	a.packetOutbound = make(chan *myInboundPacket)
+	if a.oobPacketHandler != nil {
+		if _, threadUnsafeHandler := common.Cast[N.ThreadUnsafeWriter](a.packetUpstream); !threadUnsafeHandler {
+			go a.loopUDPOOBIn()
+		} else {
+			go a.loopUDPOOBInThreadSafe()
+		}
+	} else {
+		if _, threadUnsafeHandler := common.Cast[N.ThreadUnsafeWriter](a.packetUpstream); !threadUnsafeHandler {
+			go a.loopUDPIn()
+		} else {
+			go a.loopUDPInThreadSafe()
+		}
+		go a.loopUDPOut()
+	}
+	return nil
+}
+
 func (a *myInboundAdapter) Close() error {
 	a.inShutdown.Store(true)
 	var err error
@@ -0,0 +1,383 @@
+package inbound
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/pipelistener"
+	"github.com/sagernet/sing-box/common/tls"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-dns"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	sHttp "github.com/sagernet/sing/protocol/http"
+
+	mDNS "github.com/miekg/dns"
+	"golang.org/x/net/http2"
+)
+
+const (
+	dnsNextProtoDoT = "dot"
+	dnsNextProtoDoQ = "doq"
+	dnsMimeType     = "application/dns-message"
+	dnsIdleTimeout  = 2 * time.Minute
+	// dnsMaxQueries limits queries processed at the same time, for each stream
+	// connection and for all packets of the inbound.
+	dnsMaxQueries = 64
+)
+
+var (
+	_ adapter.Inbound           = (*DNS)(nil)
+	_ adapter.InjectableInbound = (*DNS)(nil)
+)
+
+type DNS struct {
+	myInboundAdapter
+	dnsRouter     adapter.Router
+	tlsConfig     tls.ServerConfig
+	path          string
+	httpServer    *http.Server
+	h2Server      *http2.Server
+	httpListener  *pipelistener.Listener
+	quicListener  io.Closer
+	packetQueries chan struct{}
+}
+
+func NewDNS(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.DNSInboundOptions) (*DNS, error) {
+	inbound := &DNS{
+		myInboundAdapter: myInboundAdapter{
+			protocol:      C.TypeDNS,
+			network:       options.Network.Build(),
+			ctx:           ctx,
+			router:        router,
+			logger:        logger,
+			tag:           tag,
+			listenOptions: options.ListenOptions,
+		},
+		dnsRouter:     router,
+		path:          options.Path,
+		packetQueries: make(chan struct{}, dnsMaxQueries),
+	}
+	if inbound.path == "" {
+		inbound.path = "/dns-query"
+	} else if !strings.HasPrefix(inbound.path, "/") {
+		inbound.path = "/" + inbound.path
+	}
+	if options.TLS != nil {
+		tlsConfig, err := tls.NewServer(ctx, logger, common.PtrValueOrDefault(options.TLS))
+		if err != nil {
+			return nil, err
+		}
+		if tlsConfig != nil && len(tlsConfig.NextProtos()) == 0 {
+			var nextProtos []string
+			if common.Contains(inbound.network, N.NetworkTCP) {
+				nextProtos = append(nextProtos, http2.NextProtoTLS, "http/1.1", dnsNextProtoDoT)
+			}
+			if common.Contains(inbound.network, N.NetworkUDP) {
+				nextProtos = append(nextProtos, dnsNextProtoDoQ)
+			}
+			tlsConfig.SetNextProtos(nextProtos)
+		}
+		inbound.tlsConfig = tlsConfig
+	}
+	inbound.connHandler = inbound
+	inbound.packetHandler = inbound
+	return inbound, nil
+}
+
+func (d *DNS) Start() error {
+	if d.tlsConfig == nil {
+		return d.myInboundAdapter.Start()
+	}
+	err := d.tlsConfig.Start()
+	if err != nil {
+		return E.Cause(err, "create TLS config")
+	}
+	if common.Contains(d.network, N.NetworkTCP) {
+		d.httpListener = pipelistener.New(16)
+		d.h2Server = &http2.Server{
+			MaxConcurrentStreams: dnsMaxQueries,
+			IdleTimeout:          dnsIdleTimeout,
+		}
+		d.httpServer = &http.Server{
+			Handler:           d,
+			ReadHeaderTimeout: C.TCPTimeout,
+			IdleTimeout:       dnsIdleTimeout,
+			BaseContext: func(net.Listener) context.Context {
+				return d.ctx
+			},
+		}
+		go func() {
+			sErr := d.httpServer.Serve(d.httpListener)
+			if sErr != nil && !errors.Is(sErr, http.ErrServerClosed) && !E.IsClosedOrCanceled(sErr) {
+				d.logger.Error("http server serve error: ", sErr)
+			}
+		}()
+		_, err = d.ListenTCP()
+		if err != nil {
+			return err
+		}
+		go d.loopTCPIn()
+	}
+	if common.Contains(d.network, N.NetworkUDP) {
+		err = d.startQUIC()
+		if !C.WithQUIC && len(d.network) > 1 {
+			d.logger.Warn(E.Cause(err, "DNS over QUIC disabled"))
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *DNS) Close() error {
+	d.inShutdown.Store(true)
+	return common.Close(
+		d.quicListener,
+		&d.myInboundAdapter,
+		common.PtrOrNil(d.httpServer),
+		common.PtrOrNil(d.httpListener),
+		d.tlsConfig,
+	)
+}
+
+func (d *DNS) NewConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	if d.tlsConfig == nil {
+		return d.serveStream(ctx, conn, metadata)
+	}
+	tlsConn, err := tls.ServerHandshake(ctx, conn, d.tlsConfig)
+	if err != nil {
+		return E.Cause(err, "TLS handshake")
+	}
+	switch tlsConn.ConnectionState().NegotiatedProtocol {
+	case http2.NextProtoTLS:
+		d.h2Server.ServeConn(tlsConn, &http2.ServeConnOpts{
+			Context:    ctx,
+			Handler:    d,
+			BaseConfig: d.httpServer,
+		})
+		return nil
+	case "http/1.1":
+		d.httpListener.Serve(tlsConn)
+		return nil
+	case "":
+		// Clients may skip ALPN, distinguish DoH from DoT by the request method.
+		header := buf.NewSize(4)
+		_, err = header.ReadFullFrom(tlsConn, 4)
+		if err != nil {
+			header.Release()
+			return err
+		}
+		isHTTP := common.Any([]string{"GET ", "POST", "PRI "}, func(method string) bool {
+			return string(header.Bytes()) == method
+		})
+		cachedConn := bufio.NewCachedConn(tlsConn, header)
+		if isHTTP {
+			d.httpListener.Serve(cachedConn)
+			return nil
+		}
+		return d.serveStream(ctx, cachedConn, metadata)
+	default:
+		return d.serveStream(ctx, tlsConn, metadata)
+	}
+}
+
+func (d *DNS) NewPacket(ctx context.Context, conn N.PacketConn, buffer *buf.Buffer, metadata adapter.InboundContext) error {
+	return d.handlePacket(ctx, conn, buffer, metadata, metadata.Source)
+}
+
+func (d *DNS) NewPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
+	defer conn.Close()
+	for {
+		buffer := buf.NewPacket()
+		destination, err := conn.ReadPacket(buffer)
+		if err != nil {
+			buffer.Release()
+			return err
+		}
+		err = d.handlePacket(ctx, conn, buffer, metadata, destination)
+		buffer.Release()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (d *DNS) handlePacket(ctx context.Context, conn N.PacketConn, buffer *buf.Buffer, metadata adapter.InboundContext, destination M.Socksaddr) error {
+	var message mDNS.Msg
+	err := message.Unpack(buffer.Bytes())
+	if err != nil {
+		return E.Cause(err, "unpack DNS query")
+	}
+	select {
+	case d.packetQueries <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	go func() {
+		defer func() {
+			<-d.packetQueries
+		}()
+		response := d.exchange(ctx, &message, metadata)
+		responseBuffer, err := dns.TruncateDNSMessage(&message, response, 0)
+		if err != nil {
+			d.NewError(ctx, E.Cause(err, "pack DNS response"))
+			return
+		}
+		err = conn.WritePacket(responseBuffer, destination)
+		if err != nil {
+			d.NewError(ctx, E.Cause(err, "write DNS response"))
+		}
+	}()
+	return nil
+}
+
+func (d *DNS) serveStream(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	defer conn.Close()
+	var writeAccess sync.Mutex
+	queries := make(chan struct{}, dnsMaxQueries)
+	for {
+		err := conn.SetReadDeadline(time.Now().Add(dnsIdleTimeout))
+		if err != nil {
+			return err
+		}
+		message, err := readDNSStreamMessage(conn)
+		if err != nil {
+			if E.IsClosedOrCanceled(err) || errors.Is(err, io.EOF) || E.IsTimeout(err) {
+				return nil
+			}
+			return err
+		}
+		queries <- struct{}{}
+		go func() {
+			defer func() {
+				<-queries
+			}()
+			response := d.exchange(ctx, message, metadata)
+			writeAccess.Lock()
+			defer writeAccess.Unlock()
+			err := writeDNSStreamMessage(conn, response)
+			if err != nil {
+				d.NewError(ctx, E.Cause(err, "write DNS response"))
+			}
+		}()
+	}
+}
+
+func (d *DNS) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if request.URL.Path != d.path {
+		writer.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var (
+		content []byte
+		err     error
+	)
+	switch request.Method {
+	case http.MethodGet:
+		content, err = base64.RawURLEncoding.DecodeString(request.URL.Query().Get("dns"))
+	case http.MethodPost:
+		if request.Header.Get("Content-Type") != dnsMimeType {
+			writer.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		content, err = io.ReadAll(io.LimitReader(request.Body, mDNS.MaxMsgSize))
+	default:
+		writer.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var message mDNS.Msg
+	if err == nil {
+		err = message.Unpack(content)
+	}
+	if err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		d.NewError(request.Context(), E.Cause(err, "process DNS query from ", request.RemoteAddr))
+		return
+	}
+	var metadata adapter.InboundContext
+	metadata.Inbound = d.tag
+	metadata.InboundType = d.protocol
+	metadata.InboundOptions = d.listenOptions.InboundOptions
+	metadata.Source = sHttp.SourceAddress(request)
+	response := d.exchange(request.Context(), &message, metadata)
+	responseContent, err := response.Pack()
+	if err != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		d.NewError(request.Context(), E.Cause(err, "pack DNS response"))
+		return
+	}
+	writer.Header().Set("Content-Type", dnsMimeType)
+	writer.WriteHeader(http.StatusOK)
+	writer.Write(responseContent)
+}
+
+func (d *DNS) exchange(ctx context.Context, message *mDNS.Msg, metadata adapter.InboundContext) *mDNS.Msg {
+	metadata.Destination = M.Socksaddr{}
+	response, err := d.dnsRouter.Exchange(adapter.WithContext(ctx, &metadata), message)
+	if err == nil {
+		return response
+	}
+	var rCode dns.RCodeError
+	if !errors.As(err, &rCode) {
+		d.logger.ErrorContext(ctx, E.Cause(err, "exchange ", formatDNSQuestion(message)))
+		rCode = dns.RCodeServerFailure
+	}
+	response = new(mDNS.Msg)
+	response.SetRcode(message, int(rCode))
+	return response
+}
+
+func formatDNSQuestion(message *mDNS.Msg) string {
+	if len(message.Question) == 0 {
+		return "empty query"
+	}
+	return message.Question[0].Name
+}
+
+func readDNSStreamMessage(reader io.Reader) (*mDNS.Msg, error) {
+	var queryLength uint16
+	err := binary.Read(reader, binary.BigEndian, &queryLength)
+	if err != nil {
+		return nil, err
+	}
+	if queryLength == 0 {
+		return nil, dns.RCodeFormatError
+	}
+	buffer := buf.NewSize(int(queryLength))
+	defer buffer.Release()
+	_, err = buffer.ReadFullFrom(reader, int(queryLength))
+	if err != nil {
+		return nil, err
+	}
+	var message mDNS.Msg
+	err = message.Unpack(buffer.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+func writeDNSStreamMessage(writer io.Writer, message *mDNS.Msg) error {
+	content, err := message.Pack()
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(content))), content...))
+	return err
+}
@@ -0,0 +1,86 @@
+//go:build with_quic
+
+package inbound
+
+import (
+	"context"
+
+	"github.com/sagernet/quic-go"
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-quic"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+func (d *DNS) startQUIC() error {
+	udpConn, err := d.ListenUDP()
+	if err != nil {
+		return err
+	}
+	quicListener, err := qtls.Listen(udpConn, d.tlsConfig, &quic.Config{
+		MaxIdleTimeout:     dnsIdleTimeout,
+		MaxIncomingStreams: dnsMaxQueries,
+	})
+	if err != nil {
+		udpConn.Close()
+		return err
+	}
+	d.quicListener = quicListener
+	go d.loopQUICIn(quicListener)
+	return nil
+}
+
+func (d *DNS) loopQUICIn(listener qtls.Listener) {
+	for {
+		conn, err := listener.Accept(d.ctx)
+		if err != nil {
+			if !d.inShutdown.Load() && !E.IsClosedOrCanceled(err) {
+				d.logger.Error("quic server serve error: ", err)
+			}
+			return
+		}
+		go d.handleQUICConnection(conn)
+	}
+}
+
+func (d *DNS) handleQUICConnection(conn quic.Connection) {
+	ctx := log.ContextWithNewID(d.ctx)
+	var metadata adapter.InboundContext
+	metadata.Inbound = d.tag
+	metadata.InboundType = d.protocol
+	metadata.InboundOptions = d.listenOptions.InboundOptions
+	metadata.Source = M.SocksaddrFromNet(conn.RemoteAddr()).Unwrap()
+	if conn.ConnectionState().TLS.NegotiatedProtocol != dnsNextProtoDoQ {
+		conn.CloseWithError(0, "")
+		d.NewError(ctx, E.New("process connection from ", metadata.Source, ": unsupported protocol"))
+		return
+	}
+	d.logger.InfoContext(ctx, "inbound connection from ", metadata.Source)
+	for {
+		stream, err := conn.AcceptStream(ctx)
+		if err != nil {
+			conn.CloseWithError(0, "")
+			if !E.IsClosedOrCanceled(err) {
+				d.NewError(ctx, E.Cause(err, "process connection from ", metadata.Source))
+			}
+			return
+		}
+		go d.handleQUICStream(ctx, stream, metadata)
+	}
+}
+
+func (d *DNS) handleQUICStream(ctx context.Context, stream quic.Stream, metadata adapter.InboundContext) {
+	defer stream.Close()
+	message, err := readDNSStreamMessage(stream)
+	if err != nil {
+		stream.CancelRead(0)
+		d.NewError(ctx, E.Cause(err, "read DNS query"))
+		return
+	}
+	response := d.exchange(ctx, message, metadata)
+	err = writeDNSStreamMessage(stream, response)
+	if err != nil {
+		d.NewError(ctx, E.Cause(err, "write DNS response"))
+	}
+}
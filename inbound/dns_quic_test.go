@@ -0,0 +1,50 @@
+//go:build with_quic
+
+package inbound
+
+import (
+	"context"
+	stdTLS "crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/sagernet/quic-go"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDNSOverQUIC(t *testing.T) {
+	t.Parallel()
+	inbound := startTestDNS(t, &testDNSRouter{}, true)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := quic.DialAddr(ctx, inbound.udpConn.LocalAddr().String(), &stdTLS.Config{
+		InsecureSkipVerify: true,
+		NextProtos:         []string{dnsNextProtoDoQ},
+	}, nil)
+	require.NoError(t, err)
+	defer conn.CloseWithError(0, "")
+	for i := 0; i < 2; i++ {
+		stream, err := conn.OpenStreamSync(ctx)
+		require.NoError(t, err)
+		require.NoError(t, writeDNSStreamMessage(stream, newTestDNSQuery()))
+		require.NoError(t, stream.Close())
+		response, err := readDNSStreamMessage(stream)
+		require.NoError(t, err)
+		requireTestDNSResponse(t, response)
+	}
+
+	// queries of a connection are limited by the stream limit
+	conn, err = quic.DialAddr(ctx, inbound.udpConn.LocalAddr().String(), &stdTLS.Config{
+		InsecureSkipVerify: true,
+		NextProtos:         []string{dnsNextProtoDoQ},
+	}, nil)
+	require.NoError(t, err)
+	defer conn.CloseWithError(0, "")
+	for i := 0; i < dnsMaxQueries; i++ {
+		_, err = conn.OpenStream()
+		require.NoError(t, err)
+	}
+	_, err = conn.OpenStream()
+	require.ErrorContains(t, err, "too many open streams")
+}
@@ -0,0 +1,187 @@
+package inbound
+
+import (
+	"bytes"
+	"context"
+	stdTLS "crypto/tls"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/tls"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+
+	mDNS "github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+type testDNSRouter struct {
+	adapter.Router
+	access   sync.Mutex
+	queries  int
+	maxQuery int
+	block    chan struct{}
+}
+
+func (r *testDNSRouter) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, error) {
+	r.access.Lock()
+	r.queries++
+	if r.queries > r.maxQuery {
+		r.maxQuery = r.queries
+	}
+	r.access.Unlock()
+	if r.block != nil {
+		<-r.block
+	}
+	r.access.Lock()
+	r.queries--
+	r.access.Unlock()
+	response := new(mDNS.Msg)
+	response.SetReply(message)
+	response.Answer = append(response.Answer, &mDNS.A{
+		Hdr: mDNS.RR_Header{Name: message.Question[0].Name, Rrtype: mDNS.TypeA, Class: mDNS.ClassINET, Ttl: 60},
+		A:   net.IPv4(1, 1, 1, 1),
+	})
+	return response, nil
+}
+
+func startTestDNS(t *testing.T, router adapter.Router, withTLS bool) *DNS {
+	options := option.DNSInboundOptions{
+		ListenOptions: option.ListenOptions{
+			Listen: option.NewListenAddress(netip.AddrFrom4([4]byte{127, 0, 0, 1})),
+		},
+	}
+	if withTLS {
+		privateKeyPem, publicKeyPem, err := tls.GenerateKeyPair(nil, "example.org", time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		options.TLS = &option.InboundTLSOptions{
+			Enabled:     true,
+			Certificate: []string{string(publicKeyPem)},
+			Key:         []string{string(privateKeyPem)},
+		}
+	}
+	inbound, err := NewDNS(context.Background(), router, log.NewNOPFactory().Logger(), "dns-in", options)
+	require.NoError(t, err)
+	require.NoError(t, inbound.Start())
+	t.Cleanup(func() {
+		inbound.Close()
+	})
+	return inbound
+}
+
+func newTestDNSQuery() *mDNS.Msg {
+	message := new(mDNS.Msg)
+	message.SetQuestion("example.org.", mDNS.TypeA)
+	return message
+}
+
+func requireTestDNSResponse(t *testing.T, response *mDNS.Msg) {
+	require.Equal(t, mDNS.RcodeSuccess, response.Rcode)
+	require.Len(t, response.Answer, 1)
+	require.Equal(t, "1.1.1.1", response.Answer[0].(*mDNS.A).A.String())
+}
+
+func TestDNSPlain(t *testing.T) {
+	t.Parallel()
+	inbound := startTestDNS(t, &testDNSRouter{}, false)
+	for network, address := range map[string]string{
+		"udp": inbound.udpConn.LocalAddr().String(),
+		"tcp": inbound.tcpListener.Addr().String(),
+	} {
+		client := &mDNS.Client{Net: network, Timeout: 5 * time.Second}
+		response, _, err := client.Exchange(newTestDNSQuery(), address)
+		require.NoError(t, err, network)
+		requireTestDNSResponse(t, response)
+	}
+}
+
+func TestDNSOverTLS(t *testing.T) {
+	t.Parallel()
+	inbound := startTestDNS(t, &testDNSRouter{}, true)
+	// clients may skip ALPN
+	for _, nextProtos := range [][]string{{dnsNextProtoDoT}, nil} {
+		client := &mDNS.Client{
+			Net:       "tcp-tls",
+			Timeout:   5 * time.Second,
+			TLSConfig: &stdTLS.Config{InsecureSkipVerify: true, NextProtos: nextProtos},
+		}
+		response, _, err := client.Exchange(newTestDNSQuery(), inbound.tcpListener.Addr().String())
+		require.NoError(t, err, nextProtos)
+		requireTestDNSResponse(t, response)
+	}
+}
+
+func TestDNSOverHTTPS(t *testing.T) {
+	t.Parallel()
+	inbound := startTestDNS(t, &testDNSRouter{}, true)
+	query, err := newTestDNSQuery().Pack()
+	require.NoError(t, err)
+	url := "https://" + inbound.tcpListener.Addr().String() + "/dns-query"
+	for _, http2 := range []bool{true, false} {
+		transport := &http.Transport{
+			TLSClientConfig:   &stdTLS.Config{InsecureSkipVerify: true},
+			ForceAttemptHTTP2: http2,
+		}
+		client := &http.Client{Transport: transport, Timeout: 5 * time.Second}
+		for _, method := range []string{http.MethodGet, http.MethodPost} {
+			var request *http.Request
+			if method == http.MethodGet {
+				request, err = http.NewRequest(method, url+"?dns="+base64.RawURLEncoding.EncodeToString(query), nil)
+			} else {
+				request, err = http.NewRequest(method, url, bytes.NewReader(query))
+				request.Header.Set("Content-Type", dnsMimeType)
+			}
+			require.NoError(t, err)
+			response, err := client.Do(request)
+			require.NoError(t, err, method)
+			require.Equal(t, http.StatusOK, response.StatusCode)
+			require.Equal(t, http2, response.ProtoMajor == 2)
+			content, err := io.ReadAll(response.Body)
+			response.Body.Close()
+			require.NoError(t, err)
+			var message mDNS.Msg
+			require.NoError(t, message.Unpack(content))
+			requireTestDNSResponse(t, &message)
+		}
+		transport.CloseIdleConnections()
+	}
+}
+
+func TestDNSStreamQueryLimit(t *testing.T) {
+	t.Parallel()
+	router := &testDNSRouter{block: make(chan struct{})}
+	inbound := startTestDNS(t, router, false)
+	conn, err := net.Dial("tcp", inbound.tcpListener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	const queryCount = dnsMaxQueries * 2
+	go func() {
+		for i := 0; i < queryCount; i++ {
+			if writeDNSStreamMessage(conn, newTestDNSQuery()) != nil {
+				return
+			}
+		}
+	}()
+	require.Eventually(t, func() bool {
+		router.access.Lock()
+		defer router.access.Unlock()
+		return router.queries == dnsMaxQueries
+	}, 5*time.Second, 10*time.Millisecond)
+	// further queries wait until running ones finish
+	time.Sleep(100 * time.Millisecond)
+	close(router.block)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	for i := 0; i < queryCount; i++ {
+		response, err := readDNSStreamMessage(conn)
+		require.NoError(t, err)
+		requireTestDNSResponse(t, response)
+	}
+	require.Equal(t, dnsMaxQueries, router.maxQuery)
+}
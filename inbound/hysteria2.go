@@ -23,13 +23,16 @@ import (
 	N "github.com/sagernet/sing/common/network"
 )
 
-var _ adapter.Inbound = (*Hysteria2)(nil)
+var (
+	_ adapter.Inbound            = (*Hysteria2)(nil)
+	_ adapter.UserManagedInbound = (*Hysteria2)(nil)
+)
 
 type Hysteria2 struct {
 	myInboundAdapter
-	tlsConfig    tls.ServerConfig
-	service      *hysteria2.Service[int]
-	userNameList []string
+	tlsConfig tls.ServerConfig
+	service   *hysteria2.Service[int]
+	users     *inboundUsers[option.Hysteria2User]
 }
 
 func NewHysteria2(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.Hysteria2InboundOptions) (*Hysteria2, error) {
@@ -110,25 +113,38 @@ func NewHysteria2(ctx context.Context, router adapter.Router, logger log.Context
 	if err != nil {
 		return nil, err
 	}
-	userList := make([]int, 0, len(options.Users))
-	userNameList := make([]string, 0, len(options.Users))
-	userPasswordList := make([]string, 0, len(options.Users))
-	for index, user := range options.Users {
-		userList = append(userList, index)
-		userNameList = append(userNameList, user.Name)
-		userPasswordList = append(userPasswordList, user.Password)
+	inbound.users = newInboundUsers(ctx, tag, func(it option.Hysteria2User) string {
+		return it.Name
+	}, func(it option.Hysteria2User) option.UserLimitOptions {
+		return it.UserLimitOptions
+	}, func(userList []int, users []option.Hysteria2User) error {
+		service.UpdateUsers(userList, common.Map(users, func(it option.Hysteria2User) string {
+			return it.Password
+		}))
+		return nil
+	})
+	err = inbound.users.reset(options.Users)
+	if err != nil {
+		return nil, err
 	}
-	service.UpdateUsers(userList, userPasswordList)
 	inbound.service = service
-	inbound.userNameList = userNameList
 	return inbound, nil
 }
 
+func (h *Hysteria2) Users() adapter.InboundUsers {
+	return h.users
+}
+
 func (h *Hysteria2) newConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
 	ctx = log.ContextWithNewID(ctx)
 	metadata = h.createMetadata(conn, metadata)
 	userID, _ := auth.UserFromContext[int](ctx)
-	if userName := h.userNameList[userID]; userName != "" {
+	userName, done, loaded := h.users.track(userID, conn)
+	if !loaded {
+		return E.New("user ", userID, " removed")
+	}
+	defer done()
+	if userName != "" {
 		metadata.User = userName
 		h.logger.InfoContext(ctx, "[", userName, "] inbound connection to ", metadata.Destination)
 	} else {
@@ -141,7 +157,12 @@ func (h *Hysteria2) newPacketConnection(ctx context.Context, conn N.PacketConn,
 	ctx = log.ContextWithNewID(ctx)
 	metadata = h.createPacketMetadata(conn, metadata)
 	userID, _ := auth.UserFromContext[int](ctx)
-	if userName := h.userNameList[userID]; userName != "" {
+	userName, done, loaded := h.users.track(userID, conn)
+	if !loaded {
+		return E.New("user ", userID, " removed")
+	}
+	defer done()
+	if userName != "" {
 		metadata.User = userName
 		h.logger.InfoContext(ctx, "[", userName, "] inbound packet connection to ", metadata.Destination)
 	} else {
@@ -151,6 +172,10 @@ func (h *Hysteria2) newPacketConnection(ctx context.Context, conn N.PacketConn,
 }
 
 func (h *Hysteria2) Start() error {
+	err := h.users.start()
+	if err != nil {
+		return err
+	}
 	if h.tlsConfig != nil {
 		err := h.tlsConfig.Start()
 		if err != nil {
@@ -165,6 +190,7 @@ func (h *Hysteria2) Start() error {
 }
 
 func (h *Hysteria2) Close() error {
+	h.users.close()
 	return common.Close(
 		&h.myInboundAdapter,
 		h.tlsConfig,
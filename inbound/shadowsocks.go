@@ -2,7 +2,9 @@ package inbound
 
 import (
 	"context"
+	"errors"
 	"net"
+	"net/http"
 	"os"
 	"time"
 
@@ -12,12 +14,14 @@ import (
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/transport/sip003"
 	"github.com/sagernet/sing-shadowsocks"
 	"github.com/sagernet/sing-shadowsocks/shadowaead"
 	"github.com/sagernet/sing-shadowsocks/shadowaead_2022"
 	"github.com/sagernet/sing/common"
 	"github.com/sagernet/sing/common/buf"
 	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
 	N "github.com/sagernet/sing/common/network"
 	"github.com/sagernet/sing/common/ntp"
 )
@@ -43,6 +47,7 @@ var (
 type Shadowsocks struct {
 	myInboundAdapter
 	service shadowsocks.Service
+	plugin  sip003.ServerPlugin
 }
 
 func newShadowsocks(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.ShadowsocksInboundOptions) (*Shadowsocks, error) {
@@ -65,6 +70,12 @@ func newShadowsocks(ctx context.Context, router adapter.Router, logger log.Conte
 	if err != nil {
 		return nil, err
 	}
+	if options.Plugin != "" {
+		inbound.plugin, err = newShadowsocksPlugin(&inbound.myInboundAdapter, options.Plugin, options.PluginPath, options.PluginOptions)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	var udpTimeout time.Duration
 	if options.UDPTimeout != 0 {
@@ -86,6 +97,17 @@ func newShadowsocks(ctx context.Context, router adapter.Router, logger log.Conte
 	return inbound, err
 }
 
+func (h *Shadowsocks) Start() error {
+	return startShadowsocks(&h.myInboundAdapter, h.plugin)
+}
+
+func (h *Shadowsocks) Close() error {
+	return common.Close(
+		&h.myInboundAdapter,
+		h.plugin,
+	)
+}
+
 func (h *Shadowsocks) NewConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
 	return h.service.NewConnection(adapter.WithContext(log.ContextWithNewID(ctx), &metadata), conn, adapter.UpstreamMetadata(metadata))
 }
@@ -97,3 +119,70 @@ func (h *Shadowsocks) NewPacket(ctx context.Context, conn N.PacketConn, buffer *
 func (h *Shadowsocks) NewPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
 	return os.ErrInvalid
 }
+
+func newShadowsocksPlugin(a *myInboundAdapter, name string, pluginPath string, pluginOptions string) (sip003.ServerPlugin, error) {
+	if !common.Contains(a.network, N.NetworkTCP) {
+		return nil, E.New("plugin requires TCP network")
+	}
+	listenAddr := M.SocksaddrFrom(a.listenOptions.Listen.Build(), a.listenOptions.ListenPort)
+	plugin, err := sip003.CreateServerPlugin(a.ctx, a.logger, name, pluginPath, pluginOptions, listenAddr, (*shadowsocksPluginHandler)(a))
+	if err != nil {
+		return nil, E.Cause(err, "create plugin: ", name)
+	}
+	return plugin, nil
+}
+
+// startShadowsocks starts the inbound. With a plugin, the plugin serves the
+// listeners it needs in place of the native TCP listener and, if the plugin
+// uses UDP, the native UDP listener.
+func startShadowsocks(a *myInboundAdapter, plugin sip003.ServerPlugin) error {
+	if plugin == nil {
+		return a.Start()
+	}
+	err := common.Start(plugin)
+	if err != nil {
+		return err
+	}
+	if common.Contains(plugin.Network(), N.NetworkTCP) {
+		tcpListener, err := a.ListenTCP()
+		if err != nil {
+			return err
+		}
+		go func() {
+			sErr := plugin.Serve(tcpListener)
+			if sErr != nil && !E.IsClosed(sErr) && !errors.Is(sErr, http.ErrServerClosed) {
+				a.logger.Error("plugin serve error: ", sErr)
+			}
+		}()
+	}
+	if common.Contains(plugin.Network(), N.NetworkUDP) {
+		udpConn, err := a.ListenUDP()
+		if err != nil {
+			return err
+		}
+		go func() {
+			sErr := plugin.ServePacket(udpConn)
+			if sErr != nil && !E.IsClosed(sErr) {
+				a.logger.Error("plugin serve error: ", sErr)
+			}
+		}()
+	} else if common.Contains(a.network, N.NetworkUDP) {
+		return a.startUDP()
+	}
+	return nil
+}
+
+var _ adapter.V2RayServerTransportHandler = (*shadowsocksPluginHandler)(nil)
+
+type shadowsocksPluginHandler myInboundAdapter
+
+func (h *shadowsocksPluginHandler) NewConnection(ctx context.Context, conn net.Conn, metadata M.Metadata) error {
+	(*myInboundAdapter)(h).injectTCP(conn, adapter.InboundContext{
+		Source: metadata.Source,
+	})
+	return nil
+}
+
+func (h *shadowsocksPluginHandler) NewError(ctx context.Context, err error) {
+	(*myInboundAdapter)(h).NewError(ctx, err)
+}
@@ -12,6 +12,7 @@ import (
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/transport/sip003"
 	"github.com/sagernet/sing-shadowsocks"
 	"github.com/sagernet/sing-shadowsocks/shadowaead"
 	"github.com/sagernet/sing-shadowsocks/shadowaead_2022"
@@ -25,14 +26,16 @@ import (
 )
 
 var (
-	_ adapter.Inbound           = (*ShadowsocksMulti)(nil)
-	_ adapter.InjectableInbound = (*ShadowsocksMulti)(nil)
+	_ adapter.Inbound            = (*ShadowsocksMulti)(nil)
+	_ adapter.InjectableInbound  = (*ShadowsocksMulti)(nil)
+	_ adapter.UserManagedInbound = (*ShadowsocksMulti)(nil)
 )
 
 type ShadowsocksMulti struct {
 	myInboundAdapter
 	service shadowsocks.MultiService[int]
-	users   []option.ShadowsocksUser
+	users   *inboundUsers[option.ShadowsocksUser]
+	plugin  sip003.ServerPlugin
 }
 
 func newShadowsocksMulti(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.ShadowsocksInboundOptions) (*ShadowsocksMulti, error) {
@@ -54,6 +57,12 @@ func newShadowsocksMulti(ctx context.Context, router adapter.Router, logger log.
 	if err != nil {
 		return nil, err
 	}
+	if options.Plugin != "" {
+		inbound.plugin, err = newShadowsocksPlugin(&inbound.myInboundAdapter, options.Plugin, options.PluginPath, options.PluginOptions)
+		if err != nil {
+			return nil, err
+		}
+	}
 	var udpTimeout time.Duration
 	if options.UDPTimeout != 0 {
 		udpTimeout = time.Duration(options.UDPTimeout)
@@ -80,20 +89,44 @@ func newShadowsocksMulti(ctx context.Context, router adapter.Router, logger log.
 	if err != nil {
 		return nil, err
 	}
-	err = service.UpdateUsersWithPasswords(common.MapIndexed(options.Users, func(index int, user option.ShadowsocksUser) int {
-		return index
-	}), common.Map(options.Users, func(user option.ShadowsocksUser) string {
-		return user.Password
-	}))
+	inbound.users = newInboundUsers(ctx, tag, func(it option.ShadowsocksUser) string {
+		return it.Name
+	}, func(it option.ShadowsocksUser) option.UserLimitOptions {
+		return it.UserLimitOptions
+	}, func(userList []int, users []option.ShadowsocksUser) error {
+		return service.UpdateUsersWithPasswords(userList, common.Map(users, func(it option.ShadowsocksUser) string {
+			return it.Password
+		}))
+	})
+	err = inbound.users.reset(options.Users)
 	if err != nil {
 		return nil, err
 	}
 	inbound.service = service
 	inbound.packetUpstream = service
-	inbound.users = options.Users
 	return inbound, err
 }
 
+func (h *ShadowsocksMulti) Users() adapter.InboundUsers {
+	return h.users
+}
+
+func (h *ShadowsocksMulti) Start() error {
+	err := h.users.start()
+	if err != nil {
+		return err
+	}
+	return startShadowsocks(&h.myInboundAdapter, h.plugin)
+}
+
+func (h *ShadowsocksMulti) Close() error {
+	h.users.close()
+	return common.Close(
+		&h.myInboundAdapter,
+		h.plugin,
+	)
+}
+
 func (h *ShadowsocksMulti) NewConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
 	return h.service.NewConnection(adapter.WithContext(log.ContextWithNewID(ctx), &metadata), conn, adapter.UpstreamMetadata(metadata))
 }
@@ -111,7 +144,11 @@ func (h *ShadowsocksMulti) newConnection(ctx context.Context, conn net.Conn, met
 	if !loaded {
 		return os.ErrInvalid
 	}
-	user := h.users[userIndex].Name
+	user, done, loaded := h.users.track(userIndex, conn)
+	if !loaded {
+		return E.New("user ", userIndex, " removed")
+	}
+	defer done()
 	if user == "" {
 		user = F.ToString(userIndex)
 	} else {
@@ -126,7 +163,11 @@ func (h *ShadowsocksMulti) newPacketConnection(ctx context.Context, conn N.Packe
 	if !loaded {
 		return os.ErrInvalid
 	}
-	user := h.users[userIndex].Name
+	user, done, loaded := h.users.track(userIndex, conn)
+	if !loaded {
+		return E.New("user ", userIndex, " removed")
+	}
+	defer done()
 	if user == "" {
 		user = F.ToString(userIndex)
 	} else {
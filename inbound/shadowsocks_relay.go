@@ -12,6 +12,7 @@ import (
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/transport/sip003"
 	"github.com/sagernet/sing-shadowsocks/shadowaead_2022"
 	"github.com/sagernet/sing/common"
 	"github.com/sagernet/sing/common/auth"
@@ -29,6 +30,7 @@ type ShadowsocksRelay struct {
 	myInboundAdapter
 	service      *shadowaead_2022.RelayService[int]
 	destinations []option.ShadowsocksDestination
+	plugin       sip003.ServerPlugin
 }
 
 func newShadowsocksRelay(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.ShadowsocksInboundOptions) (*ShadowsocksRelay, error) {
@@ -51,6 +53,12 @@ func newShadowsocksRelay(ctx context.Context, router adapter.Router, logger log.
 	if err != nil {
 		return nil, err
 	}
+	if options.Plugin != "" {
+		inbound.plugin, err = newShadowsocksPlugin(&inbound.myInboundAdapter, options.Plugin, options.PluginPath, options.PluginOptions)
+		if err != nil {
+			return nil, err
+		}
+	}
 	var udpTimeout time.Duration
 	if options.UDPTimeout != 0 {
 		udpTimeout = time.Duration(options.UDPTimeout)
@@ -79,6 +87,17 @@ func newShadowsocksRelay(ctx context.Context, router adapter.Router, logger log.
 	return inbound, err
 }
 
+func (h *ShadowsocksRelay) Start() error {
+	return startShadowsocks(&h.myInboundAdapter, h.plugin)
+}
+
+func (h *ShadowsocksRelay) Close() error {
+	return common.Close(
+		&h.myInboundAdapter,
+		h.plugin,
+	)
+}
+
 func (h *ShadowsocksRelay) NewConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
 	return h.service.NewConnection(adapter.WithContext(log.ContextWithNewID(ctx), &metadata), conn, adapter.UpstreamMetadata(metadata))
 }
@@ -0,0 +1,312 @@
+package inbound
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/subtle"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	_ adapter.Inbound            = (*SSH)(nil)
+	_ adapter.InjectableInbound  = (*SSH)(nil)
+	_ adapter.UserManagedInbound = (*SSH)(nil)
+)
+
+const sshUserIDExtension = "sing-box-user-id"
+
+type SSH struct {
+	myInboundAdapter
+	config     *ssh.ServerConfig
+	users      *inboundUsers[option.SSHUser]
+	userAccess sync.RWMutex
+	userMap    map[string]sshUser
+}
+
+type sshUser struct {
+	id             int
+	password       string
+	authorizedKeys map[string]bool
+}
+
+func NewSSH(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.SSHInboundOptions) (*SSH, error) {
+	inbound := &SSH{
+		myInboundAdapter: myInboundAdapter{
+			protocol:      C.TypeSSH,
+			network:       []string{N.NetworkTCP},
+			ctx:           ctx,
+			router:        router,
+			logger:        logger,
+			tag:           tag,
+			listenOptions: options.ListenOptions,
+		},
+	}
+	if len(options.Users) == 0 {
+		return nil, E.New("missing users")
+	}
+	inbound.config = &ssh.ServerConfig{
+		PasswordCallback:  inbound.passwordCallback,
+		PublicKeyCallback: inbound.publicKeyCallback,
+		ServerVersion:     options.ServerVersion,
+	}
+	hostKey, err := newSSHHostKey(logger, options)
+	if err != nil {
+		return nil, err
+	}
+	inbound.config.AddHostKey(hostKey)
+	inbound.users = newInboundUsers(ctx, tag, func(it option.SSHUser) string {
+		return it.Name
+	}, func(it option.SSHUser) option.UserLimitOptions {
+		return it.UserLimitOptions
+	}, func(userList []int, users []option.SSHUser) error {
+		userMap := make(map[string]sshUser)
+		for index, user := range users {
+			if user.Name == "" {
+				return E.New("missing name for user ", index)
+			}
+			if user.Password == "" && len(user.AuthorizedKeys) == 0 {
+				return E.New("missing password or authorized_keys for user ", user.Name)
+			}
+			authorizedKeys, err := parseAuthorizedKeys(user.AuthorizedKeys)
+			if err != nil {
+				return E.Cause(err, "parse authorized_keys for user ", user.Name)
+			}
+			userMap[user.Name] = sshUser{
+				id:             userList[index],
+				password:       user.Password,
+				authorizedKeys: authorizedKeys,
+			}
+		}
+		inbound.userAccess.Lock()
+		inbound.userMap = userMap
+		inbound.userAccess.Unlock()
+		return nil
+	})
+	err = inbound.users.reset(options.Users)
+	if err != nil {
+		return nil, err
+	}
+	inbound.connHandler = inbound
+	return inbound, nil
+}
+
+func newSSHHostKey(logger log.ContextLogger, options option.SSHInboundOptions) (ssh.Signer, error) {
+	var hostKey []byte
+	if len(options.HostKey) > 0 {
+		hostKey = []byte(strings.Join(options.HostKey, "\n"))
+	} else if options.HostKeyPath != "" {
+		var err error
+		hostKey, err = os.ReadFile(os.ExpandEnv(options.HostKeyPath))
+		if err != nil {
+			return nil, E.Cause(err, "read host key")
+		}
+	} else {
+		logger.Warn("host key not configured, using a temporary key")
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return ssh.NewSignerFromKey(privateKey)
+	}
+	signer, err := ssh.ParsePrivateKey(hostKey)
+	if err != nil {
+		return nil, E.Cause(err, "parse host key")
+	}
+	return signer, nil
+}
+
+func parseAuthorizedKeys(authorizedKeys []string) (map[string]bool, error) {
+	keys := make(map[string]bool)
+	for _, content := range authorizedKeys {
+		rest := []byte(content)
+		for len(strings.TrimSpace(string(rest))) > 0 {
+			publicKey, _, _, next, err := ssh.ParseAuthorizedKey(rest)
+			if err != nil {
+				return nil, err
+			}
+			keys[string(publicKey.Marshal())] = true
+			rest = next
+		}
+	}
+	return keys, nil
+}
+
+func (h *SSH) Start() error {
+	err := h.users.start()
+	if err != nil {
+		return err
+	}
+	return h.myInboundAdapter.Start()
+}
+
+func (h *SSH) Close() error {
+	h.users.close()
+	return h.myInboundAdapter.Close()
+}
+
+func (h *SSH) Users() adapter.InboundUsers {
+	return h.users
+}
+
+func (h *SSH) loadUser(name string) (sshUser, bool) {
+	h.userAccess.RLock()
+	defer h.userAccess.RUnlock()
+	user, loaded := h.userMap[name]
+	return user, loaded
+}
+
+func (h *SSH) passwordCallback(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+	user, loaded := h.loadUser(conn.User())
+	if !loaded || user.password == "" || subtle.ConstantTimeCompare([]byte(user.password), password) != 1 {
+		return nil, E.New("password rejected for ", conn.User())
+	}
+	return sshPermissions(user), nil
+}
+
+func (h *SSH) publicKeyCallback(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+	user, loaded := h.loadUser(conn.User())
+	if !loaded || !user.authorizedKeys[string(key.Marshal())] {
+		return nil, E.New("public key rejected for ", conn.User())
+	}
+	return sshPermissions(user), nil
+}
+
+func sshPermissions(user sshUser) *ssh.Permissions {
+	return &ssh.Permissions{
+		Extensions: map[string]string{
+			sshUserIDExtension: strconv.Itoa(user.id),
+		},
+	}
+}
+
+func (h *SSH) NewConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	err := conn.SetDeadline(time.Now().Add(C.TCPTimeout))
+	if err != nil {
+		return err
+	}
+	serverConn, channels, requests, err := ssh.NewServerConn(conn, h.config)
+	if err != nil {
+		return err
+	}
+	defer serverConn.Close()
+	err = conn.SetDeadline(time.Time{})
+	if err != nil {
+		return err
+	}
+	userID, err := strconv.Atoi(serverConn.Permissions.Extensions[sshUserIDExtension])
+	if err != nil {
+		return err
+	}
+	// the connection is closed with all its channels once the user is removed
+	user, done, loaded := h.users.track(userID, serverConn)
+	if !loaded {
+		return E.New("user removed: ", serverConn.User())
+	}
+	defer done()
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "direct-tcpip" {
+			newChannel.Reject(ssh.Prohibited, "only port forwarding is allowed")
+			continue
+		}
+		go h.newChannel(ctx, serverConn, user, newChannel, metadata)
+	}
+	return nil
+}
+
+func (h *SSH) NewPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
+	return os.ErrInvalid
+}
+
+// sshDirectTCPIP is the payload of a direct-tcpip channel open request, see
+// RFC 4254 section 7.2.
+type sshDirectTCPIP struct {
+	Host       string
+	Port       uint32
+	OriginHost string
+	OriginPort uint32
+}
+
+func (h *SSH) newChannel(ctx context.Context, serverConn *ssh.ServerConn, user string, newChannel ssh.NewChannel, metadata adapter.InboundContext) {
+	var request sshDirectTCPIP
+	err := ssh.Unmarshal(newChannel.ExtraData(), &request)
+	if err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, "invalid request")
+		return
+	}
+	if request.Port > 65535 {
+		newChannel.Reject(ssh.ConnectionFailed, "invalid port")
+		return
+	}
+	destination := M.ParseSocksaddrHostPort(request.Host, uint16(request.Port))
+	if !destination.IsValid() {
+		newChannel.Reject(ssh.ConnectionFailed, "invalid destination")
+		return
+	}
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		h.NewError(ctx, E.Cause(err, "accept channel"))
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	conn := &sshChannelConn{
+		Channel:    channel,
+		localAddr:  serverConn.LocalAddr(),
+		remoteAddr: serverConn.RemoteAddr(),
+	}
+	ctx = log.ContextWithNewID(ctx)
+	metadata.User = user
+	metadata.Destination = destination
+	h.logger.InfoContext(ctx, "[", user, "] inbound connection to ", metadata.Destination)
+	err = h.router.RouteConnection(ctx, conn, metadata)
+	if err != nil {
+		conn.Close()
+		h.NewError(ctx, err)
+	}
+}
+
+type sshChannelConn struct {
+	ssh.Channel
+	localAddr  net.Addr
+	remoteAddr net.Addr
+}
+
+func (c *sshChannelConn) LocalAddr() net.Addr {
+	return c.localAddr
+}
+
+func (c *sshChannelConn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+func (c *sshChannelConn) SetDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func (c *sshChannelConn) SetReadDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func (c *sshChannelConn) SetWriteDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func (c *sshChannelConn) NeedAdditionalReadDeadline() bool {
+	return true
+}
@@ -22,14 +22,15 @@ import (
 )
 
 var (
-	_ adapter.Inbound           = (*Trojan)(nil)
-	_ adapter.InjectableInbound = (*Trojan)(nil)
+	_ adapter.Inbound            = (*Trojan)(nil)
+	_ adapter.InjectableInbound  = (*Trojan)(nil)
+	_ adapter.UserManagedInbound = (*Trojan)(nil)
 )
 
 type Trojan struct {
 	myInboundAdapter
 	service                  *trojan.Service[int]
-	users                    []option.TrojanUser
+	users                    *inboundUsers[option.TrojanUser]
 	tlsConfig                tls.ServerConfig
 	fallbackAddr             M.Socksaddr
 	fallbackAddrTLSNextProto map[string]M.Socksaddr
@@ -47,7 +48,6 @@ func NewTrojan(ctx context.Context, router adapter.Router, logger log.ContextLog
 			tag:           tag,
 			listenOptions: options.ListenOptions,
 		},
-		users: options.Users,
 	}
 	if options.TLS != nil {
 		tlsConfig, err := tls.NewServer(ctx, logger, common.PtrValueOrDefault(options.TLS))
@@ -81,11 +81,16 @@ func NewTrojan(ctx context.Context, router adapter.Router, logger log.ContextLog
 		fallbackHandler = adapter.NewUpstreamContextHandler(inbound.fallbackConnection, nil, nil)
 	}
 	service := trojan.NewService[int](adapter.NewUpstreamContextHandler(inbound.newConnection, inbound.newPacketConnection, inbound), fallbackHandler)
-	err := service.UpdateUsers(common.MapIndexed(options.Users, func(index int, it option.TrojanUser) int {
-		return index
-	}), common.Map(options.Users, func(it option.TrojanUser) string {
-		return it.Password
-	}))
+	inbound.users = newInboundUsers(ctx, tag, func(it option.TrojanUser) string {
+		return it.Name
+	}, func(it option.TrojanUser) option.UserLimitOptions {
+		return it.UserLimitOptions
+	}, func(userList []int, users []option.TrojanUser) error {
+		return service.UpdateUsers(userList, common.Map(users, func(it option.TrojanUser) string {
+			return it.Password
+		}))
+	})
+	err := inbound.users.reset(options.Users)
 	if err != nil {
 		return nil, err
 	}
@@ -104,7 +109,15 @@ func NewTrojan(ctx context.Context, router adapter.Router, logger log.ContextLog
 	return inbound, nil
 }
 
+func (h *Trojan) Users() adapter.InboundUsers {
+	return h.users
+}
+
 func (h *Trojan) Start() error {
+	err := h.users.start()
+	if err != nil {
+		return err
+	}
 	if h.tlsConfig != nil {
 		err := h.tlsConfig.Start()
 		if err != nil {
@@ -142,6 +155,7 @@ func (h *Trojan) Start() error {
 }
 
 func (h *Trojan) Close() error {
+	h.users.close()
 	return common.Close(
 		&h.myInboundAdapter,
 		h.tlsConfig,
@@ -174,7 +188,11 @@ func (h *Trojan) newConnection(ctx context.Context, conn net.Conn, metadata adap
 	if !loaded {
 		return os.ErrInvalid
 	}
-	user := h.users[userIndex].Name
+	user, done, loaded := h.users.track(userIndex, conn)
+	if !loaded {
+		return E.New("user ", userIndex, " removed")
+	}
+	defer done()
 	if user == "" {
 		user = F.ToString(userIndex)
 	} else {
@@ -212,7 +230,11 @@ func (h *Trojan) newPacketConnection(ctx context.Context, conn N.PacketConn, met
 	if !loaded {
 		return os.ErrInvalid
 	}
-	user := h.users[userIndex].Name
+	user, done, loaded := h.users.track(userIndex, conn)
+	if !loaded {
+		return E.New("user ", userIndex, " removed")
+	}
+	defer done()
 	if user == "" {
 		user = F.ToString(userIndex)
 	} else {
@@ -22,13 +22,16 @@ import (
 	"github.com/gofrs/uuid/v5"
 )
 
-var _ adapter.Inbound = (*TUIC)(nil)
+var (
+	_ adapter.Inbound            = (*TUIC)(nil)
+	_ adapter.UserManagedInbound = (*TUIC)(nil)
+)
 
 type TUIC struct {
 	myInboundAdapter
-	tlsConfig    tls.ServerConfig
-	server       *tuic.Service[int]
-	userNameList []string
+	tlsConfig tls.ServerConfig
+	server    *tuic.Service[int]
+	users     *inboundUsers[option.TUICUser]
 }
 
 func NewTUIC(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.TUICInboundOptions) (*TUIC, error) {
@@ -72,34 +75,49 @@ func NewTUIC(ctx context.Context, router adapter.Router, logger log.ContextLogge
 	if err != nil {
 		return nil, err
 	}
-	var userList []int
-	var userNameList []string
-	var userUUIDList [][16]byte
-	var userPasswordList []string
-	for index, user := range options.Users {
-		if user.UUID == "" {
-			return nil, E.New("missing uuid for user ", index)
-		}
-		userUUID, err := uuid.FromString(user.UUID)
-		if err != nil {
-			return nil, E.Cause(err, "invalid uuid for user ", index)
+	inbound.users = newInboundUsers(ctx, tag, func(it option.TUICUser) string {
+		return it.Name
+	}, func(it option.TUICUser) option.UserLimitOptions {
+		return it.UserLimitOptions
+	}, func(userList []int, users []option.TUICUser) error {
+		userUUIDList := make([][16]byte, 0, len(users))
+		for index, user := range users {
+			if user.UUID == "" {
+				return E.New("missing uuid for user ", index)
+			}
+			userUUID, err := uuid.FromString(user.UUID)
+			if err != nil {
+				return E.Cause(err, "invalid uuid for user ", index)
+			}
+			userUUIDList = append(userUUIDList, userUUID)
 		}
-		userList = append(userList, index)
-		userNameList = append(userNameList, user.Name)
-		userUUIDList = append(userUUIDList, userUUID)
-		userPasswordList = append(userPasswordList, user.Password)
+		service.UpdateUsers(userList, userUUIDList, common.Map(users, func(it option.TUICUser) string {
+			return it.Password
+		}))
+		return nil
+	})
+	err = inbound.users.reset(options.Users)
+	if err != nil {
+		return nil, err
 	}
-	service.UpdateUsers(userList, userUUIDList, userPasswordList)
 	inbound.server = service
-	inbound.userNameList = userNameList
 	return inbound, nil
 }
 
+func (h *TUIC) Users() adapter.InboundUsers {
+	return h.users
+}
+
 func (h *TUIC) newConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
 	ctx = log.ContextWithNewID(ctx)
 	metadata = h.createMetadata(conn, metadata)
 	userID, _ := auth.UserFromContext[int](ctx)
-	if userName := h.userNameList[userID]; userName != "" {
+	userName, done, loaded := h.users.track(userID, conn)
+	if !loaded {
+		return E.New("user ", userID, " removed")
+	}
+	defer done()
+	if userName != "" {
 		metadata.User = userName
 		h.logger.InfoContext(ctx, "[", userName, "] inbound connection to ", metadata.Destination)
 	} else {
@@ -112,7 +130,12 @@ func (h *TUIC) newPacketConnection(ctx context.Context, conn N.PacketConn, metad
 	ctx = log.ContextWithNewID(ctx)
 	metadata = h.createPacketMetadata(conn, metadata)
 	userID, _ := auth.UserFromContext[int](ctx)
-	if userName := h.userNameList[userID]; userName != "" {
+	userName, done, loaded := h.users.track(userID, conn)
+	if !loaded {
+		return E.New("user ", userID, " removed")
+	}
+	defer done()
+	if userName != "" {
 		metadata.User = userName
 		h.logger.InfoContext(ctx, "[", userName, "] inbound packet connection to ", metadata.Destination)
 	} else {
@@ -122,6 +145,10 @@ func (h *TUIC) newPacketConnection(ctx context.Context, conn N.PacketConn, metad
 }
 
 func (h *TUIC) Start() error {
+	err := h.users.start()
+	if err != nil {
+		return err
+	}
 	if h.tlsConfig != nil {
 		err := h.tlsConfig.Start()
 		if err != nil {
@@ -136,6 +163,7 @@ func (h *TUIC) Start() error {
 }
 
 func (h *TUIC) Close() error {
+	h.users.close()
 	return common.Close(
 		&h.myInboundAdapter,
 		h.tlsConfig,
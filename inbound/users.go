@@ -0,0 +1,289 @@
+package inbound
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/userlimit"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+)
+
+var _ adapter.InboundUsers = (*inboundUsers[option.VMessUser])(nil)
+
+// inboundUsers holds users of a multi-user inbound. Each user keeps a stable id
+// as long as it exists, so that the protocol service can be updated while
+// connections authenticated by id are in flight.
+type inboundUsers[U any] struct {
+	ctx      context.Context
+	tag      string
+	userName func(U) string
+	limits   func(U) option.UserLimitOptions
+	update   func(userList []int, users []U) error
+	access   sync.RWMutex
+	ids      []int
+	users    map[int]U
+	nextID   int
+	conns    map[int]map[*trackedConn]struct{}
+	started  bool
+}
+
+type trackedConn struct {
+	conn any
+}
+
+func newInboundUsers[U any](ctx context.Context, tag string, userName func(U) string, limits func(U) option.UserLimitOptions, update func(userList []int, users []U) error) *inboundUsers[U] {
+	return &inboundUsers[U]{
+		ctx:      ctx,
+		tag:      tag,
+		userName: userName,
+		limits:   limits,
+		update:   update,
+		users:    make(map[int]U),
+		conns:    make(map[int]map[*trackedConn]struct{}),
+	}
+}
+
+// track returns the name of the user and registers the connection to be closed
+// when the user is removed. loaded is false if the user no longer exists.
+func (u *inboundUsers[U]) track(id int, conn any) (name string, done func(), loaded bool) {
+	u.access.Lock()
+	defer u.access.Unlock()
+	user, loaded := u.users[id]
+	if !loaded {
+		return
+	}
+	tracked := &trackedConn{conn}
+	conns := u.conns[id]
+	if conns == nil {
+		conns = make(map[*trackedConn]struct{})
+		u.conns[id] = conns
+	}
+	conns[tracked] = struct{}{}
+	return u.userName(user), func() {
+		u.access.Lock()
+		defer u.access.Unlock()
+		delete(u.conns[id], tracked)
+		if len(u.conns[id]) == 0 {
+			delete(u.conns, id)
+		}
+	}, true
+}
+
+func (u *inboundUsers[U]) List() []any {
+	u.access.RLock()
+	defer u.access.RUnlock()
+	return common.Map(u.ids, func(id int) any {
+		return u.users[id]
+	})
+}
+
+func (u *inboundUsers[U]) Add(content []byte) error {
+	user, err := json.UnmarshalExtended[U](content)
+	if err != nil {
+		return err
+	}
+	name := u.userName(user)
+	if name == "" {
+		return E.New("missing user name")
+	}
+	u.access.Lock()
+	defer u.access.Unlock()
+	if _, loaded := u.find(name); loaded {
+		return E.New("user ", name, " already exists")
+	}
+	ids := make([]int, 0, len(u.ids)+1)
+	ids = append(append(ids, u.ids...), u.nextID)
+	users := make(map[int]U, len(u.users)+1)
+	for id, it := range u.users {
+		users[id] = it
+	}
+	users[u.nextID] = user
+	err = u.apply(ids, users)
+	if err != nil {
+		return err
+	}
+	u.nextID++
+	return nil
+}
+
+func (u *inboundUsers[U]) Update(name string, content []byte) error {
+	user, err := json.UnmarshalExtended[U](content)
+	if err != nil {
+		return err
+	}
+	if newName := u.userName(user); newName != name {
+		return E.New("user name mismatch: ", newName)
+	}
+	u.access.Lock()
+	defer u.access.Unlock()
+	id, loaded := u.find(name)
+	if !loaded {
+		return E.New("user ", name, " not found")
+	}
+	users := make(map[int]U, len(u.users))
+	for userID, it := range u.users {
+		users[userID] = it
+	}
+	users[id] = user
+	return u.apply(u.ids, users)
+}
+
+func (u *inboundUsers[U]) Remove(name string) error {
+	u.access.Lock()
+	defer u.access.Unlock()
+	id, loaded := u.find(name)
+	if !loaded {
+		return E.New("user ", name, " not found")
+	}
+	users := make(map[int]U, len(u.users))
+	for userID, it := range u.users {
+		if userID != id {
+			users[userID] = it
+		}
+	}
+	return u.apply(common.Filter(u.ids, func(it int) bool {
+		return it != id
+	}), users)
+}
+
+func (u *inboundUsers[U]) Reset(content []byte) error {
+	userList, err := json.UnmarshalExtended[[]U](content)
+	if err != nil {
+		return err
+	}
+	return u.reset(userList)
+}
+
+// reset replaces all users. Users with the same name as an existing user keep
+// its id and connections.
+func (u *inboundUsers[U]) reset(userList []U) error {
+	u.access.Lock()
+	defer u.access.Unlock()
+	nextID := u.nextID
+	ids := make([]int, 0, len(userList))
+	users := make(map[int]U, len(userList))
+	names := make(map[string]bool)
+	for _, user := range userList {
+		name := u.userName(user)
+		if name != "" {
+			if names[name] {
+				return E.New("duplicate user name: ", name)
+			}
+			names[name] = true
+		}
+		id, loaded := u.find(name)
+		if !loaded {
+			id = nextID
+			nextID++
+		}
+		ids = append(ids, id)
+		users[id] = user
+	}
+	err := u.apply(ids, users)
+	if err != nil {
+		return err
+	}
+	u.nextID = nextID
+	return nil
+}
+
+func (u *inboundUsers[U]) find(name string) (int, bool) {
+	if name == "" {
+		return 0, false
+	}
+	for id, user := range u.users {
+		if u.userName(user) == name {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// start registers limits of the users. Limits are registered only while the
+// inbound runs, so that an inbound replacing another one with the same tag on
+// reload does not change limits before it starts.
+func (u *inboundUsers[U]) start() error {
+	u.access.Lock()
+	defer u.access.Unlock()
+	u.started = true
+	for _, id := range u.ids {
+		user := u.users[id]
+		err := userlimit.Register(u.ctx, u.tag, u.userName(user), u.limits(user))
+		if err != nil {
+			return E.Cause(err, "register user limits")
+		}
+	}
+	return nil
+}
+
+func (u *inboundUsers[U]) close() {
+	u.access.Lock()
+	defer u.access.Unlock()
+	if !u.started {
+		return
+	}
+	u.started = false
+	for _, user := range u.users {
+		userlimit.Unregister(u.ctx, u.tag, u.userName(user))
+	}
+}
+
+// apply updates the protocol service with the users, then, if started,
+// registers their limits and unregisters limits of users that were removed or
+// lost limits.
+func (u *inboundUsers[U]) apply(ids []int, users map[int]U) error {
+	for _, id := range ids {
+		user := users[id]
+		err := userlimit.Check(u.ctx, u.tag, u.userName(user), u.limits(user))
+		if err != nil {
+			return E.Cause(err, "check user limits")
+		}
+	}
+	err := u.update(ids, common.Map(ids, func(id int) U {
+		return users[id]
+	}))
+	if err != nil {
+		return err
+	}
+	if u.started {
+		limitedUsers := make(map[string]bool)
+		for _, id := range ids {
+			user := users[id]
+			name := u.userName(user)
+			limits := u.limits(user)
+			if limits.IsEmpty() {
+				continue
+			}
+			limitedUsers[name] = true
+			err = E.Errors(err, userlimit.Register(u.ctx, u.tag, name, limits))
+		}
+		for _, user := range u.users {
+			if name := u.userName(user); !limitedUsers[name] {
+				userlimit.Unregister(u.ctx, u.tag, name)
+			}
+		}
+	}
+	var removedConns []any
+	for id, conns := range u.conns {
+		if _, loaded := users[id]; loaded {
+			continue
+		}
+		for tracked := range conns {
+			removedConns = append(removedConns, tracked.conn)
+		}
+		delete(u.conns, id)
+	}
+	u.ids = ids
+	u.users = users
+	if len(removedConns) > 0 {
+		go common.Close(removedConns...)
+	}
+	if err != nil {
+		return E.Cause(err, "register user limits")
+	}
+	return nil
+}
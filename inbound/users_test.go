@@ -0,0 +1,59 @@
+package inbound
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sagernet/sing-box/common/userlimit"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/service"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestInboundUsers(ctx context.Context, users []option.TrojanUser) (*inboundUsers[option.TrojanUser], error) {
+	inboundUsers := newInboundUsers(ctx, "in", func(it option.TrojanUser) string {
+		return it.Name
+	}, func(it option.TrojanUser) option.UserLimitOptions {
+		return it.UserLimitOptions
+	}, func(userList []int, users []option.TrojanUser) error {
+		return nil
+	})
+	return inboundUsers, inboundUsers.reset(users)
+}
+
+func TestInboundUsersLimits(t *testing.T) {
+	t.Parallel()
+	ctx := service.ContextWithDefaultRegistry(context.Background())
+	manager := userlimit.NewManager(ctx, log.NewNOPFactory().Logger())
+	service.MustRegisterPtr(ctx, manager)
+	limits := func(maxConnections int) option.UserLimitOptions {
+		return option.UserLimitOptions{MaxConnections: maxConnections}
+	}
+	users, err := newTestInboundUsers(ctx, []option.TrojanUser{{Name: "user", UserLimitOptions: limits(1)}})
+	require.NoError(t, err)
+	// limits are registered only while the inbound runs
+	require.NoError(t, manager.Check("other", "user", limits(2)))
+	require.NoError(t, users.start())
+	require.ErrorContains(t, manager.Check("other", "user", limits(2)), "conflicting limits")
+
+	// an inbound replacing it on reload changes the limits when it starts
+	replacement, err := newTestInboundUsers(ctx, []option.TrojanUser{{Name: "user", UserLimitOptions: limits(3)}})
+	require.NoError(t, err)
+	require.NoError(t, manager.Check("other", "user", limits(1)))
+	replacement.close()
+	require.NoError(t, manager.Check("other", "user", limits(1)))
+	users.close()
+	require.NoError(t, manager.Check("other", "user", limits(2)))
+	require.NoError(t, replacement.start())
+	require.NoError(t, manager.Check("other", "user", limits(3)))
+	require.ErrorContains(t, manager.Check("other", "user", limits(1)), "conflicting limits")
+
+	// users changed while running update the limits
+	require.NoError(t, replacement.Reset([]byte(`[{"name": "user", "max_connections": 4}]`)))
+	require.NoError(t, manager.Check("other", "user", limits(4)))
+	require.NoError(t, replacement.Reset([]byte(`[]`)))
+	require.NoError(t, manager.Check("other", "user", limits(2)))
+	replacement.close()
+}
@@ -25,14 +25,15 @@ import (
 )
 
 var (
-	_ adapter.Inbound           = (*VLESS)(nil)
-	_ adapter.InjectableInbound = (*VLESS)(nil)
+	_ adapter.Inbound            = (*VLESS)(nil)
+	_ adapter.InjectableInbound  = (*VLESS)(nil)
+	_ adapter.UserManagedInbound = (*VLESS)(nil)
 )
 
 type VLESS struct {
 	myInboundAdapter
 	ctx       context.Context
-	users     []option.VLESSUser
+	users     *inboundUsers[option.VLESSUser]
 	service   *vless.Service[int]
 	tlsConfig tls.ServerConfig
 	transport adapter.V2RayServerTransport
@@ -49,8 +50,7 @@ func NewVLESS(ctx context.Context, router adapter.Router, logger log.ContextLogg
 			tag:           tag,
 			listenOptions: options.ListenOptions,
 		},
-		ctx:   ctx,
-		users: options.Users,
+		ctx: ctx,
 	}
 	var err error
 	inbound.router, err = mux.NewRouterWithOptions(inbound.router, logger, common.PtrValueOrDefault(options.Multiplex))
@@ -58,13 +58,22 @@ func NewVLESS(ctx context.Context, router adapter.Router, logger log.ContextLogg
 		return nil, err
 	}
 	service := vless.NewService[int](logger, adapter.NewUpstreamContextHandler(inbound.newConnection, inbound.newPacketConnection, inbound))
-	service.UpdateUsers(common.MapIndexed(inbound.users, func(index int, _ option.VLESSUser) int {
-		return index
-	}), common.Map(inbound.users, func(it option.VLESSUser) string {
-		return it.UUID
-	}), common.Map(inbound.users, func(it option.VLESSUser) string {
-		return it.Flow
-	}))
+	inbound.users = newInboundUsers(ctx, tag, func(it option.VLESSUser) string {
+		return it.Name
+	}, func(it option.VLESSUser) option.UserLimitOptions {
+		return it.UserLimitOptions
+	}, func(userList []int, users []option.VLESSUser) error {
+		service.UpdateUsers(userList, common.Map(users, func(it option.VLESSUser) string {
+			return it.UUID
+		}), common.Map(users, func(it option.VLESSUser) string {
+			return it.Flow
+		}))
+		return nil
+	})
+	err = inbound.users.reset(options.Users)
+	if err != nil {
+		return nil, err
+	}
 	inbound.service = service
 	if options.TLS != nil {
 		inbound.tlsConfig, err = tls.NewServer(ctx, logger, common.PtrValueOrDefault(options.TLS))
@@ -82,8 +91,16 @@ func NewVLESS(ctx context.Context, router adapter.Router, logger log.ContextLogg
 	return inbound, nil
 }
 
+func (h *VLESS) Users() adapter.InboundUsers {
+	return h.users
+}
+
 func (h *VLESS) Start() error {
-	err := common.Start(
+	err := h.users.start()
+	if err != nil {
+		return err
+	}
+	err = common.Start(
 		h.service,
 		h.tlsConfig,
 	)
@@ -121,6 +138,7 @@ func (h *VLESS) Start() error {
 }
 
 func (h *VLESS) Close() error {
+	h.users.close()
 	return common.Close(
 		h.service,
 		&h.myInboundAdapter,
@@ -154,7 +172,11 @@ func (h *VLESS) newConnection(ctx context.Context, conn net.Conn, metadata adapt
 	if !loaded {
 		return os.ErrInvalid
 	}
-	user := h.users[userIndex].Name
+	user, done, loaded := h.users.track(userIndex, conn)
+	if !loaded {
+		return E.New("user ", userIndex, " removed")
+	}
+	defer done()
 	if user == "" {
 		user = F.ToString(userIndex)
 	} else {
@@ -169,7 +191,11 @@ func (h *VLESS) newPacketConnection(ctx context.Context, conn N.PacketConn, meta
 	if !loaded {
 		return os.ErrInvalid
 	}
-	user := h.users[userIndex].Name
+	user, done, loaded := h.users.track(userIndex, conn)
+	if !loaded {
+		return E.New("user ", userIndex, " removed")
+	}
+	defer done()
 	if user == "" {
 		user = F.ToString(userIndex)
 	} else {
@@ -25,15 +25,16 @@ import (
 )
 
 var (
-	_ adapter.Inbound           = (*VMess)(nil)
-	_ adapter.InjectableInbound = (*VMess)(nil)
+	_ adapter.Inbound            = (*VMess)(nil)
+	_ adapter.InjectableInbound  = (*VMess)(nil)
+	_ adapter.UserManagedInbound = (*VMess)(nil)
 )
 
 type VMess struct {
 	myInboundAdapter
 	ctx       context.Context
 	service   *vmess.Service[int]
-	users     []option.VMessUser
+	users     *inboundUsers[option.VMessUser]
 	tlsConfig tls.ServerConfig
 	transport adapter.V2RayServerTransport
 }
@@ -49,8 +50,7 @@ func NewVMess(ctx context.Context, router adapter.Router, logger log.ContextLogg
 			tag:           tag,
 			listenOptions: options.ListenOptions,
 		},
-		ctx:   ctx,
-		users: options.Users,
+		ctx: ctx,
 	}
 	var err error
 	inbound.router, err = mux.NewRouterWithOptions(inbound.router, logger, common.PtrValueOrDefault(options.Multiplex))
@@ -66,13 +66,18 @@ func NewVMess(ctx context.Context, router adapter.Router, logger log.ContextLogg
 	}
 	service := vmess.NewService[int](adapter.NewUpstreamContextHandler(inbound.newConnection, inbound.newPacketConnection, inbound), serviceOptions...)
 	inbound.service = service
-	err = service.UpdateUsers(common.MapIndexed(options.Users, func(index int, it option.VMessUser) int {
-		return index
-	}), common.Map(options.Users, func(it option.VMessUser) string {
-		return it.UUID
-	}), common.Map(options.Users, func(it option.VMessUser) int {
-		return it.AlterId
-	}))
+	inbound.users = newInboundUsers(ctx, tag, func(it option.VMessUser) string {
+		return it.Name
+	}, func(it option.VMessUser) option.UserLimitOptions {
+		return it.UserLimitOptions
+	}, func(userList []int, users []option.VMessUser) error {
+		return service.UpdateUsers(userList, common.Map(users, func(it option.VMessUser) string {
+			return it.UUID
+		}), common.Map(users, func(it option.VMessUser) int {
+			return it.AlterId
+		}))
+	})
+	err = inbound.users.reset(options.Users)
 	if err != nil {
 		return nil, err
 	}
@@ -92,8 +97,16 @@ func NewVMess(ctx context.Context, router adapter.Router, logger log.ContextLogg
 	return inbound, nil
 }
 
+func (h *VMess) Users() adapter.InboundUsers {
+	return h.users
+}
+
 func (h *VMess) Start() error {
-	err := common.Start(
+	err := h.users.start()
+	if err != nil {
+		return err
+	}
+	err = common.Start(
 		h.service,
 		h.tlsConfig,
 	)
@@ -131,6 +144,7 @@ func (h *VMess) Start() error {
 }
 
 func (h *VMess) Close() error {
+	h.users.close()
 	return common.Close(
 		h.service,
 		&h.myInboundAdapter,
@@ -164,7 +178,11 @@ func (h *VMess) newConnection(ctx context.Context, conn net.Conn, metadata adapt
 	if !loaded {
 		return os.ErrInvalid
 	}
-	user := h.users[userIndex].Name
+	user, done, loaded := h.users.track(userIndex, conn)
+	if !loaded {
+		return E.New("user ", userIndex, " removed")
+	}
+	defer done()
 	if user == "" {
 		user = F.ToString(userIndex)
 	} else {
@@ -179,7 +197,11 @@ func (h *VMess) newPacketConnection(ctx context.Context, conn N.PacketConn, meta
 	if !loaded {
 		return os.ErrInvalid
 	}
-	user := h.users[userIndex].Name
+	user, done, loaded := h.users.track(userIndex, conn)
+	if !loaded {
+		return E.New("user ", userIndex, " removed")
+	}
+	defer done()
 	if user == "" {
 		user = F.ToString(userIndex)
 	} else {
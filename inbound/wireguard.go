@@ -0,0 +1,287 @@
+//go:build with_wireguard
+
+package inbound
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/userlimit"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/transport/wireguard"
+	"github.com/sagernet/sing-tun"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/wireguard-go/conn"
+	"github.com/sagernet/wireguard-go/device"
+)
+
+var (
+	_ adapter.Inbound = (*WireGuard)(nil)
+	_ tun.Handler     = (*WireGuard)(nil)
+)
+
+// WireGuard accepts WireGuard peers and routes their TCP and UDP flows, which
+// are terminated by a gVisor stack.
+type WireGuard struct {
+	myInboundAdapter
+	workers    int
+	udpTimeout int64
+	ipcConf    string
+	peers      []wireGuardPeer
+	tunDevice  wireguard.ServerDevice
+	stack      tun.Stack
+	device     *device.Device
+	started    bool
+}
+
+type wireGuardPeer struct {
+	name       string
+	allowedIPs []netip.Prefix
+	limits     option.UserLimitOptions
+}
+
+func NewWireGuard(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.WireGuardInboundOptions) (*WireGuard, error) {
+	inbound := &WireGuard{
+		myInboundAdapter: myInboundAdapter{
+			protocol:      C.TypeWireGuard,
+			network:       []string{N.NetworkUDP},
+			ctx:           ctx,
+			router:        router,
+			logger:        logger,
+			tag:           tag,
+			listenOptions: options.ListenOptions,
+		},
+		workers: options.Workers,
+	}
+	if options.ListenPort == 0 {
+		return nil, E.New("missing listen_port")
+	}
+	if len(options.Peers) == 0 {
+		return nil, E.New("missing peers")
+	}
+	var udpTimeout time.Duration
+	if options.UDPTimeout != 0 {
+		udpTimeout = time.Duration(options.UDPTimeout)
+	} else {
+		udpTimeout = C.UDPTimeout
+	}
+	inbound.udpTimeout = int64(udpTimeout.Seconds())
+	{
+		bytes, err := base64.StdEncoding.DecodeString(options.PrivateKey)
+		if err != nil {
+			return nil, E.Cause(err, "decode private key")
+		}
+		inbound.ipcConf = "private_key=" + hex.EncodeToString(bytes)
+	}
+	inbound.ipcConf += "\nlisten_port=" + F.ToString(options.ListenPort)
+	for peerIndex, rawPeer := range options.Peers {
+		if len(rawPeer.AllowedIPs) == 0 {
+			return nil, E.New("missing allowed_ips for peer ", peerIndex)
+		}
+		{
+			bytes, err := base64.StdEncoding.DecodeString(rawPeer.PublicKey)
+			if err != nil {
+				return nil, E.Cause(err, "decode public key for peer ", peerIndex)
+			}
+			inbound.ipcConf += "\npublic_key=" + hex.EncodeToString(bytes)
+		}
+		if rawPeer.PreSharedKey != "" {
+			bytes, err := base64.StdEncoding.DecodeString(rawPeer.PreSharedKey)
+			if err != nil {
+				return nil, E.Cause(err, "decode pre shared key for peer ", peerIndex)
+			}
+			inbound.ipcConf += "\npreshared_key=" + hex.EncodeToString(bytes)
+		}
+		for _, allowedIP := range rawPeer.AllowedIPs {
+			inbound.ipcConf += "\nallowed_ip=" + allowedIP.String()
+		}
+		if rawPeer.Name != "" {
+			err := userlimit.Check(ctx, tag, rawPeer.Name, rawPeer.UserLimitOptions)
+			if err != nil {
+				return nil, E.Cause(err, "check user limits")
+			}
+		}
+		inbound.peers = append(inbound.peers, wireGuardPeer{
+			name:       rawPeer.Name,
+			allowedIPs: rawPeer.AllowedIPs,
+			limits:     rawPeer.UserLimitOptions,
+		})
+	}
+	mtu := options.MTU
+	if mtu == 0 {
+		mtu = 1408
+	}
+	tunDevice, err := wireguard.NewServerDevice(mtu)
+	if err != nil {
+		return nil, E.Cause(err, "create WireGuard device")
+	}
+	inbound.tunDevice = tunDevice
+	return inbound, nil
+}
+
+func (h *WireGuard) Start() error {
+	h.started = true
+	for _, peer := range h.peers {
+		if peer.name == "" {
+			continue
+		}
+		err := userlimit.Register(h.ctx, h.tag, peer.name, peer.limits)
+		if err != nil {
+			return E.Cause(err, "register user limits")
+		}
+	}
+	ipStack, err := tun.NewGVisor(tun.StackOptions{
+		Context:    h.ctx,
+		Tun:        h.tunDevice.Tun(),
+		UDPTimeout: h.udpTimeout,
+		Handler:    h,
+		Logger:     h.logger,
+	})
+	if err != nil {
+		return err
+	}
+	err = ipStack.Start()
+	if err != nil {
+		return err
+	}
+	h.stack = ipStack
+	bind := conn.NewStdNetBind(&wireGuardListener{
+		ctx:    h.ctx,
+		listen: h.listenOptions.Listen.Build(),
+	})
+	wgDevice := device.NewDevice(h.tunDevice, bind, &device.Logger{
+		Verbosef: func(format string, args ...interface{}) {
+			h.logger.Debug(fmt.Sprintf(strings.ToLower(format), args...))
+		},
+		Errorf: func(format string, args ...interface{}) {
+			h.logger.Error(fmt.Sprintf(strings.ToLower(format), args...))
+		},
+	}, h.workers)
+	h.device = wgDevice
+	err = wgDevice.IpcSet(h.ipcConf)
+	if err != nil {
+		return E.Cause(err, "setup wireguard")
+	}
+	err = wgDevice.Up()
+	if err != nil {
+		return err
+	}
+	h.logger.Info("udp server started at ", M.SocksaddrFrom(h.listenOptions.Listen.Build(), h.listenOptions.ListenPort))
+	return nil
+}
+
+func (h *WireGuard) Close() error {
+	if h.started {
+		for _, peer := range h.peers {
+			userlimit.Unregister(h.ctx, h.tag, peer.name)
+		}
+	}
+	if h.device != nil {
+		h.device.Close()
+	} else {
+		h.tunDevice.Close()
+	}
+	return common.Close(h.stack)
+}
+
+func (h *WireGuard) NewConnection(ctx context.Context, conn net.Conn, upstreamMetadata M.Metadata) error {
+	ctx = log.ContextWithNewID(ctx)
+	metadata := h.createFlowMetadata(upstreamMetadata)
+	if metadata.User != "" {
+		h.logger.InfoContext(ctx, "[", metadata.User, "] inbound connection from ", metadata.Source)
+		h.logger.InfoContext(ctx, "[", metadata.User, "] inbound connection to ", metadata.Destination)
+	} else {
+		h.logger.InfoContext(ctx, "inbound connection from ", metadata.Source)
+		h.logger.InfoContext(ctx, "inbound connection to ", metadata.Destination)
+	}
+	err := h.router.RouteConnection(ctx, conn, metadata)
+	if err != nil {
+		h.NewError(ctx, err)
+	}
+	return nil
+}
+
+func (h *WireGuard) NewPacketConnection(ctx context.Context, conn N.PacketConn, upstreamMetadata M.Metadata) error {
+	ctx = log.ContextWithNewID(ctx)
+	metadata := h.createFlowMetadata(upstreamMetadata)
+	if metadata.User != "" {
+		h.logger.InfoContext(ctx, "[", metadata.User, "] inbound packet connection from ", metadata.Source)
+		h.logger.InfoContext(ctx, "[", metadata.User, "] inbound packet connection to ", metadata.Destination)
+	} else {
+		h.logger.InfoContext(ctx, "inbound packet connection from ", metadata.Source)
+		h.logger.InfoContext(ctx, "inbound packet connection to ", metadata.Destination)
+	}
+	err := h.router.RoutePacketConnection(ctx, conn, metadata)
+	if err != nil {
+		h.NewError(ctx, err)
+	}
+	return nil
+}
+
+func (h *WireGuard) createFlowMetadata(upstreamMetadata M.Metadata) adapter.InboundContext {
+	var metadata adapter.InboundContext
+	metadata.Inbound = h.tag
+	metadata.InboundType = C.TypeWireGuard
+	metadata.InboundOptions = h.listenOptions.InboundOptions
+	metadata.Source = upstreamMetadata.Source
+	metadata.Destination = upstreamMetadata.Destination
+	metadata.User = h.peerName(upstreamMetadata.Source.Addr.Unmap())
+	return metadata
+}
+
+// peerName returns the name of the peer of which allowed_ips most specifically
+// contains the address. WireGuard drops packets from peers with a source
+// address outside their allowed_ips, so the match identifies the peer.
+func (h *WireGuard) peerName(addr netip.Addr) string {
+	var (
+		name string
+		bits = -1
+	)
+	for _, peer := range h.peers {
+		for _, prefix := range peer.allowedIPs {
+			if prefix.Bits() > bits && prefix.Contains(addr) {
+				name = peer.name
+				bits = prefix.Bits()
+			}
+		}
+	}
+	return name
+}
+
+var _ conn.Listener = (*wireGuardListener)(nil)
+
+type wireGuardListener struct {
+	ctx    context.Context
+	listen netip.Addr
+}
+
+func (l *wireGuardListener) ListenPacketCompat(network, address string) (net.PacketConn, error) {
+	if !l.listen.IsUnspecified() || l.listen.Is4() {
+		if l.listen.Is4() != (network == "udp4") {
+			return nil, syscall.EAFNOSUPPORT
+		}
+		if !l.listen.IsUnspecified() {
+			_, port, err := net.SplitHostPort(address)
+			if err != nil {
+				return nil, err
+			}
+			address = net.JoinHostPort(l.listen.String(), port)
+		}
+	}
+	var listenConfig net.ListenConfig
+	return listenConfig.ListenPacket(l.ctx, network, address)
+}
@@ -0,0 +1,231 @@
+package log
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/service/filemanager"
+)
+
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+type FileOptions struct {
+	MaxSize    int64
+	MaxAge     time.Duration
+	MaxBackups int
+	Compress   bool
+	// Header is written at the start of each new file.
+	Header []byte
+}
+
+// FileWriter appends to a log file, rotating it by size or age and keeping
+// at most MaxBackups rotated files next to it.
+type FileWriter struct {
+	ctx      context.Context
+	path     string
+	options  FileOptions
+	access   sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	closed   bool
+	cleaning sync.WaitGroup
+}
+
+func NewFileWriter(ctx context.Context, path string, options FileOptions) *FileWriter {
+	return &FileWriter{
+		ctx:     ctx,
+		path:    path,
+		options: options,
+	}
+}
+
+func (w *FileWriter) Start() error {
+	w.access.Lock()
+	defer w.access.Unlock()
+	return w.open()
+}
+
+func (w *FileWriter) open() error {
+	file, err := filemanager.OpenFile(w.ctx, w.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	w.file = file
+	w.size = 0
+	w.openedAt = time.Now()
+	if fileInfo, statErr := file.Stat(); statErr == nil {
+		w.size = fileInfo.Size()
+		if w.size > 0 {
+			w.openedAt = fileInfo.ModTime()
+		}
+	}
+	if w.size == 0 && len(w.options.Header) > 0 {
+		n, _ := file.Write(w.options.Header)
+		w.size = int64(n)
+	}
+	return nil
+}
+
+func (w *FileWriter) Write(p []byte) (n int, err error) {
+	w.access.Lock()
+	defer w.access.Unlock()
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+	if w.file == nil {
+		err = w.open()
+		if err != nil {
+			return 0, E.Cause(err, "open log file")
+		}
+	}
+	if w.size > int64(len(w.options.Header)) && (w.options.MaxSize > 0 && w.size+int64(len(p)) > w.options.MaxSize ||
+		w.options.MaxAge > 0 && time.Since(w.openedAt) >= w.options.MaxAge) {
+		err = w.rotate()
+		if err != nil {
+			if w.file == nil {
+				return 0, E.Cause(err, "rotate log file")
+			}
+			os.Stderr.WriteString("rotate log file " + w.path + ": " + err.Error() + "\n")
+		}
+	}
+	n, err = w.file.Write(p)
+	w.size += int64(n)
+	return
+}
+
+func (w *FileWriter) WriteMessage(level Level, message string) {
+	w.Write([]byte(message))
+}
+
+// rotate moves the log file to a backup and opens a new one. If the file can
+// not be moved, the original path is reopened to keep logging.
+func (w *FileWriter) rotate() error {
+	w.file.Close()
+	w.file = nil
+	backupPath := w.backupPath()
+	err := os.Rename(w.path, backupPath)
+	if err != nil {
+		return E.Errors(err, w.open())
+	}
+	err = w.open()
+	if err != nil {
+		return err
+	}
+	w.cleaning.Add(1)
+	go func() {
+		defer w.cleaning.Done()
+		w.cleanup(backupPath)
+	}()
+	return nil
+}
+
+// backupPath returns an unused backup path, moving the time forward if a
+// backup of the same millisecond exists.
+func (w *FileWriter) backupPath() string {
+	backupTime := time.Now()
+	for {
+		backupPath := w.path + "." + backupTime.Format(backupTimeFormat)
+		if !fileExists(backupPath) && !fileExists(backupPath+".gz") {
+			return backupPath
+		}
+		backupTime = backupTime.Add(time.Millisecond)
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+func (w *FileWriter) cleanup(backupPath string) {
+	if w.options.Compress {
+		err := compressFile(backupPath)
+		if err != nil {
+			os.Stderr.WriteString("compress log file " + backupPath + ": " + err.Error() + "\n")
+		}
+	}
+	if w.options.MaxBackups <= 0 {
+		return
+	}
+	backups, err := filepath.Glob(w.path + ".*")
+	if err != nil {
+		return
+	}
+	// a backup may exist both uncompressed and compressed while compressing
+	backups = common.Uniq(common.Map(backups, func(it string) string {
+		return strings.TrimSuffix(it, ".gz")
+	}))
+	backups = common.Filter(backups, func(it string) bool {
+		_, parseErr := time.Parse(backupTimeFormat, strings.TrimPrefix(it, w.path+"."))
+		return parseErr == nil
+	})
+	if len(backups) <= w.options.MaxBackups {
+		return
+	}
+	sort.Strings(backups)
+	for _, expired := range backups[:len(backups)-w.options.MaxBackups] {
+		os.Remove(expired)
+		os.Remove(expired + ".gz")
+	}
+}
+
+// Reopen closes and reopens the log file, for use after it was moved by an
+// external tool such as logrotate.
+func (w *FileWriter) Reopen() error {
+	w.access.Lock()
+	defer w.access.Unlock()
+	if w.file != nil {
+		w.file.Close()
+		w.file = nil
+	}
+	return w.open()
+}
+
+func (w *FileWriter) Close() error {
+	w.access.Lock()
+	var err error
+	w.closed = true
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	w.access.Unlock()
+	w.cleaning.Wait()
+	return err
+}
+
+func compressFile(path string) error {
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	destination, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	writer := gzip.NewWriter(destination)
+	_, err = io.Copy(writer, source)
+	if err == nil {
+		err = writer.Close()
+	}
+	if closeErr := destination.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	source.Close()
+	return os.Remove(path)
+}
@@ -0,0 +1,145 @@
+package log
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestFileWriter(t *testing.T, options FileOptions) (*FileWriter, string) {
+	path := filepath.Join(t.TempDir(), "box.log")
+	writer := NewFileWriter(context.Background(), path, options)
+	require.NoError(t, writer.Start())
+	t.Cleanup(func() {
+		writer.Close()
+	})
+	return writer, path
+}
+
+func writeString(t *testing.T, writer *FileWriter, content string) {
+	_, err := writer.Write([]byte(content))
+	require.NoError(t, err)
+}
+
+func backupFiles(t *testing.T, path string) []string {
+	backups, err := filepath.Glob(path + ".*")
+	require.NoError(t, err)
+	return backups
+}
+
+func TestFileWriterRotateSize(t *testing.T) {
+	t.Parallel()
+	writer, path := newTestFileWriter(t, FileOptions{MaxSize: 10})
+	for i := 0; i < 5; i++ {
+		writeString(t, writer, "12345678\n")
+	}
+	require.NoError(t, writer.Close())
+	// backups of the same millisecond must not overwrite each other
+	backups := backupFiles(t, path)
+	require.Len(t, backups, 4)
+	for _, backup := range backups {
+		content, err := os.ReadFile(backup)
+		require.NoError(t, err)
+		require.Equal(t, "12345678\n", string(content))
+	}
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "12345678\n", string(content))
+}
+
+func TestFileWriterRotateAge(t *testing.T) {
+	t.Parallel()
+	writer, path := newTestFileWriter(t, FileOptions{MaxAge: 50 * time.Millisecond, Header: []byte("header\n")})
+	writeString(t, writer, "a\n")
+	writeString(t, writer, "b\n")
+	require.Empty(t, backupFiles(t, path))
+	time.Sleep(60 * time.Millisecond)
+	writeString(t, writer, "c\n")
+	require.NoError(t, writer.Close())
+	backups := backupFiles(t, path)
+	require.Len(t, backups, 1)
+	content, err := os.ReadFile(backups[0])
+	require.NoError(t, err)
+	require.Equal(t, "header\na\nb\n", string(content))
+	content, err = os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "header\nc\n", string(content))
+}
+
+func TestFileWriterMaxBackups(t *testing.T) {
+	t.Parallel()
+	for _, compress := range []bool{false, true} {
+		writer, path := newTestFileWriter(t, FileOptions{MaxSize: 2, MaxBackups: 2, Compress: compress})
+		for i := 0; i < 6; i++ {
+			writeString(t, writer, string(rune('a'+i))+"\n")
+			// wait for cleanup to avoid racing with the next rotation
+			writer.cleaning.Wait()
+		}
+		require.NoError(t, writer.Close())
+		backups := backupFiles(t, path)
+		require.Len(t, backups, 2)
+		for _, backup := range backups {
+			require.Equal(t, compress, filepath.Ext(backup) == ".gz")
+		}
+		require.Less(t, backups[0], backups[1])
+	}
+}
+
+func TestFileWriterCleanupCompressing(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "box.log")
+	writer := NewFileWriter(context.Background(), path, FileOptions{MaxBackups: 2})
+	backupTime := time.Now()
+	var backups []string
+	for i := 0; i < 3; i++ {
+		backups = append(backups, path+"."+backupTime.Add(time.Duration(i)*time.Millisecond).Format(backupTimeFormat))
+	}
+	// the newest backup exists twice while it is being compressed
+	for _, backup := range append(backups, backups[2]+".gz") {
+		require.NoError(t, os.WriteFile(backup, nil, 0o644))
+	}
+	writer.cleanup(backups[2])
+	require.Equal(t, []string{backups[1], backups[2], backups[2] + ".gz"}, backupFiles(t, path))
+}
+
+func TestFileWriterReopen(t *testing.T) {
+	t.Parallel()
+	writer, path := newTestFileWriter(t, FileOptions{})
+	writeString(t, writer, "a\n")
+	require.NoError(t, os.Rename(path, path+".old"))
+	writeString(t, writer, "b\n")
+	require.NoError(t, writer.Reopen())
+	writeString(t, writer, "c\n")
+	require.NoError(t, writer.Close())
+	content, err := os.ReadFile(path + ".old")
+	require.NoError(t, err)
+	require.Equal(t, "a\nb\n", string(content))
+	content, err = os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "c\n", string(content))
+	_, err = writer.Write([]byte("d\n"))
+	require.ErrorIs(t, err, os.ErrClosed)
+}
+
+func TestFileWriterRotateFailure(t *testing.T) {
+	t.Parallel()
+	writer, path := newTestFileWriter(t, FileOptions{MaxSize: 4})
+	writeString(t, writer, "a\n")
+	// rotating fails as the file is gone, the original path must be reopened
+	require.NoError(t, os.Remove(path))
+	writeString(t, writer, "bc\n")
+	writeString(t, writer, "d\n")
+	require.NoError(t, writer.Close())
+	backups := backupFiles(t, path)
+	require.Len(t, backups, 1)
+	content, err := os.ReadFile(backups[0])
+	require.NoError(t, err)
+	require.Equal(t, "bc\n", string(content))
+	content, err = os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "d\n", string(content))
+}
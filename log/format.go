@@ -18,9 +18,16 @@ type Formatter struct {
 	FullTimestamp    bool
 	TimestampFormat  string
 	DisableLineBreak bool
+	JSON             bool
+	// ContextFields reads connection fields for JSON entries from the context,
+	// as packages storing them cannot be imported here.
+	ContextFields []func(ctx context.Context) []Field
 }
 
 func (f Formatter) Format(ctx context.Context, level Level, tag string, message string, timestamp time.Time) string {
+	if f.JSON {
+		return f.formatJSON(ctx, level, tag, message, timestamp)
+	}
 	levelString := strings.ToUpper(FormatLevel(level))
 	if !f.DisableColors {
 		switch level {
@@ -90,6 +97,10 @@ func (f Formatter) Format(ctx context.Context, level Level, tag string, message
 }
 
 func (f Formatter) FormatWithSimple(ctx context.Context, level Level, tag string, message string, timestamp time.Time) (string, string) {
+	if f.JSON {
+		_, messageSimple := Formatter{BaseTime: f.BaseTime, DisableColors: true}.FormatWithSimple(ctx, level, tag, message, timestamp)
+		return f.formatJSON(ctx, level, tag, message, timestamp), messageSimple
+	}
 	levelString := strings.ToUpper(FormatLevel(level))
 	if !f.DisableColors {
 		switch level {
@@ -0,0 +1,76 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/sagernet/sing/common/json"
+)
+
+type Field struct {
+	Key   string
+	Value any
+}
+
+func (f Formatter) formatJSON(ctx context.Context, level Level, tag string, message string, timestamp time.Time) string {
+	fields := []Field{
+		{"time", timestamp.Format(time.RFC3339Nano)},
+		{"level", FormatLevel(level)},
+	}
+	if tag != "" {
+		fields = append(fields, Field{"module", tag})
+	}
+	if ctx != nil {
+		if id, hasId := IDFromContext(ctx); hasId {
+			fields = append(fields, Field{"id", id.ID}, Field{"elapsed_ms", time.Since(id.CreatedAt).Milliseconds()})
+		}
+	}
+	fields = append(fields, Field{"message", strings.TrimSuffix(message, "\n")})
+	if ctx != nil {
+		for _, fieldsFunc := range f.ContextFields {
+			for _, field := range fieldsFunc(ctx) {
+				fields = appendField(fields, field)
+			}
+		}
+	}
+	var buffer bytes.Buffer
+	buffer.WriteByte('{')
+	for i, field := range fields {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+		writeJSONValue(&buffer, field.Key)
+		buffer.WriteByte(':')
+		writeJSONValue(&buffer, field.Value)
+	}
+	buffer.WriteByte('}')
+	if !f.DisableLineBreak {
+		buffer.WriteByte('\n')
+	}
+	return buffer.String()
+}
+
+// appendField keeps the first value of each key, so context fields can neither
+// replace the base fields nor each other.
+func appendField(fields []Field, field Field) []Field {
+	for _, existing := range fields {
+		if existing.Key == field.Key {
+			return fields
+		}
+	}
+	return append(fields, field)
+}
+
+func writeJSONValue(buffer *bytes.Buffer, value any) {
+	var content bytes.Buffer
+	encoder := json.NewEncoder(&content)
+	encoder.SetEscapeHTML(false)
+	err := encoder.Encode(value)
+	if err != nil {
+		content.Reset()
+		encoder.Encode(err.Error())
+	}
+	buffer.Write(bytes.TrimSuffix(content.Bytes(), []byte{'\n'}))
+}
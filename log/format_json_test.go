@@ -0,0 +1,40 @@
+package log
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testFieldKey struct{}
+
+func TestFormatJSON(t *testing.T) {
+	t.Parallel()
+	formatter := Formatter{
+		JSON:             true,
+		DisableLineBreak: true,
+		ContextFields: []func(ctx context.Context) []Field{
+			func(ctx context.Context) []Field {
+				if value, loaded := ctx.Value(testFieldKey{}).(string); loaded {
+					return []Field{{"outbound", value}, {"level", "replaced"}}
+				}
+				return nil
+			},
+			func(ctx context.Context) []Field {
+				return []Field{{"outbound", "second"}}
+			},
+		},
+	}
+	timestamp := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	ctx := context.WithValue(context.Background(), testFieldKey{}, "<direct>")
+	require.Equal(t,
+		`{"time":"2024-01-02T03:04:05Z","level":"info","module":"router","message":"hello","outbound":"<direct>"}`,
+		formatter.Format(ctx, LevelInfo, "router", "hello\n", timestamp),
+	)
+	require.Equal(t,
+		`{"time":"2024-01-02T03:04:05Z","level":"error","message":"a \"quoted\" <message>","outbound":"second"}`,
+		formatter.Format(context.Background(), LevelError, "", `a "quoted" <message>`, timestamp),
+	)
+}
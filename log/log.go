@@ -17,6 +17,7 @@ type Options struct {
 	DefaultWriter  io.Writer
 	BaseTime       time.Time
 	PlatformWriter PlatformWriter
+	ContextFields  []func(ctx context.Context) []Field
 }
 
 func New(options Options) (Factory, error) {
@@ -31,9 +32,11 @@ func New(options Options) (Factory, error) {
 
 	switch logOptions.Output {
 	case "":
-		logWriter = options.DefaultWriter
-		if logWriter == nil {
-			logWriter = os.Stderr
+		if len(logOptions.Outputs) == 0 {
+			logWriter = options.DefaultWriter
+			if logWriter == nil {
+				logWriter = os.Stderr
+			}
 		}
 	case "stderr":
 		logWriter = os.Stderr
@@ -42,14 +45,11 @@ func New(options Options) (Factory, error) {
 	default:
 		logFilePath = logOptions.Output
 	}
-	logFormatter := Formatter{
-		BaseTime:         options.BaseTime,
-		DisableColors:    logOptions.DisableColor || logFilePath != "",
-		DisableTimestamp: !logOptions.Timestamp && logFilePath != "",
-		FullTimestamp:    logOptions.Timestamp,
-		TimestampFormat:  "-0700 2006-01-02 15:04:05",
+	logFormatter, err := newFormatter(options, logOptions.Format, logOptions.Timestamp, logOptions.DisableColor || logFilePath != "", logFilePath != "")
+	if err != nil {
+		return nil, err
 	}
-	factory := NewDefaultFactory(
+	factory := newDefaultFactory(
 		options.Context,
 		logFormatter,
 		logWriter,
@@ -57,6 +57,7 @@ func New(options Options) (Factory, error) {
 		options.PlatformWriter,
 		options.Observable,
 	)
+	factory.fileOptions = newFileOptions(logOptions.LogFileOptions)
 	if logOptions.Level != "" {
 		logLevel, err := ParseLevel(logOptions.Level)
 		if err != nil {
@@ -66,5 +67,110 @@ func New(options Options) (Factory, error) {
 	} else {
 		factory.SetLevel(LevelTrace)
 	}
+	if len(logOptions.ModuleLevels) > 0 {
+		factory.moduleLevels = make(map[string]Level)
+		for module, level := range logOptions.ModuleLevels {
+			moduleLevel, err := ParseLevel(level)
+			if err != nil {
+				return nil, E.Cause(err, "parse log level for module ", module)
+			}
+			factory.moduleLevels[module] = moduleLevel
+		}
+	}
+	for i, outputOptions := range logOptions.Outputs {
+		err = factory.addOutput(options, outputOptions)
+		if err != nil {
+			return nil, E.Cause(err, "parse log output[", i, "]")
+		}
+	}
 	return factory, nil
 }
+
+func (f *defaultFactory) addOutput(options Options, outputOptions option.LogOutputOptions) error {
+	logSink := new(sink)
+	if outputOptions.Level != "" {
+		level, err := ParseLevel(outputOptions.Level)
+		if err != nil {
+			return E.Cause(err, "parse log level")
+		}
+		logSink.level = level
+		logSink.hasLevel = true
+	}
+	var (
+		isTerminal bool
+		err        error
+	)
+	switch outputOptions.Type {
+	case "stderr":
+		logSink.writer = streamWriter{os.Stderr}
+		isTerminal = true
+	case "stdout":
+		logSink.writer = streamWriter{os.Stdout}
+		isTerminal = true
+	case "file":
+		if outputOptions.Path == "" {
+			return E.New("missing path")
+		}
+		logSink.writer = NewFileWriter(options.Context, outputOptions.Path, newFileOptions(outputOptions.LogFileOptions))
+	case "syslog":
+		logSink.writer, err = newSyslogWriter(SyslogOptions{
+			Network:  outputOptions.Network,
+			Address:  outputOptions.Address,
+			Facility: outputOptions.Facility,
+			Tag:      outputOptions.Tag,
+		})
+		if err != nil {
+			return err
+		}
+	case "clash_api":
+		if f.observableSink.formatter, err = newFormatter(options, outputOptions.Format, false, true, true); err != nil {
+			return err
+		}
+		f.observableSink.formatter.DisableLineBreak = true
+		f.observableSink.level = logSink.level
+		f.observableSink.hasLevel = logSink.hasLevel
+		return nil
+	case "":
+		return E.New("missing type")
+	default:
+		return E.New("unknown log output type: ", outputOptions.Type)
+	}
+	logSink.formatter, err = newFormatter(options, outputOptions.Format, outputOptions.Timestamp, options.Options.DisableColor || !isTerminal, !isTerminal)
+	if err != nil {
+		return err
+	}
+	if outputOptions.Type == "syslog" {
+		// syslog adds its own timestamp
+		logSink.formatter.DisableTimestamp = true
+	}
+	f.sinks = append(f.sinks, logSink)
+	return nil
+}
+
+func newFormatter(options Options, format string, timestamp bool, disableColors bool, nonTerminal bool) (Formatter, error) {
+	formatter := Formatter{
+		BaseTime:         options.BaseTime,
+		DisableColors:    disableColors,
+		DisableTimestamp: !timestamp && nonTerminal,
+		FullTimestamp:    timestamp,
+		TimestampFormat:  "-0700 2006-01-02 15:04:05",
+	}
+	switch format {
+	case "", "text":
+	case "json":
+		formatter.JSON = true
+		formatter.ContextFields = options.ContextFields
+	default:
+		return Formatter{}, E.New("unknown log format: ", format)
+	}
+	return formatter, nil
+}
+
+func newFileOptions(options option.LogFileOptions) FileOptions {
+	return FileOptions{
+		MaxSize:    int64(options.MaxSize),
+		MaxAge:     time.Duration(options.MaxAge),
+		MaxBackups: options.MaxBackups,
+		Compress:   options.Compress,
+	}
+}
@@ -4,12 +4,13 @@ import (
 	"context"
 	"io"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
 	F "github.com/sagernet/sing/common/format"
 	"github.com/sagernet/sing/common/observable"
-	"github.com/sagernet/sing/service/filemanager"
 )
 
 var _ Factory = (*defaultFactory)(nil)
@@ -19,11 +20,15 @@ type defaultFactory struct {
 	formatter         Formatter
 	platformFormatter Formatter
 	writer            io.Writer
-	file              *os.File
+	file              *FileWriter
 	filePath          string
+	fileOptions       FileOptions
 	platformWriter    PlatformWriter
 	needObservable    bool
 	level             Level
+	moduleLevels      map[string]Level
+	sinks             []*sink
+	observableSink    *sink
 	subscriber        *observable.Subscriber[Entry]
 	observer          *observable.Observer[Entry]
 }
@@ -36,6 +41,17 @@ func NewDefaultFactory(
 	platformWriter PlatformWriter,
 	needObservable bool,
 ) ObservableFactory {
+	return newDefaultFactory(ctx, formatter, writer, filePath, platformWriter, needObservable)
+}
+
+func newDefaultFactory(
+	ctx context.Context,
+	formatter Formatter,
+	writer io.Writer,
+	filePath string,
+	platformWriter PlatformWriter,
+	needObservable bool,
+) *defaultFactory {
 	factory := &defaultFactory{
 		ctx:       ctx,
 		formatter: formatter,
@@ -48,6 +64,7 @@ func NewDefaultFactory(
 		platformWriter: platformWriter,
 		needObservable: needObservable,
 		level:          LevelTrace,
+		observableSink: &sink{formatter: Formatter{DisableColors: true}},
 		subscriber:     observable.NewSubscriber[Entry](128),
 	}
 	if platformWriter != nil {
@@ -59,21 +76,45 @@ func NewDefaultFactory(
 
 func (f *defaultFactory) Start() error {
 	if f.filePath != "" {
-		logFile, err := filemanager.OpenFile(f.ctx, f.filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		logFile := NewFileWriter(f.ctx, f.filePath, f.fileOptions)
+		err := logFile.Start()
 		if err != nil {
 			return err
 		}
 		f.writer = logFile
 		f.file = logFile
 	}
+	for _, logSink := range f.sinks {
+		if starter, isStarter := logSink.writer.(interface{ Start() error }); isStarter {
+			err := starter.Start()
+			if err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
 func (f *defaultFactory) Close() error {
-	return common.Close(
-		common.PtrOrNil(f.file),
-		f.observer,
-	)
+	closers := []any{common.PtrOrNil(f.file), f.observer}
+	for _, logSink := range f.sinks {
+		closers = append(closers, logSink.writer)
+	}
+	return common.Close(closers...)
+}
+
+// Reopen reopens all log files, so that an external tool can rotate them.
+func (f *defaultFactory) Reopen() error {
+	var errors error
+	if f.file != nil {
+		errors = f.file.Reopen()
+	}
+	for _, logSink := range f.sinks {
+		if logFile, isFile := logSink.writer.(*FileWriter); isFile {
+			errors = E.Errors(errors, logFile.Reopen())
+		}
+	}
+	return errors
 }
 
 func (f *defaultFactory) Level() Level {
@@ -89,7 +130,27 @@ func (f *defaultFactory) Logger() ContextLogger {
 }
 
 func (f *defaultFactory) NewLogger(tag string) ContextLogger {
-	return &observableLogger{f, tag}
+	logger := &observableLogger{defaultFactory: f, tag: tag}
+	logger.moduleLevel, logger.hasModuleLevel = f.loadModuleLevel(tag)
+	return logger
+}
+
+// loadModuleLevel finds the longest configured module matching the tag,
+// e.g. both "inbound" and "inbound/mixed" match "inbound/mixed[mixed-in]".
+func (f *defaultFactory) loadModuleLevel(tag string) (level Level, loaded bool) {
+	var matchedModule string
+	for module, moduleLevel := range f.moduleLevels {
+		if len(module) <= len(matchedModule) || !strings.HasPrefix(tag, module) {
+			continue
+		}
+		if len(tag) > len(module) && tag[len(module)] != '/' && tag[len(module)] != '[' {
+			continue
+		}
+		matchedModule = module
+		level = moduleLevel
+		loaded = true
+	}
+	return
 }
 
 func (f *defaultFactory) Subscribe() (subscription observable.Subscription[Entry], done <-chan struct{}, err error) {
@@ -104,37 +165,61 @@ var _ ContextLogger = (*observableLogger)(nil)
 
 type observableLogger struct {
 	*defaultFactory
-	tag string
+	tag            string
+	moduleLevel    Level
+	hasModuleLevel bool
 }
 
 func (l *observableLogger) Log(ctx context.Context, level Level, args []any) {
 	level = OverrideLevelFromContext(level, ctx)
-	if level > l.level {
+	defaultLevel := l.level
+	if l.hasModuleLevel {
+		defaultLevel = l.moduleLevel
+	}
+	maxLevel := defaultLevel
+	for _, logSink := range l.sinks {
+		if sinkLevel := logSink.maxLevel(defaultLevel); sinkLevel > maxLevel {
+			maxLevel = sinkLevel
+		}
+	}
+	if l.needObservable {
+		if observableLevel := l.observableSink.maxLevel(defaultLevel); observableLevel > maxLevel {
+			maxLevel = observableLevel
+		}
+	}
+	if level > maxLevel {
 		return
 	}
 	nowTime := time.Now()
-	if l.needObservable {
-		message, messageSimple := l.formatter.FormatWithSimple(ctx, level, l.tag, F.ToString(args...), nowTime)
+	content := F.ToString(args...)
+	if level <= defaultLevel {
+		message := l.formatter.Format(ctx, level, l.tag, content, nowTime)
 		if level == LevelPanic {
 			panic(message)
 		}
-		l.writer.Write([]byte(message))
-		if level == LevelFatal {
-			os.Exit(1)
+		if l.writer != nil {
+			l.writer.Write([]byte(message))
 		}
-		l.subscriber.Emit(Entry{level, messageSimple})
-	} else {
-		message := l.formatter.Format(ctx, level, l.tag, F.ToString(args...), nowTime)
-		if level == LevelPanic {
-			panic(message)
+		if l.platformWriter != nil {
+			l.platformWriter.WriteMessage(level, l.platformFormatter.Format(ctx, level, l.tag, content, nowTime))
 		}
-		l.writer.Write([]byte(message))
-		if level == LevelFatal {
-			os.Exit(1)
+	}
+	for _, logSink := range l.sinks {
+		if level <= logSink.maxLevel(defaultLevel) {
+			logSink.writer.WriteMessage(level, logSink.formatter.Format(ctx, level, l.tag, content, nowTime))
+		}
+	}
+	if l.needObservable && level <= l.observableSink.maxLevel(defaultLevel) {
+		var messageSimple string
+		if l.observableSink.formatter.JSON {
+			messageSimple = l.observableSink.formatter.Format(ctx, level, l.tag, content, nowTime)
+		} else {
+			_, messageSimple = l.observableSink.formatter.FormatWithSimple(ctx, level, l.tag, content, nowTime)
 		}
+		l.subscriber.Emit(Entry{level, messageSimple})
 	}
-	if l.platformWriter != nil {
-		l.platformWriter.WriteMessage(level, l.platformFormatter.Format(ctx, level, l.tag, F.ToString(args...), nowTime))
+	if level == LevelFatal {
+		os.Exit(1)
 	}
 }
 
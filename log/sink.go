@@ -0,0 +1,31 @@
+package log
+
+import "io"
+
+type sinkWriter interface {
+	WriteMessage(level Level, message string)
+}
+
+// sink is an additional log output with its own formatter, and with its own
+// level if set, otherwise it follows the level of the logger.
+type sink struct {
+	formatter Formatter
+	level     Level
+	hasLevel  bool
+	writer    sinkWriter
+}
+
+func (s *sink) maxLevel(defaultLevel Level) Level {
+	if s.hasLevel {
+		return s.level
+	}
+	return defaultLevel
+}
+
+type streamWriter struct {
+	io.Writer
+}
+
+func (w streamWriter) WriteMessage(level Level, message string) {
+	w.Write([]byte(message))
+}
@@ -0,0 +1,191 @@
+package log
+
+import (
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	E "github.com/sagernet/sing/common/exceptions"
+	N "github.com/sagernet/sing/common/network"
+)
+
+var syslogFacilities = map[string]int{
+	"kern":     0,
+	"user":     1,
+	"mail":     2,
+	"daemon":   3,
+	"auth":     4,
+	"syslog":   5,
+	"lpr":      6,
+	"news":     7,
+	"uucp":     8,
+	"cron":     9,
+	"authpriv": 10,
+	"ftp":      11,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
+}
+
+type SyslogOptions struct {
+	Network  string
+	Address  string
+	Facility string
+	Tag      string
+}
+
+// syslogWriter sends entries to a local syslog socket in the BSD format, or to
+// a remote server with a full timestamp and hostname.
+type syslogWriter struct {
+	network  string
+	address  string
+	facility int
+	tag      string
+	hostname string
+	access   sync.Mutex
+	conn     net.Conn
+	closed   bool
+}
+
+func newSyslogWriter(options SyslogOptions) (*syslogWriter, error) {
+	writer := &syslogWriter{
+		network: options.Network,
+		address: options.Address,
+		tag:     options.Tag,
+	}
+	switch writer.network {
+	case "", "unix", "unixgram":
+	case N.NetworkTCP, N.NetworkUDP:
+		if writer.address == "" {
+			return nil, E.New("missing syslog server address")
+		}
+	default:
+		return nil, E.New("unknown syslog network: ", writer.network)
+	}
+	if options.Facility == "" {
+		writer.facility = syslogFacilities["daemon"]
+	} else {
+		facility, loaded := syslogFacilities[options.Facility]
+		if !loaded {
+			return nil, E.New("unknown syslog facility: ", options.Facility)
+		}
+		writer.facility = facility
+	}
+	if writer.tag == "" {
+		writer.tag = "sing-box"
+	}
+	writer.hostname, _ = os.Hostname()
+	return writer, nil
+}
+
+func (w *syslogWriter) Start() error {
+	w.access.Lock()
+	defer w.access.Unlock()
+	return w.connect()
+}
+
+func (w *syslogWriter) connect() error {
+	if w.network == N.NetworkTCP || w.network == N.NetworkUDP {
+		conn, err := net.Dial(w.network, w.address)
+		if err != nil {
+			return E.Cause(err, "connect to syslog server")
+		}
+		w.conn = conn
+		return nil
+	}
+	addresses := []string{w.address}
+	if w.address == "" {
+		if runtime.GOOS == "darwin" {
+			addresses = []string{"/var/run/syslog"}
+		} else {
+			addresses = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+		}
+	}
+	networks := []string{w.network}
+	if w.network == "" {
+		networks = []string{"unixgram", "unix"}
+	}
+	var lastErr error
+	for _, address := range addresses {
+		for _, network := range networks {
+			conn, err := net.Dial(network, address)
+			if err == nil {
+				w.conn = conn
+				return nil
+			}
+			lastErr = err
+		}
+	}
+	return E.Cause(lastErr, "connect to syslog")
+}
+
+func (w *syslogWriter) WriteMessage(level Level, message string) {
+	var severity int
+	switch level {
+	case LevelPanic:
+		severity = 1
+	case LevelFatal:
+		severity = 2
+	case LevelError:
+		severity = 3
+	case LevelWarn:
+		severity = 4
+	case LevelInfo:
+		severity = 6
+	default:
+		severity = 7
+	}
+	priority := "<" + strconv.Itoa(w.facility*8+severity) + ">"
+	message = strings.TrimSuffix(message, "\n")
+	var content string
+	if w.network == N.NetworkTCP || w.network == N.NetworkUDP {
+		content = priority + time.Now().Format(time.RFC3339) + " " + w.hostname + " " + w.tag + "[" + strconv.Itoa(os.Getpid()) + "]: " + message
+	} else {
+		content = priority + time.Now().Format(time.Stamp) + " " + w.tag + "[" + strconv.Itoa(os.Getpid()) + "]: " + message
+	}
+	w.access.Lock()
+	defer w.access.Unlock()
+	if w.closed || w.conn == nil && w.connect() != nil {
+		return
+	}
+	_, err := w.conn.Write(w.frame(content))
+	if err != nil {
+		w.conn.Close()
+		w.conn = nil
+		if w.connect() == nil {
+			w.conn.Write(w.frame(content))
+		}
+	}
+}
+
+// frame terminates messages sent over stream sockets, where the newline is the
+// only message boundary.
+func (w *syslogWriter) frame(content string) []byte {
+	switch w.conn.RemoteAddr().Network() {
+	case N.NetworkTCP, "unix":
+		return []byte(content + "\n")
+	default:
+		return []byte(content)
+	}
+}
+
+func (w *syslogWriter) Close() error {
+	w.access.Lock()
+	defer w.access.Unlock()
+	w.closed = true
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
@@ -14,6 +14,7 @@ type _Options struct {
 	NTP          *NTPOptions          `json:"ntp,omitempty"`
 	Inbounds     []Inbound            `json:"inbounds,omitempty"`
 	Outbounds    []Outbound           `json:"outbounds,omitempty"`
+	Providers    []OutboundProvider   `json:"providers,omitempty"`
 	Route        *RouteOptions        `json:"route,omitempty"`
 	Experimental *ExperimentalOptions `json:"experimental,omitempty"`
 }
@@ -32,9 +33,33 @@ func (o *Options) UnmarshalJSON(content []byte) error {
 }
 
 type LogOptions struct {
-	Disabled     bool   `json:"disabled,omitempty"`
-	Level        string `json:"level,omitempty"`
-	Output       string `json:"output,omitempty"`
-	Timestamp    bool   `json:"timestamp,omitempty"`
-	DisableColor bool   `json:"-"`
+	Disabled     bool              `json:"disabled,omitempty"`
+	Level        string            `json:"level,omitempty"`
+	ModuleLevels map[string]string `json:"module_levels,omitempty"`
+	Output       string            `json:"output,omitempty"`
+	Format       string            `json:"format,omitempty"`
+	Timestamp    bool              `json:"timestamp,omitempty"`
+	LogFileOptions
+	Outputs      []LogOutputOptions `json:"outputs,omitempty"`
+	DisableColor bool               `json:"-"`
+}
+
+type LogFileOptions struct {
+	MaxSize    MemoryBytes `json:"max_size,omitempty"`
+	MaxAge     Duration    `json:"max_age,omitempty"`
+	MaxBackups int         `json:"max_backups,omitempty"`
+	Compress   bool        `json:"compress,omitempty"`
+}
+
+type LogOutputOptions struct {
+	Type      string `json:"type"`
+	Level     string `json:"level,omitempty"`
+	Format    string `json:"format,omitempty"`
+	Timestamp bool   `json:"timestamp,omitempty"`
+	Path      string `json:"path,omitempty"`
+	LogFileOptions
+	Network  string `json:"network,omitempty"`
+	Address  string `json:"address,omitempty"`
+	Facility string `json:"facility,omitempty"`
+	Tag      string `json:"tag,omitempty"`
 }
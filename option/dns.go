@@ -27,6 +27,7 @@ type DNSClientOptions struct {
 	DisableCache     bool           `json:"disable_cache,omitempty"`
 	DisableExpire    bool           `json:"disable_expire,omitempty"`
 	IndependentCache bool           `json:"independent_cache,omitempty"`
+	ServeStale       bool           `json:"serve_stale,omitempty"`
 	ClientSubnet     *ListenAddress `json:"client_subnet,omitempty"`
 }
 
@@ -35,3 +36,10 @@ type DNSFakeIPOptions struct {
 	Inet4Range *netip.Prefix `json:"inet4_range,omitempty"`
 	Inet6Range *netip.Prefix `json:"inet6_range,omitempty"`
 }
+
+type DNSInboundOptions struct {
+	ListenOptions
+	Network NetworkList `json:"network,omitempty"`
+	InboundTLSOptionsContainer
+	Path string `json:"path,omitempty"`
+}
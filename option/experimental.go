@@ -4,6 +4,9 @@ type ExperimentalOptions struct {
 	CacheFile *CacheFileOptions `json:"cache_file,omitempty"`
 	ClashAPI  *ClashAPIOptions  `json:"clash_api,omitempty"`
 	V2RayAPI  *V2RayAPIOptions  `json:"v2ray_api,omitempty"`
+	Metrics   *MetricsOptions   `json:"metrics,omitempty"`
+	AccessLog *AccessLogOptions `json:"access_log,omitempty"`
+	UserAPI   *UserAPIOptions   `json:"user_api,omitempty"`
 	Debug     *DebugOptions     `json:"debug,omitempty"`
 }
 
@@ -14,6 +17,18 @@ type CacheFileOptions struct {
 	StoreFakeIP bool     `json:"store_fakeip,omitempty"`
 	StoreRDRC   bool     `json:"store_rdrc,omitempty"`
 	RDRCTimeout Duration `json:"rdrc_timeout,omitempty"`
+	StoreDNS    bool     `json:"store_dns,omitempty"`
+}
+
+type MetricsOptions struct {
+	Listen string `json:"listen,omitempty"`
+	Path   string `json:"path,omitempty"`
+}
+
+type AccessLogOptions struct {
+	Path   string `json:"path,omitempty"`
+	Format string `json:"format,omitempty"`
+	LogFileOptions
 }
 
 type ClashAPIOptions struct {
@@ -48,3 +63,9 @@ type V2RayStatsServiceOptions struct {
 	Outbounds []string `json:"outbounds,omitempty"`
 	Users     []string `json:"users,omitempty"`
 }
+
+type UserAPIOptions struct {
+	Listen string `json:"listen,omitempty"`
+	Secret string `json:"secret,omitempty"`
+	Path   string `json:"path,omitempty"`
+}
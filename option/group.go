@@ -2,15 +2,56 @@ package option
 
 type SelectorOutboundOptions struct {
 	Outbounds                 []string `json:"outbounds"`
+	Providers                 []string `json:"providers,omitempty"`
+	Include                   string   `json:"include,omitempty"`
+	Exclude                   string   `json:"exclude,omitempty"`
 	Default                   string   `json:"default,omitempty"`
 	InterruptExistConnections bool     `json:"interrupt_exist_connections,omitempty"`
 }
 
 type URLTestOutboundOptions struct {
 	Outbounds                 []string `json:"outbounds"`
+	Providers                 []string `json:"providers,omitempty"`
+	Include                   string   `json:"include,omitempty"`
+	Exclude                   string   `json:"exclude,omitempty"`
 	URL                       string   `json:"url,omitempty"`
 	Interval                  Duration `json:"interval,omitempty"`
 	Tolerance                 uint16   `json:"tolerance,omitempty"`
 	IdleTimeout               Duration `json:"idle_timeout,omitempty"`
 	InterruptExistConnections bool     `json:"interrupt_exist_connections,omitempty"`
 }
+
+type FallbackOutboundOptions struct {
+	Outbounds                 []string `json:"outbounds"`
+	Providers                 []string `json:"providers,omitempty"`
+	Include                   string   `json:"include,omitempty"`
+	Exclude                   string   `json:"exclude,omitempty"`
+	URL                       string   `json:"url,omitempty"`
+	Interval                  Duration `json:"interval,omitempty"`
+	StablePeriod              Duration `json:"stable_period,omitempty"`
+	InterruptExistConnections bool     `json:"interrupt_exist_connections,omitempty"`
+}
+
+type LoadBalanceOutboundOptions struct {
+	Outbounds  []string `json:"outbounds"`
+	Providers  []string `json:"providers,omitempty"`
+	Include    string   `json:"include,omitempty"`
+	Exclude    string   `json:"exclude,omitempty"`
+	Strategy   string   `json:"strategy,omitempty"`
+	SessionTTL Duration `json:"session_ttl,omitempty"`
+	URL        string   `json:"url,omitempty"`
+	Interval   Duration `json:"interval,omitempty"`
+}
+
+type ChainOutboundOptions struct {
+	Outbounds []string `json:"outbounds"`
+}
+
+type LimiterOutboundOptions struct {
+	Outbound           string      `json:"outbound"`
+	UpMbps             int         `json:"up_mbps,omitempty"`
+	DownMbps           int         `json:"down_mbps,omitempty"`
+	ConnectionUpMbps   int         `json:"connection_up_mbps,omitempty"`
+	ConnectionDownMbps int         `json:"connection_down_mbps,omitempty"`
+	Burst              MemoryBytes `json:"burst,omitempty"`
+}
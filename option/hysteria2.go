@@ -20,6 +20,7 @@ type Hysteria2Obfs struct {
 type Hysteria2User struct {
 	Name     string `json:"name,omitempty"`
 	Password string `json:"password,omitempty"`
+	UserLimitOptions
 }
 
 type Hysteria2OutboundOptions struct {
@@ -27,6 +27,10 @@ type _Outbound struct {
 	Hysteria2Options    Hysteria2OutboundOptions    `json:"-"`
 	SelectorOptions     SelectorOutboundOptions     `json:"-"`
 	URLTestOptions      URLTestOutboundOptions      `json:"-"`
+	FallbackOptions     FallbackOutboundOptions     `json:"-"`
+	LoadBalanceOptions  LoadBalanceOutboundOptions  `json:"-"`
+	ChainOptions        ChainOutboundOptions        `json:"-"`
+	LimiterOptions      LimiterOutboundOptions      `json:"-"`
 }
 
 type Outbound _Outbound
@@ -70,6 +74,14 @@ func (h *Outbound) RawOptions() (any, error) {
 		rawOptionsPtr = &h.SelectorOptions
 	case C.TypeURLTest:
 		rawOptionsPtr = &h.URLTestOptions
+	case C.TypeFallback:
+		rawOptionsPtr = &h.FallbackOptions
+	case C.TypeLoadBalance:
+		rawOptionsPtr = &h.LoadBalanceOptions
+	case C.TypeChain:
+		rawOptionsPtr = &h.ChainOptions
+	case C.TypeLimiter:
+		rawOptionsPtr = &h.LimiterOptions
 	case "":
 		return nil, E.New("missing outbound type")
 	default:
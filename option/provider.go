@@ -0,0 +1,66 @@
+package option
+
+import (
+	C "github.com/sagernet/sing-box/constant"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+)
+
+type _OutboundProvider struct {
+	Type          string                        `json:"type"`
+	Tag           string                        `json:"tag"`
+	LocalOptions  LocalOutboundProviderOptions  `json:"-"`
+	RemoteOptions RemoteOutboundProviderOptions `json:"-"`
+}
+
+type OutboundProvider _OutboundProvider
+
+func (p OutboundProvider) MarshalJSON() ([]byte, error) {
+	var v any
+	switch p.Type {
+	case C.ProviderTypeLocal:
+		v = p.LocalOptions
+	case C.ProviderTypeRemote:
+		v = p.RemoteOptions
+	default:
+		return nil, E.New("unknown provider type: " + p.Type)
+	}
+	return MarshallObjects((_OutboundProvider)(p), v)
+}
+
+func (p *OutboundProvider) UnmarshalJSON(bytes []byte) error {
+	err := json.Unmarshal(bytes, (*_OutboundProvider)(p))
+	if err != nil {
+		return err
+	}
+	if p.Tag == "" {
+		return E.New("missing tag")
+	}
+	var v any
+	switch p.Type {
+	case C.ProviderTypeLocal:
+		v = &p.LocalOptions
+	case C.ProviderTypeRemote:
+		v = &p.RemoteOptions
+	case "":
+		return E.New("missing type")
+	default:
+		return E.New("unknown provider type: " + p.Type)
+	}
+	err = UnmarshallExcluded(bytes, (*_OutboundProvider)(p), v)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+type LocalOutboundProviderOptions struct {
+	Path string `json:"path,omitempty"`
+}
+
+type RemoteOutboundProviderOptions struct {
+	URL            string   `json:"url"`
+	UserAgent      string   `json:"user_agent,omitempty"`
+	DownloadDetour string   `json:"download_detour,omitempty"`
+	UpdateInterval Duration `json:"update_interval,omitempty"`
+}
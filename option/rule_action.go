@@ -0,0 +1,13 @@
+package option
+
+type RuleAction struct {
+	Action          string           `json:"action,omitempty"`
+	Outbound        string           `json:"outbound,omitempty"`
+	OverrideAddress string           `json:"override_address,omitempty"`
+	OverridePort    uint16           `json:"override_port,omitempty"`
+	Method          string           `json:"method,omitempty"`
+	Sniffer         Listable[string] `json:"sniffer,omitempty"`
+	Timeout         Duration         `json:"timeout,omitempty"`
+	Server          string           `json:"server,omitempty"`
+	Strategy        DomainStrategy   `json:"strategy,omitempty"`
+}
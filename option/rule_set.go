@@ -16,7 +16,8 @@ import (
 type _RuleSet struct {
 	Type          string        `json:"type"`
 	Tag           string        `json:"tag"`
-	Format        string        `json:"format"`
+	Format        string        `json:"format,omitempty"`
+	InlineOptions PlainRuleSet  `json:"-"`
 	LocalOptions  LocalRuleSet  `json:"-"`
 	RemoteOptions RemoteRuleSet `json:"-"`
 }
@@ -26,6 +27,8 @@ type RuleSet _RuleSet
 func (r RuleSet) MarshalJSON() ([]byte, error) {
 	var v any
 	switch r.Type {
+	case C.RuleSetTypeInline:
+		v = r.InlineOptions
 	case C.RuleSetTypeLocal:
 		v = r.LocalOptions
 	case C.RuleSetTypeRemote:
@@ -44,15 +47,23 @@ func (r *RuleSet) UnmarshalJSON(bytes []byte) error {
 	if r.Tag == "" {
 		return E.New("missing tag")
 	}
-	switch r.Format {
-	case "":
-		return E.New("missing format")
-	case C.RuleSetFormatSource, C.RuleSetFormatBinary:
-	default:
-		return E.New("unknown rule set format: " + r.Format)
+	if r.Type != C.RuleSetTypeInline {
+		switch r.Format {
+		case "":
+			return E.New("missing format")
+		case C.RuleSetFormatSource, C.RuleSetFormatBinary,
+			C.RuleSetFormatClashDomain, C.RuleSetFormatClashIPCIDR, C.RuleSetFormatClashClassical,
+			C.RuleSetFormatAdGuard, C.RuleSetFormatHosts, C.RuleSetFormatDnsmasq:
+		default:
+			return E.New("unknown rule set format: " + r.Format)
+		}
+	} else if r.Format != "" {
+		return E.New("format is not allowed for inline rule set")
 	}
 	var v any
 	switch r.Type {
+	case C.RuleSetTypeInline:
+		v = &r.InlineOptions
 	case C.RuleSetTypeLocal:
 		v = &r.LocalOptions
 	case C.RuleSetTypeRemote:
@@ -77,6 +88,7 @@ type RemoteRuleSet struct {
 	URL            string   `json:"url"`
 	DownloadDetour string   `json:"download_detour,omitempty"`
 	UpdateInterval Duration `json:"update_interval,omitempty"`
+	MaxAge         Duration `json:"max_age,omitempty"`
 }
 
 type _HeadlessRule struct {
@@ -135,24 +147,32 @@ func (r HeadlessRule) IsValid() bool {
 }
 
 type DefaultHeadlessRule struct {
-	QueryType       Listable[DNSQueryType] `json:"query_type,omitempty"`
-	Network         Listable[string]       `json:"network,omitempty"`
-	Domain          Listable[string]       `json:"domain,omitempty"`
-	DomainSuffix    Listable[string]       `json:"domain_suffix,omitempty"`
-	DomainKeyword   Listable[string]       `json:"domain_keyword,omitempty"`
-	DomainRegex     Listable[string]       `json:"domain_regex,omitempty"`
-	SourceIPCIDR    Listable[string]       `json:"source_ip_cidr,omitempty"`
-	IPCIDR          Listable[string]       `json:"ip_cidr,omitempty"`
-	SourcePort      Listable[uint16]       `json:"source_port,omitempty"`
-	SourcePortRange Listable[string]       `json:"source_port_range,omitempty"`
-	Port            Listable[uint16]       `json:"port,omitempty"`
-	PortRange       Listable[string]       `json:"port_range,omitempty"`
-	ProcessName     Listable[string]       `json:"process_name,omitempty"`
-	ProcessPath     Listable[string]       `json:"process_path,omitempty"`
-	PackageName     Listable[string]       `json:"package_name,omitempty"`
-	WIFISSID        Listable[string]       `json:"wifi_ssid,omitempty"`
-	WIFIBSSID       Listable[string]       `json:"wifi_bssid,omitempty"`
-	Invert          bool                   `json:"invert,omitempty"`
+	Inbound           Listable[string]       `json:"inbound,omitempty"`
+	QueryType         Listable[DNSQueryType] `json:"query_type,omitempty"`
+	Network           Listable[string]       `json:"network,omitempty"`
+	AuthUser          Listable[string]       `json:"auth_user,omitempty"`
+	Protocol          Listable[string]       `json:"protocol,omitempty"`
+	Domain            Listable[string]       `json:"domain,omitempty"`
+	DomainSuffix      Listable[string]       `json:"domain_suffix,omitempty"`
+	DomainKeyword     Listable[string]       `json:"domain_keyword,omitempty"`
+	DomainRegex       Listable[string]       `json:"domain_regex,omitempty"`
+	SourceIPCIDR      Listable[string]       `json:"source_ip_cidr,omitempty"`
+	SourceIPIsPrivate bool                   `json:"source_ip_is_private,omitempty"`
+	IPCIDR            Listable[string]       `json:"ip_cidr,omitempty"`
+	IPIsPrivate       bool                   `json:"ip_is_private,omitempty"`
+	SourcePort        Listable[uint16]       `json:"source_port,omitempty"`
+	SourcePortRange   Listable[string]       `json:"source_port_range,omitempty"`
+	Port              Listable[uint16]       `json:"port,omitempty"`
+	PortRange         Listable[string]       `json:"port_range,omitempty"`
+	ProcessName       Listable[string]       `json:"process_name,omitempty"`
+	ProcessPath       Listable[string]       `json:"process_path,omitempty"`
+	PackageName       Listable[string]       `json:"package_name,omitempty"`
+	User              Listable[string]       `json:"user,omitempty"`
+	UserID            Listable[int32]        `json:"user_id,omitempty"`
+	ClashMode         string                 `json:"clash_mode,omitempty"`
+	WIFISSID          Listable[string]       `json:"wifi_ssid,omitempty"`
+	WIFIBSSID         Listable[string]       `json:"wifi_bssid,omitempty"`
+	Invert            bool                   `json:"invert,omitempty"`
 
 	DomainMatcher *domain.Matcher `json:"-"`
 	SourceIPSet   *netipx.IPSet   `json:"-"`
@@ -165,6 +185,11 @@ func (r DefaultHeadlessRule) IsValid() bool {
 	return !reflect.DeepEqual(r, defaultValue)
 }
 
+func (r DefaultHeadlessRule) RequiresVersion2() bool {
+	return len(r.Inbound) > 0 || len(r.AuthUser) > 0 || len(r.Protocol) > 0 || r.SourceIPIsPrivate || r.IPIsPrivate ||
+		len(r.User) > 0 || len(r.UserID) > 0 || r.ClashMode != ""
+}
+
 type LogicalHeadlessRule struct {
 	Mode   string         `json:"mode"`
 	Rules  []HeadlessRule `json:"rules,omitempty"`
@@ -175,6 +200,17 @@ func (r LogicalHeadlessRule) IsValid() bool {
 	return len(r.Rules) > 0 && common.All(r.Rules, HeadlessRule.IsValid)
 }
 
+func (r HeadlessRule) RequiresVersion2() bool {
+	switch r.Type {
+	case C.RuleTypeDefault, "":
+		return r.DefaultOptions.RequiresVersion2()
+	case C.RuleTypeLogical:
+		return common.Any(r.LogicalOptions.Rules, HeadlessRule.RequiresVersion2)
+	default:
+		return false
+	}
+}
+
 type _PlainRuleSetCompat struct {
 	Version int          `json:"version"`
 	Options PlainRuleSet `json:"-"`
@@ -185,7 +221,7 @@ type PlainRuleSetCompat _PlainRuleSetCompat
 func (r PlainRuleSetCompat) MarshalJSON() ([]byte, error) {
 	var v any
 	switch r.Version {
-	case C.RuleSetVersion1:
+	case C.RuleSetVersion1, C.RuleSetVersion2:
 		v = r.Options
 	default:
 		return nil, E.New("unknown rule set version: ", r.Version)
@@ -200,7 +236,7 @@ func (r *PlainRuleSetCompat) UnmarshalJSON(bytes []byte) error {
 	}
 	var v any
 	switch r.Version {
-	case C.RuleSetVersion1:
+	case C.RuleSetVersion1, C.RuleSetVersion2:
 		v = &r.Options
 	case 0:
 		return E.New("missing rule set version")
@@ -211,13 +247,16 @@ func (r *PlainRuleSetCompat) UnmarshalJSON(bytes []byte) error {
 	if err != nil {
 		return err
 	}
+	if r.Version < C.RuleSetVersion2 && r.Options.RequiresVersion2() {
+		return E.New("rule set version ", C.RuleSetVersion2, " is required for inbound, auth_user, protocol, source_ip_is_private, ip_is_private, user, user_id and clash_mode")
+	}
 	return nil
 }
 
 func (r PlainRuleSetCompat) Upgrade() PlainRuleSet {
 	var result PlainRuleSet
 	switch r.Version {
-	case C.RuleSetVersion1:
+	case C.RuleSetVersion1, C.RuleSetVersion2:
 		result = r.Options
 	default:
 		panic("unknown rule set version: " + F.ToString(r.Version))
@@ -228,3 +267,7 @@ func (r PlainRuleSetCompat) Upgrade() PlainRuleSet {
 type PlainRuleSet struct {
 	Rules []HeadlessRule `json:"rules,omitempty"`
 }
+
+func (r PlainRuleSet) RequiresVersion2() bool {
+	return common.Any(r.Rules, HeadlessRule.RequiresVersion2)
+}
@@ -2,17 +2,21 @@ package option
 
 type ShadowsocksInboundOptions struct {
 	ListenOptions
-	Network      NetworkList              `json:"network,omitempty"`
-	Method       string                   `json:"method"`
-	Password     string                   `json:"password,omitempty"`
-	Users        []ShadowsocksUser        `json:"users,omitempty"`
-	Destinations []ShadowsocksDestination `json:"destinations,omitempty"`
-	Multiplex    *InboundMultiplexOptions `json:"multiplex,omitempty"`
+	Network       NetworkList              `json:"network,omitempty"`
+	Method        string                   `json:"method"`
+	Password      string                   `json:"password,omitempty"`
+	Users         []ShadowsocksUser        `json:"users,omitempty"`
+	Destinations  []ShadowsocksDestination `json:"destinations,omitempty"`
+	Multiplex     *InboundMultiplexOptions `json:"multiplex,omitempty"`
+	Plugin        string                   `json:"plugin,omitempty"`
+	PluginPath    string                   `json:"plugin_path,omitempty"`
+	PluginOptions string                   `json:"plugin_opts,omitempty"`
 }
 
 type ShadowsocksUser struct {
 	Name     string `json:"name"`
 	Password string `json:"password"`
+	UserLimitOptions
 }
 
 type ShadowsocksDestination struct {
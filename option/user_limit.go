@@ -0,0 +1,14 @@
+package option
+
+type UserLimitOptions struct {
+	UpMbps         int         `json:"up_mbps,omitempty"`
+	DownMbps       int         `json:"down_mbps,omitempty"`
+	DailyQuota     MemoryBytes `json:"daily_quota,omitempty"`
+	MonthlyQuota   MemoryBytes `json:"monthly_quota,omitempty"`
+	MaxConnections int         `json:"max_connections,omitempty"`
+	MaxIPs         int         `json:"max_ips,omitempty"`
+}
+
+func (o UserLimitOptions) IsEmpty() bool {
+	return o == UserLimitOptions{}
+}
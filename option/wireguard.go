@@ -19,6 +19,22 @@ type WireGuardOutboundOptions struct {
 	Network       NetworkList `json:"network,omitempty"`
 }
 
+type WireGuardInboundOptions struct {
+	ListenOptions
+	PrivateKey string                 `json:"private_key"`
+	Peers      []WireGuardInboundPeer `json:"peers"`
+	Workers    int                    `json:"workers,omitempty"`
+	MTU        uint32                 `json:"mtu,omitempty"`
+}
+
+type WireGuardInboundPeer struct {
+	Name         string                 `json:"name,omitempty"`
+	PublicKey    string                 `json:"public_key"`
+	PreSharedKey string                 `json:"pre_shared_key,omitempty"`
+	AllowedIPs   Listable[netip.Prefix] `json:"allowed_ips"`
+	UserLimitOptions
+}
+
 type WireGuardPeer struct {
 	ServerOptions
 	PublicKey    string           `json:"public_key,omitempty"`
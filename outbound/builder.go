@@ -59,6 +59,14 @@ func New(ctx context.Context, router adapter.Router, logger log.ContextLogger, t
 		return NewSelector(ctx, router, logger, tag, options.SelectorOptions)
 	case C.TypeURLTest:
 		return NewURLTest(ctx, router, logger, tag, options.URLTestOptions)
+	case C.TypeFallback:
+		return NewFallback(ctx, router, logger, tag, options.FallbackOptions)
+	case C.TypeLoadBalance:
+		return NewLoadBalance(ctx, router, logger, tag, options.LoadBalanceOptions)
+	case C.TypeChain:
+		return NewChain(router, logger, tag, options.ChainOptions)
+	case C.TypeLimiter:
+		return NewLimiter(router, logger, tag, options.LimiterOptions)
 	default:
 		return nil, E.New("unknown outbound type: ", options.Type)
 	}
@@ -0,0 +1,156 @@
+package outbound
+
+import (
+	"context"
+	"net"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/dialer"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+var (
+	_ adapter.Outbound      = (*Chain)(nil)
+	_ adapter.OutboundChain = (*Chain)(nil)
+)
+
+// Chain connects through its outbounds in order, each hop dialing its server
+// through the previous one instead of its own detour.
+type Chain struct {
+	myOutboundAdapter
+	tags   []string
+	dialer N.Dialer
+}
+
+func NewChain(router adapter.Router, logger log.ContextLogger, tag string, options option.ChainOutboundOptions) (*Chain, error) {
+	if len(options.Outbounds) < 2 {
+		return nil, E.New("chain requires at least two outbounds")
+	}
+	return &Chain{
+		myOutboundAdapter: myOutboundAdapter{
+			protocol:     C.TypeChain,
+			network:      []string{N.NetworkTCP, N.NetworkUDP},
+			router:       router,
+			logger:       logger,
+			tag:          tag,
+			dependencies: options.Outbounds,
+		},
+		tags: options.Outbounds,
+	}, nil
+}
+
+func (h *Chain) Start() error {
+	network := []string{N.NetworkTCP, N.NetworkUDP}
+	var hopDialer N.Dialer
+	for i, tag := range h.tags {
+		detour, loaded := h.router.Outbound(tag)
+		if !loaded {
+			return E.New("outbound ", i, " not found: ", tag)
+		}
+		if i > 0 {
+			err := h.checkHop(detour)
+			if err != nil {
+				return E.Cause(err, "outbound ", i)
+			}
+		}
+		network = common.Filter(network, func(it string) bool {
+			return common.Contains(detour.Network(), it)
+		})
+		hopDialer = &chainHop{detour, hopDialer}
+	}
+	if len(network) == 0 {
+		return E.New("no network supported by all outbounds")
+	}
+	h.network = network
+	h.dialer = hopDialer
+	return nil
+}
+
+// checkHop checks that the outbound, or all outbounds of the group, dial
+// their server per connection, so that it can be dialed through the previous hop.
+func (h *Chain) checkHop(detour adapter.Outbound) error {
+	if group, isGroup := detour.(adapter.OutboundGroup); isGroup {
+		for _, tag := range group.All() {
+			member, loaded := h.router.Outbound(tag)
+			if !loaded {
+				continue
+			}
+			err := h.checkHop(member)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	switch detour.Type() {
+	case C.TypeDirect, C.TypeBlock, C.TypeDNS, C.TypeWireGuard, C.TypeTor, C.TypeSSH, C.TypeHysteria, C.TypeHysteria2, C.TypeTUIC:
+		return E.New(detour.Type(), " outbound ", detour.Tag(), " can only be used as the entry of a chain")
+	}
+	if multiplexer, isMultiplexer := detour.(multiplexOutbound); isMultiplexer && multiplexer.multiplexEnabled() {
+		return E.New("outbound ", detour.Tag(), " with multiplex can only be used as the entry of a chain")
+	}
+	return nil
+}
+
+func (h *Chain) Hops() []string {
+	return h.tags
+}
+
+func (h *Chain) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	ctx, metadata := adapter.AppendContext(ctx)
+	metadata.Outbound = h.tag
+	metadata.Destination = destination
+	if !common.Contains(h.network, N.NetworkName(network)) {
+		return nil, E.Extend(N.ErrUnknownNetwork, network)
+	}
+	return h.dialer.DialContext(ctx, network, destination)
+}
+
+func (h *Chain) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	ctx, metadata := adapter.AppendContext(ctx)
+	metadata.Outbound = h.tag
+	metadata.Destination = destination
+	if !common.Contains(h.network, N.NetworkUDP) {
+		return nil, E.Extend(N.ErrUnknownNetwork, N.NetworkUDP)
+	}
+	return h.dialer.ListenPacket(ctx, destination)
+}
+
+func (h *Chain) NewConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	return NewConnection(ctx, h, conn, metadata)
+}
+
+func (h *Chain) NewPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
+	return NewPacketConnection(ctx, h, conn, metadata)
+}
+
+// multiplexOutbound is implemented by outbounds that may share a multiplexed
+// connection between connections, which ignores the previous hop of a chain.
+type multiplexOutbound interface {
+	multiplexEnabled() bool
+}
+
+type chainHop struct {
+	outbound adapter.Outbound
+	previous N.Dialer
+}
+
+func (h *chainHop) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	if h.previous != nil {
+		ctx = dialer.ContextWithDetourOverride(ctx, h.previous)
+	}
+	return h.outbound.DialContext(ctx, network, destination)
+}
+
+func (h *chainHop) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	if h.previous != nil {
+		ctx = dialer.ContextWithDetourOverride(ctx, h.previous)
+	}
+	return h.outbound.ListenPacket(ctx, destination)
+}
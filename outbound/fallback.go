@@ -0,0 +1,439 @@
+package outbound
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/interrupt"
+	"github.com/sagernet/sing-box/common/urltest"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/atomic"
+	"github.com/sagernet/sing/common/batch"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/service"
+	"github.com/sagernet/sing/service/pause"
+)
+
+var (
+	_ adapter.Outbound                = (*Fallback)(nil)
+	_ adapter.OutboundGroup           = (*Fallback)(nil)
+	_ adapter.URLTestGroup            = (*Fallback)(nil)
+	_ adapter.InterfaceUpdateListener = (*Fallback)(nil)
+)
+
+type Fallback struct {
+	myOutboundAdapter
+	ctx                          context.Context
+	outbounds                    *groupOutbounds
+	link                         string
+	interval                     time.Duration
+	stablePeriod                 time.Duration
+	history                      *urltest.HistoryStorage
+	pauseManager                 pause.Manager
+	interruptGroup               *interrupt.Group
+	interruptExternalConnections bool
+	checking                     atomic.Bool
+
+	outboundsAccess sync.RWMutex
+	outboundList    []adapter.Outbound
+
+	access              sync.Mutex
+	states              map[string]*fallbackState
+	selectedOutboundTCP adapter.Outbound
+	selectedOutboundUDP adapter.Outbound
+
+	ticker *time.Ticker
+	close  chan struct{}
+}
+
+type fallbackState struct {
+	available bool
+	since     time.Time
+}
+
+func NewFallback(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.FallbackOutboundOptions) (*Fallback, error) {
+	outbounds, err := newGroupOutbounds(router, options.Outbounds, options.Providers, options.Include, options.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	interval := time.Duration(options.Interval)
+	if interval == 0 {
+		interval = C.DefaultURLTestInterval
+	}
+	stablePeriod := time.Duration(options.StablePeriod)
+	if stablePeriod == 0 {
+		stablePeriod = interval
+	}
+	var history *urltest.HistoryStorage
+	if history = service.PtrFromContext[urltest.HistoryStorage](ctx); history != nil {
+	} else if clashServer := router.ClashServer(); clashServer != nil {
+		history = clashServer.HistoryStorage()
+	} else {
+		history = urltest.NewHistoryStorage()
+	}
+	return &Fallback{
+		myOutboundAdapter: myOutboundAdapter{
+			protocol:     C.TypeFallback,
+			network:      []string{N.NetworkTCP, N.NetworkUDP},
+			router:       router,
+			logger:       logger,
+			tag:          tag,
+			dependencies: options.Outbounds,
+		},
+		ctx:                          ctx,
+		outbounds:                    outbounds,
+		link:                         options.URL,
+		interval:                     interval,
+		stablePeriod:                 stablePeriod,
+		history:                      history,
+		pauseManager:                 service.FromContext[pause.Manager](ctx),
+		interruptGroup:               interrupt.NewGroup(),
+		interruptExternalConnections: options.InterruptExistConnections,
+		states:                       make(map[string]*fallbackState),
+		close:                        make(chan struct{}),
+	}, nil
+}
+
+func (s *Fallback) Start() error {
+	outbounds, err := s.outbounds.Start(s.updateOutbounds)
+	if err != nil {
+		return err
+	}
+	s.outboundsAccess.Lock()
+	s.outboundList = outbounds
+	s.outboundsAccess.Unlock()
+	return nil
+}
+
+func (s *Fallback) PostStart() error {
+	s.access.Lock()
+	s.ticker = time.NewTicker(s.interval)
+	s.access.Unlock()
+	go s.loopCheck()
+	return nil
+}
+
+func (s *Fallback) Close() error {
+	s.access.Lock()
+	if s.ticker != nil {
+		s.ticker.Stop()
+		close(s.close)
+		s.ticker = nil
+	}
+	s.access.Unlock()
+	return s.outbounds.Close()
+}
+
+func (s *Fallback) updateOutbounds() {
+	outbounds, err := s.outbounds.Outbounds()
+	if err != nil {
+		s.logger.Error("update outbounds: ", err)
+		return
+	}
+	s.outboundsAccess.Lock()
+	s.outboundList = outbounds
+	s.outboundsAccess.Unlock()
+	s.access.Lock()
+	for tag := range s.states {
+		if !common.Any(outbounds, func(it adapter.Outbound) bool {
+			return it.Tag() == tag
+		}) {
+			delete(s.states, tag)
+		}
+	}
+	s.access.Unlock()
+	go s.CheckOutbounds()
+}
+
+func (s *Fallback) Outbounds() []adapter.Outbound {
+	s.outboundsAccess.RLock()
+	defer s.outboundsAccess.RUnlock()
+	return s.outboundList
+}
+
+func (s *Fallback) Now() string {
+	s.access.Lock()
+	defer s.access.Unlock()
+	if s.selectedOutboundTCP != nil {
+		return s.selectedOutboundTCP.Tag()
+	} else if s.selectedOutboundUDP != nil {
+		return s.selectedOutboundUDP.Tag()
+	}
+	if outbounds := s.Outbounds(); len(outbounds) > 0 {
+		return outbounds[0].Tag()
+	}
+	return ""
+}
+
+func (s *Fallback) All() []string {
+	return outboundTags(s.Outbounds())
+}
+
+func (s *Fallback) URLTest(ctx context.Context) (map[string]uint16, error) {
+	return s.urlTest(ctx)
+}
+
+func (s *Fallback) CheckOutbounds() {
+	_, _ = s.urlTest(s.ctx)
+}
+
+func (s *Fallback) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	switch N.NetworkName(network) {
+	case N.NetworkTCP, N.NetworkUDP:
+	default:
+		return nil, E.Extend(N.ErrUnknownNetwork, network)
+	}
+	var lastErr error
+	tried := make(map[adapter.Outbound]bool)
+	for {
+		outbound := s.selectOutbound(network, tried)
+		if outbound == nil {
+			break
+		}
+		tried[outbound] = true
+		conn, err := outbound.DialContext(ctx, network, destination)
+		if err == nil {
+			return s.interruptGroup.NewConn(conn, interrupt.IsExternalConnectionFromContext(ctx)), nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		s.logger.ErrorContext(ctx, "outbound ", outbound.Tag(), ": ", err)
+		s.setUnavailable(outbound)
+		lastErr = err
+	}
+	if lastErr == nil {
+		return nil, E.New("missing supported outbound")
+	}
+	return nil, lastErr
+}
+
+func (s *Fallback) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	var lastErr error
+	tried := make(map[adapter.Outbound]bool)
+	for {
+		outbound := s.selectOutbound(N.NetworkUDP, tried)
+		if outbound == nil {
+			break
+		}
+		tried[outbound] = true
+		conn, err := outbound.ListenPacket(ctx, destination)
+		if err == nil {
+			return s.interruptGroup.NewPacketConn(conn, interrupt.IsExternalConnectionFromContext(ctx)), nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		s.logger.ErrorContext(ctx, "outbound ", outbound.Tag(), ": ", err)
+		s.setUnavailable(outbound)
+		lastErr = err
+	}
+	if lastErr == nil {
+		return nil, E.New("missing supported outbound")
+	}
+	return nil, lastErr
+}
+
+func (s *Fallback) NewConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	ctx = interrupt.ContextWithIsExternalConnection(ctx)
+	return NewConnection(ctx, s, conn, metadata)
+}
+
+func (s *Fallback) NewPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
+	ctx = interrupt.ContextWithIsExternalConnection(ctx)
+	return NewPacketConnection(ctx, s, conn, metadata)
+}
+
+func (s *Fallback) InterfaceUpdated() {
+	go s.CheckOutbounds()
+	return
+}
+
+func (s *Fallback) loopCheck() {
+	s.CheckOutbounds()
+	s.access.Lock()
+	ticker := s.ticker
+	s.access.Unlock()
+	if ticker == nil {
+		return
+	}
+	for {
+		select {
+		case <-s.close:
+			return
+		case <-ticker.C:
+		}
+		s.pauseManager.WaitActive()
+		s.CheckOutbounds()
+	}
+}
+
+func (s *Fallback) urlTest(ctx context.Context) (map[string]uint16, error) {
+	result := make(map[string]uint16)
+	if s.checking.Swap(true) {
+		return result, nil
+	}
+	defer s.checking.Store(false)
+	b, _ := batch.New(ctx, batch.WithConcurrencyNum[any](10))
+	checked := make(map[string]bool)
+	var resultAccess sync.Mutex
+	for _, detour := range s.Outbounds() {
+		tag := detour.Tag()
+		if checked[tag] {
+			continue
+		}
+		checked[tag] = true
+		realTag := RealTag(detour)
+		p, loaded := s.router.Outbound(realTag)
+		if !loaded {
+			continue
+		}
+		b.Go(tag, func() (any, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), C.TCPTimeout)
+			defer cancel()
+			t, err := urltest.URLTest(ctx, s.link, p)
+			if err != nil {
+				s.logger.Debug("outbound ", tag, " unavailable: ", err)
+				s.history.DeleteURLTestHistory(realTag)
+				s.setAvailable(tag, false)
+			} else {
+				s.logger.Debug("outbound ", tag, " available: ", t, "ms")
+				s.history.StoreURLTestHistory(realTag, &urltest.History{
+					Time:  time.Now(),
+					Delay: t,
+				})
+				s.setAvailable(tag, true)
+				resultAccess.Lock()
+				result[tag] = t
+				resultAccess.Unlock()
+			}
+			return nil, nil
+		})
+	}
+	b.Wait()
+	s.performUpdateCheck()
+	return result, nil
+}
+
+func (s *Fallback) setAvailable(tag string, available bool) {
+	s.access.Lock()
+	defer s.access.Unlock()
+	state := s.states[tag]
+	if state == nil {
+		s.states[tag] = &fallbackState{available: available, since: time.Now()}
+		return
+	}
+	if state.available == available {
+		return
+	}
+	state.available = available
+	state.since = time.Now()
+	if available {
+		s.logger.Info("outbound ", tag, " recovered")
+	} else {
+		s.logger.Warn("outbound ", tag, " is down")
+	}
+}
+
+func (s *Fallback) setUnavailable(outbound adapter.Outbound) {
+	s.history.DeleteURLTestHistory(RealTag(outbound))
+	s.setAvailable(outbound.Tag(), false)
+	s.performUpdateCheck()
+}
+
+func (s *Fallback) performUpdateCheck() {
+	outbounds := s.Outbounds()
+	now := time.Now()
+	s.access.Lock()
+	var updated bool
+	if outbound := s.selectLocked(outbounds, N.NetworkTCP, s.selectedOutboundTCP, now); outbound != nil && outbound != s.selectedOutboundTCP {
+		s.selectedOutboundTCP = outbound
+		updated = true
+	}
+	if outbound := s.selectLocked(outbounds, N.NetworkUDP, s.selectedOutboundUDP, now); outbound != nil && outbound != s.selectedOutboundUDP {
+		s.selectedOutboundUDP = outbound
+		updated = true
+	}
+	selected := s.selectedOutboundTCP
+	s.access.Unlock()
+	if updated {
+		if selected != nil {
+			s.logger.Info("switched to ", selected.Tag())
+		}
+		s.interruptGroup.Interrupt(s.interruptExternalConnections)
+	}
+}
+
+func (s *Fallback) selectOutbound(network string, tried map[adapter.Outbound]bool) adapter.Outbound {
+	outbounds := s.Outbounds()
+	s.access.Lock()
+	defer s.access.Unlock()
+	var selected adapter.Outbound
+	if network == N.NetworkTCP {
+		selected = s.selectedOutboundTCP
+	} else {
+		selected = s.selectedOutboundUDP
+	}
+	if selected == nil || !common.Contains(outbounds, selected) {
+		selected = s.selectLocked(outbounds, network, nil, time.Now())
+		if network == N.NetworkTCP {
+			s.selectedOutboundTCP = selected
+		} else {
+			s.selectedOutboundUDP = selected
+		}
+	}
+	if selected != nil && !tried[selected] {
+		return selected
+	}
+	for _, detour := range outbounds {
+		if tried[detour] || !common.Contains(detour.Network(), network) {
+			continue
+		}
+		if state := s.states[detour.Tag()]; state != nil && !state.available {
+			continue
+		}
+		return detour
+	}
+	return nil
+}
+
+func (s *Fallback) selectLocked(outbounds []adapter.Outbound, network string, current adapter.Outbound, now time.Time) adapter.Outbound {
+	currentAvailable := current != nil && common.Contains(outbounds, current) && s.isAvailableLocked(current)
+	var first adapter.Outbound
+	for _, detour := range outbounds {
+		if !common.Contains(detour.Network(), network) {
+			continue
+		}
+		if first == nil {
+			first = detour
+		}
+		if detour == current {
+			if currentAvailable {
+				return current
+			}
+			continue
+		}
+		state := s.states[detour.Tag()]
+		if state != nil && !state.available {
+			continue
+		}
+		if currentAvailable && (state == nil || now.Sub(state.since) < s.stablePeriod) {
+			continue
+		}
+		return detour
+	}
+	return first
+}
+
+func (s *Fallback) isAvailableLocked(detour adapter.Outbound) bool {
+	state := s.states[detour.Tag()]
+	return state == nil || state.available
+}
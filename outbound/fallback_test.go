@@ -0,0 +1,167 @@
+package outbound
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/atomic"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/service"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testGroupRouter struct {
+	adapter.Router
+	outbounds map[string]adapter.Outbound
+}
+
+func (r *testGroupRouter) Outbound(tag string) (adapter.Outbound, bool) {
+	outbound, loaded := r.outbounds[tag]
+	return outbound, loaded
+}
+
+func (r *testGroupRouter) ClashServer() adapter.ClashServer {
+	return nil
+}
+
+type testGroupOutbound struct {
+	myOutboundAdapter
+	down atomic.Bool
+}
+
+func (o *testGroupOutbound) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	if o.down.Load() {
+		return nil, E.New("outbound ", o.tag, " is down")
+	}
+	conn, serverConn := net.Pipe()
+	serverConn.Close()
+	return conn, nil
+}
+
+func (o *testGroupOutbound) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	return nil, E.New("not implemented")
+}
+
+func (o *testGroupOutbound) NewConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	return NewConnection(ctx, o, conn, metadata)
+}
+
+func (o *testGroupOutbound) NewPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
+	return NewPacketConnection(ctx, o, conn, metadata)
+}
+
+// newTestGroupRouter creates outbounds supporting the networks by tag.
+func newTestGroupRouter(networks map[string][]string) *testGroupRouter {
+	router := &testGroupRouter{outbounds: make(map[string]adapter.Outbound)}
+	for tag, network := range networks {
+		router.outbounds[tag] = &testGroupOutbound{
+			myOutboundAdapter: myOutboundAdapter{
+				protocol: C.TypeDirect,
+				network:  network,
+				tag:      tag,
+			},
+		}
+	}
+	return router
+}
+
+func newTestFallback(t *testing.T, stablePeriod time.Duration) (*Fallback, *testGroupRouter) {
+	tcpAndUDP := []string{N.NetworkTCP, N.NetworkUDP}
+	router := newTestGroupRouter(map[string][]string{
+		"a": {N.NetworkTCP},
+		"b": tcpAndUDP,
+		"c": tcpAndUDP,
+	})
+	fallback, err := NewFallback(service.ContextWithDefaultRegistry(context.Background()), router, log.NewNOPFactory().Logger(), "fallback", option.FallbackOutboundOptions{
+		Outbounds:    []string{"a", "b", "c"},
+		StablePeriod: option.Duration(stablePeriod),
+	})
+	require.NoError(t, err)
+	require.NoError(t, fallback.Start())
+	t.Cleanup(func() {
+		fallback.Close()
+	})
+	return fallback, router
+}
+
+func TestFallbackSelect(t *testing.T) {
+	t.Parallel()
+	fallback, router := newTestFallback(t, time.Minute)
+	now := time.Now()
+	up := func(age time.Duration) *fallbackState {
+		return &fallbackState{available: true, since: now.Add(-age)}
+	}
+	down := &fallbackState{since: now}
+	for _, testCase := range []struct {
+		name     string
+		states   map[string]*fallbackState
+		network  string
+		current  string
+		selected string
+	}{
+		{name: "priority", network: N.NetworkTCP, selected: "a"},
+		{name: "network", network: N.NetworkUDP, selected: "b"},
+		{name: "failover", states: map[string]*fallbackState{"a": down}, network: N.NetworkTCP, selected: "b"},
+		{name: "failover to lower priority", states: map[string]*fallbackState{"a": down, "b": down}, network: N.NetworkTCP, selected: "c"},
+		{name: "all down", states: map[string]*fallbackState{"a": down, "b": down, "c": down}, network: N.NetworkTCP, selected: "a"},
+		{name: "keep current", states: map[string]*fallbackState{"a": up(time.Second)}, network: N.NetworkTCP, current: "b", selected: "b"},
+		{name: "failback", states: map[string]*fallbackState{"a": up(2 * time.Minute)}, network: N.NetworkTCP, current: "b", selected: "a"},
+		{name: "failback to higher priority", states: map[string]*fallbackState{"a": up(time.Second), "b": up(2 * time.Minute)}, network: N.NetworkTCP, current: "c", selected: "b"},
+		{name: "current down", states: map[string]*fallbackState{"a": up(time.Second), "b": down}, network: N.NetworkTCP, current: "b", selected: "a"},
+	} {
+		fallback.states = make(map[string]*fallbackState)
+		for tag, state := range testCase.states {
+			fallback.states[tag] = state
+		}
+		var current adapter.Outbound
+		if testCase.current != "" {
+			current = router.outbounds[testCase.current]
+		}
+		selected := fallback.selectLocked(fallback.Outbounds(), testCase.network, current, now)
+		require.Equal(t, testCase.selected, selected.Tag(), testCase.name)
+	}
+}
+
+func TestFallbackDial(t *testing.T) {
+	t.Parallel()
+	fallback, router := newTestFallback(t, time.Minute)
+	ctx := context.Background()
+	destination := M.ParseSocksaddr("example.com:443")
+	conn, err := fallback.DialContext(ctx, N.NetworkTCP, destination)
+	require.NoError(t, err)
+	conn.Close()
+	require.Equal(t, "a", fallback.Now())
+
+	// a failed dial fails over to the next outbound
+	router.outbounds["a"].(*testGroupOutbound).down.Store(true)
+	conn, err = fallback.DialContext(ctx, N.NetworkTCP, destination)
+	require.NoError(t, err)
+	conn.Close()
+	require.Equal(t, "b", fallback.Now())
+
+	// a recovered outbound is used again only after the stable period
+	router.outbounds["a"].(*testGroupOutbound).down.Store(false)
+	fallback.setAvailable("a", true)
+	fallback.performUpdateCheck()
+	require.Equal(t, "b", fallback.Now())
+	fallback.access.Lock()
+	fallback.states["a"].since = time.Now().Add(-2 * time.Minute)
+	fallback.access.Unlock()
+	fallback.performUpdateCheck()
+	require.Equal(t, "a", fallback.Now())
+
+	for _, detour := range router.outbounds {
+		detour.(*testGroupOutbound).down.Store(true)
+	}
+	_, err = fallback.DialContext(ctx, N.NetworkTCP, destination)
+	require.ErrorContains(t, err, "is down")
+}
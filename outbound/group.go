@@ -0,0 +1,122 @@
+package outbound
+
+import (
+	"regexp"
+	"sync"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/x/list"
+)
+
+type groupOutbounds struct {
+	router       adapter.Router
+	tags         []string
+	providerTags []string
+	include      *regexp.Regexp
+	exclude      *regexp.Regexp
+
+	access    sync.Mutex
+	providers []adapter.OutboundProvider
+	callbacks []*list.Element[adapter.OutboundProviderUpdateCallback]
+}
+
+func newGroupOutbounds(router adapter.Router, tags []string, providerTags []string, include string, exclude string) (*groupOutbounds, error) {
+	if len(tags) == 0 && len(providerTags) == 0 {
+		return nil, E.New("missing tags")
+	}
+	group := &groupOutbounds{
+		router:       router,
+		tags:         tags,
+		providerTags: providerTags,
+	}
+	if include != "" {
+		regex, err := regexp.Compile(include)
+		if err != nil {
+			return nil, E.Cause(err, "parse include")
+		}
+		group.include = regex
+	}
+	if exclude != "" {
+		regex, err := regexp.Compile(exclude)
+		if err != nil {
+			return nil, E.Cause(err, "parse exclude")
+		}
+		group.exclude = regex
+	}
+	return group, nil
+}
+
+func (g *groupOutbounds) HasProviders() bool {
+	return len(g.providerTags) > 0
+}
+
+func (g *groupOutbounds) Start(onUpdate func()) ([]adapter.Outbound, error) {
+	g.access.Lock()
+	defer g.access.Unlock()
+	for i, tag := range g.providerTags {
+		provider, loaded := g.router.OutboundProvider(tag)
+		if !loaded {
+			return nil, E.New("provider ", i, " not found: ", tag)
+		}
+		g.providers = append(g.providers, provider)
+		g.callbacks = append(g.callbacks, provider.RegisterCallback(func(adapter.OutboundProvider) {
+			onUpdate()
+		}))
+	}
+	return g.outbounds()
+}
+
+func (g *groupOutbounds) Outbounds() ([]adapter.Outbound, error) {
+	g.access.Lock()
+	defer g.access.Unlock()
+	return g.outbounds()
+}
+
+func (g *groupOutbounds) outbounds() ([]adapter.Outbound, error) {
+	outbounds := make([]adapter.Outbound, 0, len(g.tags))
+	outboundTags := make(map[string]bool)
+	for i, tag := range g.tags {
+		detour, loaded := g.router.Outbound(tag)
+		if !loaded {
+			return nil, E.New("outbound ", i, " not found: ", tag)
+		}
+		outbounds = append(outbounds, detour)
+		outboundTags[tag] = true
+	}
+	for _, provider := range g.providers {
+		for _, detour := range provider.Outbounds() {
+			tag := detour.Tag()
+			if outboundTags[tag] {
+				continue
+			}
+			if g.include != nil && !g.include.MatchString(tag) {
+				continue
+			}
+			if g.exclude != nil && g.exclude.MatchString(tag) {
+				continue
+			}
+			outbounds = append(outbounds, detour)
+			outboundTags[tag] = true
+		}
+	}
+	return outbounds, nil
+}
+
+func (g *groupOutbounds) Close() error {
+	g.access.Lock()
+	defer g.access.Unlock()
+	for i, provider := range g.providers {
+		provider.UnregisterCallback(g.callbacks[i])
+	}
+	g.providers = nil
+	g.callbacks = nil
+	return nil
+}
+
+func outboundTags(outbounds []adapter.Outbound) []string {
+	return common.Map(outbounds, func(it adapter.Outbound) string {
+		return it.Tag()
+	})
+}
@@ -0,0 +1,122 @@
+package outbound
+
+import (
+	"context"
+	"net"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/ratelimit"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+var (
+	_ adapter.Outbound      = (*Limiter)(nil)
+	_ adapter.OutboundChain = (*Limiter)(nil)
+)
+
+// Limiter connects through another outbound, limiting the bandwidth shared by
+// all its connections and optionally the bandwidth of each connection.
+type Limiter struct {
+	myOutboundAdapter
+	outboundTag        string
+	outbound           adapter.Outbound
+	upload             *ratelimit.Bucket
+	download           *ratelimit.Bucket
+	connectionUpload   int64
+	connectionDownload int64
+	burst              int64
+}
+
+func NewLimiter(router adapter.Router, logger log.ContextLogger, tag string, options option.LimiterOutboundOptions) (*Limiter, error) {
+	if options.Outbound == "" {
+		return nil, E.New("missing outbound")
+	}
+	if options.UpMbps < 0 || options.DownMbps < 0 || options.ConnectionUpMbps < 0 || options.ConnectionDownMbps < 0 {
+		return nil, E.New("invalid bandwidth")
+	}
+	outbound := &Limiter{
+		myOutboundAdapter: myOutboundAdapter{
+			protocol:     C.TypeLimiter,
+			network:      []string{N.NetworkTCP, N.NetworkUDP},
+			router:       router,
+			logger:       logger,
+			tag:          tag,
+			dependencies: []string{options.Outbound},
+		},
+		outboundTag:        options.Outbound,
+		connectionUpload:   int64(options.ConnectionUpMbps) * C.MbpsToBps,
+		connectionDownload: int64(options.ConnectionDownMbps) * C.MbpsToBps,
+		burst:              int64(options.Burst),
+	}
+	if options.UpMbps > 0 {
+		outbound.upload = ratelimit.NewBucket(int64(options.UpMbps)*C.MbpsToBps, outbound.burst)
+	}
+	if options.DownMbps > 0 {
+		outbound.download = ratelimit.NewBucket(int64(options.DownMbps)*C.MbpsToBps, outbound.burst)
+	}
+	return outbound, nil
+}
+
+func (h *Limiter) Start() error {
+	detour, loaded := h.router.Outbound(h.outboundTag)
+	if !loaded {
+		return E.New("outbound not found: ", h.outboundTag)
+	}
+	h.outbound = detour
+	h.network = detour.Network()
+	return nil
+}
+
+func (h *Limiter) Hops() []string {
+	return []string{h.outboundTag}
+}
+
+func (h *Limiter) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	conn, err := h.outbound.DialContext(ctx, network, destination)
+	if err != nil {
+		return nil, err
+	}
+	uploadBuckets, downloadBuckets := h.buckets()
+	return ratelimit.NewConn(conn, downloadBuckets, uploadBuckets), nil
+}
+
+func (h *Limiter) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	conn, err := h.outbound.ListenPacket(ctx, destination)
+	if err != nil {
+		return nil, err
+	}
+	uploadBuckets, downloadBuckets := h.buckets()
+	return bufio.NewNetPacketConn(ratelimit.NewPacketConn(bufio.NewPacketConn(conn), downloadBuckets, uploadBuckets)), nil
+}
+
+func (h *Limiter) NewConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	uploadBuckets, downloadBuckets := h.buckets()
+	return h.outbound.NewConnection(ctx, ratelimit.NewConn(conn, uploadBuckets, downloadBuckets), metadata)
+}
+
+func (h *Limiter) NewPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
+	uploadBuckets, downloadBuckets := h.buckets()
+	return h.outbound.NewPacketConnection(ctx, ratelimit.NewPacketConn(conn, uploadBuckets, downloadBuckets), metadata)
+}
+
+func (h *Limiter) buckets() (uploadBuckets []*ratelimit.Bucket, downloadBuckets []*ratelimit.Bucket) {
+	if h.upload != nil {
+		uploadBuckets = append(uploadBuckets, h.upload)
+	}
+	if h.connectionUpload > 0 {
+		uploadBuckets = append(uploadBuckets, ratelimit.NewBucket(h.connectionUpload, h.burst))
+	}
+	if h.download != nil {
+		downloadBuckets = append(downloadBuckets, h.download)
+	}
+	if h.connectionDownload > 0 {
+		downloadBuckets = append(downloadBuckets, ratelimit.NewBucket(h.connectionDownload, h.burst))
+	}
+	return
+}
@@ -0,0 +1,383 @@
+package outbound
+
+import (
+	"context"
+	"hash/fnv"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/urltest"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/atomic"
+	"github.com/sagernet/sing/common/batch"
+	"github.com/sagernet/sing/common/cache"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/service"
+	"github.com/sagernet/sing/service/pause"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+var (
+	_ adapter.Outbound                = (*LoadBalance)(nil)
+	_ adapter.OutboundGroup           = (*LoadBalance)(nil)
+	_ adapter.URLTestGroup            = (*LoadBalance)(nil)
+	_ adapter.InterfaceUpdateListener = (*LoadBalance)(nil)
+)
+
+type LoadBalance struct {
+	myOutboundAdapter
+	ctx          context.Context
+	outbounds    *groupOutbounds
+	strategy     string
+	link         string
+	interval     time.Duration
+	history      *urltest.HistoryStorage
+	pauseManager pause.Manager
+	sessions     *cache.LruCache[string, string]
+	index        atomic.Uint32
+	last         atomic.TypedValue[string]
+	checking     atomic.Bool
+
+	outboundsAccess sync.RWMutex
+	outboundList    []adapter.Outbound
+
+	access      sync.Mutex
+	unavailable map[string]bool
+	ticker      *time.Ticker
+	close       chan struct{}
+}
+
+func NewLoadBalance(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.LoadBalanceOutboundOptions) (*LoadBalance, error) {
+	outbounds, err := newGroupOutbounds(router, options.Outbounds, options.Providers, options.Include, options.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	outbound := &LoadBalance{
+		myOutboundAdapter: myOutboundAdapter{
+			protocol:     C.TypeLoadBalance,
+			network:      []string{N.NetworkTCP, N.NetworkUDP},
+			router:       router,
+			logger:       logger,
+			tag:          tag,
+			dependencies: options.Outbounds,
+		},
+		ctx:          ctx,
+		outbounds:    outbounds,
+		strategy:     options.Strategy,
+		link:         options.URL,
+		interval:     time.Duration(options.Interval),
+		pauseManager: service.FromContext[pause.Manager](ctx),
+		unavailable:  make(map[string]bool),
+		close:        make(chan struct{}),
+	}
+	switch outbound.strategy {
+	case "":
+		outbound.strategy = C.LoadBalanceStrategyRoundRobin
+	case C.LoadBalanceStrategyRoundRobin, C.LoadBalanceStrategyConsistentHashing:
+	case C.LoadBalanceStrategyStickySessions:
+		sessionTTL := time.Duration(options.SessionTTL)
+		if sessionTTL == 0 {
+			sessionTTL = 10 * time.Minute
+		}
+		outbound.sessions = cache.New[string, string](
+			cache.WithAge[string, string](int64(sessionTTL.Seconds())),
+			cache.WithUpdateAgeOnGet[string, string](),
+		)
+	default:
+		return nil, E.New("unknown load balance strategy: ", options.Strategy)
+	}
+	if outbound.interval == 0 {
+		outbound.interval = C.DefaultURLTestInterval
+	}
+	if outbound.history = service.PtrFromContext[urltest.HistoryStorage](ctx); outbound.history != nil {
+	} else if clashServer := router.ClashServer(); clashServer != nil {
+		outbound.history = clashServer.HistoryStorage()
+	} else {
+		outbound.history = urltest.NewHistoryStorage()
+	}
+	return outbound, nil
+}
+
+func (s *LoadBalance) Start() error {
+	outbounds, err := s.outbounds.Start(s.updateOutbounds)
+	if err != nil {
+		return err
+	}
+	s.outboundsAccess.Lock()
+	s.outboundList = outbounds
+	s.outboundsAccess.Unlock()
+	return nil
+}
+
+func (s *LoadBalance) PostStart() error {
+	s.access.Lock()
+	s.ticker = time.NewTicker(s.interval)
+	s.access.Unlock()
+	go s.loopCheck()
+	return nil
+}
+
+func (s *LoadBalance) Close() error {
+	s.access.Lock()
+	if s.ticker != nil {
+		s.ticker.Stop()
+		close(s.close)
+		s.ticker = nil
+	}
+	s.access.Unlock()
+	return s.outbounds.Close()
+}
+
+func (s *LoadBalance) updateOutbounds() {
+	outbounds, err := s.outbounds.Outbounds()
+	if err != nil {
+		s.logger.Error("update outbounds: ", err)
+		return
+	}
+	s.outboundsAccess.Lock()
+	s.outboundList = outbounds
+	s.outboundsAccess.Unlock()
+	go s.CheckOutbounds()
+}
+
+func (s *LoadBalance) Outbounds() []adapter.Outbound {
+	s.outboundsAccess.RLock()
+	defer s.outboundsAccess.RUnlock()
+	return s.outboundList
+}
+
+func (s *LoadBalance) Now() string {
+	if last := s.last.Load(); last != "" {
+		return last
+	}
+	if outbounds := s.Outbounds(); len(outbounds) > 0 {
+		return outbounds[0].Tag()
+	}
+	return ""
+}
+
+func (s *LoadBalance) All() []string {
+	return outboundTags(s.Outbounds())
+}
+
+func (s *LoadBalance) URLTest(ctx context.Context) (map[string]uint16, error) {
+	return s.urlTest(ctx)
+}
+
+func (s *LoadBalance) CheckOutbounds() {
+	_, _ = s.urlTest(s.ctx)
+}
+
+func (s *LoadBalance) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	switch N.NetworkName(network) {
+	case N.NetworkTCP, N.NetworkUDP:
+	default:
+		return nil, E.Extend(N.ErrUnknownNetwork, network)
+	}
+	outbound := s.selectOutbound(network, adapter.ContextFrom(ctx), destination)
+	if outbound == nil {
+		return nil, E.New("missing supported outbound")
+	}
+	conn, err := outbound.DialContext(ctx, network, destination)
+	if err != nil {
+		s.logger.ErrorContext(ctx, err)
+		s.setUnavailable(outbound)
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (s *LoadBalance) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	outbound := s.selectOutbound(N.NetworkUDP, adapter.ContextFrom(ctx), destination)
+	if outbound == nil {
+		return nil, E.New("missing supported outbound")
+	}
+	conn, err := outbound.ListenPacket(ctx, destination)
+	if err != nil {
+		s.logger.ErrorContext(ctx, err)
+		s.setUnavailable(outbound)
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (s *LoadBalance) NewConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	return NewConnection(adapter.WithContext(ctx, &metadata), s, conn, metadata)
+}
+
+func (s *LoadBalance) NewPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
+	return NewPacketConnection(adapter.WithContext(ctx, &metadata), s, conn, metadata)
+}
+
+func (s *LoadBalance) InterfaceUpdated() {
+	go s.CheckOutbounds()
+	return
+}
+
+func (s *LoadBalance) selectOutbound(network string, metadata *adapter.InboundContext, destination M.Socksaddr) adapter.Outbound {
+	candidates := s.candidates(network)
+	if len(candidates) == 0 {
+		return nil
+	}
+	var outbound adapter.Outbound
+	switch s.strategy {
+	case C.LoadBalanceStrategyConsistentHashing:
+		outbound = rendezvousSelect(candidates, loadBalanceDomain(metadata, destination))
+	case C.LoadBalanceStrategyStickySessions:
+		var source string
+		if metadata != nil {
+			source = metadata.Source.Addr.String()
+		}
+		key := source + "|" + loadBalanceDomain(metadata, destination)
+		if tag, loaded := s.sessions.Load(key); loaded {
+			for _, detour := range candidates {
+				if detour.Tag() == tag {
+					outbound = detour
+					break
+				}
+			}
+		}
+		if outbound == nil {
+			outbound = candidates[int(s.index.Add(1)-1)%len(candidates)]
+			s.sessions.Store(key, outbound.Tag())
+		}
+	default:
+		outbound = candidates[int(s.index.Add(1)-1)%len(candidates)]
+	}
+	s.last.Store(outbound.Tag())
+	return outbound
+}
+
+func (s *LoadBalance) candidates(network string) []adapter.Outbound {
+	outbounds := common.Filter(s.Outbounds(), func(it adapter.Outbound) bool {
+		return common.Contains(it.Network(), network)
+	})
+	s.access.Lock()
+	available := common.Filter(outbounds, func(it adapter.Outbound) bool {
+		return !s.unavailable[it.Tag()]
+	})
+	s.access.Unlock()
+	if len(available) == 0 {
+		return outbounds
+	}
+	return available
+}
+
+func (s *LoadBalance) setUnavailable(outbound adapter.Outbound) {
+	s.history.DeleteURLTestHistory(RealTag(outbound))
+	s.access.Lock()
+	s.unavailable[outbound.Tag()] = true
+	s.access.Unlock()
+}
+
+func (s *LoadBalance) loopCheck() {
+	s.CheckOutbounds()
+	s.access.Lock()
+	ticker := s.ticker
+	s.access.Unlock()
+	if ticker == nil {
+		return
+	}
+	for {
+		select {
+		case <-s.close:
+			return
+		case <-ticker.C:
+		}
+		s.pauseManager.WaitActive()
+		s.CheckOutbounds()
+	}
+}
+
+func (s *LoadBalance) urlTest(ctx context.Context) (map[string]uint16, error) {
+	result := make(map[string]uint16)
+	if s.checking.Swap(true) {
+		return result, nil
+	}
+	defer s.checking.Store(false)
+	b, _ := batch.New(ctx, batch.WithConcurrencyNum[any](10))
+	checked := make(map[string]bool)
+	unavailable := make(map[string]bool)
+	var resultAccess sync.Mutex
+	for _, detour := range s.Outbounds() {
+		tag := detour.Tag()
+		if checked[tag] {
+			continue
+		}
+		checked[tag] = true
+		realTag := RealTag(detour)
+		p, loaded := s.router.Outbound(realTag)
+		if !loaded {
+			continue
+		}
+		b.Go(tag, func() (any, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), C.TCPTimeout)
+			defer cancel()
+			t, err := urltest.URLTest(ctx, s.link, p)
+			resultAccess.Lock()
+			defer resultAccess.Unlock()
+			if err != nil {
+				s.logger.Debug("outbound ", tag, " unavailable: ", err)
+				s.history.DeleteURLTestHistory(realTag)
+				unavailable[tag] = true
+			} else {
+				s.logger.Debug("outbound ", tag, " available: ", t, "ms")
+				s.history.StoreURLTestHistory(realTag, &urltest.History{
+					Time:  time.Now(),
+					Delay: t,
+				})
+				result[tag] = t
+			}
+			return nil, nil
+		})
+	}
+	b.Wait()
+	s.access.Lock()
+	s.unavailable = unavailable
+	s.access.Unlock()
+	return result, nil
+}
+
+func loadBalanceDomain(metadata *adapter.InboundContext, destination M.Socksaddr) string {
+	var domain string
+	if metadata != nil {
+		domain = metadata.Domain
+	}
+	if domain == "" {
+		domain = destination.Fqdn
+	}
+	if domain == "" {
+		return destination.Addr.String()
+	}
+	if etldPlusOne, err := publicsuffix.EffectiveTLDPlusOne(domain); err == nil {
+		return etldPlusOne
+	}
+	return domain
+}
+
+func rendezvousSelect(outbounds []adapter.Outbound, key string) adapter.Outbound {
+	var (
+		maxWeight uint64
+		selected  adapter.Outbound
+	)
+	for _, detour := range outbounds {
+		hash := fnv.New64a()
+		hash.Write([]byte(key))
+		hash.Write([]byte{0})
+		hash.Write([]byte(detour.Tag()))
+		weight := hash.Sum64()
+		if selected == nil || weight > maxWeight {
+			maxWeight = weight
+			selected = detour
+		}
+	}
+	return selected
+}
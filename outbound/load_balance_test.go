@@ -0,0 +1,130 @@
+package outbound
+
+import (
+	"context"
+	"net/netip"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/service"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLoadBalance(t *testing.T, strategy string, sessionTTL time.Duration) *LoadBalance {
+	tcpAndUDP := []string{N.NetworkTCP, N.NetworkUDP}
+	router := newTestGroupRouter(map[string][]string{
+		"a": {N.NetworkTCP},
+		"b": tcpAndUDP,
+		"c": tcpAndUDP,
+	})
+	loadBalance, err := NewLoadBalance(service.ContextWithDefaultRegistry(context.Background()), router, log.NewNOPFactory().Logger(), "load-balance", option.LoadBalanceOutboundOptions{
+		Outbounds:  []string{"a", "b", "c"},
+		Strategy:   strategy,
+		SessionTTL: option.Duration(sessionTTL),
+	})
+	require.NoError(t, err)
+	require.NoError(t, loadBalance.Start())
+	t.Cleanup(func() {
+		loadBalance.Close()
+	})
+	return loadBalance
+}
+
+func testLoadBalanceSelect(loadBalance *LoadBalance, network string, source string, domain string) string {
+	metadata := &adapter.InboundContext{Domain: domain}
+	if source != "" {
+		metadata.Source = M.SocksaddrFrom(netip.MustParseAddr(source), 10000)
+	}
+	return loadBalance.selectOutbound(network, metadata, M.ParseSocksaddrHostPort(domain, 443)).Tag()
+}
+
+func TestLoadBalanceRoundRobin(t *testing.T) {
+	t.Parallel()
+	for _, testCase := range []struct {
+		name        string
+		network     string
+		unavailable []string
+		selected    []string
+	}{
+		{name: "tcp", network: N.NetworkTCP, selected: []string{"a", "b", "c", "a"}},
+		{name: "udp", network: N.NetworkUDP, selected: []string{"b", "c", "b"}},
+		{name: "unavailable", network: N.NetworkTCP, unavailable: []string{"b"}, selected: []string{"a", "c", "a"}},
+		{name: "all unavailable", network: N.NetworkUDP, unavailable: []string{"b", "c"}, selected: []string{"b", "c"}},
+	} {
+		loadBalance := newTestLoadBalance(t, "", 0)
+		for _, tag := range testCase.unavailable {
+			loadBalance.unavailable[tag] = true
+		}
+		var selected []string
+		for range testCase.selected {
+			selected = append(selected, testLoadBalanceSelect(loadBalance, testCase.network, "", "example.com"))
+		}
+		require.Equal(t, testCase.selected, selected, testCase.name)
+	}
+}
+
+func TestLoadBalanceConsistentHashing(t *testing.T) {
+	t.Parallel()
+	loadBalance := newTestLoadBalance(t, C.LoadBalanceStrategyConsistentHashing, 0)
+	selected := make(map[string]string)
+	counts := make(map[string]int)
+	for i := 0; i < 100; i++ {
+		domain := "domain" + strconv.Itoa(i) + ".com"
+		selected[domain] = testLoadBalanceSelect(loadBalance, N.NetworkTCP, "", domain)
+		counts[selected[domain]]++
+		// subdomains of a domain use the same outbound
+		require.Equal(t, selected[domain], testLoadBalanceSelect(loadBalance, N.NetworkTCP, "1.1.1.1", "www."+domain))
+	}
+	require.Len(t, counts, 3)
+
+	// only domains of an unavailable outbound move
+	loadBalance.unavailable["c"] = true
+	for domain, tag := range selected {
+		current := testLoadBalanceSelect(loadBalance, N.NetworkTCP, "", domain)
+		if tag == "c" {
+			require.NotEqual(t, "c", current, domain)
+		} else {
+			require.Equal(t, tag, current, domain)
+		}
+	}
+}
+
+func TestLoadBalanceStickySessions(t *testing.T) {
+	t.Parallel()
+	loadBalance := newTestLoadBalance(t, C.LoadBalanceStrategyStickySessions, time.Second)
+	for _, testCase := range []struct {
+		name        string
+		source      string
+		domain      string
+		unavailable []string
+		selected    string
+	}{
+		{name: "new session", source: "1.1.1.1", domain: "example.com", selected: "a"},
+		{name: "same session", source: "1.1.1.1", domain: "example.com", selected: "a"},
+		{name: "subdomain", source: "1.1.1.1", domain: "www.example.com", selected: "a"},
+		{name: "other source", source: "2.2.2.2", domain: "example.com", selected: "b"},
+		{name: "other domain", source: "1.1.1.1", domain: "example.org", selected: "c"},
+		{name: "unavailable", source: "1.1.1.1", domain: "example.com", unavailable: []string{"a"}, selected: "c"},
+		{name: "moved session", source: "1.1.1.1", domain: "example.com", selected: "c"},
+	} {
+		loadBalance.unavailable = make(map[string]bool)
+		for _, tag := range testCase.unavailable {
+			loadBalance.unavailable[tag] = true
+		}
+		require.Equal(t, testCase.selected, testLoadBalanceSelect(loadBalance, N.NetworkTCP, testCase.source, testCase.domain), testCase.name)
+	}
+
+	// sessions expire after the TTL and get the next outbound
+	time.Sleep(2100 * time.Millisecond)
+	_, loaded := loadBalance.sessions.Load("1.1.1.1|example.com")
+	require.False(t, loaded)
+	require.Equal(t, "b", testLoadBalanceSelect(loadBalance, N.NetworkTCP, "1.1.1.1", "example.com"))
+}
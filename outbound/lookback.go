@@ -1,6 +1,18 @@
 package outbound
 
-import "context"
+import (
+	"context"
+
+	"github.com/sagernet/sing-box/log"
+)
+
+// LogContextFields returns the outbound tag in the context for JSON log entries.
+func LogContextFields(ctx context.Context) []log.Field {
+	if outboundTag, loaded := TagFromContext(ctx); loaded {
+		return []log.Field{{Key: "outbound", Value: outboundTag}}
+	}
+	return nil
+}
 
 type outboundTagKey struct{}
 
@@ -3,6 +3,7 @@ package outbound
 import (
 	"context"
 	"net"
+	"sync"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/common/interrupt"
@@ -23,8 +24,10 @@ var (
 type Selector struct {
 	myOutboundAdapter
 	ctx                          context.Context
-	tags                         []string
+	group                        *groupOutbounds
 	defaultTag                   string
+	access                       sync.Mutex
+	tags                         []string
 	outbounds                    map[string]adapter.Outbound
 	selected                     adapter.Outbound
 	interruptGroup               *interrupt.Group
@@ -32,6 +35,10 @@ type Selector struct {
 }
 
 func NewSelector(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.SelectorOutboundOptions) (*Selector, error) {
+	group, err := newGroupOutbounds(router, options.Outbounds, options.Providers, options.Include, options.Exclude)
+	if err != nil {
+		return nil, err
+	}
 	outbound := &Selector{
 		myOutboundAdapter: myOutboundAdapter{
 			protocol:     C.TypeSelector,
@@ -41,34 +48,49 @@ func NewSelector(ctx context.Context, router adapter.Router, logger log.ContextL
 			dependencies: options.Outbounds,
 		},
 		ctx:                          ctx,
-		tags:                         options.Outbounds,
+		group:                        group,
 		defaultTag:                   options.Default,
 		outbounds:                    make(map[string]adapter.Outbound),
 		interruptGroup:               interrupt.NewGroup(),
 		interruptExternalConnections: options.InterruptExistConnections,
 	}
-	if len(outbound.tags) == 0 {
-		return nil, E.New("missing tags")
-	}
 	return outbound, nil
 }
 
 func (s *Selector) Network() []string {
-	if s.selected == nil {
+	s.access.Lock()
+	selected := s.selected
+	s.access.Unlock()
+	if selected == nil {
 		return []string{N.NetworkTCP, N.NetworkUDP}
 	}
-	return s.selected.Network()
+	return selected.Network()
 }
 
 func (s *Selector) Start() error {
-	for i, tag := range s.tags {
-		detour, loaded := s.router.Outbound(tag)
-		if !loaded {
-			return E.New("outbound ", i, " not found: ", tag)
-		}
-		s.outbounds[tag] = detour
+	outbounds, err := s.group.Start(s.updateOutbounds)
+	if err != nil {
+		return err
+	}
+	s.access.Lock()
+	defer s.access.Unlock()
+	s.loadOutbounds(outbounds)
+	return s.selectDefault()
+}
+
+func (s *Selector) Close() error {
+	return s.group.Close()
+}
+
+func (s *Selector) loadOutbounds(outbounds []adapter.Outbound) {
+	s.tags = outboundTags(outbounds)
+	s.outbounds = make(map[string]adapter.Outbound)
+	for _, detour := range outbounds {
+		s.outbounds[detour.Tag()] = detour
 	}
+}
 
+func (s *Selector) selectDefault() error {
 	if s.tag != "" {
 		cacheFile := service.FromContext[adapter.CacheFile](s.ctx)
 		if cacheFile != nil {
@@ -85,34 +107,77 @@ func (s *Selector) Start() error {
 
 	if s.defaultTag != "" {
 		detour, loaded := s.outbounds[s.defaultTag]
-		if !loaded {
+		if loaded {
+			s.selected = detour
+			return nil
+		}
+		if !s.group.HasProviders() {
 			return E.New("default outbound not found: ", s.defaultTag)
 		}
-		s.selected = detour
-		return nil
 	}
 
-	s.selected = s.outbounds[s.tags[0]]
+	if len(s.tags) > 0 {
+		s.selected = s.outbounds[s.tags[0]]
+	} else {
+		s.selected = nil
+	}
 	return nil
 }
 
+func (s *Selector) updateOutbounds() {
+	outbounds, err := s.group.Outbounds()
+	if err != nil {
+		s.logger.Error("update outbounds: ", err)
+		return
+	}
+	s.access.Lock()
+	defer s.access.Unlock()
+	lastSelected := s.selected
+	s.loadOutbounds(outbounds)
+	if lastSelected != nil {
+		if detour, loaded := s.outbounds[lastSelected.Tag()]; loaded {
+			s.selected = detour
+		} else {
+			s.selected = nil
+		}
+	}
+	if s.selected == nil {
+		_ = s.selectDefault()
+	}
+	if s.selected != lastSelected {
+		s.interruptGroup.Interrupt(s.interruptExternalConnections)
+	}
+}
+
 func (s *Selector) Now() string {
-	return s.selected.Tag()
+	s.access.Lock()
+	selected := s.selected
+	s.access.Unlock()
+	if selected == nil {
+		return ""
+	}
+	return selected.Tag()
 }
 
 func (s *Selector) All() []string {
+	s.access.Lock()
+	defer s.access.Unlock()
 	return s.tags
 }
 
 func (s *Selector) SelectOutbound(tag string) bool {
+	s.access.Lock()
 	detour, loaded := s.outbounds[tag]
 	if !loaded {
+		s.access.Unlock()
 		return false
 	}
 	if s.selected == detour {
+		s.access.Unlock()
 		return true
 	}
 	s.selected = detour
+	s.access.Unlock()
 	if s.tag != "" {
 		cacheFile := service.FromContext[adapter.CacheFile](s.ctx)
 		if cacheFile != nil {
@@ -126,8 +191,22 @@ func (s *Selector) SelectOutbound(tag string) bool {
 	return true
 }
 
+func (s *Selector) selectedOutbound() (adapter.Outbound, error) {
+	s.access.Lock()
+	selected := s.selected
+	s.access.Unlock()
+	if selected == nil {
+		return nil, E.New("missing selected outbound")
+	}
+	return selected, nil
+}
+
 func (s *Selector) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
-	conn, err := s.selected.DialContext(ctx, network, destination)
+	selected, err := s.selectedOutbound()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := selected.DialContext(ctx, network, destination)
 	if err != nil {
 		return nil, err
 	}
@@ -135,7 +214,11 @@ func (s *Selector) DialContext(ctx context.Context, network string, destination
 }
 
 func (s *Selector) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
-	conn, err := s.selected.ListenPacket(ctx, destination)
+	selected, err := s.selectedOutbound()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := selected.ListenPacket(ctx, destination)
 	if err != nil {
 		return nil, err
 	}
@@ -143,13 +226,21 @@ func (s *Selector) ListenPacket(ctx context.Context, destination M.Socksaddr) (n
 }
 
 func (s *Selector) NewConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	selected, err := s.selectedOutbound()
+	if err != nil {
+		return err
+	}
 	ctx = interrupt.ContextWithIsExternalConnection(ctx)
-	return s.selected.NewConnection(ctx, conn, metadata)
+	return selected.NewConnection(ctx, conn, metadata)
 }
 
 func (s *Selector) NewPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
+	selected, err := s.selectedOutbound()
+	if err != nil {
+		return err
+	}
 	ctx = interrupt.ContextWithIsExternalConnection(ctx)
-	return s.selected.NewPacketConnection(ctx, conn, metadata)
+	return selected.NewPacketConnection(ctx, conn, metadata)
 }
 
 func RealTag(detour adapter.Outbound) string {
@@ -31,7 +31,7 @@ var (
 type URLTest struct {
 	myOutboundAdapter
 	ctx                          context.Context
-	tags                         []string
+	outbounds                    *groupOutbounds
 	link                         string
 	interval                     time.Duration
 	tolerance                    uint16
@@ -41,6 +41,10 @@ type URLTest struct {
 }
 
 func NewURLTest(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.URLTestOutboundOptions) (*URLTest, error) {
+	outbounds, err := newGroupOutbounds(router, options.Outbounds, options.Providers, options.Include, options.Exclude)
+	if err != nil {
+		return nil, err
+	}
 	outbound := &URLTest{
 		myOutboundAdapter: myOutboundAdapter{
 			protocol:     C.TypeURLTest,
@@ -51,27 +55,20 @@ func NewURLTest(ctx context.Context, router adapter.Router, logger log.ContextLo
 			dependencies: options.Outbounds,
 		},
 		ctx:                          ctx,
-		tags:                         options.Outbounds,
+		outbounds:                    outbounds,
 		link:                         options.URL,
 		interval:                     time.Duration(options.Interval),
 		tolerance:                    options.Tolerance,
 		idleTimeout:                  time.Duration(options.IdleTimeout),
 		interruptExternalConnections: options.InterruptExistConnections,
 	}
-	if len(outbound.tags) == 0 {
-		return nil, E.New("missing tags")
-	}
 	return outbound, nil
 }
 
 func (s *URLTest) Start() error {
-	outbounds := make([]adapter.Outbound, 0, len(s.tags))
-	for i, tag := range s.tags {
-		detour, loaded := s.router.Outbound(tag)
-		if !loaded {
-			return E.New("outbound ", i, " not found: ", tag)
-		}
-		outbounds = append(outbounds, detour)
+	outbounds, err := s.outbounds.Start(s.updateOutbounds)
+	if err != nil {
+		return err
 	}
 	group, err := NewURLTestGroup(
 		s.ctx,
@@ -98,21 +95,40 @@ func (s *URLTest) PostStart() error {
 
 func (s *URLTest) Close() error {
 	return common.Close(
+		s.outbounds,
 		common.PtrOrNil(s.group),
 	)
 }
 
+func (s *URLTest) updateOutbounds() {
+	if s.group == nil {
+		return
+	}
+	outbounds, err := s.outbounds.Outbounds()
+	if err != nil {
+		s.logger.Error("update outbounds: ", err)
+		return
+	}
+	s.group.SetOutbounds(outbounds)
+}
+
 func (s *URLTest) Now() string {
-	if s.group.selectedOutboundTCP != nil {
-		return s.group.selectedOutboundTCP.Tag()
-	} else if s.group.selectedOutboundUDP != nil {
-		return s.group.selectedOutboundUDP.Tag()
+	if s.group == nil {
+		return ""
+	}
+	if outbound := s.group.selectedOutbound(N.NetworkTCP); outbound != nil {
+		return outbound.Tag()
+	} else if outbound = s.group.selectedOutbound(N.NetworkUDP); outbound != nil {
+		return outbound.Tag()
 	}
 	return ""
 }
 
 func (s *URLTest) All() []string {
-	return s.tags
+	if s.group == nil {
+		return nil
+	}
+	return outboundTags(s.group.Outbounds())
 }
 
 func (s *URLTest) URLTest(ctx context.Context) (map[string]uint16, error) {
@@ -127,10 +143,8 @@ func (s *URLTest) DialContext(ctx context.Context, network string, destination M
 	s.group.Touch()
 	var outbound adapter.Outbound
 	switch N.NetworkName(network) {
-	case N.NetworkTCP:
-		outbound = s.group.selectedOutboundTCP
-	case N.NetworkUDP:
-		outbound = s.group.selectedOutboundUDP
+	case N.NetworkTCP, N.NetworkUDP:
+		outbound = s.group.selectedOutbound(N.NetworkName(network))
 	default:
 		return nil, E.Extend(N.ErrUnknownNetwork, network)
 	}
@@ -151,7 +165,7 @@ func (s *URLTest) DialContext(ctx context.Context, network string, destination M
 
 func (s *URLTest) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
 	s.group.Touch()
-	outbound := s.group.selectedOutboundUDP
+	outbound := s.group.selectedOutbound(N.NetworkUDP)
 	if outbound == nil {
 		outbound, _ = s.group.Select(N.NetworkUDP)
 	}
@@ -186,6 +200,7 @@ type URLTestGroup struct {
 	ctx                          context.Context
 	router                       adapter.Router
 	logger                       log.Logger
+	outboundsAccess              sync.RWMutex
 	outbounds                    []adapter.Outbound
 	link                         string
 	interval                     time.Duration
@@ -194,6 +209,7 @@ type URLTestGroup struct {
 	history                      *urltest.HistoryStorage
 	checking                     atomic.Bool
 	pauseManager                 pause.Manager
+	selectedAccess               sync.RWMutex
 	selectedOutboundTCP          adapter.Outbound
 	selectedOutboundUDP          adapter.Outbound
 	interruptGroup               *interrupt.Group
@@ -285,11 +301,44 @@ func (g *URLTestGroup) Close() error {
 	return nil
 }
 
+func (g *URLTestGroup) Outbounds() []adapter.Outbound {
+	g.outboundsAccess.RLock()
+	defer g.outboundsAccess.RUnlock()
+	return g.outbounds
+}
+
+func (g *URLTestGroup) SetOutbounds(outbounds []adapter.Outbound) {
+	g.outboundsAccess.Lock()
+	g.outbounds = outbounds
+	g.outboundsAccess.Unlock()
+	g.selectedAccess.Lock()
+	if g.selectedOutboundTCP != nil && !common.Contains(outbounds, g.selectedOutboundTCP) {
+		g.selectedOutboundTCP = nil
+	}
+	if g.selectedOutboundUDP != nil && !common.Contains(outbounds, g.selectedOutboundUDP) {
+		g.selectedOutboundUDP = nil
+	}
+	g.selectedAccess.Unlock()
+	if g.started {
+		go g.CheckOutbounds(false)
+	}
+}
+
+func (g *URLTestGroup) selectedOutbound(network string) adapter.Outbound {
+	g.selectedAccess.RLock()
+	defer g.selectedAccess.RUnlock()
+	if network == N.NetworkUDP {
+		return g.selectedOutboundUDP
+	}
+	return g.selectedOutboundTCP
+}
+
 func (g *URLTestGroup) Select(network string) (adapter.Outbound, bool) {
 	var minDelay uint16
 	var minTime time.Time
 	var minOutbound adapter.Outbound
-	for _, detour := range g.outbounds {
+	outbounds := g.Outbounds()
+	for _, detour := range outbounds {
 		if !common.Contains(detour.Network(), network) {
 			continue
 		}
@@ -304,7 +353,7 @@ func (g *URLTestGroup) Select(network string) (adapter.Outbound, bool) {
 		}
 	}
 	if minOutbound == nil {
-		for _, detour := range g.outbounds {
+		for _, detour := range outbounds {
 			if !common.Contains(detour.Network(), network) {
 				continue
 			}
@@ -355,7 +404,7 @@ func (g *URLTestGroup) urlTest(ctx context.Context, force bool) (map[string]uint
 	b, _ := batch.New(ctx, batch.WithConcurrencyNum[any](10))
 	checked := make(map[string]bool)
 	var resultAccess sync.Mutex
-	for _, detour := range g.outbounds {
+	for _, detour := range g.Outbounds() {
 		tag := detour.Tag()
 		realTag := RealTag(detour)
 		if checked[realTag] {
@@ -397,6 +446,7 @@ func (g *URLTestGroup) urlTest(ctx context.Context, force bool) (map[string]uint
 
 func (g *URLTestGroup) performUpdateCheck() {
 	var updated bool
+	g.selectedAccess.Lock()
 	if outbound, exists := g.Select(N.NetworkTCP); outbound != nil && (g.selectedOutboundTCP == nil || (exists && outbound != g.selectedOutboundTCP)) {
 		g.selectedOutboundTCP = outbound
 		updated = true
@@ -405,6 +455,7 @@ func (g *URLTestGroup) performUpdateCheck() {
 		g.selectedOutboundUDP = outbound
 		updated = true
 	}
+	g.selectedAccess.Unlock()
 	if updated {
 		g.interruptGroup.Interrupt(g.interruptExternalConnections)
 	}
@@ -126,6 +126,10 @@ func (h *VLESS) NewPacketConnection(ctx context.Context, conn N.PacketConn, meta
 	return NewPacketConnection(ctx, h, conn, metadata)
 }
 
+func (h *VLESS) multiplexEnabled() bool {
+	return h.multiplexDialer != nil
+}
+
 func (h *VLESS) InterfaceUpdated() {
 	if h.multiplexDialer != nil {
 		h.multiplexDialer.Reset()
@@ -102,6 +102,10 @@ func NewVMess(ctx context.Context, router adapter.Router, logger log.ContextLogg
 	return outbound, nil
 }
 
+func (h *VMess) multiplexEnabled() bool {
+	return h.multiplexDialer != nil
+}
+
 func (h *VMess) InterfaceUpdated() {
 	if h.multiplexDialer != nil {
 		h.multiplexDialer.Reset()
@@ -0,0 +1,74 @@
+package provider
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/service/filemanager"
+)
+
+var _ adapter.OutboundProvider = (*LocalProvider)(nil)
+
+type LocalProvider struct {
+	myProviderAdapter
+	path string
+}
+
+func NewLocal(ctx context.Context, router adapter.Router, logFactory log.Factory, logger log.ContextLogger, tag string, options option.LocalOutboundProviderOptions) (*LocalProvider, error) {
+	if options.Path == "" {
+		return nil, E.New("missing path")
+	}
+	return &LocalProvider{
+		myProviderAdapter: myProviderAdapter{
+			ctx:          ctx,
+			router:       router,
+			logFactory:   logFactory,
+			logger:       logger,
+			providerType: C.ProviderTypeLocal,
+			tag:          tag,
+		},
+		path: filemanager.BasePath(ctx, options.Path),
+	}, nil
+}
+
+func (p *LocalProvider) Start() error {
+	return p.reload()
+}
+
+func (p *LocalProvider) PostStart() error {
+	return p.postStart()
+}
+
+func (p *LocalProvider) Update(ctx context.Context) error {
+	err := p.reload()
+	if err != nil {
+		p.setLastError(err)
+	}
+	return err
+}
+
+func (p *LocalProvider) reload() error {
+	path, err := filepath.Abs(p.path)
+	if err != nil {
+		return err
+	}
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return p.loadContent(p, content, fileInfo.ModTime())
+}
+
+func (p *LocalProvider) Close() error {
+	return p.myProviderAdapter.Close()
+}
@@ -0,0 +1,110 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	"github.com/sagernet/sing/common/json"
+	"github.com/sagernet/sing/common/logger"
+)
+
+func ParseSubscription(logger logger.Logger, content []byte) ([]option.Outbound, error) {
+	content = bytes.TrimSpace(bytes.TrimPrefix(content, []byte("\xef\xbb\xbf")))
+	if len(content) == 0 {
+		return nil, E.New("empty subscription")
+	}
+	var (
+		outbounds []option.Outbound
+		err       error
+	)
+	switch {
+	case content[0] == '{':
+		outbounds, err = parseSingBoxSubscription(logger, content)
+	case isClashSubscription(content):
+		outbounds, err = parseClashSubscription(logger, content)
+	default:
+		if decoded, decodeErr := decodeBase64(string(content)); decodeErr == nil && bytes.Contains(decoded, []byte("://")) {
+			content = decoded
+		}
+		if !bytes.Contains(content, []byte("://")) {
+			return nil, E.New("unknown subscription format")
+		}
+		outbounds, err = parseLinkSubscription(logger, string(content))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(outbounds) == 0 {
+		return nil, E.New("no outbounds found in subscription")
+	}
+	return uniqueTags(outbounds), nil
+}
+
+func parseSingBoxSubscription(logger logger.Logger, content []byte) ([]option.Outbound, error) {
+	var subscription struct {
+		Outbounds []json.RawMessage `json:"outbounds"`
+	}
+	err := json.Unmarshal(content, &subscription)
+	if err != nil {
+		return nil, E.Cause(err, "parse sing-box subscription")
+	}
+	outbounds := make([]option.Outbound, 0, len(subscription.Outbounds))
+	for i, rawOutbound := range subscription.Outbounds {
+		var outbound option.Outbound
+		err = json.Unmarshal(rawOutbound, &outbound)
+		if err != nil {
+			logger.Warn("skip outbounds[", i, "]: ", err)
+			continue
+		}
+		if !isProxyType(outbound.Type) {
+			continue
+		}
+		if outbound.Tag == "" {
+			outbound.Tag = F.ToString(outbound.Type, "-", i)
+		}
+		outbounds = append(outbounds, outbound)
+	}
+	return outbounds, nil
+}
+
+func isProxyType(outboundType string) bool {
+	switch outboundType {
+	case C.TypeDirect, C.TypeBlock, C.TypeDNS, C.TypeSelector, C.TypeURLTest, C.TypeFallback, C.TypeLoadBalance, C.TypeChain, C.TypeLimiter:
+		return false
+	default:
+		return true
+	}
+}
+
+func uniqueTags(outbounds []option.Outbound) []option.Outbound {
+	tagCount := make(map[string]int)
+	for i := range outbounds {
+		tag := outbounds[i].Tag
+		count := tagCount[tag]
+		tagCount[tag] = count + 1
+		if count > 0 {
+			outbounds[i].Tag = F.ToString(tag, " (", count, ")")
+		}
+	}
+	return outbounds
+}
+
+func decodeBase64(content string) ([]byte, error) {
+	content = strings.Map(func(r rune) rune {
+		switch r {
+		case '\r', '\n', ' ', '\t':
+			return -1
+		}
+		return r
+	}, content)
+	content = strings.TrimRight(content, "=")
+	if strings.ContainsAny(content, "-_") {
+		return base64.RawURLEncoding.DecodeString(content)
+	}
+	return base64.RawStdEncoding.DecodeString(content)
+}
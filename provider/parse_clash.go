@@ -0,0 +1,395 @@
+package provider
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	N "github.com/sagernet/sing/common/network"
+
+	"gopkg.in/yaml.v3"
+)
+
+type clashProxy map[string]any
+
+func isClashSubscription(content []byte) bool {
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("proxies:")) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseClashSubscription(logger logger.Logger, content []byte) ([]option.Outbound, error) {
+	var config struct {
+		Proxies []clashProxy `yaml:"proxies"`
+	}
+	err := yaml.Unmarshal(content, &config)
+	if err != nil {
+		return nil, E.Cause(err, "parse clash subscription")
+	}
+	outbounds := make([]option.Outbound, 0, len(config.Proxies))
+	for i, proxy := range config.Proxies {
+		outbound, err := proxy.Build()
+		if err != nil {
+			logger.Warn("skip proxies[", i, "] ", proxy.String("name"), ": ", err)
+			continue
+		}
+		outbounds = append(outbounds, outbound)
+	}
+	return outbounds, nil
+}
+
+func (p clashProxy) Build() (option.Outbound, error) {
+	outbound := option.Outbound{
+		Tag: p.String("name"),
+	}
+	if outbound.Tag == "" {
+		return outbound, E.New("missing name")
+	}
+	serverOptions := option.ServerOptions{
+		Server:     p.String("server"),
+		ServerPort: uint16(p.Int("port")),
+	}
+	if serverOptions.Server == "" || serverOptions.ServerPort == 0 {
+		return outbound, E.New("missing server or port")
+	}
+	var network option.NetworkList
+	if p.Has("udp") && !p.Bool("udp") {
+		network = N.NetworkTCP
+	}
+	proxyType := p.String("type")
+	switch proxyType {
+	case "ss":
+		outbound.Type = C.TypeShadowsocks
+		outbound.ShadowsocksOptions = option.ShadowsocksOutboundOptions{
+			ServerOptions: serverOptions,
+			Method:        p.String("cipher"),
+			Password:      p.String("password"),
+			Network:       network,
+		}
+		if plugin := p.String("plugin"); plugin != "" {
+			pluginName, pluginOptions, err := p.buildShadowsocksPlugin(plugin)
+			if err != nil {
+				return outbound, err
+			}
+			outbound.ShadowsocksOptions.Plugin = pluginName
+			outbound.ShadowsocksOptions.PluginOptions = pluginOptions
+		}
+		if p.Bool("udp-over-tcp") {
+			outbound.ShadowsocksOptions.UDPOverTCP = &option.UDPOverTCPOptions{Enabled: true}
+		}
+	case "vmess":
+		outbound.Type = C.TypeVMess
+		security := p.String("cipher")
+		if security == "" {
+			security = "auto"
+		}
+		outbound.VMessOptions = option.VMessOutboundOptions{
+			ServerOptions:               serverOptions,
+			UUID:                        p.String("uuid"),
+			Security:                    security,
+			AlterId:                     p.Int("alterId"),
+			Network:                     network,
+			OutboundTLSOptionsContainer: p.buildTLS("servername"),
+		}
+		transport, err := p.buildTransport()
+		if err != nil {
+			return outbound, err
+		}
+		outbound.VMessOptions.Transport = transport
+	case "vless":
+		outbound.Type = C.TypeVLESS
+		outbound.VLESSOptions = option.VLESSOutboundOptions{
+			ServerOptions:               serverOptions,
+			UUID:                        p.String("uuid"),
+			Flow:                        p.String("flow"),
+			Network:                     network,
+			OutboundTLSOptionsContainer: p.buildTLS("servername"),
+		}
+		transport, err := p.buildTransport()
+		if err != nil {
+			return outbound, err
+		}
+		outbound.VLESSOptions.Transport = transport
+	case "trojan":
+		outbound.Type = C.TypeTrojan
+		outbound.TrojanOptions = option.TrojanOutboundOptions{
+			ServerOptions:               serverOptions,
+			Password:                    p.String("password"),
+			Network:                     network,
+			OutboundTLSOptionsContainer: p.buildTLS("sni"),
+		}
+		transport, err := p.buildTransport()
+		if err != nil {
+			return outbound, err
+		}
+		outbound.TrojanOptions.Transport = transport
+	case "socks5":
+		outbound.Type = C.TypeSOCKS
+		outbound.SocksOptions = option.SocksOutboundOptions{
+			ServerOptions: serverOptions,
+			Username:      p.String("username"),
+			Password:      p.String("password"),
+			Network:       network,
+		}
+	case "http":
+		outbound.Type = C.TypeHTTP
+		outbound.HTTPOptions = option.HTTPOutboundOptions{
+			ServerOptions:               serverOptions,
+			Username:                    p.String("username"),
+			Password:                    p.String("password"),
+			OutboundTLSOptionsContainer: p.buildTLS("sni"),
+		}
+	case "hysteria2":
+		outbound.Type = C.TypeHysteria2
+		outbound.Hysteria2Options = option.Hysteria2OutboundOptions{
+			ServerOptions:               serverOptions,
+			UpMbps:                      parseClashBandwidth(p.String("up")),
+			DownMbps:                    parseClashBandwidth(p.String("down")),
+			Password:                    p.String("password"),
+			Network:                     network,
+			OutboundTLSOptionsContainer: p.buildTLS("sni"),
+		}
+		if obfsType := p.String("obfs"); obfsType != "" {
+			outbound.Hysteria2Options.Obfs = &option.Hysteria2Obfs{
+				Type:     obfsType,
+				Password: p.String("obfs-password"),
+			}
+		}
+	case "tuic":
+		outbound.Type = C.TypeTUIC
+		outbound.TUICOptions = option.TUICOutboundOptions{
+			ServerOptions:               serverOptions,
+			UUID:                        p.String("uuid"),
+			Password:                    p.String("password"),
+			CongestionControl:           p.String("congestion-controller"),
+			UDPRelayMode:                p.String("udp-relay-mode"),
+			ZeroRTTHandshake:            p.Bool("reduce-rtt"),
+			Network:                     network,
+			OutboundTLSOptionsContainer: p.buildTLS("sni"),
+		}
+	case "":
+		return outbound, E.New("missing type")
+	default:
+		return outbound, E.New("unsupported type: ", proxyType)
+	}
+	return outbound, nil
+}
+
+func (p clashProxy) buildTLS(serverNameKey string) option.OutboundTLSOptionsContainer {
+	enabled := p.Bool("tls")
+	serverName := p.String(serverNameKey)
+	realityOptions := p.Map("reality-opts")
+	switch p.String("type") {
+	case "trojan", "hysteria2", "tuic":
+		enabled = true
+	}
+	if !enabled && realityOptions == nil {
+		return option.OutboundTLSOptionsContainer{}
+	}
+	tlsOptions := &option.OutboundTLSOptions{
+		Enabled:    true,
+		ServerName: serverName,
+		Insecure:   p.Bool("skip-cert-verify"),
+		ALPN:       p.StringList("alpn"),
+	}
+	if fingerprint := p.String("client-fingerprint"); fingerprint != "" {
+		tlsOptions.UTLS = &option.OutboundUTLSOptions{
+			Enabled:     true,
+			Fingerprint: fingerprint,
+		}
+	}
+	if realityOptions != nil {
+		tlsOptions.Reality = &option.OutboundRealityOptions{
+			Enabled:   true,
+			PublicKey: realityOptions.String("public-key"),
+			ShortID:   realityOptions.String("short-id"),
+		}
+	}
+	return option.OutboundTLSOptionsContainer{TLS: tlsOptions}
+}
+
+func (p clashProxy) buildTransport() (*option.V2RayTransportOptions, error) {
+	switch network := p.String("network"); network {
+	case "", "tcp":
+		return nil, nil
+	case "ws":
+		wsOptions := p.Map("ws-opts")
+		transport := &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeWebsocket,
+			WebsocketOptions: option.V2RayWebsocketOptions{
+				Path:                wsOptions.String("path"),
+				Headers:             wsOptions.Headers("headers"),
+				MaxEarlyData:        uint32(wsOptions.Int("max-early-data")),
+				EarlyDataHeaderName: wsOptions.String("early-data-header-name"),
+			},
+		}
+		if wsOptions.Bool("v2ray-http-upgrade") {
+			transport.Type = C.V2RayTransportTypeHTTPUpgrade
+			transport.HTTPUpgradeOptions = option.V2RayHTTPUpgradeOptions{
+				Path:    transport.WebsocketOptions.Path,
+				Headers: transport.WebsocketOptions.Headers,
+			}
+			transport.WebsocketOptions = option.V2RayWebsocketOptions{}
+		}
+		return transport, nil
+	case "grpc":
+		return &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeGRPC,
+			GRPCOptions: option.V2RayGRPCOptions{
+				ServiceName: p.Map("grpc-opts").String("grpc-service-name"),
+			},
+		}, nil
+	case "h2":
+		h2Options := p.Map("h2-opts")
+		return &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeHTTP,
+			HTTPOptions: option.V2RayHTTPOptions{
+				Host: h2Options.StringList("host"),
+				Path: h2Options.String("path"),
+			},
+		}, nil
+	case "http":
+		httpOptions := p.Map("http-opts")
+		var path string
+		if paths := httpOptions.StringList("path"); len(paths) > 0 {
+			path = paths[0]
+		}
+		return &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeHTTP,
+			HTTPOptions: option.V2RayHTTPOptions{
+				Method:  httpOptions.String("method"),
+				Path:    path,
+				Headers: httpOptions.Headers("headers"),
+			},
+		}, nil
+	default:
+		return nil, E.New("unsupported network: ", network)
+	}
+}
+
+func (p clashProxy) buildShadowsocksPlugin(plugin string) (string, string, error) {
+	pluginOptions := p.Map("plugin-opts")
+	var options []string
+	switch plugin {
+	case "obfs":
+		options = append(options, "obfs="+pluginOptions.String("mode"))
+		if host := pluginOptions.String("host"); host != "" {
+			options = append(options, "obfs-host="+host)
+		}
+		return "obfs-local", strings.Join(options, ";"), nil
+	case "v2ray-plugin":
+		if mode := pluginOptions.String("mode"); mode != "" && mode != "websocket" {
+			return "", "", E.New("unsupported v2ray-plugin mode: ", mode)
+		}
+		if pluginOptions.Bool("tls") {
+			options = append(options, "tls")
+		}
+		if host := pluginOptions.String("host"); host != "" {
+			options = append(options, "host="+host)
+		}
+		if path := pluginOptions.String("path"); path != "" {
+			options = append(options, "path="+path)
+		}
+		if pluginOptions.Bool("mux") {
+			options = append(options, "mux=1")
+		}
+		return "v2ray-plugin", strings.Join(options, ";"), nil
+	default:
+		return "", "", E.New("unsupported plugin: ", plugin)
+	}
+}
+
+func (p clashProxy) Has(key string) bool {
+	_, loaded := p[key]
+	return loaded
+}
+
+func (p clashProxy) String(key string) string {
+	switch value := p[key].(type) {
+	case string:
+		return value
+	case int:
+		return strconv.Itoa(value)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	default:
+		return ""
+	}
+}
+
+func (p clashProxy) Int(key string) int {
+	switch value := p[key].(type) {
+	case int:
+		return value
+	case float64:
+		return int(value)
+	case string:
+		intValue, _ := strconv.Atoi(value)
+		return intValue
+	default:
+		return 0
+	}
+}
+
+func (p clashProxy) Bool(key string) bool {
+	switch value := p[key].(type) {
+	case bool:
+		return value
+	case string:
+		boolValue, _ := strconv.ParseBool(value)
+		return boolValue
+	default:
+		return false
+	}
+}
+
+func (p clashProxy) StringList(key string) []string {
+	switch value := p[key].(type) {
+	case string:
+		return []string{value}
+	case []any:
+		var list []string
+		for _, item := range value {
+			if itemString, isString := item.(string); isString {
+				list = append(list, itemString)
+			}
+		}
+		return list
+	default:
+		return nil
+	}
+}
+
+func (p clashProxy) Map(key string) clashProxy {
+	value, _ := p[key].(map[string]any)
+	return value
+}
+
+func (p clashProxy) Headers(key string) option.HTTPHeader {
+	headers := p.Map(key)
+	if len(headers) == 0 {
+		return nil
+	}
+	httpHeader := make(option.HTTPHeader)
+	for name := range headers {
+		httpHeader[name] = headers.StringList(name)
+	}
+	return httpHeader
+}
+
+func parseClashBandwidth(bandwidth string) int {
+	bandwidth = strings.TrimSpace(strings.ToLower(bandwidth))
+	bandwidth = strings.TrimSuffix(bandwidth, "mbps")
+	bandwidth = strings.TrimSpace(bandwidth)
+	mbps, _ := strconv.Atoi(bandwidth)
+	return mbps
+}
@@ -0,0 +1,421 @@
+package provider
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+	"github.com/sagernet/sing/common/logger"
+)
+
+func parseLinkSubscription(logger logger.Logger, content string) ([]option.Outbound, error) {
+	var outbounds []option.Outbound
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		outbound, err := parseLink(line)
+		if err != nil {
+			logger.Warn("skip line ", i+1, ": ", err)
+			continue
+		}
+		outbounds = append(outbounds, outbound)
+	}
+	return outbounds, nil
+}
+
+func parseLink(link string) (option.Outbound, error) {
+	scheme, _, found := strings.Cut(link, "://")
+	if !found {
+		return option.Outbound{}, E.New("invalid link")
+	}
+	switch strings.ToLower(scheme) {
+	case "ss":
+		return parseShadowsocksLink(link)
+	case "vmess":
+		return parseVMessLink(link)
+	case "vless":
+		return parseVLESSLink(link)
+	case "trojan":
+		return parseTrojanLink(link)
+	case "hysteria2", "hy2":
+		return parseHysteria2Link(link)
+	case "tuic":
+		return parseTUICLink(link)
+	default:
+		return option.Outbound{}, E.New("unsupported scheme: ", scheme)
+	}
+}
+
+func parseLinkURL(link string) (*url.URL, option.ServerOptions, error) {
+	linkURL, err := url.Parse(link)
+	if err != nil {
+		return nil, option.ServerOptions{}, err
+	}
+	serverOptions, err := linkServerOptions(linkURL)
+	if err != nil {
+		return nil, option.ServerOptions{}, err
+	}
+	return linkURL, serverOptions, nil
+}
+
+func linkServerOptions(linkURL *url.URL) (option.ServerOptions, error) {
+	port, err := strconv.ParseUint(linkURL.Port(), 10, 16)
+	if err != nil {
+		return option.ServerOptions{}, E.Cause(err, "parse port")
+	}
+	serverOptions := option.ServerOptions{
+		Server:     linkURL.Hostname(),
+		ServerPort: uint16(port),
+	}
+	if serverOptions.Server == "" {
+		return option.ServerOptions{}, E.New("missing server")
+	}
+	return serverOptions, nil
+}
+
+func linkTag(linkURL *url.URL, serverOptions option.ServerOptions) string {
+	if linkURL.Fragment != "" {
+		return linkURL.Fragment
+	}
+	return serverOptions.Build().String()
+}
+
+func parseShadowsocksLink(link string) (option.Outbound, error) {
+	linkURL, err := url.Parse(link)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	if linkURL.User == nil {
+		// legacy format: ss://base64(method:password@server:port)#name
+		encoded, fragment, _ := strings.Cut(strings.TrimPrefix(link, "ss://"), "#")
+		decoded, err := decodeBase64(encoded)
+		if err != nil {
+			return option.Outbound{}, E.Cause(err, "decode legacy link")
+		}
+		legacyURL := "ss://" + string(decoded)
+		if fragment != "" {
+			legacyURL += "#" + fragment
+		}
+		linkURL, err = url.Parse(legacyURL)
+		if err != nil {
+			return option.Outbound{}, err
+		}
+	}
+	serverOptions, err := linkServerOptions(linkURL)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	method := linkURL.User.Username()
+	password, hasPassword := linkURL.User.Password()
+	if !hasPassword {
+		decoded, err := decodeBase64(method)
+		if err != nil {
+			return option.Outbound{}, E.Cause(err, "decode user info")
+		}
+		method, password, _ = strings.Cut(string(decoded), ":")
+	}
+	outbound := option.Outbound{
+		Type: C.TypeShadowsocks,
+		Tag:  linkTag(linkURL, serverOptions),
+		ShadowsocksOptions: option.ShadowsocksOutboundOptions{
+			ServerOptions: serverOptions,
+			Method:        method,
+			Password:      password,
+		},
+	}
+	if plugin := linkURL.Query().Get("plugin"); plugin != "" {
+		pluginName, pluginOptions, _ := strings.Cut(plugin, ";")
+		if pluginName == "simple-obfs" {
+			pluginName = "obfs-local"
+		}
+		outbound.ShadowsocksOptions.Plugin = pluginName
+		outbound.ShadowsocksOptions.PluginOptions = pluginOptions
+	}
+	return outbound, nil
+}
+
+type vmessLink struct {
+	Name        string `json:"ps"`
+	Address     string `json:"add"`
+	Port        any    `json:"port"`
+	ID          string `json:"id"`
+	AlterID     any    `json:"aid"`
+	Security    string `json:"scy"`
+	Network     string `json:"net"`
+	Type        string `json:"type"`
+	Host        string `json:"host"`
+	Path        string `json:"path"`
+	TLS         string `json:"tls"`
+	SNI         string `json:"sni"`
+	ALPN        string `json:"alpn"`
+	Fingerprint string `json:"fp"`
+}
+
+func parseVMessLink(link string) (option.Outbound, error) {
+	decoded, err := decodeBase64(strings.TrimPrefix(link, "vmess://"))
+	if err != nil {
+		return option.Outbound{}, E.Cause(err, "decode vmess link")
+	}
+	var vmess vmessLink
+	err = json.Unmarshal(decoded, &vmess)
+	if err != nil {
+		return option.Outbound{}, E.Cause(err, "parse vmess link")
+	}
+	port, err := strconv.ParseUint(anyToString(vmess.Port), 10, 16)
+	if err != nil {
+		return option.Outbound{}, E.Cause(err, "parse port")
+	}
+	alterID, _ := strconv.Atoi(anyToString(vmess.AlterID))
+	serverOptions := option.ServerOptions{
+		Server:     vmess.Address,
+		ServerPort: uint16(port),
+	}
+	security := vmess.Security
+	if security == "" {
+		security = "auto"
+	}
+	outbound := option.Outbound{
+		Type: C.TypeVMess,
+		Tag:  vmess.Name,
+		VMessOptions: option.VMessOutboundOptions{
+			ServerOptions: serverOptions,
+			UUID:          vmess.ID,
+			Security:      security,
+			AlterId:       alterID,
+		},
+	}
+	if outbound.Tag == "" {
+		outbound.Tag = serverOptions.Build().String()
+	}
+	if vmess.TLS == "tls" {
+		outbound.VMessOptions.TLS = buildLinkTLS(vmess.SNI, vmess.ALPN, vmess.Fingerprint, false)
+	}
+	outbound.VMessOptions.Transport, err = buildLinkTransport(vmess.Network, vmess.Type, vmess.Host, vmess.Path, vmess.Path)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	return outbound, nil
+}
+
+func parseVLESSLink(link string) (option.Outbound, error) {
+	linkURL, serverOptions, err := parseLinkURL(link)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	query := linkURL.Query()
+	outbound := option.Outbound{
+		Type: C.TypeVLESS,
+		Tag:  linkTag(linkURL, serverOptions),
+		VLESSOptions: option.VLESSOutboundOptions{
+			ServerOptions: serverOptions,
+			UUID:          linkURL.User.Username(),
+			Flow:          query.Get("flow"),
+		},
+	}
+	outbound.VLESSOptions.TLS = buildLinkQueryTLS(query)
+	outbound.VLESSOptions.Transport, err = buildLinkTransport(query.Get("type"), query.Get("headerType"), query.Get("host"), query.Get("path"), query.Get("serviceName"))
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	return outbound, nil
+}
+
+func parseTrojanLink(link string) (option.Outbound, error) {
+	linkURL, serverOptions, err := parseLinkURL(link)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	query := linkURL.Query()
+	if query.Get("security") == "" {
+		query.Set("security", "tls")
+	}
+	outbound := option.Outbound{
+		Type: C.TypeTrojan,
+		Tag:  linkTag(linkURL, serverOptions),
+		TrojanOptions: option.TrojanOutboundOptions{
+			ServerOptions: serverOptions,
+			Password:      linkURL.User.Username(),
+		},
+	}
+	outbound.TrojanOptions.TLS = buildLinkQueryTLS(query)
+	outbound.TrojanOptions.Transport, err = buildLinkTransport(query.Get("type"), query.Get("headerType"), query.Get("host"), query.Get("path"), query.Get("serviceName"))
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	return outbound, nil
+}
+
+func parseHysteria2Link(link string) (option.Outbound, error) {
+	linkURL, serverOptions, err := parseLinkURL(link)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	query := linkURL.Query()
+	password := linkURL.User.Username()
+	if userPassword, hasPassword := linkURL.User.Password(); hasPassword {
+		password = password + ":" + userPassword
+	}
+	outbound := option.Outbound{
+		Type: C.TypeHysteria2,
+		Tag:  linkTag(linkURL, serverOptions),
+		Hysteria2Options: option.Hysteria2OutboundOptions{
+			ServerOptions: serverOptions,
+			Password:      password,
+			OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+				TLS: buildLinkTLS(query.Get("sni"), "", "", query.Get("insecure") == "1"),
+			},
+		},
+	}
+	if obfsType := query.Get("obfs"); obfsType != "" {
+		outbound.Hysteria2Options.Obfs = &option.Hysteria2Obfs{
+			Type:     obfsType,
+			Password: query.Get("obfs-password"),
+		}
+	}
+	return outbound, nil
+}
+
+func parseTUICLink(link string) (option.Outbound, error) {
+	linkURL, serverOptions, err := parseLinkURL(link)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	query := linkURL.Query()
+	password, _ := linkURL.User.Password()
+	outbound := option.Outbound{
+		Type: C.TypeTUIC,
+		Tag:  linkTag(linkURL, serverOptions),
+		TUICOptions: option.TUICOutboundOptions{
+			ServerOptions:     serverOptions,
+			UUID:              linkURL.User.Username(),
+			Password:          password,
+			CongestionControl: query.Get("congestion_control"),
+			UDPRelayMode:      query.Get("udp_relay_mode"),
+			OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{
+				TLS: buildLinkTLS(query.Get("sni"), query.Get("alpn"), "", query.Get("allow_insecure") == "1"),
+			},
+		},
+	}
+	return outbound, nil
+}
+
+func buildLinkQueryTLS(query url.Values) *option.OutboundTLSOptions {
+	switch query.Get("security") {
+	case "tls":
+		return buildLinkTLS(query.Get("sni"), query.Get("alpn"), query.Get("fp"), query.Get("allowInsecure") == "1")
+	case "reality":
+		tlsOptions := buildLinkTLS(query.Get("sni"), query.Get("alpn"), query.Get("fp"), false)
+		tlsOptions.Reality = &option.OutboundRealityOptions{
+			Enabled:   true,
+			PublicKey: query.Get("pbk"),
+			ShortID:   query.Get("sid"),
+		}
+		if tlsOptions.UTLS == nil {
+			tlsOptions.UTLS = &option.OutboundUTLSOptions{
+				Enabled:     true,
+				Fingerprint: "chrome",
+			}
+		}
+		return tlsOptions
+	default:
+		return nil
+	}
+}
+
+func buildLinkTLS(serverName string, alpn string, fingerprint string, insecure bool) *option.OutboundTLSOptions {
+	tlsOptions := &option.OutboundTLSOptions{
+		Enabled:    true,
+		ServerName: serverName,
+		Insecure:   insecure,
+	}
+	if alpn != "" {
+		tlsOptions.ALPN = strings.Split(alpn, ",")
+	}
+	if fingerprint != "" {
+		tlsOptions.UTLS = &option.OutboundUTLSOptions{
+			Enabled:     true,
+			Fingerprint: fingerprint,
+		}
+	}
+	return tlsOptions
+}
+
+func buildLinkTransport(network string, headerType string, host string, path string, serviceName string) (*option.V2RayTransportOptions, error) {
+	switch network {
+	case "", "tcp":
+		if headerType == "http" {
+			return &option.V2RayTransportOptions{
+				Type: C.V2RayTransportTypeHTTP,
+				HTTPOptions: option.V2RayHTTPOptions{
+					Host: splitNotEmpty(host),
+					Path: path,
+				},
+			}, nil
+		}
+		return nil, nil
+	case "ws":
+		transport := &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeWebsocket,
+			WebsocketOptions: option.V2RayWebsocketOptions{
+				Path: path,
+			},
+		}
+		if host != "" {
+			transport.WebsocketOptions.Headers = option.HTTPHeader{"Host": {host}}
+		}
+		return transport, nil
+	case "httpupgrade":
+		return &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeHTTPUpgrade,
+			HTTPUpgradeOptions: option.V2RayHTTPUpgradeOptions{
+				Host: host,
+				Path: path,
+			},
+		}, nil
+	case "grpc":
+		return &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeGRPC,
+			GRPCOptions: option.V2RayGRPCOptions{
+				ServiceName: serviceName,
+			},
+		}, nil
+	case "h2", "http":
+		return &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeHTTP,
+			HTTPOptions: option.V2RayHTTPOptions{
+				Host: splitNotEmpty(host),
+				Path: path,
+			},
+		}, nil
+	case "quic":
+		return &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeQUIC,
+		}, nil
+	default:
+		return nil, E.New("unsupported network: ", network)
+	}
+}
+
+func splitNotEmpty(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(content, ",")
+}
+
+func anyToString(value any) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
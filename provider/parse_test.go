@@ -0,0 +1,59 @@
+package provider_test
+
+import (
+	"encoding/base64"
+	"testing"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/provider"
+	"github.com/sagernet/sing/common/logger"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSingBoxSubscription(t *testing.T) {
+	t.Parallel()
+	content := `{"outbounds":[{"type":"direct","tag":"direct"},{"type":"shadowsocks","tag":"ss","server":"127.0.0.1","server_port":8388,"method":"aes-128-gcm","password":"password"}]}`
+	outbounds, err := provider.ParseSubscription(logger.NOP(), []byte(content))
+	require.NoError(t, err)
+	require.Len(t, outbounds, 1)
+	require.Equal(t, "ss", outbounds[0].Tag)
+	require.Equal(t, C.TypeShadowsocks, outbounds[0].Type)
+}
+
+func TestParseClashSubscription(t *testing.T) {
+	t.Parallel()
+	content := `
+proxies:
+  - name: trojan
+    type: trojan
+    server: example.org
+    port: 443
+    password: password
+  - name: trojan
+    type: trojan
+    server: example.com
+    port: 443
+    password: password
+`
+	outbounds, err := provider.ParseSubscription(logger.NOP(), []byte(content))
+	require.NoError(t, err)
+	require.Len(t, outbounds, 2)
+	require.Equal(t, "trojan", outbounds[0].Tag)
+	require.Equal(t, "trojan (1)", outbounds[1].Tag)
+	require.Equal(t, "example.com", outbounds[1].TrojanOptions.Server)
+}
+
+func TestParseLinkSubscription(t *testing.T) {
+	t.Parallel()
+	content := "ss://" + base64.RawURLEncoding.EncodeToString([]byte("aes-128-gcm:password")) + "@127.0.0.1:8388#example\n" +
+		"trojan://password@example.org:443?sni=example.org#trojan\n"
+	outbounds, err := provider.ParseSubscription(logger.NOP(), []byte(base64.StdEncoding.EncodeToString([]byte(content))))
+	require.NoError(t, err)
+	require.Len(t, outbounds, 2)
+	require.Equal(t, "example", outbounds[0].Tag)
+	require.Equal(t, "aes-128-gcm", outbounds[0].ShadowsocksOptions.Method)
+	require.Equal(t, uint16(8388), outbounds[0].ShadowsocksOptions.ServerPort)
+	require.Equal(t, "trojan", outbounds[1].Tag)
+	require.Equal(t, "example.org", outbounds[1].TrojanOptions.TLS.ServerName)
+}
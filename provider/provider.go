@@ -0,0 +1,279 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/urltest"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/outbound"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/batch"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	"github.com/sagernet/sing/common/json"
+	"github.com/sagernet/sing/common/x/list"
+	"github.com/sagernet/sing/service"
+)
+
+func New(ctx context.Context, router adapter.Router, logFactory log.Factory, options option.OutboundProvider) (adapter.OutboundProvider, error) {
+	logger := logFactory.NewLogger(F.ToString("provider/", options.Type, "[", options.Tag, "]"))
+	switch options.Type {
+	case C.ProviderTypeLocal:
+		return NewLocal(ctx, router, logFactory, logger, options.Tag, options.LocalOptions)
+	case C.ProviderTypeRemote:
+		return NewRemote(ctx, router, logFactory, logger, options.Tag, options.RemoteOptions)
+	default:
+		return nil, E.New("unknown provider type: ", options.Type)
+	}
+}
+
+type myProviderAdapter struct {
+	ctx          context.Context
+	router       adapter.Router
+	logFactory   log.Factory
+	logger       log.ContextLogger
+	providerType string
+	tag          string
+
+	loadAccess      sync.Mutex
+	started         bool
+	access          sync.RWMutex
+	outbounds       []adapter.Outbound
+	outboundByTag   map[string]adapter.Outbound
+	outboundOptions map[string][]byte
+	updatedAt       time.Time
+	lastError       error
+
+	callbackAccess sync.Mutex
+	callbacks      list.List[adapter.OutboundProviderUpdateCallback]
+}
+
+func (a *myProviderAdapter) Type() string {
+	return a.providerType
+}
+
+func (a *myProviderAdapter) Tag() string {
+	return a.tag
+}
+
+func (a *myProviderAdapter) Outbounds() []adapter.Outbound {
+	a.access.RLock()
+	defer a.access.RUnlock()
+	return a.outbounds
+}
+
+func (a *myProviderAdapter) Outbound(tag string) (adapter.Outbound, bool) {
+	a.access.RLock()
+	defer a.access.RUnlock()
+	detour, loaded := a.outboundByTag[tag]
+	return detour, loaded
+}
+
+func (a *myProviderAdapter) UpdatedAt() time.Time {
+	a.access.RLock()
+	defer a.access.RUnlock()
+	return a.updatedAt
+}
+
+func (a *myProviderAdapter) LastError() error {
+	a.access.RLock()
+	defer a.access.RUnlock()
+	return a.lastError
+}
+
+func (a *myProviderAdapter) setLastError(err error) {
+	a.access.Lock()
+	a.lastError = err
+	a.access.Unlock()
+}
+
+func (a *myProviderAdapter) RegisterCallback(callback adapter.OutboundProviderUpdateCallback) *list.Element[adapter.OutboundProviderUpdateCallback] {
+	a.callbackAccess.Lock()
+	defer a.callbackAccess.Unlock()
+	return a.callbacks.PushBack(callback)
+}
+
+func (a *myProviderAdapter) UnregisterCallback(element *list.Element[adapter.OutboundProviderUpdateCallback]) {
+	a.callbackAccess.Lock()
+	defer a.callbackAccess.Unlock()
+	a.callbacks.Remove(element)
+}
+
+func (a *myProviderAdapter) postStart() error {
+	a.loadAccess.Lock()
+	defer a.loadAccess.Unlock()
+	a.started = true
+	for _, detour := range a.Outbounds() {
+		if lateOutbound, isLateOutbound := detour.(adapter.PostStarter); isLateOutbound {
+			err := lateOutbound.PostStart()
+			if err != nil {
+				return E.Cause(err, "post-start outbound/", detour.Type(), "[", detour.Tag(), "]")
+			}
+		}
+	}
+	return nil
+}
+
+func (a *myProviderAdapter) loadContent(provider adapter.OutboundProvider, content []byte, updatedAt time.Time) error {
+	outboundOptionsList, err := ParseSubscription(a.logger, content)
+	if err != nil {
+		return err
+	}
+	a.loadAccess.Lock()
+	defer a.loadAccess.Unlock()
+	a.access.RLock()
+	lastOutboundByTag := a.outboundByTag
+	lastOutboundOptions := a.outboundOptions
+	a.access.RUnlock()
+	var (
+		outbounds       []adapter.Outbound
+		outboundByTag   = make(map[string]adapter.Outbound)
+		outboundOptions = make(map[string][]byte)
+		newOutbounds    []adapter.Outbound
+	)
+	for _, options := range outboundOptionsList {
+		if detour, loaded := a.router.Outbound(options.Tag); loaded && lastOutboundByTag[options.Tag] != detour {
+			a.logger.Warn("skip outbound ", options.Tag, ": tag already in use")
+			continue
+		}
+		optionsContent, err := json.Marshal(&options)
+		if err != nil {
+			return E.Cause(err, "marshal outbound ", options.Tag)
+		}
+		if lastOptions, loaded := lastOutboundOptions[options.Tag]; loaded && bytes.Equal(lastOptions, optionsContent) {
+			detour := lastOutboundByTag[options.Tag]
+			outbounds = append(outbounds, detour)
+			outboundByTag[options.Tag] = detour
+			outboundOptions[options.Tag] = optionsContent
+			continue
+		}
+		detour, err := outbound.New(
+			a.ctx,
+			a.router,
+			a.logFactory.NewLogger(F.ToString("outbound/", options.Type, "[", options.Tag, "]")),
+			options.Tag,
+			options,
+		)
+		if err != nil {
+			a.logger.Warn("skip outbound ", options.Tag, ": ", err)
+			continue
+		}
+		outbounds = append(outbounds, detour)
+		outboundByTag[options.Tag] = detour
+		outboundOptions[options.Tag] = optionsContent
+		newOutbounds = append(newOutbounds, detour)
+	}
+	for _, detour := range newOutbounds {
+		if starter, isStarter := detour.(common.Starter); isStarter {
+			err = starter.Start()
+			if err != nil {
+				for _, createdOutbound := range newOutbounds {
+					common.Close(createdOutbound)
+				}
+				return E.Cause(err, "initialize outbound/", detour.Type(), "[", detour.Tag(), "]")
+			}
+		}
+	}
+	if a.started {
+		for _, detour := range newOutbounds {
+			if lateOutbound, isLateOutbound := detour.(adapter.PostStarter); isLateOutbound {
+				err = lateOutbound.PostStart()
+				if err != nil {
+					for _, createdOutbound := range newOutbounds {
+						common.Close(createdOutbound)
+					}
+					return E.Cause(err, "post-start outbound/", detour.Type(), "[", detour.Tag(), "]")
+				}
+			}
+		}
+	}
+	a.access.Lock()
+	a.outbounds = outbounds
+	a.outboundByTag = outboundByTag
+	a.outboundOptions = outboundOptions
+	a.updatedAt = updatedAt
+	a.lastError = nil
+	a.access.Unlock()
+	a.logger.Info("loaded ", len(outbounds), " outbounds")
+	a.callbackAccess.Lock()
+	var callbacks []adapter.OutboundProviderUpdateCallback
+	for element := a.callbacks.Front(); element != nil; element = element.Next() {
+		callbacks = append(callbacks, element.Value)
+	}
+	a.callbackAccess.Unlock()
+	for _, callback := range callbacks {
+		callback(provider)
+	}
+	for tag, detour := range lastOutboundByTag {
+		if outboundByTag[tag] == detour {
+			continue
+		}
+		err = common.Close(detour)
+		if err != nil {
+			a.logger.Error(E.Cause(err, "close outbound/", detour.Type(), "[", tag, "]"))
+		}
+	}
+	return nil
+}
+
+func (a *myProviderAdapter) HealthCheck(ctx context.Context) (map[string]uint16, error) {
+	var history *urltest.HistoryStorage
+	if history = service.PtrFromContext[urltest.HistoryStorage](a.ctx); history != nil {
+	} else if clashServer := a.router.ClashServer(); clashServer != nil {
+		history = clashServer.HistoryStorage()
+	}
+	result := make(map[string]uint16)
+	var resultAccess sync.Mutex
+	b, _ := batch.New(ctx, batch.WithConcurrencyNum[any](10))
+	for _, detour := range a.Outbounds() {
+		tag := detour.Tag()
+		detourInPlace := detour
+		b.Go(tag, func() (any, error) {
+			testCtx, cancel := context.WithTimeout(ctx, C.TCPTimeout)
+			defer cancel()
+			t, err := urltest.URLTest(testCtx, "", detourInPlace)
+			if err != nil {
+				a.logger.Debug("outbound ", tag, " unavailable: ", err)
+				if history != nil {
+					history.DeleteURLTestHistory(tag)
+				}
+			} else {
+				a.logger.Debug("outbound ", tag, " available: ", t, "ms")
+				if history != nil {
+					history.StoreURLTestHistory(tag, &urltest.History{
+						Time:  time.Now(),
+						Delay: t,
+					})
+				}
+				resultAccess.Lock()
+				result[tag] = t
+				resultAccess.Unlock()
+			}
+			return nil, nil
+		})
+	}
+	b.Wait()
+	return result, nil
+}
+
+func (a *myProviderAdapter) Close() error {
+	a.access.Lock()
+	outbounds := a.outbounds
+	a.outbounds = nil
+	a.outboundByTag = nil
+	a.outboundOptions = nil
+	a.access.Unlock()
+	var err error
+	for _, detour := range outbounds {
+		err = E.Append(err, common.Close(detour), func(err error) error {
+			return E.Cause(err, "close outbound/", detour.Type(), "[", detour.Tag(), "]")
+		})
+	}
+	return err
+}
@@ -0,0 +1,227 @@
+package provider
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/service"
+	"github.com/sagernet/sing/service/pause"
+)
+
+var _ adapter.OutboundProvider = (*RemoteProvider)(nil)
+
+type RemoteProvider struct {
+	myProviderAdapter
+	cancel         context.CancelFunc
+	url            string
+	userAgent      string
+	downloadDetour string
+	updateInterval time.Duration
+	dialer         N.Dialer
+	updateAccess   sync.Mutex
+	lastEtag       string
+	updateTicker   *time.Ticker
+	pauseManager   pause.Manager
+}
+
+func NewRemote(ctx context.Context, router adapter.Router, logFactory log.Factory, logger log.ContextLogger, tag string, options option.RemoteOutboundProviderOptions) (*RemoteProvider, error) {
+	if options.URL == "" {
+		return nil, E.New("missing url")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	var updateInterval time.Duration
+	if options.UpdateInterval > 0 {
+		updateInterval = time.Duration(options.UpdateInterval)
+	} else {
+		updateInterval = 24 * time.Hour
+	}
+	userAgent := options.UserAgent
+	if userAgent == "" {
+		userAgent = "sing-box " + C.Version
+	}
+	return &RemoteProvider{
+		myProviderAdapter: myProviderAdapter{
+			ctx:          ctx,
+			router:       router,
+			logFactory:   logFactory,
+			logger:       logger,
+			providerType: C.ProviderTypeRemote,
+			tag:          tag,
+		},
+		cancel:         cancel,
+		url:            options.URL,
+		userAgent:      userAgent,
+		downloadDetour: options.DownloadDetour,
+		updateInterval: updateInterval,
+		pauseManager:   service.FromContext[pause.Manager](ctx),
+	}, nil
+}
+
+func (p *RemoteProvider) Start() error {
+	if p.downloadDetour != "" {
+		outbound, loaded := p.router.Outbound(p.downloadDetour)
+		if !loaded {
+			return E.New("download_detour not found: ", p.downloadDetour)
+		}
+		p.dialer = outbound
+	} else {
+		outbound, err := p.router.DefaultOutbound(N.NetworkTCP)
+		if err != nil {
+			return err
+		}
+		p.dialer = outbound
+	}
+	cacheFile := service.FromContext[adapter.CacheFile](p.ctx)
+	if cacheFile != nil {
+		if savedProvider := cacheFile.LoadOutboundProvider(p.tag); savedProvider != nil {
+			err := p.loadContent(p, savedProvider.Content, savedProvider.LastUpdated)
+			if err != nil {
+				p.logger.Error(E.Cause(err, "restore cached provider"))
+			} else {
+				p.access.Lock()
+				p.lastEtag = savedProvider.LastEtag
+				p.access.Unlock()
+			}
+		}
+	}
+	return nil
+}
+
+func (p *RemoteProvider) PostStart() error {
+	err := p.postStart()
+	if err != nil {
+		return err
+	}
+	p.updateTicker = time.NewTicker(p.updateInterval)
+	go p.loopUpdate()
+	return nil
+}
+
+func (p *RemoteProvider) loopUpdate() {
+	if time.Since(p.UpdatedAt()) > p.updateInterval {
+		err := p.Update(p.ctx)
+		if err != nil {
+			p.logger.Error("update provider: ", err)
+		}
+	}
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-p.updateTicker.C:
+			p.pauseManager.WaitActive()
+			err := p.Update(p.ctx)
+			if err != nil {
+				p.logger.Error("update provider: ", err)
+			}
+		}
+	}
+}
+
+func (p *RemoteProvider) Update(ctx context.Context) error {
+	p.updateAccess.Lock()
+	defer p.updateAccess.Unlock()
+	err := p.fetchOnce(ctx)
+	if err != nil {
+		p.setLastError(err)
+	}
+	return err
+}
+
+func (p *RemoteProvider) fetchOnce(ctx context.Context) error {
+	p.logger.Debug("updating provider from URL: ", p.url)
+	httpClient := &http.Client{
+		Transport: &http.Transport{
+			ForceAttemptHTTP2:   true,
+			TLSHandshakeTimeout: C.TCPTimeout,
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return p.dialer.DialContext(ctx, network, M.ParseSocksaddr(addr))
+			},
+		},
+	}
+	defer httpClient.CloseIdleConnections()
+	request, err := http.NewRequest("GET", p.url, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("User-Agent", p.userAgent)
+	p.access.RLock()
+	lastEtag := p.lastEtag
+	p.access.RUnlock()
+	if lastEtag != "" && len(p.Outbounds()) > 0 {
+		request.Header.Set("If-None-Match", lastEtag)
+	}
+	response, err := httpClient.Do(request.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	cacheFile := service.FromContext[adapter.CacheFile](p.ctx)
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		updatedAt := time.Now()
+		p.access.Lock()
+		p.updatedAt = updatedAt
+		p.lastError = nil
+		p.access.Unlock()
+		if cacheFile != nil {
+			savedProvider := cacheFile.LoadOutboundProvider(p.tag)
+			if savedProvider != nil {
+				savedProvider.LastUpdated = updatedAt
+				err = cacheFile.SaveOutboundProvider(p.tag, savedProvider)
+				if err != nil {
+					p.logger.Error("save provider updated time: ", err)
+				}
+			}
+		}
+		p.logger.Info("update provider: not modified")
+		return nil
+	default:
+		return E.New("unexpected status: ", response.Status)
+	}
+	content, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	updatedAt := time.Now()
+	err = p.loadContent(p, content, updatedAt)
+	if err != nil {
+		return err
+	}
+	lastEtag = response.Header.Get("Etag")
+	p.access.Lock()
+	p.lastEtag = lastEtag
+	p.access.Unlock()
+	if cacheFile != nil {
+		err = cacheFile.SaveOutboundProvider(p.tag, &adapter.SavedOutboundProvider{
+			Content:     content,
+			LastUpdated: updatedAt,
+			LastEtag:    lastEtag,
+		})
+		if err != nil {
+			p.logger.Error("save provider cache: ", err)
+		}
+	}
+	p.logger.Info("updated provider")
+	return nil
+}
+
+func (p *RemoteProvider) Close() error {
+	if p.updateTicker != nil {
+		p.updateTicker.Stop()
+	}
+	p.cancel()
+	return p.myProviderAdapter.Close()
+}
@@ -0,0 +1,265 @@
+package route
+
+import (
+	"context"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-dns"
+	"github.com/sagernet/sing/common/cache"
+	"github.com/sagernet/sing/common/logger"
+
+	mDNS "github.com/miekg/dns"
+)
+
+const (
+	dnsCacheMaxSize     = 4096
+	dnsCacheSaveDelay   = time.Minute
+	dnsCacheStaleTTL    = 30
+	dnsCacheStaleMaxAge = 3 * 24 * time.Hour
+)
+
+type dnsCacheEntry struct {
+	message  *mDNS.Msg
+	expireAt time.Time
+}
+
+// dnsCache keeps answers after they expire from the client cache, so that they
+// can be served stale and persisted to the cache file.
+type dnsCache struct {
+	ctx        context.Context
+	logger     logger.ContextLogger
+	serveStale bool
+	cache      *cache.LruCache[mDNS.Question, *dnsCacheEntry]
+
+	access     sync.Mutex
+	cacheFile  adapter.CacheFile
+	saveTimer  *time.Timer
+	refreshing map[mDNS.Question]bool
+}
+
+func newDNSCache(ctx context.Context, logger logger.ContextLogger, serveStale bool) *dnsCache {
+	return &dnsCache{
+		ctx:        ctx,
+		logger:     logger,
+		serveStale: serveStale,
+		cache:      cache.New(cache.WithSize[mDNS.Question, *dnsCacheEntry](dnsCacheMaxSize)),
+		refreshing: make(map[mDNS.Question]bool),
+	}
+}
+
+func (c *dnsCache) restore(cacheFile adapter.CacheFile) {
+	var restored int
+	for _, saved := range cacheFile.LoadDNSCache() {
+		if time.Since(saved.ExpireAt) > dnsCacheStaleMaxAge {
+			continue
+		}
+		c.cache.Store(saved.Message.Question[0], &dnsCacheEntry{
+			message:  saved.Message,
+			expireAt: saved.ExpireAt,
+		})
+		restored++
+	}
+	c.access.Lock()
+	c.cacheFile = cacheFile
+	c.access.Unlock()
+	c.logger.Debug("restored ", restored, " DNS cache entries")
+}
+
+func (c *dnsCache) load(question mDNS.Question) (response *mDNS.Msg, expired bool, loaded bool) {
+	entry, loaded := c.cache.Load(question)
+	if !loaded {
+		return
+	}
+	timeToLive := int(time.Until(entry.expireAt).Seconds())
+	if timeToLive <= 0 {
+		if !c.serveStale {
+			return nil, false, false
+		}
+		expired = true
+		timeToLive = dnsCacheStaleTTL
+	}
+	response = entry.message.Copy()
+	for _, recordList := range [][]mDNS.RR{response.Answer, response.Ns, response.Extra} {
+		for _, record := range recordList {
+			record.Header().Ttl = uint32(timeToLive)
+		}
+	}
+	return
+}
+
+func (c *dnsCache) Exchange(ctx context.Context, message *mDNS.Msg, refresh func(ctx context.Context, message *mDNS.Msg)) (*mDNS.Msg, bool) {
+	if len(message.Question) != 1 || dns.DisableCacheFromContext(ctx) {
+		return nil, false
+	}
+	question := message.Question[0]
+	response, expired, loaded := c.load(question)
+	if !loaded {
+		return nil, false
+	}
+	if expired {
+		c.logger.DebugContext(ctx, "serve stale ", formatQuestion(question.String()))
+		refreshMessage := message.Copy()
+		c.refresh(ctx, question, func(ctx context.Context) {
+			refresh(ctx, refreshMessage)
+		})
+	}
+	response.Id = message.Id
+	return response, true
+}
+
+func (c *dnsCache) Lookup(ctx context.Context, domain string, strategy dns.DomainStrategy, refresh func(ctx context.Context)) ([]netip.Addr, bool) {
+	if dns.DisableCacheFromContext(ctx) {
+		return nil, false
+	}
+	var (
+		addresses  []netip.Addr
+		anyExpired bool
+	)
+	questions := dnsCacheLookupQuestions(domain, strategy)
+	for _, question := range questions {
+		response, expired, loaded := c.load(question)
+		if !loaded {
+			continue
+		}
+		anyExpired = anyExpired || expired
+		questionAddresses, _ := dns.MessageToAddresses(response)
+		if strategy == dns.DomainStrategyPreferIPv6 {
+			addresses = append(questionAddresses, addresses...)
+		} else {
+			addresses = append(addresses, questionAddresses...)
+		}
+	}
+	if len(addresses) == 0 {
+		return nil, false
+	}
+	if anyExpired {
+		c.logger.DebugContext(ctx, "serve stale ", domain)
+		c.refresh(ctx, questions[0], refresh)
+	}
+	return addresses, true
+}
+
+func (c *dnsCache) refresh(ctx context.Context, question mDNS.Question, refresh func(ctx context.Context)) {
+	c.access.Lock()
+	if c.refreshing[question] {
+		c.access.Unlock()
+		return
+	}
+	c.refreshing[question] = true
+	c.access.Unlock()
+	refreshCtx := log.ContextWithNewID(c.ctx)
+	if metadata := adapter.ContextFrom(ctx); metadata != nil {
+		metadataCopy := *metadata
+		refreshCtx = adapter.WithContext(refreshCtx, &metadataCopy)
+	}
+	go func() {
+		refresh(refreshCtx)
+		c.access.Lock()
+		delete(c.refreshing, question)
+		c.access.Unlock()
+	}()
+}
+
+func (c *dnsCache) StoreExchange(question mDNS.Question, response *mDNS.Msg) {
+	if response.Rcode != mDNS.RcodeSuccess && response.Rcode != mDNS.RcodeNameError {
+		return
+	}
+	var timeToLive int
+	for _, recordList := range [][]mDNS.RR{response.Answer, response.Ns, response.Extra} {
+		for _, record := range recordList {
+			if timeToLive == 0 || record.Header().Ttl > 0 && int(record.Header().Ttl) < timeToLive {
+				timeToLive = int(record.Header().Ttl)
+			}
+		}
+	}
+	if timeToLive == 0 {
+		return
+	}
+	message := response.Copy()
+	message.Id = 0
+	message.Question = []mDNS.Question{question}
+	c.store(question, message, time.Now().Add(time.Duration(timeToLive)*time.Second))
+}
+
+// StoreLookup copies lookup answers out of the client cache, which keeps the
+// original TTL of each record.
+func (c *dnsCache) StoreLookup(client *dns.Client, ctx context.Context, domain string, strategy dns.DomainStrategy) {
+	for _, question := range dnsCacheLookupQuestions(domain, strategy) {
+		response, loaded := client.ExchangeCache(ctx, &mDNS.Msg{Question: []mDNS.Question{question}})
+		if !loaded {
+			continue
+		}
+		c.StoreExchange(question, response)
+	}
+}
+
+func (c *dnsCache) store(question mDNS.Question, message *mDNS.Msg, expireAt time.Time) {
+	c.cache.Store(question, &dnsCacheEntry{
+		message:  message,
+		expireAt: expireAt,
+	})
+	c.access.Lock()
+	defer c.access.Unlock()
+	if c.cacheFile != nil && c.saveTimer == nil {
+		c.saveTimer = time.AfterFunc(dnsCacheSaveDelay, c.save)
+	}
+}
+
+func (c *dnsCache) save() {
+	c.access.Lock()
+	cacheFile := c.cacheFile
+	c.saveTimer = nil
+	c.access.Unlock()
+	if cacheFile == nil {
+		return
+	}
+	var messages []adapter.SavedDNSMessage
+	c.cache.Range(func(question mDNS.Question, entry *dnsCacheEntry) {
+		if time.Since(entry.expireAt) > dnsCacheStaleMaxAge {
+			return
+		}
+		messages = append(messages, adapter.SavedDNSMessage{
+			Message:  entry.message,
+			ExpireAt: entry.expireAt,
+		})
+	})
+	err := cacheFile.SaveDNSCache(messages)
+	if err != nil {
+		c.logger.Warn("save DNS cache: ", err)
+	}
+}
+
+func (c *dnsCache) Clear() {
+	c.cache.Clear()
+}
+
+func (c *dnsCache) Close() error {
+	c.access.Lock()
+	if c.saveTimer != nil {
+		c.saveTimer.Stop()
+	}
+	c.access.Unlock()
+	c.save()
+	c.access.Lock()
+	c.cacheFile = nil
+	c.access.Unlock()
+	return nil
+}
+
+func dnsCacheLookupQuestions(domain string, strategy dns.DomainStrategy) []mDNS.Question {
+	name := mDNS.Fqdn(domain)
+	question4 := mDNS.Question{Name: name, Qtype: mDNS.TypeA, Qclass: mDNS.ClassINET}
+	question6 := mDNS.Question{Name: name, Qtype: mDNS.TypeAAAA, Qclass: mDNS.ClassINET}
+	switch strategy {
+	case dns.DomainStrategyUseIPv4:
+		return []mDNS.Question{question4}
+	case dns.DomainStrategyUseIPv6:
+		return []mDNS.Question{question6}
+	default:
+		return []mDNS.Question{question4, question6}
+	}
+}
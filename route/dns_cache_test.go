@@ -0,0 +1,174 @@
+package route
+
+import (
+	"context"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-dns"
+	"github.com/sagernet/sing/common/logger"
+
+	mDNS "github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDNSResponse(question mDNS.Question, ttl uint32, address string) *mDNS.Msg {
+	response := new(mDNS.Msg)
+	response.Question = []mDNS.Question{question}
+	header := mDNS.RR_Header{Name: question.Name, Rrtype: question.Qtype, Class: mDNS.ClassINET, Ttl: ttl}
+	addr := netip.MustParseAddr(address)
+	if addr.Is4() {
+		response.Answer = append(response.Answer, &mDNS.A{Hdr: header, A: addr.AsSlice()})
+	} else {
+		response.Answer = append(response.Answer, &mDNS.AAAA{Hdr: header, AAAA: addr.AsSlice()})
+	}
+	return response
+}
+
+func testQuestion(qType uint16) mDNS.Question {
+	return mDNS.Question{Name: "example.com.", Qtype: qType, Qclass: mDNS.ClassINET}
+}
+
+func TestDNSCacheExpire(t *testing.T) {
+	t.Parallel()
+	question := testQuestion(mDNS.TypeA)
+	for _, serveStale := range []bool{false, true} {
+		cache := newDNSCache(context.Background(), logger.NOP(), serveStale)
+		cache.StoreExchange(question, newTestDNSResponse(question, 60, "1.1.1.1"))
+		response, expired, loaded := cache.load(question)
+		require.True(t, loaded)
+		require.False(t, expired)
+		require.LessOrEqual(t, response.Answer[0].Header().Ttl, uint32(60))
+		require.Greater(t, response.Answer[0].Header().Ttl, uint32(0))
+
+		cache.store(question, newTestDNSResponse(question, 60, "1.1.1.1"), time.Now().Add(-time.Second))
+		response, expired, loaded = cache.load(question)
+		require.Equal(t, serveStale, loaded)
+		if serveStale {
+			require.True(t, expired)
+			require.Equal(t, uint32(dnsCacheStaleTTL), response.Answer[0].Header().Ttl)
+		}
+	}
+}
+
+func TestDNSCacheStoreExchange(t *testing.T) {
+	t.Parallel()
+	question := testQuestion(mDNS.TypeA)
+	cache := newDNSCache(context.Background(), logger.NOP(), false)
+	failure := newTestDNSResponse(question, 60, "1.1.1.1")
+	failure.Rcode = mDNS.RcodeServerFailure
+	cache.StoreExchange(question, failure)
+	cache.StoreExchange(question, newTestDNSResponse(question, 0, "1.1.1.1"))
+	_, _, loaded := cache.load(question)
+	require.False(t, loaded)
+
+	response := newTestDNSResponse(question, 60, "1.1.1.1")
+	response.Id = 1
+	cache.StoreExchange(question, response)
+	exchanged, loaded := cache.Exchange(context.Background(), &mDNS.Msg{MsgHdr: mDNS.MsgHdr{Id: 2}, Question: []mDNS.Question{question}}, nil)
+	require.True(t, loaded)
+	require.Equal(t, uint16(2), exchanged.Id)
+
+	cache.Clear()
+	_, _, loaded = cache.load(question)
+	require.False(t, loaded)
+}
+
+func TestDNSCacheLookup(t *testing.T) {
+	t.Parallel()
+	cache := newDNSCache(context.Background(), logger.NOP(), false)
+	question4 := testQuestion(mDNS.TypeA)
+	question6 := testQuestion(mDNS.TypeAAAA)
+	cache.StoreExchange(question4, newTestDNSResponse(question4, 60, "1.1.1.1"))
+	cache.StoreExchange(question6, newTestDNSResponse(question6, 60, "::1"))
+	addresses, loaded := cache.Lookup(context.Background(), "example.com", dns.DomainStrategyPreferIPv6, nil)
+	require.True(t, loaded)
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("::1"), netip.MustParseAddr("1.1.1.1")}, addresses)
+	addresses, loaded = cache.Lookup(context.Background(), "example.com", dns.DomainStrategyUseIPv4, nil)
+	require.True(t, loaded)
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("1.1.1.1")}, addresses)
+	_, loaded = cache.Lookup(dns.ContextWithDisableCache(context.Background(), true), "example.com", dns.DomainStrategyAsIS, nil)
+	require.False(t, loaded)
+}
+
+func TestDNSCacheStaleRefresh(t *testing.T) {
+	t.Parallel()
+	question := testQuestion(mDNS.TypeA)
+	cache := newDNSCache(context.Background(), logger.NOP(), true)
+	cache.store(question, newTestDNSResponse(question, 60, "1.1.1.1"), time.Now().Add(-time.Second))
+	var refreshed atomic.Int32
+	release := make(chan struct{})
+	done := make(chan struct{})
+	refresh := func(ctx context.Context, message *mDNS.Msg) {
+		require.Equal(t, question, message.Question[0])
+		refreshed.Add(1)
+		<-release
+		cache.StoreExchange(question, newTestDNSResponse(question, 60, "2.2.2.2"))
+		close(done)
+	}
+	request := &mDNS.Msg{Question: []mDNS.Question{question}}
+	for i := 0; i < 3; i++ {
+		response, loaded := cache.Exchange(context.Background(), request, refresh)
+		require.True(t, loaded)
+		require.Equal(t, "1.1.1.1", response.Answer[0].(*mDNS.A).A.String())
+	}
+	close(release)
+	<-done
+	require.Equal(t, int32(1), refreshed.Load())
+	require.Eventually(t, func() bool {
+		cache.access.Lock()
+		defer cache.access.Unlock()
+		return len(cache.refreshing) == 0
+	}, time.Second, 10*time.Millisecond)
+	response, loaded := cache.Exchange(context.Background(), request, refresh)
+	require.True(t, loaded)
+	require.Equal(t, "2.2.2.2", response.Answer[0].(*mDNS.A).A.String())
+}
+
+type testDNSCacheFile struct {
+	adapter.CacheFile
+	messages []adapter.SavedDNSMessage
+}
+
+func (f *testDNSCacheFile) LoadDNSCache() []adapter.SavedDNSMessage {
+	return f.messages
+}
+
+func (f *testDNSCacheFile) SaveDNSCache(messages []adapter.SavedDNSMessage) error {
+	f.messages = messages
+	return nil
+}
+
+func TestDNSCacheRestore(t *testing.T) {
+	t.Parallel()
+	question4 := testQuestion(mDNS.TypeA)
+	question6 := testQuestion(mDNS.TypeAAAA)
+	cacheFile := &testDNSCacheFile{messages: []adapter.SavedDNSMessage{{
+		Message:  newTestDNSResponse(question4, 60, "1.1.1.1"),
+		ExpireAt: time.Now().Add(time.Minute),
+	}, {
+		Message:  newTestDNSResponse(question6, 60, "::1"),
+		ExpireAt: time.Now().Add(-dnsCacheStaleMaxAge - time.Minute),
+	}}}
+	cache := newDNSCache(context.Background(), logger.NOP(), true)
+	cache.restore(cacheFile)
+	_, expired, loaded := cache.load(question4)
+	require.True(t, loaded)
+	require.False(t, expired)
+	_, _, loaded = cache.load(question6)
+	require.False(t, loaded)
+
+	question := mDNS.Question{Name: "example.org.", Qtype: mDNS.TypeA, Qclass: mDNS.ClassINET}
+	cache.StoreExchange(question, newTestDNSResponse(question, 60, "2.2.2.2"))
+	require.NoError(t, cache.Close())
+	require.Len(t, cacheFile.messages, 2)
+
+	restored := newDNSCache(context.Background(), logger.NOP(), false)
+	restored.restore(cacheFile)
+	response, _, loaded := restored.load(question)
+	require.True(t, loaded)
+	require.Equal(t, "2.2.2.2", response.Answer[0].(*mDNS.A).A.String())
+}
@@ -1,6 +1,7 @@
 package route
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"net"
@@ -10,6 +11,7 @@ import (
 	"os/user"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sagernet/sing-box/adapter"
@@ -20,6 +22,7 @@ import (
 	"github.com/sagernet/sing-box/common/process"
 	"github.com/sagernet/sing-box/common/sniff"
 	"github.com/sagernet/sing-box/common/taskmonitor"
+	"github.com/sagernet/sing-box/common/userlimit"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/experimental/libbox/platform"
 	"github.com/sagernet/sing-box/log"
@@ -38,6 +41,7 @@ import (
 	"github.com/sagernet/sing/common/control"
 	E "github.com/sagernet/sing/common/exceptions"
 	F "github.com/sagernet/sing/common/format"
+	"github.com/sagernet/sing/common/json"
 	M "github.com/sagernet/sing/common/metadata"
 	N "github.com/sagernet/sing/common/network"
 	serviceNTP "github.com/sagernet/sing/common/ntp"
@@ -52,11 +56,14 @@ var _ adapter.Router = (*Router)(nil)
 
 type Router struct {
 	ctx                                context.Context
+	logFactory                         log.Factory
 	logger                             log.ContextLogger
 	dnsLogger                          log.ContextLogger
 	inboundByTag                       map[string]adapter.Inbound
 	outbounds                          []adapter.Outbound
 	outboundByTag                      map[string]adapter.Outbound
+	outboundProviders                  []adapter.OutboundProvider
+	outboundProviderByTag              map[string]adapter.OutboundProvider
 	rules                              []adapter.Rule
 	defaultDetour                      string
 	defaultOutboundForConnection       adapter.Outbound
@@ -71,15 +78,21 @@ type Router struct {
 	needFindProcess                    bool
 	dnsClient                          *dns.Client
 	dnsIndependentCache                bool
+	dnsDisableCache                    bool
+	dnsCache                           *dnsCache
 	defaultDomainStrategy              dns.DomainStrategy
 	dnsRules                           []adapter.DNSRule
 	ruleSets                           []adapter.RuleSet
 	ruleSetMap                         map[string]adapter.RuleSet
+	ruleSetOptions                     map[string][]byte
 	defaultTransport                   dns.Transport
 	transports                         []dns.Transport
 	transportMap                       map[string]dns.Transport
+	transportByTag                     map[string]dns.Transport
+	transportOptions                   map[string][]byte
 	transportDomainStrategy            map[dns.Transport]dns.DomainStrategy
 	dnsReverseMapping                  *DNSReverseMapping
+	dnsHijacker                        *outbound.DNS
 	fakeIPStore                        adapter.FakeIPStore
 	interfaceFinder                    myInterfaceFinder
 	autoDetectInterface                bool
@@ -94,11 +107,15 @@ type Router struct {
 	pauseManager                       pause.Manager
 	clashServer                        adapter.ClashServer
 	v2rayServer                        adapter.V2RayServer
+	metricsServer                      adapter.MetricsServer
+	accessLogger                       adapter.AccessLogger
+	userLimiter                        *userlimit.Manager
 	platformInterface                  platform.Interface
 	needWIFIState                      bool
 	needPackageManager                 bool
 	wifiState                          adapter.WIFIState
 	started                            bool
+	access                             sync.RWMutex
 }
 
 func NewRouter(
@@ -112,12 +129,12 @@ func NewRouter(
 ) (*Router, error) {
 	router := &Router{
 		ctx:                   ctx,
+		logFactory:            logFactory,
 		logger:                logFactory.NewLogger("router"),
 		dnsLogger:             logFactory.NewLogger("dns"),
 		outboundByTag:         make(map[string]adapter.Outbound),
 		rules:                 make([]adapter.Rule, 0, len(options.Rules)),
 		dnsRules:              make([]adapter.DNSRule, 0, len(dnsOptions.Rules)),
-		ruleSetMap:            make(map[string]adapter.RuleSet),
 		needGeoIPDatabase:     hasRule(options.Rules, isGeoIPRule) || hasDNSRule(dnsOptions.Rules, isGeoIPDNSRule),
 		needGeositeDatabase:   hasRule(options.Rules, isGeositeRule) || hasDNSRule(dnsOptions.Rules, isGeositeDNSRule),
 		geoIPOptions:          common.PtrValueOrDefault(options.GeoIP),
@@ -125,12 +142,14 @@ func NewRouter(
 		geositeCache:          make(map[string]adapter.Rule),
 		needFindProcess:       hasRule(options.Rules, isProcessRule) || hasDNSRule(dnsOptions.Rules, isProcessDNSRule) || options.FindProcess,
 		dnsIndependentCache:   dnsOptions.IndependentCache,
+		dnsDisableCache:       dnsOptions.DisableCache,
 		defaultDetour:         options.Final,
 		defaultDomainStrategy: dns.DomainStrategy(dnsOptions.Strategy),
 		autoDetectInterface:   options.AutoDetectInterface,
 		defaultInterface:      options.DefaultInterface,
 		defaultMark:           options.DefaultMark,
 		pauseManager:          service.FromContext[pause.Manager](ctx),
+		userLimiter:           service.PtrFromContext[userlimit.Manager](ctx),
 		platformInterface:     platformInterface,
 		needWIFIState:         hasRule(options.Rules, isWIFIRule) || hasDNSRule(dnsOptions.Rules, isWIFIDNSRule),
 		needPackageManager: C.IsAndroid && platformInterface == nil && common.Any(inbounds, func(inbound option.Inbound) bool {
@@ -153,6 +172,12 @@ func NewRouter(
 		},
 		Logger: router.dnsLogger,
 	})
+	if dnsOptions.ServeStale {
+		if dnsOptions.DisableCache || dnsOptions.IndependentCache {
+			return nil, E.New("serve_stale is not available with disable_cache or independent_cache")
+		}
+		router.dnsCache = newDNSCache(ctx, router.dnsLogger, true)
+	}
 	for i, ruleOptions := range options.Rules {
 		routeRule, err := NewRule(router, router.logger, ruleOptions, true)
 		if err != nil {
@@ -167,24 +192,150 @@ func NewRouter(
 		}
 		router.dnsRules = append(router.dnsRules, dnsRule)
 	}
-	for i, ruleSetOptions := range options.RuleSet {
-		if _, exists := router.ruleSetMap[ruleSetOptions.Tag]; exists {
+	ctx = adapter.ContextWithRouter(ctx, router)
+	ruleSets, err := router.newRuleSets(ctx, options.RuleSet, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	router.ruleSets = ruleSets.ruleSets
+	router.ruleSetMap = ruleSets.ruleSetMap
+	router.ruleSetOptions = ruleSets.ruleSetOptions
+	transports, err := router.newDNSTransports(ctx, dnsOptions, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	router.defaultTransport = transports.defaultTransport
+	router.transports = transports.transports
+	router.transportMap = transports.transportMap
+	router.transportByTag = transports.transportByTag
+	router.transportOptions = transports.transportOptions
+	router.transportDomainStrategy = transports.transportDomainStrategy
+	err = checkRuleDNSServers(router.rules, router.transportMap)
+	if err != nil {
+		return nil, err
+	}
+
+	if dnsOptions.ReverseMapping {
+		router.dnsReverseMapping = NewDNSReverseMapping()
+	}
+	router.dnsHijacker = outbound.NewDNS(router, "")
+
+	if fakeIPOptions := dnsOptions.FakeIP; fakeIPOptions != nil && dnsOptions.FakeIP.Enabled {
+		var inet4Range netip.Prefix
+		var inet6Range netip.Prefix
+		if fakeIPOptions.Inet4Range != nil {
+			inet4Range = *fakeIPOptions.Inet4Range
+		}
+		if fakeIPOptions.Inet6Range != nil {
+			inet6Range = *fakeIPOptions.Inet6Range
+		}
+		router.fakeIPStore = fakeip.NewStore(ctx, router.logger, inet4Range, inet6Range)
+	}
+
+	usePlatformDefaultInterfaceMonitor := platformInterface != nil && platformInterface.UsePlatformDefaultInterfaceMonitor()
+	needInterfaceMonitor := options.AutoDetectInterface || common.Any(inbounds, func(inbound option.Inbound) bool {
+		return inbound.HTTPOptions.SetSystemProxy || inbound.MixedOptions.SetSystemProxy || inbound.TunOptions.AutoRoute
+	})
+
+	if !usePlatformDefaultInterfaceMonitor {
+		networkMonitor, err := tun.NewNetworkUpdateMonitor(router.logger)
+		if !((err != nil && !needInterfaceMonitor) || errors.Is(err, os.ErrInvalid)) {
+			if err != nil {
+				return nil, err
+			}
+			router.networkMonitor = networkMonitor
+			networkMonitor.RegisterCallback(func() {
+				_ = router.interfaceFinder.update()
+			})
+			interfaceMonitor, err := tun.NewDefaultInterfaceMonitor(router.networkMonitor, router.logger, tun.DefaultInterfaceMonitorOptions{
+				OverrideAndroidVPN:    options.OverrideAndroidVPN,
+				UnderNetworkExtension: platformInterface != nil && platformInterface.UnderNetworkExtension(),
+			})
+			if err != nil {
+				return nil, E.New("auto_detect_interface unsupported on current platform")
+			}
+			interfaceMonitor.RegisterCallback(router.notifyNetworkUpdate)
+			router.interfaceMonitor = interfaceMonitor
+		}
+	} else {
+		interfaceMonitor := platformInterface.CreateDefaultInterfaceMonitor(router.logger)
+		interfaceMonitor.RegisterCallback(router.notifyNetworkUpdate)
+		router.interfaceMonitor = interfaceMonitor
+	}
+
+	if runtime.GOOS == "windows" {
+		powerListener, err := winpowrprof.NewEventListener(router.notifyWindowsPowerEvent)
+		if err != nil {
+			return nil, E.Cause(err, "initialize power listener")
+		}
+		router.powerListener = powerListener
+	}
+
+	if ntpOptions.Enabled {
+		timeService, err := ntp.NewService(ctx, router, logFactory.NewLogger("ntp"), ntpOptions)
+		if err != nil {
+			return nil, err
+		}
+		service.ContextWith[serviceNTP.TimeService](ctx, timeService)
+		router.timeService = timeService
+	}
+	return router, nil
+}
+
+type ruleSetState struct {
+	ruleSets       []adapter.RuleSet
+	ruleSetMap     map[string]adapter.RuleSet
+	ruleSetOptions map[string][]byte
+}
+
+func (r *Router) newRuleSets(ctx context.Context, options []option.RuleSet, last *ruleSetState, reloadedOutbounds map[string]bool) (*ruleSetState, error) {
+	state := &ruleSetState{
+		ruleSets:       make([]adapter.RuleSet, 0, len(options)),
+		ruleSetMap:     make(map[string]adapter.RuleSet),
+		ruleSetOptions: make(map[string][]byte),
+	}
+	for i, ruleSetOptions := range options {
+		if _, exists := state.ruleSetMap[ruleSetOptions.Tag]; exists {
 			return nil, E.New("duplicate rule-set tag: ", ruleSetOptions.Tag)
 		}
-		ruleSet, err := NewRuleSet(ctx, router, router.logger, ruleSetOptions)
+		content, err := json.Marshal(ruleSetOptions)
 		if err != nil {
 			return nil, E.Cause(err, "parse rule-set[", i, "]")
 		}
-		router.ruleSets = append(router.ruleSets, ruleSet)
-		router.ruleSetMap[ruleSetOptions.Tag] = ruleSet
+		var ruleSet adapter.RuleSet
+		if last != nil && bytes.Equal(last.ruleSetOptions[ruleSetOptions.Tag], content) && !reloadedOutbounds[ruleSetOptions.RemoteOptions.DownloadDetour] {
+			ruleSet = last.ruleSetMap[ruleSetOptions.Tag]
+		} else {
+			ruleSet, err = NewRuleSet(ctx, r, r.logger, ruleSetOptions)
+			if err != nil {
+				return nil, E.Cause(err, "parse rule-set[", i, "]")
+			}
+		}
+		state.ruleSets = append(state.ruleSets, ruleSet)
+		state.ruleSetMap[ruleSetOptions.Tag] = ruleSet
+		state.ruleSetOptions[ruleSetOptions.Tag] = content
 	}
+	return state, nil
+}
+
+type dnsTransportState struct {
+	defaultTransport        dns.Transport
+	transports              []dns.Transport
+	transportMap            map[string]dns.Transport
+	transportByTag          map[string]dns.Transport
+	transportOptions        map[string][]byte
+	transportDomainStrategy map[dns.Transport]dns.DomainStrategy
+}
 
+func (r *Router) newDNSTransports(ctx context.Context, dnsOptions option.DNSOptions, last *dnsTransportState, reloadedOutbounds map[string]bool) (*dnsTransportState, error) {
 	transports := make([]dns.Transport, len(dnsOptions.Servers))
 	dummyTransportMap := make(map[string]dns.Transport)
 	transportMap := make(map[string]dns.Transport)
 	transportTags := make([]string, len(dnsOptions.Servers))
 	transportTagMap := make(map[string]bool)
+	transportOptions := make(map[string][]byte)
 	transportDomainStrategy := make(map[dns.Transport]dns.DomainStrategy)
+	reusedTransports := make(map[string]bool)
 	for i, server := range dnsOptions.Servers {
 		var tag string
 		if server.Tag != "" {
@@ -197,8 +348,15 @@ func NewRouter(
 		}
 		transportTags[i] = tag
 		transportTagMap[tag] = true
+		if server.ClientSubnet == nil {
+			server.ClientSubnet = dnsOptions.ClientSubnet
+		}
+		content, err := json.Marshal(server)
+		if err != nil {
+			return nil, E.Cause(err, "parse dns server[", tag, "]")
+		}
+		transportOptions[tag] = content
 	}
-	ctx = adapter.ContextWithRouter(ctx, router)
 	for {
 		lastLen := len(dummyTransportMap)
 		for i, server := range dnsOptions.Servers {
@@ -208,9 +366,9 @@ func NewRouter(
 			}
 			var detour N.Dialer
 			if server.Detour == "" {
-				detour = dialer.NewRouter(router)
+				detour = dialer.NewRouter(r)
 			} else {
-				detour = dialer.NewDetour(router, server.Detour)
+				detour = dialer.NewDetour(r, server.Detour)
 			}
 			switch server.Address {
 			case "local":
@@ -229,7 +387,7 @@ func NewRouter(
 						return nil, E.New("parse dns server[", tag, "]: address resolver not found: ", server.AddressResolver)
 					}
 					if upstream, exists := dummyTransportMap[server.AddressResolver]; exists {
-						detour = dns.NewDialerWrapper(detour, router.dnsClient, upstream, dns.DomainStrategy(server.AddressStrategy), time.Duration(server.AddressFallbackDelay))
+						detour = dns.NewDialerWrapper(detour, r.dnsClient, upstream, dns.DomainStrategy(server.AddressStrategy), time.Duration(server.AddressFallbackDelay))
 					} else {
 						continue
 					}
@@ -237,22 +395,31 @@ func NewRouter(
 					return nil, E.New("parse dns server[", tag, "]: missing address_resolver")
 				}
 			}
-			var clientSubnet netip.Addr
-			if server.ClientSubnet != nil {
-				clientSubnet = server.ClientSubnet.Build()
-			} else if dnsOptions.ClientSubnet != nil {
-				clientSubnet = dnsOptions.ClientSubnet.Build()
+			var transport dns.Transport
+			if last != nil && bytes.Equal(last.transportOptions[tag], transportOptions[tag]) && !reloadedOutbounds[server.Detour] && (server.AddressResolver == "" || reusedTransports[server.AddressResolver]) {
+				transport = last.transportByTag[tag]
 			}
-			transport, err := dns.CreateTransport(dns.TransportOptions{
-				Context:      ctx,
-				Logger:       logFactory.NewLogger(F.ToString("dns/transport[", tag, "]")),
-				Name:         tag,
-				Dialer:       detour,
-				Address:      server.Address,
-				ClientSubnet: clientSubnet,
-			})
-			if err != nil {
-				return nil, E.Cause(err, "parse dns server[", tag, "]")
+			if transport != nil {
+				reusedTransports[tag] = true
+			} else {
+				var clientSubnet netip.Addr
+				if server.ClientSubnet != nil {
+					clientSubnet = server.ClientSubnet.Build()
+				} else if dnsOptions.ClientSubnet != nil {
+					clientSubnet = dnsOptions.ClientSubnet.Build()
+				}
+				var err error
+				transport, err = dns.CreateTransport(dns.TransportOptions{
+					Context:      ctx,
+					Logger:       r.logFactory.NewLogger(F.ToString("dns/transport[", tag, "]")),
+					Name:         tag,
+					Dialer:       detour,
+					Address:      server.Address,
+					ClientSubnet: clientSubnet,
+				})
+				if err != nil {
+					return nil, E.Cause(err, "parse dns server[", tag, "]")
+				}
 			}
 			transports[i] = transport
 			dummyTransportMap[tag] = transport
@@ -294,7 +461,7 @@ func NewRouter(
 				Context: ctx,
 				Name:    "local",
 				Address: "local",
-				Dialer:  common.Must1(dialer.NewDefault(router, option.DialerOptions{})),
+				Dialer:  common.Must1(dialer.NewDefault(r, option.DialerOptions{})),
 			})))
 		}
 		defaultTransport = transports[0]
@@ -302,78 +469,55 @@ func NewRouter(
 	if _, isFakeIP := defaultTransport.(adapter.FakeIPTransport); isFakeIP {
 		return nil, E.New("default DNS server cannot be fakeip")
 	}
-	router.defaultTransport = defaultTransport
-	router.transports = transports
-	router.transportMap = transportMap
-	router.transportDomainStrategy = transportDomainStrategy
+	return &dnsTransportState{
+		defaultTransport:        defaultTransport,
+		transports:              transports,
+		transportMap:            transportMap,
+		transportByTag:          dummyTransportMap,
+		transportOptions:        transportOptions,
+		transportDomainStrategy: transportDomainStrategy,
+	}, nil
+}
 
-	if dnsOptions.ReverseMapping {
-		router.dnsReverseMapping = NewDNSReverseMapping()
+func (r *Router) Initialize(inbounds []adapter.Inbound, outbounds []adapter.Outbound, outboundProviders []adapter.OutboundProvider, defaultOutbound func() adapter.Outbound) error {
+	err := r.UpdateOutbounds(r.defaultDetour, inbounds, outbounds, outboundProviders, defaultOutbound)
+	if err != nil {
+		return err
 	}
+	return r.checkRuleOutbounds(r.rules)
+}
 
-	if fakeIPOptions := dnsOptions.FakeIP; fakeIPOptions != nil && dnsOptions.FakeIP.Enabled {
-		var inet4Range netip.Prefix
-		var inet6Range netip.Prefix
-		if fakeIPOptions.Inet4Range != nil {
-			inet4Range = *fakeIPOptions.Inet4Range
-		}
-		if fakeIPOptions.Inet6Range != nil {
-			inet6Range = *fakeIPOptions.Inet6Range
+// checkRuleOutbounds checks outbounds of route rules, which can not be
+// outbounds of providers, as they are not loaded yet on start.
+func (r *Router) checkRuleOutbounds(rules []adapter.Rule) error {
+	r.access.RLock()
+	defer r.access.RUnlock()
+	for i, rule := range rules {
+		if _, isRoute := rule.Action().(*RuleActionRoute); !isRoute {
+			continue
 		}
-		router.fakeIPStore = fakeip.NewStore(ctx, router.logger, inet4Range, inet6Range)
-	}
-
-	usePlatformDefaultInterfaceMonitor := platformInterface != nil && platformInterface.UsePlatformDefaultInterfaceMonitor()
-	needInterfaceMonitor := options.AutoDetectInterface || common.Any(inbounds, func(inbound option.Inbound) bool {
-		return inbound.HTTPOptions.SetSystemProxy || inbound.MixedOptions.SetSystemProxy || inbound.TunOptions.AutoRoute
-	})
-
-	if !usePlatformDefaultInterfaceMonitor {
-		networkMonitor, err := tun.NewNetworkUpdateMonitor(router.logger)
-		if !((err != nil && !needInterfaceMonitor) || errors.Is(err, os.ErrInvalid)) {
-			if err != nil {
-				return nil, err
-			}
-			router.networkMonitor = networkMonitor
-			networkMonitor.RegisterCallback(func() {
-				_ = router.interfaceFinder.update()
-			})
-			interfaceMonitor, err := tun.NewDefaultInterfaceMonitor(router.networkMonitor, router.logger, tun.DefaultInterfaceMonitorOptions{
-				OverrideAndroidVPN:    options.OverrideAndroidVPN,
-				UnderNetworkExtension: platformInterface != nil && platformInterface.UnderNetworkExtension(),
-			})
-			if err != nil {
-				return nil, E.New("auto_detect_interface unsupported on current platform")
-			}
-			interfaceMonitor.RegisterCallback(router.notifyNetworkUpdate)
-			router.interfaceMonitor = interfaceMonitor
+		if _, loaded := r.outboundByTag[rule.Outbound()]; !loaded {
+			return E.New("outbound not found for rule[", i, "]: ", rule.Outbound())
 		}
-	} else {
-		interfaceMonitor := platformInterface.CreateDefaultInterfaceMonitor(router.logger)
-		interfaceMonitor.RegisterCallback(router.notifyNetworkUpdate)
-		router.interfaceMonitor = interfaceMonitor
 	}
+	return nil
+}
 
-	if runtime.GOOS == "windows" {
-		powerListener, err := winpowrprof.NewEventListener(router.notifyWindowsPowerEvent)
-		if err != nil {
-			return nil, E.Cause(err, "initialize power listener")
+// checkRuleDNSServers checks DNS servers of resolve actions of route rules.
+func checkRuleDNSServers(rules []adapter.Rule, transportMap map[string]dns.Transport) error {
+	for i, rule := range rules {
+		resolveAction, isResolve := rule.Action().(*RuleActionResolve)
+		if !isResolve || resolveAction.Server == "" {
+			continue
 		}
-		router.powerListener = powerListener
-	}
-
-	if ntpOptions.Enabled {
-		timeService, err := ntp.NewService(ctx, router, logFactory.NewLogger("ntp"), ntpOptions)
-		if err != nil {
-			return nil, err
+		if _, loaded := transportMap[resolveAction.Server]; !loaded {
+			return E.New("DNS server not found for rule[", i, "]: ", resolveAction.Server)
 		}
-		service.ContextWith[serviceNTP.TimeService](ctx, timeService)
-		router.timeService = timeService
 	}
-	return router, nil
+	return nil
 }
 
-func (r *Router) Initialize(inbounds []adapter.Inbound, outbounds []adapter.Outbound, defaultOutbound func() adapter.Outbound) error {
+func (r *Router) UpdateOutbounds(defaultDetour string, inbounds []adapter.Inbound, outbounds []adapter.Outbound, outboundProviders []adapter.OutboundProvider, defaultOutbound func() adapter.Outbound) error {
 	inboundByTag := make(map[string]adapter.Inbound)
 	for _, inbound := range inbounds {
 		inboundByTag[inbound.Tag()] = inbound
@@ -382,12 +526,19 @@ func (r *Router) Initialize(inbounds []adapter.Inbound, outbounds []adapter.Outb
 	for _, detour := range outbounds {
 		outboundByTag[detour.Tag()] = detour
 	}
+	outboundProviderByTag := make(map[string]adapter.OutboundProvider)
+	for _, provider := range outboundProviders {
+		if _, exists := outboundProviderByTag[provider.Tag()]; exists {
+			return E.New("duplicate provider tag: ", provider.Tag())
+		}
+		outboundProviderByTag[provider.Tag()] = provider
+	}
 	var defaultOutboundForConnection adapter.Outbound
 	var defaultOutboundForPacketConnection adapter.Outbound
-	if r.defaultDetour != "" {
-		detour, loaded := outboundByTag[r.defaultDetour]
+	if defaultDetour != "" {
+		detour, loaded := outboundByTag[defaultDetour]
 		if !loaded {
-			return E.New("default detour not found: ", r.defaultDetour)
+			return E.New("default detour not found: ", defaultDetour)
 		}
 		if common.Contains(detour.Network(), N.NetworkTCP) {
 			defaultOutboundForConnection = detour
@@ -442,16 +593,16 @@ func (r *Router) Initialize(inbounds []adapter.Inbound, outbounds []adapter.Outb
 		r.logger.Info("using ", defaultOutboundForConnection.Type(), "[", description, "] as default outbound for connection")
 		r.logger.Info("using ", defaultOutboundForPacketConnection.Type(), "[", packetDescription, "] as default outbound for packet connection")
 	}
+	r.access.Lock()
+	r.defaultDetour = defaultDetour
 	r.inboundByTag = inboundByTag
 	r.outbounds = outbounds
 	r.defaultOutboundForConnection = defaultOutboundForConnection
 	r.defaultOutboundForPacketConnection = defaultOutboundForPacketConnection
 	r.outboundByTag = outboundByTag
-	for i, rule := range r.rules {
-		if _, loaded := outboundByTag[rule.Outbound()]; !loaded {
-			return E.New("outbound not found for rule[", i, "]: ", rule.Outbound())
-		}
-	}
+	r.outboundProviders = outboundProviders
+	r.outboundProviderByTag = outboundProviderByTag
+	r.access.Unlock()
 	return nil
 }
 
@@ -459,6 +610,8 @@ func (r *Router) Outbounds() []adapter.Outbound {
 	if !r.started {
 		return nil
 	}
+	r.access.RLock()
+	defer r.access.RUnlock()
 	return r.outbounds
 }
 
@@ -488,6 +641,19 @@ func (r *Router) PreStart() error {
 			return err
 		}
 	}
+	cacheFile := service.FromContext[adapter.CacheFile](r.ctx)
+	if cacheFile != nil && cacheFile.StoreDNS() {
+		if r.dnsDisableCache || r.dnsIndependentCache {
+			r.dnsLogger.Warn("store_dns is not available with disable_cache or independent_cache")
+		} else {
+			if r.dnsCache == nil {
+				r.dnsCache = newDNSCache(r.ctx, r.dnsLogger, false)
+			}
+			monitor.Start("restore DNS cache")
+			r.dnsCache.restore(cacheFile)
+			monitor.Finish()
+		}
+	}
 	return nil
 }
 
@@ -678,6 +844,13 @@ func (r *Router) Close() error {
 		})
 		monitor.Finish()
 	}
+	if r.dnsCache != nil {
+		monitor.Start("save DNS cache")
+		err = E.Append(err, r.dnsCache.Close(), func(err error) error {
+			return E.Cause(err, "save DNS cache")
+		})
+		monitor.Finish()
+	}
 	for i, transport := range r.transports {
 		monitor.Start("close dns transport[", i, "]")
 		err = E.Append(err, transport.Close(), func(err error) error {
@@ -751,11 +924,38 @@ func (r *Router) PostStart() error {
 }
 
 func (r *Router) Outbound(tag string) (adapter.Outbound, bool) {
+	r.access.RLock()
 	outbound, loaded := r.outboundByTag[tag]
-	return outbound, loaded
+	outboundProviders := r.outboundProviders
+	r.access.RUnlock()
+	if loaded {
+		return outbound, true
+	}
+	for _, provider := range outboundProviders {
+		outbound, loaded = provider.Outbound(tag)
+		if loaded {
+			return outbound, true
+		}
+	}
+	return nil, false
+}
+
+func (r *Router) OutboundProviders() []adapter.OutboundProvider {
+	r.access.RLock()
+	defer r.access.RUnlock()
+	return r.outboundProviders
+}
+
+func (r *Router) OutboundProvider(tag string) (adapter.OutboundProvider, bool) {
+	r.access.RLock()
+	defer r.access.RUnlock()
+	provider, loaded := r.outboundProviderByTag[tag]
+	return provider, loaded
 }
 
 func (r *Router) DefaultOutbound(network string) (adapter.Outbound, error) {
+	r.access.RLock()
+	defer r.access.RUnlock()
 	if network == N.NetworkTCP {
 		if r.defaultOutboundForConnection == nil {
 			return nil, E.New("missing default outbound for TCP connections")
@@ -773,7 +973,15 @@ func (r *Router) FakeIPStore() adapter.FakeIPStore {
 	return r.fakeIPStore
 }
 
+func (r *Router) RuleSets() []adapter.RuleSet {
+	r.access.RLock()
+	defer r.access.RUnlock()
+	return r.ruleSets
+}
+
 func (r *Router) RuleSet(tag string) (adapter.RuleSet, bool) {
+	r.access.RLock()
+	defer r.access.RUnlock()
 	ruleSet, loaded := r.ruleSetMap[tag]
 	return ruleSet, loaded
 }
@@ -791,7 +999,9 @@ func (r *Router) RouteConnection(ctx context.Context, conn net.Conn, metadata ad
 		if metadata.LastInbound == metadata.InboundDetour {
 			return E.New("routing loop on detour: ", metadata.InboundDetour)
 		}
+		r.access.RLock()
 		detour := r.inboundByTag[metadata.InboundDetour]
+		r.access.RUnlock()
 		if detour == nil {
 			return E.New("inbound detour not found: ", metadata.InboundDetour)
 		}
@@ -843,30 +1053,7 @@ func (r *Router) RouteConnection(ctx context.Context, conn net.Conn, metadata ad
 	}
 
 	if metadata.InboundOptions.SniffEnabled {
-		buffer := buf.NewPacket()
-		sniffMetadata, err := sniff.PeekStream(ctx, conn, buffer, time.Duration(metadata.InboundOptions.SniffTimeout), sniff.StreamDomainNameQuery, sniff.TLSClientHello, sniff.HTTPHost)
-		if sniffMetadata != nil {
-			metadata.Protocol = sniffMetadata.Protocol
-			metadata.Domain = sniffMetadata.Domain
-			if metadata.InboundOptions.SniffOverrideDestination && M.IsDomainName(metadata.Domain) {
-				metadata.Destination = M.Socksaddr{
-					Fqdn: metadata.Domain,
-					Port: metadata.Destination.Port,
-				}
-			}
-			if metadata.Domain != "" {
-				r.logger.DebugContext(ctx, "sniffed protocol: ", metadata.Protocol, ", domain: ", metadata.Domain)
-			} else {
-				r.logger.DebugContext(ctx, "sniffed protocol: ", metadata.Protocol)
-			}
-		} else if err != nil {
-			r.logger.TraceContext(ctx, "sniffed no protocol: ", err)
-		}
-		if !buffer.IsEmpty() {
-			conn = bufio.NewCachedConn(conn, buffer)
-		} else {
-			buffer.Release()
-		}
+		conn = r.sniffConnection(ctx, conn, &metadata, time.Duration(metadata.InboundOptions.SniffTimeout), sniff.StreamDomainNameQuery, sniff.TLSClientHello, sniff.HTTPHost)
 	}
 
 	if r.dnsReverseMapping != nil && metadata.Domain == "" {
@@ -890,24 +1077,62 @@ func (r *Router) RouteConnection(ctx context.Context, conn net.Conn, metadata ad
 	} else if metadata.Destination.IsIPv6() {
 		metadata.IPVersion = 6
 	}
-	ctx, matchedRule, detour, err := r.match(ctx, &metadata, r.defaultOutboundForConnection)
+	r.access.RLock()
+	defaultOutbound := r.defaultOutboundForConnection
+	r.access.RUnlock()
+	state := &routeState{conn: conn}
+	ctx, err := r.match(ctx, &metadata, defaultOutbound, state)
 	if err != nil {
 		return err
 	}
+	conn = state.conn
+	switch action := state.action.(type) {
+	case *RuleActionReject:
+		return r.rejectConnection(ctx, conn, metadata, action)
+	case *RuleActionHijackDNS:
+		return r.dnsHijacker.NewConnection(ctx, conn, metadata)
+	}
+	matchedRule, detour := state.rule, state.outbound
 	if !common.Contains(detour.Network(), N.NetworkTCP) {
 		return E.New("missing supported outbound, closing connection")
 	}
+	if r.userLimiter != nil {
+		limitedConn, tracker, err := r.userLimiter.RoutedConnection(ctx, conn, metadata)
+		if err != nil {
+			return err
+		}
+		defer tracker.Leave()
+		conn = limitedConn
+	}
+	var finishTrackers []adapter.FinishTracker
 	if r.clashServer != nil {
 		trackerConn, tracker := r.clashServer.RoutedConnection(ctx, conn, metadata, matchedRule)
 		defer tracker.Leave()
 		conn = trackerConn
+		if finishTracker, isFinishTracker := tracker.(adapter.FinishTracker); isFinishTracker {
+			finishTrackers = append(finishTrackers, finishTracker)
+		}
 	}
 	if r.v2rayServer != nil {
 		if statsService := r.v2rayServer.StatsService(); statsService != nil {
 			conn = statsService.RoutedConnection(metadata.Inbound, detour.Tag(), metadata.User, conn)
 		}
 	}
-	return detour.NewConnection(ctx, conn, metadata)
+	if r.metricsServer != nil {
+		trackerConn, tracker := r.metricsServer.RoutedConnection(ctx, conn, metadata, detour.Tag())
+		defer tracker.Leave()
+		conn = trackerConn
+	}
+	if r.accessLogger != nil {
+		trackerConn, tracker := r.accessLogger.RoutedConnection(ctx, conn, metadata, matchedRule, detour)
+		conn = trackerConn
+		finishTrackers = append(finishTrackers, tracker)
+	}
+	err = detour.NewConnection(ctx, conn, metadata)
+	for _, tracker := range finishTrackers {
+		tracker.Finish(err)
+	}
+	return err
 }
 
 func (r *Router) RoutePacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
@@ -918,7 +1143,9 @@ func (r *Router) RoutePacketConnection(ctx context.Context, conn N.PacketConn, m
 		if metadata.LastInbound == metadata.InboundDetour {
 			return E.New("routing loop on detour: ", metadata.InboundDetour)
 		}
+		r.access.RLock()
 		detour := r.inboundByTag[metadata.InboundDetour]
+		r.access.RUnlock()
 		if detour == nil {
 			return E.New("inbound detour not found: ", metadata.InboundDetour)
 		}
@@ -973,19 +1200,7 @@ func (r *Router) RoutePacketConnection(ctx context.Context, conn N.PacketConn, m
 		if metadata.InboundOptions.SniffEnabled {
 			sniffMetadata, _ := sniff.PeekPacket(ctx, buffer.Bytes(), sniff.DomainNameQuery, sniff.QUICClientHello, sniff.STUNMessage)
 			if sniffMetadata != nil {
-				metadata.Protocol = sniffMetadata.Protocol
-				metadata.Domain = sniffMetadata.Domain
-				if metadata.InboundOptions.SniffOverrideDestination && M.IsDomainName(metadata.Domain) {
-					metadata.Destination = M.Socksaddr{
-						Fqdn: metadata.Domain,
-						Port: metadata.Destination.Port,
-					}
-				}
-				if metadata.Domain != "" {
-					r.logger.DebugContext(ctx, "sniffed packet protocol: ", metadata.Protocol, ", domain: ", metadata.Domain)
-				} else {
-					r.logger.DebugContext(ctx, "sniffed packet protocol: ", metadata.Protocol)
-				}
+				r.applySniffResult(ctx, &metadata, sniffMetadata, true)
 			}
 		}
 		conn = bufio.NewCachedPacketConn(conn, buffer, destination)
@@ -1010,42 +1225,86 @@ func (r *Router) RoutePacketConnection(ctx context.Context, conn N.PacketConn, m
 	} else if metadata.Destination.IsIPv6() {
 		metadata.IPVersion = 6
 	}
-	ctx, matchedRule, detour, err := r.match(ctx, &metadata, r.defaultOutboundForPacketConnection)
+	r.access.RLock()
+	defaultOutbound := r.defaultOutboundForPacketConnection
+	r.access.RUnlock()
+	state := &routeState{packetConn: conn}
+	ctx, err := r.match(ctx, &metadata, defaultOutbound, state)
 	if err != nil {
 		return err
 	}
+	conn = state.packetConn
+	switch action := state.action.(type) {
+	case *RuleActionReject:
+		return r.rejectPacketConnection(ctx, conn, metadata, action)
+	case *RuleActionHijackDNS:
+		return r.dnsHijacker.NewPacketConnection(ctx, conn, metadata)
+	}
+	matchedRule, detour := state.rule, state.outbound
 	if !common.Contains(detour.Network(), N.NetworkUDP) {
 		return E.New("missing supported outbound, closing packet connection")
 	}
+	if r.userLimiter != nil {
+		limitedConn, tracker, err := r.userLimiter.RoutedPacketConnection(ctx, conn, metadata)
+		if err != nil {
+			return err
+		}
+		defer tracker.Leave()
+		conn = limitedConn
+	}
+	var finishTrackers []adapter.FinishTracker
 	if r.clashServer != nil {
 		trackerConn, tracker := r.clashServer.RoutedPacketConnection(ctx, conn, metadata, matchedRule)
 		defer tracker.Leave()
 		conn = trackerConn
+		if finishTracker, isFinishTracker := tracker.(adapter.FinishTracker); isFinishTracker {
+			finishTrackers = append(finishTrackers, finishTracker)
+		}
 	}
 	if r.v2rayServer != nil {
 		if statsService := r.v2rayServer.StatsService(); statsService != nil {
 			conn = statsService.RoutedPacketConnection(metadata.Inbound, detour.Tag(), metadata.User, conn)
 		}
 	}
+	if r.metricsServer != nil {
+		trackerConn, tracker := r.metricsServer.RoutedPacketConnection(ctx, conn, metadata, detour.Tag())
+		defer tracker.Leave()
+		conn = trackerConn
+	}
 	if metadata.FakeIP {
 		conn = bufio.NewNATPacketConn(bufio.NewNetPacketConn(conn), metadata.OriginDestination, metadata.Destination)
 	}
-	return detour.NewPacketConnection(ctx, conn, metadata)
+	if r.accessLogger != nil {
+		trackerConn, tracker := r.accessLogger.RoutedPacketConnection(ctx, conn, metadata, matchedRule, detour)
+		conn = trackerConn
+		finishTrackers = append(finishTrackers, tracker)
+	}
+	err = detour.NewPacketConnection(ctx, conn, metadata)
+	for _, tracker := range finishTrackers {
+		tracker.Finish(err)
+	}
+	return err
 }
 
-func (r *Router) match(ctx context.Context, metadata *adapter.InboundContext, defaultOutbound adapter.Outbound) (context.Context, adapter.Rule, adapter.Outbound, error) {
-	matchRule, matchOutbound := r.match0(ctx, metadata, defaultOutbound)
+func (r *Router) match(ctx context.Context, metadata *adapter.InboundContext, defaultOutbound adapter.Outbound, state *routeState) (context.Context, error) {
+	err := r.match0(ctx, metadata, defaultOutbound, state)
+	if err != nil {
+		return nil, err
+	}
+	if state.outbound == nil {
+		return ctx, nil
+	}
 	if contextOutbound, loaded := outbound.TagFromContext(ctx); loaded {
-		if contextOutbound == matchOutbound.Tag() {
-			return nil, nil, nil, E.New("connection loopback in outbound/", matchOutbound.Type(), "[", matchOutbound.Tag(), "]")
+		if contextOutbound == state.outbound.Tag() {
+			return nil, E.New("connection loopback in outbound/", state.outbound.Type(), "[", state.outbound.Tag(), "]")
 		}
 	}
-	ctx = outbound.ContextWithTag(ctx, matchOutbound.Tag())
-	return ctx, matchRule, matchOutbound, nil
+	ctx = outbound.ContextWithTag(ctx, state.outbound.Tag())
+	return ctx, nil
 }
 
-func (r *Router) match0(ctx context.Context, metadata *adapter.InboundContext, defaultOutbound adapter.Outbound) (adapter.Rule, adapter.Outbound) {
-	if r.processSearcher != nil {
+func (r *Router) match0(ctx context.Context, metadata *adapter.InboundContext, defaultOutbound adapter.Outbound, state *routeState) error {
+	if r.processSearcher != nil && state.trace == nil {
 		var originDestination netip.AddrPort
 		if metadata.OriginDestination.IsValid() {
 			originDestination = metadata.OriginDestination.AddrPort()
@@ -1076,18 +1335,63 @@ func (r *Router) match0(ctx context.Context, metadata *adapter.InboundContext, d
 			metadata.ProcessInfo = processInfo
 		}
 	}
-	for i, rule := range r.rules {
+	r.access.RLock()
+	rules := r.rules
+	r.access.RUnlock()
+	var routeOptions RouteActionOptions
+	for i, rule := range rules {
 		metadata.ResetRuleCache()
-		if rule.Match(metadata) {
-			detour := rule.Outbound()
-			r.logger.DebugContext(ctx, "match[", i, "] ", rule.String(), " => ", detour)
-			if outbound, loaded := r.Outbound(detour); loaded {
-				return rule, outbound
+		matched := rule.Match(metadata)
+		if state.trace != nil {
+			state.trace.Rules = append(state.trace.Rules, traceRule(i, rule, *metadata, matched))
+		}
+		if !matched {
+			continue
+		}
+		r.logger.DebugContext(ctx, "match[", i, "] ", rule.String(), " => ", rule.Action())
+		switch action := rule.Action().(type) {
+		case *RuleActionRoute:
+			detour, loaded := r.Outbound(action.Outbound)
+			if !loaded {
+				r.logger.ErrorContext(ctx, "outbound not found: ", action.Outbound)
+				state.traceNote("outbound not found")
+				continue
+			}
+			routeOptions.merge(action.RouteActionOptions)
+			routeOptions.apply(metadata)
+			state.rule = rule
+			state.action = action
+			state.outbound = detour
+			return nil
+		case *RuleActionRouteOptions:
+			routeOptions.merge(action.RouteActionOptions)
+		case *RuleActionSniff:
+			if state.trace != nil {
+				state.traceNote("sniff skipped")
+				continue
+			}
+			err := r.actionSniff(ctx, metadata, action, state)
+			if err != nil {
+				return err
+			}
+		case *RuleActionResolve:
+			if state.trace != nil {
+				state.traceNote("resolve skipped")
+				continue
 			}
-			r.logger.ErrorContext(ctx, "outbound not found: ", detour)
+			err := r.actionResolve(ctx, metadata, action)
+			if err != nil {
+				return err
+			}
+		default:
+			state.rule = rule
+			state.action = action
+			return nil
 		}
 	}
-	return nil, defaultOutbound
+	routeOptions.apply(metadata)
+	state.outbound = defaultOutbound
+	return nil
 }
 
 func (r *Router) InterfaceFinder() control.InterfaceFinder {
@@ -1148,6 +1452,8 @@ func (r *Router) DefaultMark() int {
 }
 
 func (r *Router) Rules() []adapter.Rule {
+	r.access.RLock()
+	defer r.access.RUnlock()
 	return r.rules
 }
 
@@ -1183,6 +1489,22 @@ func (r *Router) SetV2RayServer(server adapter.V2RayServer) {
 	r.v2rayServer = server
 }
 
+func (r *Router) MetricsServer() adapter.MetricsServer {
+	return r.metricsServer
+}
+
+func (r *Router) SetMetricsServer(server adapter.MetricsServer) {
+	r.metricsServer = server
+}
+
+func (r *Router) AccessLogger() adapter.AccessLogger {
+	return r.accessLogger
+}
+
+func (r *Router) SetAccessLogger(logger adapter.AccessLogger) {
+	r.accessLogger = logger
+}
+
 func (r *Router) OnPackagesUpdated(packages int, sharedUsers int) {
 	r.logger.Info("updated packages list: ", packages, " packages, ", sharedUsers, " shared users")
 }
@@ -1225,14 +1547,29 @@ func (r *Router) notifyNetworkUpdate(event int) {
 func (r *Router) ResetNetwork() error {
 	conntrack.Close()
 
-	for _, outbound := range r.outbounds {
+	r.access.RLock()
+	outbounds := r.outbounds
+	outboundProviders := r.outboundProviders
+	transports := r.transports
+	r.access.RUnlock()
+
+	for _, outbound := range outbounds {
 		listener, isListener := outbound.(adapter.InterfaceUpdateListener)
 		if isListener {
 			listener.InterfaceUpdated()
 		}
 	}
 
-	for _, transport := range r.transports {
+	for _, provider := range outboundProviders {
+		for _, outbound := range provider.Outbounds() {
+			listener, isListener := outbound.(adapter.InterfaceUpdateListener)
+			if isListener {
+				listener.InterfaceUpdated()
+			}
+		}
+	}
+
+	for _, transport := range transports {
 		transport.Reset()
 	}
 	return nil
@@ -42,8 +42,13 @@ func (r *Router) matchDNS(ctx context.Context, allowFakeIP bool, index int) (con
 	if metadata == nil {
 		panic("no context")
 	}
-	if index < len(r.dnsRules) {
-		dnsRules := r.dnsRules
+	r.access.RLock()
+	dnsRules := r.dnsRules
+	transportMap := r.transportMap
+	transportDomainStrategy := r.transportDomainStrategy
+	defaultTransport := r.defaultTransport
+	r.access.RUnlock()
+	if index < len(dnsRules) {
 		if index != -1 {
 			dnsRules = dnsRules[index+1:]
 		}
@@ -51,7 +56,7 @@ func (r *Router) matchDNS(ctx context.Context, allowFakeIP bool, index int) (con
 			metadata.ResetRuleCache()
 			if rule.Match(metadata) {
 				detour := rule.Outbound()
-				transport, loaded := r.transportMap[detour]
+				transport, loaded := transportMap[detour]
 				if !loaded {
 					r.dnsLogger.ErrorContext(ctx, "transport not found: ", detour)
 					continue
@@ -74,7 +79,7 @@ func (r *Router) matchDNS(ctx context.Context, allowFakeIP bool, index int) (con
 				if clientSubnet := rule.ClientSubnet(); clientSubnet != nil {
 					ctx = dns.ContextWithClientSubnet(ctx, *clientSubnet)
 				}
-				if domainStrategy, dsLoaded := r.transportDomainStrategy[transport]; dsLoaded {
+				if domainStrategy, dsLoaded := transportDomainStrategy[transport]; dsLoaded {
 					return ctx, transport, domainStrategy, rule, ruleIndex
 				} else {
 					return ctx, transport, r.defaultDomainStrategy, rule, ruleIndex
@@ -82,10 +87,10 @@ func (r *Router) matchDNS(ctx context.Context, allowFakeIP bool, index int) (con
 			}
 		}
 	}
-	if domainStrategy, dsLoaded := r.transportDomainStrategy[r.defaultTransport]; dsLoaded {
-		return ctx, r.defaultTransport, domainStrategy, nil, -1
+	if domainStrategy, dsLoaded := transportDomainStrategy[defaultTransport]; dsLoaded {
+		return ctx, defaultTransport, domainStrategy, nil, -1
 	} else {
-		return ctx, r.defaultTransport, r.defaultDomainStrategy, nil, -1
+		return ctx, defaultTransport, r.defaultDomainStrategy, nil, -1
 	}
 }
 
@@ -100,60 +105,15 @@ func (r *Router) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, er
 		err       error
 	)
 	response, cached = r.dnsClient.ExchangeCache(ctx, message)
+	if !cached && r.dnsCache != nil {
+		response, cached = r.dnsCache.Exchange(ctx, message, func(ctx context.Context, message *mDNS.Msg) {
+			r.exchange(ctx, message)
+		})
+	}
 	if !cached {
-		var metadata *adapter.InboundContext
-		ctx, metadata = adapter.AppendContext(ctx)
-		if len(message.Question) > 0 {
-			metadata.QueryType = message.Question[0].Qtype
-			switch metadata.QueryType {
-			case mDNS.TypeA:
-				metadata.IPVersion = 4
-			case mDNS.TypeAAAA:
-				metadata.IPVersion = 6
-			}
-			metadata.Domain = fqdnToDomain(message.Question[0].Name)
-		}
-		var (
-			strategy  dns.DomainStrategy
-			rule      adapter.DNSRule
-			ruleIndex int
-		)
-		ruleIndex = -1
-		for {
-			var (
-				dnsCtx       context.Context
-				cancel       context.CancelFunc
-				addressLimit bool
-			)
-
-			dnsCtx, transport, strategy, rule, ruleIndex = r.matchDNS(ctx, true, ruleIndex)
-			dnsCtx, cancel = context.WithTimeout(dnsCtx, C.DNSTimeout)
-			if rule != nil && rule.WithAddressLimit() && isAddressQuery(message) {
-				addressLimit = true
-				response, err = r.dnsClient.ExchangeWithResponseCheck(dnsCtx, transport, message, strategy, func(response *mDNS.Msg) bool {
-					metadata.DestinationAddresses, _ = dns.MessageToAddresses(response)
-					return rule.MatchAddressLimit(metadata)
-				})
-			} else {
-				addressLimit = false
-				response, err = r.dnsClient.Exchange(dnsCtx, transport, message, strategy)
-			}
-			cancel()
-			if err != nil {
-				if errors.Is(err, dns.ErrResponseRejectedCached) {
-					r.dnsLogger.DebugContext(ctx, E.Cause(err, "response rejected for ", formatQuestion(message.Question[0].String())), " (cached)")
-				} else if errors.Is(err, dns.ErrResponseRejected) {
-					r.dnsLogger.DebugContext(ctx, E.Cause(err, "response rejected for ", formatQuestion(message.Question[0].String())))
-				} else if len(message.Question) > 0 {
-					r.dnsLogger.ErrorContext(ctx, E.Cause(err, "exchange failed for ", formatQuestion(message.Question[0].String())))
-				} else {
-					r.dnsLogger.ErrorContext(ctx, E.Cause(err, "exchange failed for <empty query>"))
-				}
-			}
-			if !addressLimit || err == nil {
-				break
-			}
-		}
+		response, transport, err = r.exchange(ctx, message)
+	} else if r.metricsServer != nil {
+		r.metricsServer.DNSCacheHit()
 	}
 	if err != nil {
 		return nil, err
@@ -173,20 +133,98 @@ func (r *Router) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, er
 	return response, nil
 }
 
-func (r *Router) Lookup(ctx context.Context, domain string, strategy dns.DomainStrategy) ([]netip.Addr, error) {
+func (r *Router) exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, dns.Transport, error) {
+	var (
+		response  *mDNS.Msg
+		transport dns.Transport
+		err       error
+	)
+	ctx, metadata := adapter.AppendContext(ctx)
+	if len(message.Question) > 0 {
+		metadata.QueryType = message.Question[0].Qtype
+		switch metadata.QueryType {
+		case mDNS.TypeA:
+			metadata.IPVersion = 4
+		case mDNS.TypeAAAA:
+			metadata.IPVersion = 6
+		}
+		metadata.Domain = fqdnToDomain(message.Question[0].Name)
+	}
 	var (
-		responseAddrs []netip.Addr
-		cached        bool
-		err           error
+		strategy  dns.DomainStrategy
+		rule      adapter.DNSRule
+		ruleIndex int
 	)
-	responseAddrs, cached = r.dnsClient.LookupCache(ctx, domain, strategy)
+	ruleIndex = -1
+	for {
+		var (
+			dnsCtx       context.Context
+			cancel       context.CancelFunc
+			addressLimit bool
+		)
+
+		dnsCtx, transport, strategy, rule, ruleIndex = r.matchDNS(ctx, true, ruleIndex)
+		dnsCtx, cancel = context.WithTimeout(dnsCtx, C.DNSTimeout)
+		queryStart := time.Now()
+		if rule != nil && rule.WithAddressLimit() && isAddressQuery(message) {
+			addressLimit = true
+			response, err = r.dnsClient.ExchangeWithResponseCheck(dnsCtx, transport, message, strategy, func(response *mDNS.Msg) bool {
+				metadata.DestinationAddresses, _ = dns.MessageToAddresses(response)
+				return rule.MatchAddressLimit(metadata)
+			})
+		} else {
+			addressLimit = false
+			response, err = r.dnsClient.Exchange(dnsCtx, transport, message, strategy)
+		}
+		cancel()
+		r.recordDNSQuery(transport, queryStart, err)
+		if err != nil {
+			if errors.Is(err, dns.ErrResponseRejectedCached) {
+				r.dnsLogger.DebugContext(ctx, E.Cause(err, "response rejected for ", formatQuestion(message.Question[0].String())), " (cached)")
+			} else if errors.Is(err, dns.ErrResponseRejected) {
+				r.dnsLogger.DebugContext(ctx, E.Cause(err, "response rejected for ", formatQuestion(message.Question[0].String())))
+			} else if len(message.Question) > 0 {
+				r.dnsLogger.ErrorContext(ctx, E.Cause(err, "exchange failed for ", formatQuestion(message.Question[0].String())))
+			} else {
+				r.dnsLogger.ErrorContext(ctx, E.Cause(err, "exchange failed for <empty query>"))
+			}
+		}
+		if !addressLimit || err == nil {
+			break
+		}
+	}
+	if err == nil && r.dnsCache != nil && len(message.Question) == 1 {
+		_, isFakeIP := transport.(adapter.FakeIPTransport)
+		if !isFakeIP && (rule == nil || !rule.DisableCache()) && !dns.DisableCacheFromContext(ctx) {
+			r.dnsCache.StoreExchange(message.Question[0], response)
+		}
+	}
+	return response, transport, err
+}
+
+func (r *Router) Lookup(ctx context.Context, domain string, strategy dns.DomainStrategy) ([]netip.Addr, error) {
+	responseAddrs, cached := r.dnsClient.LookupCache(ctx, domain, strategy)
+	if !cached && r.dnsCache != nil {
+		responseAddrs, cached = r.dnsCache.Lookup(ctx, domain, strategy, func(ctx context.Context) {
+			r.lookup(ctx, domain, strategy)
+		})
+	}
 	if cached {
+		if r.metricsServer != nil {
+			r.metricsServer.DNSCacheHit()
+		}
 		return responseAddrs, nil
 	}
+	return r.lookup(ctx, domain, strategy)
+}
+
+func (r *Router) lookup(ctx context.Context, domain string, strategy dns.DomainStrategy) ([]netip.Addr, error) {
 	r.dnsLogger.DebugContext(ctx, "lookup domain ", domain)
 	ctx, metadata := adapter.AppendContext(ctx)
 	metadata.Domain = domain
 	var (
+		responseAddrs     []netip.Addr
+		err               error
 		transport         dns.Transport
 		transportStrategy dns.DomainStrategy
 		rule              adapter.DNSRule
@@ -206,6 +244,7 @@ func (r *Router) Lookup(ctx context.Context, domain string, strategy dns.DomainS
 			strategy = transportStrategy
 		}
 		dnsCtx, cancel = context.WithTimeout(dnsCtx, C.DNSTimeout)
+		queryStart := time.Now()
 		if rule != nil && rule.WithAddressLimit() {
 			addressLimit = true
 			responseAddrs, err = r.dnsClient.LookupWithResponseCheck(dnsCtx, transport, domain, strategy, func(responseAddrs []netip.Addr) bool {
@@ -217,6 +256,7 @@ func (r *Router) Lookup(ctx context.Context, domain string, strategy dns.DomainS
 			responseAddrs, err = r.dnsClient.Lookup(dnsCtx, transport, domain, strategy)
 		}
 		cancel()
+		r.recordDNSQuery(transport, queryStart, err)
 		if err != nil {
 			if errors.Is(err, dns.ErrResponseRejectedCached) {
 				r.dnsLogger.DebugContext(ctx, "response rejected for ", domain, " (cached)")
@@ -236,6 +276,9 @@ func (r *Router) Lookup(ctx context.Context, domain string, strategy dns.DomainS
 	if len(responseAddrs) > 0 {
 		r.dnsLogger.InfoContext(ctx, "lookup succeed for ", domain, ": ", strings.Join(F.MapToString(responseAddrs), " "))
 	}
+	if err == nil && r.dnsCache != nil && (rule == nil || !rule.DisableCache()) {
+		r.dnsCache.StoreLookup(r.dnsClient, ctx, domain, strategy)
+	}
 	return responseAddrs, err
 }
 
@@ -245,11 +288,21 @@ func (r *Router) LookupDefault(ctx context.Context, domain string) ([]netip.Addr
 
 func (r *Router) ClearDNSCache() {
 	r.dnsClient.ClearCache()
+	if r.dnsCache != nil {
+		r.dnsCache.Clear()
+	}
 	if r.platformInterface != nil {
 		r.platformInterface.ClearDNSCache()
 	}
 }
 
+func (r *Router) recordDNSQuery(transport dns.Transport, queryStart time.Time, err error) {
+	if r.metricsServer == nil || errors.Is(err, dns.ErrResponseRejectedCached) {
+		return
+	}
+	r.metricsServer.DNSQuery(transport.Name(), time.Since(queryStart), err)
+}
+
 func isAddressQuery(message *mDNS.Msg) bool {
 	for _, question := range message.Question {
 		if question.Qtype == mDNS.TypeA || question.Qtype == mDNS.TypeAAAA {
@@ -0,0 +1,250 @@
+package route
+
+import (
+	"context"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-dns"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/task"
+)
+
+var ErrRestartRequired = E.New("restart required")
+
+func (r *Router) Reload(options option.RouteOptions, dnsOptions option.DNSOptions, reloadedOutbounds map[string]bool) error {
+	if !r.needFindProcess && (hasRule(options.Rules, isProcessRule) || hasDNSRule(dnsOptions.Rules, isProcessDNSRule) || options.FindProcess) {
+		return E.Cause(ErrRestartRequired, "process rules added")
+	}
+	if !r.needWIFIState && (hasRule(options.Rules, isWIFIRule) || hasDNSRule(dnsOptions.Rules, isWIFIDNSRule)) {
+		return E.Cause(ErrRestartRequired, "WIFI rules added")
+	}
+	rules := make([]adapter.Rule, 0, len(options.Rules))
+	for i, ruleOptions := range options.Rules {
+		routeRule, err := NewRule(r, r.logger, ruleOptions, true)
+		if err != nil {
+			return E.Cause(err, "parse rule[", i, "]")
+		}
+		rules = append(rules, routeRule)
+	}
+	err := r.checkRuleOutbounds(rules)
+	if err != nil {
+		return err
+	}
+	dnsRules := make([]adapter.DNSRule, 0, len(dnsOptions.Rules))
+	for i, dnsRuleOptions := range dnsOptions.Rules {
+		dnsRule, err := NewDNSRule(r, r.logger, dnsRuleOptions, true)
+		if err != nil {
+			return E.Cause(err, "parse dns rule[", i, "]")
+		}
+		dnsRules = append(dnsRules, dnsRule)
+	}
+
+	r.access.RLock()
+	lastRules := r.rules
+	lastDNSRules := r.dnsRules
+	lastRuleSets := &ruleSetState{
+		ruleSets:       r.ruleSets,
+		ruleSetMap:     r.ruleSetMap,
+		ruleSetOptions: r.ruleSetOptions,
+	}
+	lastTransports := &dnsTransportState{
+		defaultTransport:        r.defaultTransport,
+		transports:              r.transports,
+		transportMap:            r.transportMap,
+		transportByTag:          r.transportByTag,
+		transportOptions:        r.transportOptions,
+		transportDomainStrategy: r.transportDomainStrategy,
+	}
+	r.access.RUnlock()
+
+	ctx := adapter.ContextWithRouter(r.ctx, r)
+	ruleSets, err := r.newRuleSets(ctx, options.RuleSet, lastRuleSets, reloadedOutbounds)
+	if err != nil {
+		return err
+	}
+	newRuleSets := common.Filter(ruleSets.ruleSets, func(it adapter.RuleSet) bool {
+		return !common.Contains(lastRuleSets.ruleSets, it)
+	})
+	transports, err := r.newDNSTransports(ctx, dnsOptions, lastTransports, reloadedOutbounds)
+	if err != nil {
+		for _, ruleSet := range newRuleSets {
+			ruleSet.Close()
+		}
+		return err
+	}
+	newTransports := common.Filter(transports.transports, func(it dns.Transport) bool {
+		return !common.Contains(lastTransports.transports, it)
+	})
+	err = checkRuleDNSServers(rules, transports.transportMap)
+	if err != nil {
+		for _, ruleSet := range newRuleSets {
+			ruleSet.Close()
+		}
+		for _, transport := range newTransports {
+			transport.Close()
+		}
+		return err
+	}
+	needGeoIPDatabase := hasRule(options.Rules, isGeoIPRule) || hasDNSRule(dnsOptions.Rules, isGeoIPDNSRule)
+	needGeositeDatabase := hasRule(options.Rules, isGeositeRule) || hasDNSRule(dnsOptions.Rules, isGeositeDNSRule)
+	err = r.startReloaded(rules, dnsRules, ruleSets, newRuleSets, newTransports, needGeoIPDatabase, needGeositeDatabase)
+	if err != nil {
+		r.access.Lock()
+		r.ruleSetMap = lastRuleSets.ruleSetMap
+		r.access.Unlock()
+		for _, rule := range rules {
+			rule.Close()
+		}
+		for _, rule := range dnsRules {
+			rule.Close()
+		}
+		for _, ruleSet := range newRuleSets {
+			ruleSet.Close()
+		}
+		for _, transport := range newTransports {
+			transport.Close()
+		}
+		return err
+	}
+
+	r.access.Lock()
+	r.rules = rules
+	r.dnsRules = dnsRules
+	r.defaultTransport = transports.defaultTransport
+	r.transports = transports.transports
+	r.transportMap = transports.transportMap
+	r.transportByTag = transports.transportByTag
+	r.transportOptions = transports.transportOptions
+	r.transportDomainStrategy = transports.transportDomainStrategy
+	r.ruleSets = ruleSets.ruleSets
+	r.ruleSetOptions = ruleSets.ruleSetOptions
+	r.access.Unlock()
+	r.ClearDNSCache()
+
+	for i, rule := range lastRules {
+		err = rule.Close()
+		if err != nil {
+			r.logger.Error(E.Cause(err, "close rule[", i, "]"))
+		}
+	}
+	for i, rule := range lastDNSRules {
+		err = rule.Close()
+		if err != nil {
+			r.logger.Error(E.Cause(err, "close dns rule[", i, "]"))
+		}
+	}
+	for _, ruleSet := range lastRuleSets.ruleSets {
+		if common.Contains(ruleSets.ruleSets, ruleSet) {
+			continue
+		}
+		err = ruleSet.Close()
+		if err != nil {
+			r.logger.Error(E.Cause(err, "close rule-set"))
+		}
+	}
+	for _, transport := range lastTransports.transports {
+		if common.Contains(transports.transports, transport) {
+			continue
+		}
+		err = transport.Close()
+		if err != nil {
+			r.logger.Error(E.Cause(err, "close dns transport[", transport.Name(), "]"))
+		}
+	}
+	if r.started {
+		for _, ruleSet := range newRuleSets {
+			err = ruleSet.PostStart()
+			if err != nil {
+				r.logger.Error(E.Cause(err, "post start rule-set"))
+			}
+		}
+	}
+	r.logger.Info("reloaded ", len(rules), " rules, ", len(dnsRules), " dns rules, ", len(newRuleSets), " rule-sets and ", len(newTransports), " dns servers")
+	return nil
+}
+
+func (r *Router) startReloaded(rules []adapter.Rule, dnsRules []adapter.DNSRule, ruleSets *ruleSetState, newRuleSets []adapter.RuleSet, newTransports []dns.Transport, needGeoIPDatabase bool, needGeositeDatabase bool) error {
+	if len(newRuleSets) > 0 {
+		ruleSetStartContext := NewRuleSetStartContext()
+		var ruleSetStartGroup task.Group
+		for _, ruleSet := range newRuleSets {
+			ruleSetInPlace := ruleSet
+			ruleSetStartGroup.Append0(func(ctx context.Context) error {
+				return ruleSetInPlace.StartContext(ctx, ruleSetStartContext)
+			})
+		}
+		ruleSetStartGroup.Concurrency(5)
+		ruleSetStartGroup.FastFail()
+		err := ruleSetStartGroup.Run(r.ctx)
+		ruleSetStartContext.Close()
+		if err != nil {
+			return E.Cause(err, "initialize rule-set")
+		}
+	}
+	for _, ruleSet := range ruleSets.ruleSets {
+		metadata := ruleSet.Metadata()
+		if metadata.ContainsProcessRule && !r.needFindProcess && r.processSearcher == nil {
+			return E.Cause(ErrRestartRequired, "process rules added")
+		}
+		if metadata.ContainsWIFIRule && !r.needWIFIState {
+			return E.Cause(ErrRestartRequired, "WIFI rules added")
+		}
+	}
+	r.access.Lock()
+	r.ruleSetMap = ruleSets.ruleSetMap
+	r.access.Unlock()
+
+	if needGeoIPDatabase && r.geoIPReader == nil {
+		err := r.prepareGeoIPDatabase()
+		if err != nil {
+			return err
+		}
+	}
+	if needGeositeDatabase {
+		r.geositeCache = make(map[string]adapter.Rule)
+		err := r.prepareGeositeDatabase()
+		if err != nil {
+			return err
+		}
+		for _, rule := range rules {
+			err = rule.UpdateGeosite()
+			if err != nil {
+				r.logger.Error("failed to initialize geosite: ", err)
+			}
+		}
+		for _, rule := range dnsRules {
+			err = rule.UpdateGeosite()
+			if err != nil {
+				r.logger.Error("failed to initialize geosite: ", err)
+			}
+		}
+		err = common.Close(r.geositeReader)
+		if err != nil {
+			return err
+		}
+		r.geositeCache = nil
+		r.geositeReader = nil
+	}
+
+	for i, rule := range rules {
+		err := rule.Start()
+		if err != nil {
+			return E.Cause(err, "initialize rule[", i, "]")
+		}
+	}
+	for i, rule := range dnsRules {
+		err := rule.Start()
+		if err != nil {
+			return E.Cause(err, "initialize DNS rule[", i, "]")
+		}
+	}
+	for _, transport := range newTransports {
+		err := transport.Start()
+		if err != nil {
+			return E.Cause(err, "initialize DNS server[", transport.Name(), "]")
+		}
+	}
+	return nil
+}
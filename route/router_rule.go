@@ -1,9 +1,26 @@
 package route
 
 import (
+	"context"
+	"io"
+	"net"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/sniff"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-dns"
 	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
 )
 
 func hasRule(rules []option.Rule, cond func(rule option.DefaultRule) bool) bool {
@@ -79,7 +96,7 @@ func isProcessDNSRule(rule option.DefaultDNSRule) bool {
 }
 
 func isProcessHeadlessRule(rule option.DefaultHeadlessRule) bool {
-	return len(rule.ProcessName) > 0 || len(rule.ProcessPath) > 0 || len(rule.PackageName) > 0
+	return len(rule.ProcessName) > 0 || len(rule.ProcessPath) > 0 || len(rule.PackageName) > 0 || len(rule.User) > 0 || len(rule.UserID) > 0
 }
 
 func notPrivateNode(code string) bool {
@@ -99,5 +116,178 @@ func isWIFIHeadlessRule(rule option.DefaultHeadlessRule) bool {
 }
 
 func isIPCIDRHeadlessRule(rule option.DefaultHeadlessRule) bool {
-	return len(rule.IPCIDR) > 0 || rule.IPSet != nil
+	return len(rule.IPCIDR) > 0 || rule.IPSet != nil || rule.IPIsPrivate
+}
+
+type routeState struct {
+	conn       net.Conn
+	packetConn N.PacketConn
+	rule       adapter.Rule
+	action     adapter.RuleAction
+	outbound   adapter.Outbound
+	trace      *adapter.RouteTrace
+}
+
+func (s *routeState) traceNote(note string) {
+	if s.trace != nil {
+		s.trace.Rules[len(s.trace.Rules)-1].Note = note
+	}
+}
+
+func (r *Router) sniffConnection(ctx context.Context, conn net.Conn, metadata *adapter.InboundContext, timeout time.Duration, sniffers ...sniff.StreamSniffer) net.Conn {
+	buffer := buf.NewPacket()
+	sniffMetadata, err := sniff.PeekStream(ctx, conn, buffer, timeout, sniffers...)
+	if sniffMetadata != nil {
+		r.applySniffResult(ctx, metadata, sniffMetadata, false)
+	} else if err != nil {
+		r.logger.TraceContext(ctx, "sniffed no protocol: ", err)
+	}
+	if !buffer.IsEmpty() {
+		return bufio.NewCachedConn(conn, buffer)
+	}
+	buffer.Release()
+	return conn
+}
+
+func (r *Router) sniffPacketConnection(ctx context.Context, conn N.PacketConn, metadata *adapter.InboundContext, sniffers ...sniff.PacketSniffer) (N.PacketConn, error) {
+	buffer := buf.NewPacket()
+	destination, err := conn.ReadPacket(buffer)
+	if err != nil {
+		buffer.Release()
+		return nil, err
+	}
+	sniffMetadata, _ := sniff.PeekPacket(ctx, buffer.Bytes(), sniffers...)
+	if sniffMetadata != nil {
+		r.applySniffResult(ctx, metadata, sniffMetadata, true)
+	}
+	return bufio.NewCachedPacketConn(conn, buffer, destination), nil
+}
+
+func (r *Router) applySniffResult(ctx context.Context, metadata *adapter.InboundContext, sniffMetadata *adapter.InboundContext, isPacket bool) {
+	metadata.Protocol = sniffMetadata.Protocol
+	metadata.Domain = sniffMetadata.Domain
+	if metadata.InboundOptions.SniffOverrideDestination && M.IsDomainName(metadata.Domain) {
+		metadata.Destination = M.Socksaddr{
+			Fqdn: metadata.Domain,
+			Port: metadata.Destination.Port,
+		}
+	}
+	var prefix string
+	if isPacket {
+		prefix = "sniffed packet protocol: "
+	} else {
+		prefix = "sniffed protocol: "
+	}
+	if metadata.Domain != "" {
+		r.logger.DebugContext(ctx, prefix, metadata.Protocol, ", domain: ", metadata.Domain)
+	} else {
+		r.logger.DebugContext(ctx, prefix, metadata.Protocol)
+	}
+}
+
+func (r *Router) actionSniff(ctx context.Context, metadata *adapter.InboundContext, action *RuleActionSniff, state *routeState) error {
+	if metadata.Protocol != "" {
+		return nil
+	}
+	if state.conn != nil {
+		if len(action.StreamSniffers) == 0 {
+			return nil
+		}
+		timeout := action.Timeout
+		if timeout == 0 {
+			timeout = time.Duration(metadata.InboundOptions.SniffTimeout)
+		}
+		state.conn = r.sniffConnection(ctx, state.conn, metadata, timeout, action.StreamSniffers...)
+	} else if state.packetConn != nil {
+		if len(action.PacketSniffers) == 0 {
+			return nil
+		}
+		packetConn, err := r.sniffPacketConnection(ctx, state.packetConn, metadata, action.PacketSniffers...)
+		if err != nil {
+			return err
+		}
+		state.packetConn = packetConn
+	}
+	return nil
+}
+
+func (r *Router) actionResolve(ctx context.Context, metadata *adapter.InboundContext, action *RuleActionResolve) error {
+	if !metadata.Destination.IsFqdn() {
+		return nil
+	}
+	var (
+		addresses []netip.Addr
+		err       error
+	)
+	if action.Server == "" {
+		addresses, err = r.Lookup(adapter.WithContext(ctx, metadata), metadata.Destination.Fqdn, action.Strategy)
+	} else {
+		r.access.RLock()
+		transport, loaded := r.transportMap[action.Server]
+		strategy := action.Strategy
+		if strategy == dns.DomainStrategyAsIS {
+			strategy = r.transportDomainStrategy[transport]
+		}
+		r.access.RUnlock()
+		if !loaded {
+			return E.New("DNS server not found: ", action.Server)
+		}
+		lookupCtx, cancel := context.WithTimeout(ctx, C.DNSTimeout)
+		addresses, err = r.dnsClient.Lookup(lookupCtx, transport, metadata.Destination.Fqdn, strategy)
+		cancel()
+	}
+	if err != nil {
+		return err
+	}
+	metadata.DestinationAddresses = addresses
+	r.dnsLogger.DebugContext(ctx, "resolved [", strings.Join(F.MapToString(metadata.DestinationAddresses), " "), "]")
+	return nil
+}
+
+func (r *Router) rejectConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, action *RuleActionReject) error {
+	r.logger.InfoContext(ctx, "rejected connection to ", metadata.Destination)
+	switch action.Method {
+	case C.RuleActionRejectMethodDrop:
+		closeTimer := time.AfterFunc(C.TCPTimeout, func() {
+			conn.Close()
+		})
+		defer closeTimer.Stop()
+		_, _ = io.Copy(io.Discard, conn)
+		return conn.Close()
+	case C.RuleActionRejectMethodICMPUnreachable:
+		// ICMP is not replied for TCP connections, which are closed only
+		conn.Close()
+		return E.Cause(syscall.EHOSTUNREACH, "rejected")
+	default:
+		if tcpConn, isTCPConn := common.Cast[*net.TCPConn](conn); isTCPConn {
+			tcpConn.SetLinger(0)
+		}
+		conn.Close()
+		return E.Cause(syscall.ECONNRESET, "rejected")
+	}
+}
+
+func (r *Router) rejectPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext, action *RuleActionReject) error {
+	r.logger.InfoContext(ctx, "rejected packet connection to ", metadata.Destination)
+	switch action.Method {
+	case C.RuleActionRejectMethodDrop:
+		// not all packet connections support read deadlines
+		closeTimer := time.AfterFunc(C.UDPTimeout, func() {
+			conn.Close()
+		})
+		defer closeTimer.Stop()
+		for {
+			buffer := buf.NewPacket()
+			_, err := conn.ReadPacket(buffer)
+			buffer.Release()
+			if err != nil {
+				return conn.Close()
+			}
+		}
+	case C.RuleActionRejectMethodReset:
+		return conn.Close()
+	default:
+		conn.Close()
+		return E.Cause(syscall.EHOSTUNREACH, "rejected")
+	}
 }
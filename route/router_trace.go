@@ -0,0 +1,141 @@
+package route
+
+import (
+	"context"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+// TraceRoute matches the metadata against the route rules like a new
+// connection, except that processes are not searched and sniff and resolve
+// actions are skipped.
+func (r *Router) TraceRoute(ctx context.Context, metadata adapter.InboundContext) (*adapter.RouteTrace, error) {
+	if metadata.Network == "" {
+		metadata.Network = N.NetworkTCP
+	}
+	r.access.RLock()
+	var defaultOutbound adapter.Outbound
+	switch metadata.Network {
+	case N.NetworkTCP:
+		defaultOutbound = r.defaultOutboundForConnection
+	case N.NetworkUDP:
+		defaultOutbound = r.defaultOutboundForPacketConnection
+	default:
+		r.access.RUnlock()
+		return nil, E.Cause(N.ErrUnknownNetwork, metadata.Network)
+	}
+	if metadata.Inbound != "" {
+		inbound, loaded := r.inboundByTag[metadata.Inbound]
+		if !loaded {
+			r.access.RUnlock()
+			return nil, E.New("inbound not found: ", metadata.Inbound)
+		}
+		if metadata.InboundType == "" {
+			metadata.InboundType = inbound.Type()
+		}
+	}
+	r.access.RUnlock()
+	if r.fakeIPStore != nil && r.fakeIPStore.Contains(metadata.Destination.Addr) {
+		domain, loaded := r.fakeIPStore.Lookup(metadata.Destination.Addr)
+		if !loaded {
+			return nil, E.New("missing fakeip context")
+		}
+		metadata.OriginDestination = metadata.Destination
+		metadata.Destination = M.Socksaddr{
+			Fqdn: domain,
+			Port: metadata.Destination.Port,
+		}
+		metadata.FakeIP = true
+	}
+	if r.dnsReverseMapping != nil && metadata.Domain == "" {
+		domain, loaded := r.dnsReverseMapping.Query(metadata.Destination.Addr)
+		if loaded {
+			metadata.Domain = domain
+		}
+	}
+	if metadata.Destination.IsIPv4() {
+		metadata.IPVersion = 4
+	} else if metadata.Destination.IsIPv6() {
+		metadata.IPVersion = 6
+	}
+	trace := &adapter.RouteTrace{}
+	if metadata.Destination.IsFqdn() {
+		trace.DNS = r.traceDNS(ctx, metadata, metadata.Destination.Fqdn)
+	} else if metadata.Domain != "" {
+		trace.DNS = r.traceDNS(ctx, metadata, metadata.Domain)
+	}
+	state := &routeState{trace: trace}
+	err := r.match0(ctx, &metadata, defaultOutbound, state)
+	if err != nil {
+		return nil, err
+	}
+	if state.action != nil {
+		trace.Action = state.action.Type()
+	} else {
+		trace.Action = C.RuleActionTypeRoute
+	}
+	if state.outbound != nil {
+		trace.Outbound = state.outbound.Tag()
+	}
+	return trace, nil
+}
+
+func (r *Router) traceDNS(ctx context.Context, metadata adapter.InboundContext, domain string) *adapter.RouteTraceDNS {
+	metadata.Destination = M.Socksaddr{}
+	metadata.DestinationAddresses = nil
+	metadata.Domain = domain
+	_, transport, _, rule, ruleIndex := r.matchDNS(adapter.WithContext(ctx, &metadata), true, -1)
+	_, isFakeIP := transport.(adapter.FakeIPTransport)
+	trace := &adapter.RouteTraceDNS{
+		Domain:    domain,
+		RuleIndex: ruleIndex,
+		Server:    transport.Name(),
+		FakeIP:    isFakeIP,
+	}
+	if rule != nil {
+		trace.Rule = rule.String()
+	}
+	return trace
+}
+
+func traceRule(index int, rule adapter.Rule, metadata adapter.InboundContext, matched bool) adapter.RouteTraceRule {
+	trace := adapter.RouteTraceRule{
+		Index:   index,
+		Rule:    rule.String(),
+		Action:  rule.Action().String(),
+		Matched: matched,
+	}
+	if tracer, isTracer := rule.(ruleItemTracer); isTracer {
+		trace.Items = tracer.matchedItems(metadata)
+	}
+	return trace
+}
+
+type ruleItemTracer interface {
+	matchedItems(metadata adapter.InboundContext) []string
+}
+
+func (r *abstractDefaultRule) matchedItems(metadata adapter.InboundContext) []string {
+	var items []string
+	for _, item := range r.allItems {
+		metadata.ResetRuleCache()
+		if item.Match(&metadata) {
+			items = append(items, item.String())
+		}
+	}
+	return items
+}
+
+func (r *abstractLogicalRule) matchedItems(metadata adapter.InboundContext) []string {
+	var items []string
+	for _, rule := range r.rules {
+		if tracer, isTracer := rule.(ruleItemTracer); isTracer {
+			items = append(items, tracer.matchedItems(metadata)...)
+		}
+	}
+	return items
+}
@@ -21,6 +21,7 @@ type abstractDefaultRule struct {
 	ruleSetItem             RuleItem
 	invert                  bool
 	outbound                string
+	action                  adapter.RuleAction
 }
 
 func (r *abstractDefaultRule) Type() string {
@@ -150,6 +151,10 @@ func (r *abstractDefaultRule) Outbound() string {
 	return r.outbound
 }
 
+func (r *abstractDefaultRule) Action() adapter.RuleAction {
+	return r.action
+}
+
 func (r *abstractDefaultRule) String() string {
 	if !r.invert {
 		return strings.Join(F.MapToString(r.allItems), " ")
@@ -163,6 +168,7 @@ type abstractLogicalRule struct {
 	mode     string
 	invert   bool
 	outbound string
+	action   adapter.RuleAction
 }
 
 func (r *abstractLogicalRule) Type() string {
@@ -209,6 +215,19 @@ func (r *abstractLogicalRule) Close() error {
 }
 
 func (r *abstractLogicalRule) Match(metadata *adapter.InboundContext) bool {
+	// sub-rules reset the rule cache, restore it for the rule containing the rule-set
+	ipCIDRMatchSource := metadata.IPCIDRMatchSource
+	sourceAddressMatch := metadata.SourceAddressMatch
+	sourcePortMatch := metadata.SourcePortMatch
+	destinationAddressMatch := metadata.DestinationAddressMatch
+	destinationPortMatch := metadata.DestinationPortMatch
+	defer func() {
+		metadata.IPCIDRMatchSource = ipCIDRMatchSource
+		metadata.SourceAddressMatch = sourceAddressMatch
+		metadata.SourcePortMatch = sourcePortMatch
+		metadata.DestinationAddressMatch = destinationAddressMatch
+		metadata.DestinationPortMatch = destinationPortMatch
+	}()
 	if r.mode == C.LogicalTypeAnd {
 		return common.All(r.rules, func(it adapter.HeadlessRule) bool {
 			metadata.ResetRuleCache()
@@ -226,6 +245,10 @@ func (r *abstractLogicalRule) Outbound() string {
 	return r.outbound
 }
 
+func (r *abstractLogicalRule) Action() adapter.RuleAction {
+	return r.action
+}
+
 func (r *abstractLogicalRule) String() string {
 	var op string
 	switch r.mode {
@@ -0,0 +1,55 @@
+package route
+
+import (
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	M "github.com/sagernet/sing/common/metadata"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuleSetLogicalRuleCache(t *testing.T) {
+	t.Parallel()
+	ruleSet, err := NewInlineRuleSet(nil, log.NewNOPFactory().Logger(), option.RuleSet{
+		Type: C.RuleSetTypeInline,
+		Tag:  "test",
+		InlineOptions: option.PlainRuleSet{
+			Rules: []option.HeadlessRule{{
+				Type: C.RuleTypeLogical,
+				LogicalOptions: option.LogicalHeadlessRule{
+					Mode: C.LogicalTypeOr,
+					Rules: []option.HeadlessRule{
+						{Type: C.RuleTypeDefault, DefaultOptions: option.DefaultHeadlessRule{Domain: []string{"a.example.com"}}},
+						{Type: C.RuleTypeDefault, DefaultOptions: option.DefaultHeadlessRule{Domain: []string{"b.example.com"}}},
+					},
+				},
+			}},
+		},
+	})
+	require.NoError(t, err)
+	portItem := NewPortItem(false, []uint16{443})
+	ruleSetItem := &RuleSetItem{tagList: []string{"test"}, setList: []adapter.RuleSet{ruleSet}}
+	rule := &abstractDefaultRule{
+		items:                []RuleItem{ruleSetItem},
+		destinationPortItems: []RuleItem{portItem},
+		allItems:             []RuleItem{portItem, ruleSetItem},
+	}
+	for _, testCase := range []struct {
+		destination string
+		matched     bool
+	}{
+		{"a.example.com:443", true},
+		{"b.example.com:443", true},
+		{"b.example.com:80", false},
+		{"c.example.com:443", false},
+	} {
+		destination := M.ParseSocksaddr(testCase.destination)
+		metadata := adapter.InboundContext{Domain: destination.Fqdn, Destination: destination}
+		// the logical rule in the rule-set must not reset the port matched by the outer rule
+		require.Equal(t, testCase.matched, rule.Match(&metadata), testCase.destination)
+	}
+}
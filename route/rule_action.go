@@ -0,0 +1,237 @@
+package route
+
+import (
+	"strings"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/sniff"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-dns"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+func NewRuleAction(options option.RuleAction) (adapter.RuleAction, error) {
+	switch options.Action {
+	case "", C.RuleActionTypeRoute:
+		routeOptions, err := newRouteActionOptions(options)
+		if err != nil {
+			return nil, err
+		}
+		return &RuleActionRoute{
+			Outbound:           options.Outbound,
+			RouteActionOptions: routeOptions,
+		}, nil
+	case C.RuleActionTypeRouteOptions:
+		routeOptions, err := newRouteActionOptions(options)
+		if err != nil {
+			return nil, err
+		}
+		return &RuleActionRouteOptions{
+			RouteActionOptions: routeOptions,
+		}, nil
+	case C.RuleActionTypeReject:
+		switch options.Method {
+		case "":
+			options.Method = C.RuleActionRejectMethodDefault
+		case C.RuleActionRejectMethodDefault, C.RuleActionRejectMethodDrop, C.RuleActionRejectMethodReset, C.RuleActionRejectMethodICMPUnreachable:
+		default:
+			return nil, E.New("unknown reject method: ", options.Method)
+		}
+		return &RuleActionReject{
+			Method: options.Method,
+		}, nil
+	case C.RuleActionTypeHijackDNS:
+		return &RuleActionHijackDNS{}, nil
+	case C.RuleActionTypeSniff:
+		action := &RuleActionSniff{
+			Sniffer: options.Sniffer,
+			Timeout: time.Duration(options.Timeout),
+		}
+		if len(options.Sniffer) == 0 {
+			action.StreamSniffers = []sniff.StreamSniffer{sniff.StreamDomainNameQuery, sniff.TLSClientHello, sniff.HTTPHost}
+			action.PacketSniffers = []sniff.PacketSniffer{sniff.DomainNameQuery, sniff.QUICClientHello, sniff.STUNMessage}
+		}
+		for _, name := range options.Sniffer {
+			switch name {
+			case C.ProtocolDNS:
+				action.StreamSniffers = append(action.StreamSniffers, sniff.StreamDomainNameQuery)
+				action.PacketSniffers = append(action.PacketSniffers, sniff.DomainNameQuery)
+			case C.ProtocolTLS:
+				action.StreamSniffers = append(action.StreamSniffers, sniff.TLSClientHello)
+			case C.ProtocolHTTP:
+				action.StreamSniffers = append(action.StreamSniffers, sniff.HTTPHost)
+			case C.ProtocolQUIC:
+				action.PacketSniffers = append(action.PacketSniffers, sniff.QUICClientHello)
+			case C.ProtocolSTUN:
+				action.PacketSniffers = append(action.PacketSniffers, sniff.STUNMessage)
+			default:
+				return nil, E.New("unknown sniffer: ", name)
+			}
+		}
+		return action, nil
+	case C.RuleActionTypeResolve:
+		return &RuleActionResolve{
+			Server:   options.Server,
+			Strategy: dns.DomainStrategy(options.Strategy),
+		}, nil
+	default:
+		return nil, E.New("unknown rule action: ", options.Action)
+	}
+}
+
+func isRouteAction(action string) bool {
+	return action == "" || action == C.RuleActionTypeRoute
+}
+
+func actionOutbound(action adapter.RuleAction) string {
+	if routeAction, isRoute := action.(*RuleActionRoute); isRoute {
+		return routeAction.Outbound
+	}
+	return ""
+}
+
+type RouteActionOptions struct {
+	OverrideAddress M.Socksaddr
+	OverridePort    uint16
+}
+
+func newRouteActionOptions(options option.RuleAction) (RouteActionOptions, error) {
+	var routeOptions RouteActionOptions
+	if options.OverrideAddress != "" {
+		routeOptions.OverrideAddress = M.ParseSocksaddrHostPort(options.OverrideAddress, 0)
+		if !routeOptions.OverrideAddress.IsValid() {
+			return routeOptions, E.New("invalid override address: ", options.OverrideAddress)
+		}
+	}
+	routeOptions.OverridePort = options.OverridePort
+	return routeOptions, nil
+}
+
+func (o RouteActionOptions) apply(metadata *adapter.InboundContext) {
+	if o.OverrideAddress.IsValid() {
+		metadata.Destination = M.Socksaddr{
+			Addr: o.OverrideAddress.Addr,
+			Fqdn: o.OverrideAddress.Fqdn,
+			Port: metadata.Destination.Port,
+		}
+		metadata.DestinationAddresses = nil
+	}
+	if o.OverridePort != 0 {
+		metadata.Destination.Port = o.OverridePort
+	}
+}
+
+func (o *RouteActionOptions) merge(options RouteActionOptions) {
+	if options.OverrideAddress.IsValid() {
+		o.OverrideAddress = options.OverrideAddress
+	}
+	if options.OverridePort != 0 {
+		o.OverridePort = options.OverridePort
+	}
+}
+
+func (o RouteActionOptions) isEmpty() bool {
+	return !o.OverrideAddress.IsValid() && o.OverridePort == 0
+}
+
+func (o RouteActionOptions) String() string {
+	var descriptions []string
+	if o.OverrideAddress.IsValid() {
+		descriptions = append(descriptions, "override_address="+o.OverrideAddress.AddrString())
+	}
+	if o.OverridePort != 0 {
+		descriptions = append(descriptions, "override_port="+F.ToString(o.OverridePort))
+	}
+	return strings.Join(descriptions, ",")
+}
+
+type RuleActionRoute struct {
+	Outbound string
+	RouteActionOptions
+}
+
+func (r *RuleActionRoute) Type() string {
+	return C.RuleActionTypeRoute
+}
+
+func (r *RuleActionRoute) String() string {
+	if r.RouteActionOptions.isEmpty() {
+		return r.Outbound
+	}
+	return r.Outbound + "(" + r.RouteActionOptions.String() + ")"
+}
+
+type RuleActionRouteOptions struct {
+	RouteActionOptions
+}
+
+func (r *RuleActionRouteOptions) Type() string {
+	return C.RuleActionTypeRouteOptions
+}
+
+func (r *RuleActionRouteOptions) String() string {
+	return "route-options(" + r.RouteActionOptions.String() + ")"
+}
+
+type RuleActionReject struct {
+	Method string
+}
+
+func (r *RuleActionReject) Type() string {
+	return C.RuleActionTypeReject
+}
+
+func (r *RuleActionReject) String() string {
+	if r.Method == C.RuleActionRejectMethodDefault {
+		return "reject"
+	}
+	return "reject(" + r.Method + ")"
+}
+
+type RuleActionHijackDNS struct{}
+
+func (r *RuleActionHijackDNS) Type() string {
+	return C.RuleActionTypeHijackDNS
+}
+
+func (r *RuleActionHijackDNS) String() string {
+	return "hijack-dns"
+}
+
+type RuleActionSniff struct {
+	Sniffer        []string
+	StreamSniffers []sniff.StreamSniffer
+	PacketSniffers []sniff.PacketSniffer
+	Timeout        time.Duration
+}
+
+func (r *RuleActionSniff) Type() string {
+	return C.RuleActionTypeSniff
+}
+
+func (r *RuleActionSniff) String() string {
+	if len(r.Sniffer) == 0 {
+		return "sniff"
+	}
+	return "sniff(" + strings.Join(r.Sniffer, ",") + ")"
+}
+
+type RuleActionResolve struct {
+	Server   string
+	Strategy dns.DomainStrategy
+}
+
+func (r *RuleActionResolve) Type() string {
+	return C.RuleActionTypeResolve
+}
+
+func (r *RuleActionResolve) String() string {
+	if r.Server == "" {
+		return "resolve"
+	}
+	return "resolve(" + r.Server + ")"
+}
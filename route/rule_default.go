@@ -14,7 +14,7 @@ func NewRule(router adapter.Router, logger log.ContextLogger, options option.Rul
 		if !options.DefaultOptions.IsValid() {
 			return nil, E.New("missing conditions")
 		}
-		if options.DefaultOptions.Outbound == "" && checkOutbound {
+		if checkOutbound && isRouteAction(options.DefaultOptions.Action) && options.DefaultOptions.Outbound == "" {
 			return nil, E.New("missing outbound field")
 		}
 		return NewDefaultRule(router, logger, options.DefaultOptions)
@@ -22,7 +22,7 @@ func NewRule(router adapter.Router, logger log.ContextLogger, options option.Rul
 		if !options.LogicalOptions.IsValid() {
 			return nil, E.New("missing conditions")
 		}
-		if options.LogicalOptions.Outbound == "" && checkOutbound {
+		if checkOutbound && isRouteAction(options.LogicalOptions.Action) && options.LogicalOptions.Outbound == "" {
 			return nil, E.New("missing outbound field")
 		}
 		return NewLogicalRule(router, logger, options.LogicalOptions)
@@ -43,10 +43,15 @@ type RuleItem interface {
 }
 
 func NewDefaultRule(router adapter.Router, logger log.ContextLogger, options option.DefaultRule) (*DefaultRule, error) {
+	action, err := NewRuleAction(options.RuleAction)
+	if err != nil {
+		return nil, err
+	}
 	rule := &DefaultRule{
 		abstractDefaultRule{
 			invert:   options.Invert,
-			outbound: options.Outbound,
+			outbound: actionOutbound(action),
+			action:   action,
 		},
 	}
 	if len(options.Inbound) > 0 {
@@ -219,11 +224,16 @@ type LogicalRule struct {
 }
 
 func NewLogicalRule(router adapter.Router, logger log.ContextLogger, options option.LogicalRule) (*LogicalRule, error) {
+	action, err := NewRuleAction(options.RuleAction)
+	if err != nil {
+		return nil, err
+	}
 	r := &LogicalRule{
 		abstractLogicalRule{
 			rules:    make([]adapter.HeadlessRule, len(options.Rules)),
 			invert:   options.Invert,
-			outbound: options.Outbound,
+			outbound: actionOutbound(action),
+			action:   action,
 		},
 	}
 	switch options.Mode {
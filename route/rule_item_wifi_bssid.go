@@ -28,7 +28,7 @@ func NewWIFIBSSIDItem(router adapter.Router, bssidList []string) *WIFIBSSIDItem
 }
 
 func (r *WIFIBSSIDItem) Match(metadata *adapter.InboundContext) bool {
-	return r.bssidMap[r.router.WIFIState().BSSID]
+	return r.bssidMap[wifiState(r.router, metadata).BSSID]
 }
 
 func (r *WIFIBSSIDItem) String() string {
@@ -28,7 +28,14 @@ func NewWIFISSIDItem(router adapter.Router, ssidList []string) *WIFISSIDItem {
 }
 
 func (r *WIFISSIDItem) Match(metadata *adapter.InboundContext) bool {
-	return r.ssidMap[r.router.WIFIState().SSID]
+	return r.ssidMap[wifiState(r.router, metadata).SSID]
+}
+
+func wifiState(router adapter.Router, metadata *adapter.InboundContext) adapter.WIFIState {
+	if metadata.WIFIState != nil {
+		return *metadata.WIFIState
+	}
+	return router.WIFIState()
 }
 
 func (r *WIFISSIDItem) String() string {
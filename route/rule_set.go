@@ -1,15 +1,19 @@
 package route
 
 import (
+	"bytes"
 	"context"
 	"net"
 	"net/http"
 	"sync"
 
 	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/ruleconv"
+	"github.com/sagernet/sing-box/common/srs"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/option"
 	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
 	"github.com/sagernet/sing/common/logger"
 	M "github.com/sagernet/sing/common/metadata"
 	N "github.com/sagernet/sing/common/network"
@@ -17,8 +21,10 @@ import (
 
 func NewRuleSet(ctx context.Context, router adapter.Router, logger logger.ContextLogger, options option.RuleSet) (adapter.RuleSet, error) {
 	switch options.Type {
+	case C.RuleSetTypeInline:
+		return NewInlineRuleSet(router, logger, options)
 	case C.RuleSetTypeLocal:
-		return NewLocalRuleSet(router, options)
+		return NewLocalRuleSet(router, logger, options)
 	case C.RuleSetTypeRemote:
 		return NewRemoteRuleSet(ctx, router, logger, options), nil
 	default:
@@ -26,6 +32,46 @@ func NewRuleSet(ctx context.Context, router adapter.Router, logger logger.Contex
 	}
 }
 
+// convertRuleSet converts a rule list in the format of another program, and
+// reports entries which are skipped.
+func convertRuleSet(logger logger.Logger, tag string, format string, content []byte) (option.PlainRuleSet, error) {
+	plainRuleSet, unsupported, err := ruleconv.Convert(format, content)
+	if err != nil {
+		return option.PlainRuleSet{}, err
+	}
+	if len(unsupported) > 0 {
+		logger.Warn("rule-set ", tag, ": skipped ", len(unsupported), " unsupported entries, first at ", unsupported[0])
+	}
+	return plainRuleSet, nil
+}
+
+// ruleSetItems reads items of a rule-set for counts and reload summaries.
+// Binary rule-sets are read with recovery, as domains and IP CIDRs are
+// otherwise only kept in matchers.
+func ruleSetItems(format string, content []byte) map[string]bool {
+	var (
+		plainRuleSet option.PlainRuleSet
+		err          error
+	)
+	switch format {
+	case C.RuleSetFormatSource, "":
+		var compat option.PlainRuleSetCompat
+		compat, err = json.UnmarshalExtended[option.PlainRuleSetCompat](content)
+		if err != nil {
+			return nil
+		}
+		plainRuleSet = compat.Upgrade()
+	case C.RuleSetFormatBinary:
+		plainRuleSet, err = srs.Read(bytes.NewReader(content), true)
+	default:
+		plainRuleSet, _, err = ruleconv.Convert(format, content)
+	}
+	if err != nil {
+		return nil
+	}
+	return headlessRuleItems(plainRuleSet.Rules)
+}
+
 var _ adapter.RuleSetStartContext = (*RuleSetStartContext)(nil)
 
 type RuleSetStartContext struct {
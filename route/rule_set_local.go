@@ -1,66 +1,142 @@
 package route
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/ruleconv"
 	"github.com/sagernet/sing-box/common/srs"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/atomic"
 	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
 	"github.com/sagernet/sing/common/json"
+	"github.com/sagernet/sing/common/logger"
+
+	"github.com/fsnotify/fsnotify"
 )
 
+const localRuleSetReloadDelay = 200 * time.Millisecond
+
 var _ adapter.RuleSet = (*LocalRuleSet)(nil)
 
 type LocalRuleSet struct {
-	rules    []adapter.HeadlessRule
-	metadata adapter.RuleSetMetadata
+	router      adapter.Router
+	logger      logger.ContextLogger
+	tag         string
+	ruleSetType string
+	path        string
+	format      string
+	rules       atomic.TypedValue[[]adapter.HeadlessRule]
+	metadata    atomic.TypedValue[adapter.RuleSetMetadata]
+
+	access      sync.Mutex
+	content     []byte
+	ruleCount   int
+	updatedAt   time.Time
+	lastError   error
+	watcher     *fsnotify.Watcher
+	reloadTimer *time.Timer
 }
 
-func NewLocalRuleSet(router adapter.Router, options option.RuleSet) (*LocalRuleSet, error) {
-	var plainRuleSet option.PlainRuleSet
-	switch options.Format {
+func NewLocalRuleSet(router adapter.Router, logger logger.ContextLogger, options option.RuleSet) (*LocalRuleSet, error) {
+	ruleSet := &LocalRuleSet{
+		router:      router,
+		logger:      logger,
+		tag:         options.Tag,
+		ruleSetType: C.RuleSetTypeLocal,
+		path:        options.LocalOptions.Path,
+		format:      options.Format,
+	}
+	content, err := os.ReadFile(ruleSet.path)
+	if err != nil {
+		return nil, err
+	}
+	plainRuleSet, err := ruleSet.decode(content)
+	if err != nil {
+		return nil, err
+	}
+	err = ruleSet.reloadRules(plainRuleSet)
+	if err != nil {
+		return nil, err
+	}
+	ruleSet.content = content
+	ruleSet.ruleCount = len(ruleSetItems(ruleSet.format, content))
+	ruleSet.updatedAt = time.Now()
+	return ruleSet, nil
+}
+
+func NewInlineRuleSet(router adapter.Router, logger logger.ContextLogger, options option.RuleSet) (*LocalRuleSet, error) {
+	ruleSet := &LocalRuleSet{
+		router:      router,
+		logger:      logger,
+		tag:         options.Tag,
+		ruleSetType: C.RuleSetTypeInline,
+	}
+	err := ruleSet.reloadRules(options.InlineOptions)
+	if err != nil {
+		return nil, err
+	}
+	ruleSet.ruleCount = len(headlessRuleItems(options.InlineOptions.Rules))
+	ruleSet.updatedAt = time.Now()
+	return ruleSet, nil
+}
+
+func (s *LocalRuleSet) decode(content []byte) (option.PlainRuleSet, error) {
+	switch s.format {
 	case C.RuleSetFormatSource, "":
-		content, err := os.ReadFile(options.LocalOptions.Path)
-		if err != nil {
-			return nil, err
-		}
 		compat, err := json.UnmarshalExtended[option.PlainRuleSetCompat](content)
 		if err != nil {
-			return nil, err
+			return option.PlainRuleSet{}, err
 		}
-		plainRuleSet = compat.Upgrade()
+		return compat.Upgrade(), nil
 	case C.RuleSetFormatBinary:
-		setFile, err := os.Open(options.LocalOptions.Path)
-		if err != nil {
-			return nil, err
-		}
-		plainRuleSet, err = srs.Read(setFile, false)
-		if err != nil {
-			return nil, err
-		}
+		return srs.Read(bytes.NewReader(content), false)
 	default:
-		return nil, E.New("unknown rule set format: ", options.Format)
+		if !ruleconv.IsSupported(s.format) {
+			return option.PlainRuleSet{}, E.New("unknown rule set format: ", s.format)
+		}
+		return convertRuleSet(s.logger, s.tag, s.format, content)
 	}
+}
+
+func (s *LocalRuleSet) reloadRules(plainRuleSet option.PlainRuleSet) error {
 	rules := make([]adapter.HeadlessRule, len(plainRuleSet.Rules))
 	var err error
 	for i, ruleOptions := range plainRuleSet.Rules {
-		rules[i], err = NewHeadlessRule(router, ruleOptions)
+		rules[i], err = NewHeadlessRule(s.router, ruleOptions)
 		if err != nil {
-			return nil, E.Cause(err, "parse rule_set.rules.[", i, "]")
+			return E.Cause(err, "parse rule_set.rules.[", i, "]")
 		}
 	}
 	var metadata adapter.RuleSetMetadata
 	metadata.ContainsProcessRule = hasHeadlessRule(plainRuleSet.Rules, isProcessHeadlessRule)
 	metadata.ContainsWIFIRule = hasHeadlessRule(plainRuleSet.Rules, isWIFIHeadlessRule)
 	metadata.ContainsIPCIDRRule = hasHeadlessRule(plainRuleSet.Rules, isIPCIDRHeadlessRule)
-	return &LocalRuleSet{rules, metadata}, nil
+	s.rules.Store(rules)
+	s.metadata.Store(metadata)
+	return nil
+}
+
+func (s *LocalRuleSet) Tag() string {
+	return s.tag
+}
+
+func (s *LocalRuleSet) Type() string {
+	return s.ruleSetType
 }
 
 func (s *LocalRuleSet) Match(metadata *adapter.InboundContext) bool {
-	for _, rule := range s.rules {
+	for _, rule := range s.rules.Load() {
 		if rule.Match(metadata) {
 			return true
 		}
@@ -69,6 +145,118 @@ func (s *LocalRuleSet) Match(metadata *adapter.InboundContext) bool {
 }
 
 func (s *LocalRuleSet) StartContext(ctx context.Context, startContext adapter.RuleSetStartContext) error {
+	if s.path == "" {
+		return nil
+	}
+	err := s.startWatcher()
+	if err != nil {
+		s.logger.Warn("create fsnotify watcher for rule-set ", s.tag, ": ", err)
+	}
+	return nil
+}
+
+func (s *LocalRuleSet) startWatcher() error {
+	path, err := filepath.Abs(s.path)
+	if err != nil {
+		return err
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	// Watch the parent directory so that files replaced by rename are still tracked.
+	err = watcher.Add(filepath.Dir(path))
+	if err != nil {
+		watcher.Close()
+		return err
+	}
+	s.access.Lock()
+	s.watcher = watcher
+	s.access.Unlock()
+	go s.loopUpdate(watcher, path)
+	return nil
+}
+
+func (s *LocalRuleSet) loopUpdate(watcher *fsnotify.Watcher, path string) {
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != path {
+				continue
+			}
+			if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) && !event.Has(fsnotify.Rename) {
+				continue
+			}
+			s.access.Lock()
+			if s.watcher == nil {
+				s.access.Unlock()
+				return
+			}
+			if s.reloadTimer == nil {
+				s.reloadTimer = time.AfterFunc(localRuleSetReloadDelay, s.reload)
+			} else {
+				s.reloadTimer.Reset(localRuleSetReloadDelay)
+			}
+			s.access.Unlock()
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			s.logger.Error(E.Cause(err, "fsnotify error"))
+		}
+	}
+}
+
+func (s *LocalRuleSet) reload() {
+	err := s.Update(context.Background())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		s.logger.Error(err)
+	}
+}
+
+func (s *LocalRuleSet) Update(ctx context.Context) error {
+	if s.path == "" {
+		return nil
+	}
+	s.access.Lock()
+	defer s.access.Unlock()
+	err := s.reloadFile()
+	if err != nil {
+		s.lastError = E.Cause(err, "reload rule-set ", s.tag)
+		return s.lastError
+	}
+	s.lastError = nil
+	return nil
+}
+
+func (s *LocalRuleSet) reloadFile() error {
+	content, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	plainRuleSet, err := s.decode(content)
+	if err != nil {
+		return err
+	}
+	lastMetadata := s.metadata.Load()
+	err = s.reloadRules(plainRuleSet)
+	if err != nil {
+		return err
+	}
+	metadata := s.metadata.Load()
+	if (metadata.ContainsProcessRule && !lastMetadata.ContainsProcessRule) || (metadata.ContainsWIFIRule && !lastMetadata.ContainsWIFIRule) {
+		s.logger.Warn("rule-set ", s.tag, " now contains process or WIFI rules, restart to enable them")
+	}
+	// items of both files are only read for the summary
+	items := ruleSetItems(s.format, content)
+	added, removed := diffHeadlessRuleItems(ruleSetItems(s.format, s.content), items)
+	s.content = content
+	s.ruleCount = len(items)
+	s.updatedAt = time.Now()
+	s.logger.Info("reloaded rule-set ", s.tag, ": ", len(items), " items, ", summarizeHeadlessRuleItems(added, "+"), ", ", summarizeHeadlessRuleItems(removed, "-"))
 	return nil
 }
 
@@ -77,9 +265,106 @@ func (s *LocalRuleSet) PostStart() error {
 }
 
 func (s *LocalRuleSet) Metadata() adapter.RuleSetMetadata {
-	return s.metadata
+	return s.metadata.Load()
+}
+
+func (s *LocalRuleSet) RuleCount() int {
+	s.access.Lock()
+	defer s.access.Unlock()
+	return s.ruleCount
+}
+
+func (s *LocalRuleSet) UpdatedAt() time.Time {
+	s.access.Lock()
+	defer s.access.Unlock()
+	return s.updatedAt
+}
+
+func (s *LocalRuleSet) LastError() error {
+	s.access.Lock()
+	defer s.access.Unlock()
+	return s.lastError
 }
 
 func (s *LocalRuleSet) Close() error {
-	return nil
+	s.access.Lock()
+	defer s.access.Unlock()
+	if s.reloadTimer != nil {
+		s.reloadTimer.Stop()
+	}
+	if s.watcher == nil {
+		return nil
+	}
+	err := s.watcher.Close()
+	s.watcher = nil
+	return err
+}
+
+// headlessRuleItems flattens rules into "field=value" items for reload summaries.
+// Logical rules are compared as a whole.
+func headlessRuleItems(rules []option.HeadlessRule) map[string]bool {
+	items := make(map[string]bool)
+	for _, rule := range rules {
+		if rule.Type == C.RuleTypeLogical {
+			content, err := json.Marshal(rule.LogicalOptions)
+			if err == nil {
+				items["logical="+string(content)] = true
+			}
+			continue
+		}
+		content, err := json.Marshal(rule.DefaultOptions)
+		if err != nil {
+			continue
+		}
+		var fields map[string]json.RawMessage
+		err = json.Unmarshal(content, &fields)
+		if err != nil {
+			continue
+		}
+		for field, value := range fields {
+			var values []json.RawMessage
+			if json.Unmarshal(value, &values) != nil {
+				values = []json.RawMessage{value}
+			}
+			for _, item := range values {
+				items[field+"="+string(item)] = true
+			}
+		}
+	}
+	return items
+}
+
+func diffHeadlessRuleItems(last map[string]bool, current map[string]bool) (added []string, removed []string) {
+	for item := range current {
+		if !last[item] {
+			added = append(added, item)
+		}
+	}
+	for item := range last {
+		if !current[item] {
+			removed = append(removed, item)
+		}
+	}
+	return
+}
+
+func summarizeHeadlessRuleItems(items []string, sign string) string {
+	if len(items) == 0 {
+		return sign + "0"
+	}
+	fieldCount := make(map[string]int)
+	for _, item := range items {
+		field, _, _ := strings.Cut(item, "=")
+		fieldCount[field]++
+	}
+	fields := make([]string, 0, len(fieldCount))
+	for field := range fieldCount {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	descriptions := make([]string, 0, len(fields))
+	for _, field := range fields {
+		descriptions = append(descriptions, field+" "+sign+F.ToString(fieldCount[field]))
+	}
+	return sign + F.ToString(len(items)) + " (" + strings.Join(descriptions, ", ") + ")"
 }
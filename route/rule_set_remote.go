@@ -7,12 +7,15 @@ import (
 	"net"
 	"net/http"
 	"runtime"
+	"sync"
 	"time"
 
 	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/ruleconv"
 	"github.com/sagernet/sing-box/common/srs"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/atomic"
 	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/json"
 	"github.com/sagernet/sing/common/logger"
@@ -22,6 +25,8 @@ import (
 	"github.com/sagernet/sing/service/pause"
 )
 
+const remoteRuleSetRetryDelay = 10 * time.Second
+
 var _ adapter.RuleSet = (*RemoteRuleSet)(nil)
 
 type RemoteRuleSet struct {
@@ -30,14 +35,20 @@ type RemoteRuleSet struct {
 	router         adapter.Router
 	logger         logger.ContextLogger
 	options        option.RuleSet
-	metadata       adapter.RuleSetMetadata
 	updateInterval time.Duration
+	maxAge         time.Duration
 	dialer         N.Dialer
-	rules          []adapter.HeadlessRule
-	lastUpdated    time.Time
-	lastEtag       string
-	updateTicker   *time.Ticker
+	rules          atomic.TypedValue[[]adapter.HeadlessRule]
+	metadata       atomic.TypedValue[adapter.RuleSetMetadata]
 	pauseManager   pause.Manager
+	updateAccess   sync.Mutex
+
+	access       sync.Mutex
+	ruleCount    int
+	lastUpdated  time.Time
+	lastEtag     string
+	lastModified string
+	lastError    error
 }
 
 func NewRemoteRuleSet(ctx context.Context, router adapter.Router, logger logger.ContextLogger, options option.RuleSet) *RemoteRuleSet {
@@ -55,12 +66,21 @@ func NewRemoteRuleSet(ctx context.Context, router adapter.Router, logger logger.
 		logger:         logger,
 		options:        options,
 		updateInterval: updateInterval,
+		maxAge:         time.Duration(options.RemoteOptions.MaxAge),
 		pauseManager:   service.FromContext[pause.Manager](ctx),
 	}
 }
 
+func (s *RemoteRuleSet) Tag() string {
+	return s.options.Tag
+}
+
+func (s *RemoteRuleSet) Type() string {
+	return C.RuleSetTypeRemote
+}
+
 func (s *RemoteRuleSet) Match(metadata *adapter.InboundContext) bool {
-	for _, rule := range s.rules {
+	for _, rule := range s.rules.Load() {
 		if rule.Match(metadata) {
 			return true
 		}
@@ -84,40 +104,61 @@ func (s *RemoteRuleSet) StartContext(ctx context.Context, startContext adapter.R
 		dialer = outbound
 	}
 	s.dialer = dialer
+	var savedSet *adapter.SavedRuleSet
 	cacheFile := service.FromContext[adapter.CacheFile](s.ctx)
 	if cacheFile != nil {
-		if savedSet := cacheFile.LoadRuleSet(s.options.Tag); savedSet != nil {
-			err := s.loadBytes(savedSet.Content)
-			if err != nil {
-				return E.Cause(err, "restore cached rule-set")
-			}
+		savedSet = cacheFile.LoadRuleSet(s.options.Tag)
+	}
+	if savedSet != nil && s.maxAge > 0 && time.Since(savedSet.LastUpdated) > s.maxAge {
+		s.logger.Info("cached rule-set ", s.options.Tag, " expired, updated at ", savedSet.LastUpdated.Format(time.DateTime))
+		savedSet = nil
+	}
+	if savedSet != nil {
+		err := s.loadBytes(savedSet.Content)
+		if err != nil {
+			s.logger.Error(E.Cause(err, "restore cached rule-set ", s.options.Tag))
+		} else {
+			s.access.Lock()
 			s.lastUpdated = savedSet.LastUpdated
 			s.lastEtag = savedSet.LastEtag
+			s.lastModified = savedSet.LastModified
+			s.access.Unlock()
 		}
 	}
-	if s.lastUpdated.IsZero() {
+	if s.UpdatedAt().IsZero() {
 		err := s.fetchOnce(ctx, startContext)
 		if err != nil {
 			return E.Cause(err, "initial rule-set: ", s.options.Tag)
 		}
 	}
-	s.updateTicker = time.NewTicker(s.updateInterval)
 	go s.loopUpdate()
 	return nil
 }
 
 func (s *RemoteRuleSet) PostStart() error {
-	if s.lastUpdated.IsZero() {
-		err := s.fetchOnce(s.ctx, nil)
-		if err != nil {
-			s.logger.Error("fetch rule-set ", s.options.Tag, ": ", err)
-		}
-	}
 	return nil
 }
 
 func (s *RemoteRuleSet) Metadata() adapter.RuleSetMetadata {
-	return s.metadata
+	return s.metadata.Load()
+}
+
+func (s *RemoteRuleSet) RuleCount() int {
+	s.access.Lock()
+	defer s.access.Unlock()
+	return s.ruleCount
+}
+
+func (s *RemoteRuleSet) UpdatedAt() time.Time {
+	s.access.Lock()
+	defer s.access.Unlock()
+	return s.lastUpdated
+}
+
+func (s *RemoteRuleSet) LastError() error {
+	s.access.Lock()
+	defer s.access.Unlock()
+	return s.lastError
 }
 
 func (s *RemoteRuleSet) loadBytes(content []byte) error {
@@ -139,7 +180,13 @@ func (s *RemoteRuleSet) loadBytes(content []byte) error {
 			return err
 		}
 	default:
-		return E.New("unknown rule set format: ", s.options.Format)
+		if !ruleconv.IsSupported(s.options.Format) {
+			return E.New("unknown rule set format: ", s.options.Format)
+		}
+		plainRuleSet, err = convertRuleSet(s.logger, s.options.Tag, s.options.Format, content)
+		if err != nil {
+			return err
+		}
 	}
 	rules := make([]adapter.HeadlessRule, len(plainRuleSet.Rules))
 	for i, ruleOptions := range plainRuleSet.Rules {
@@ -148,36 +195,70 @@ func (s *RemoteRuleSet) loadBytes(content []byte) error {
 			return E.Cause(err, "parse rule_set.rules.[", i, "]")
 		}
 	}
-	s.metadata.ContainsProcessRule = hasHeadlessRule(plainRuleSet.Rules, isProcessHeadlessRule)
-	s.metadata.ContainsWIFIRule = hasHeadlessRule(plainRuleSet.Rules, isWIFIHeadlessRule)
-	s.metadata.ContainsIPCIDRRule = hasHeadlessRule(plainRuleSet.Rules, isIPCIDRHeadlessRule)
-	s.rules = rules
+	var metadata adapter.RuleSetMetadata
+	metadata.ContainsProcessRule = hasHeadlessRule(plainRuleSet.Rules, isProcessHeadlessRule)
+	metadata.ContainsWIFIRule = hasHeadlessRule(plainRuleSet.Rules, isWIFIHeadlessRule)
+	metadata.ContainsIPCIDRRule = hasHeadlessRule(plainRuleSet.Rules, isIPCIDRHeadlessRule)
+	s.rules.Store(rules)
+	s.metadata.Store(metadata)
+	s.access.Lock()
+	s.ruleCount = len(ruleSetItems(s.options.Format, content))
+	s.access.Unlock()
 	return nil
 }
 
 func (s *RemoteRuleSet) loopUpdate() {
-	if time.Since(s.lastUpdated) > s.updateInterval {
-		err := s.fetchOnce(s.ctx, nil)
-		if err != nil {
-			s.logger.Error("fetch rule-set ", s.options.Tag, ": ", err)
-		}
+	var (
+		failures int
+		delay    time.Duration
+	)
+	if elapsed := time.Since(s.UpdatedAt()); elapsed < s.updateInterval {
+		delay = s.updateInterval - elapsed
 	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
 	for {
-		runtime.GC()
 		select {
 		case <-s.ctx.Done():
 			return
-		case <-s.updateTicker.C:
-			s.pauseManager.WaitActive()
-			err := s.fetchOnce(s.ctx, nil)
-			if err != nil {
-				s.logger.Error("fetch rule-set ", s.options.Tag, ": ", err)
+		case <-timer.C:
+		}
+		s.pauseManager.WaitActive()
+		err := s.Update(s.ctx)
+		if err != nil {
+			failures++
+			delay = remoteRuleSetRetryDelay << (failures - 1)
+			if delay <= 0 || delay > s.updateInterval {
+				delay = s.updateInterval
+			}
+			s.logger.Error("fetch rule-set ", s.options.Tag, ": ", err, ", retry in ", delay)
+			if s.maxAge > 0 && time.Since(s.UpdatedAt()) > s.maxAge && s.rules.Load() != nil {
+				s.logger.Error("rule-set ", s.options.Tag, " is older than max_age, stop using it until updated")
+				s.rules.Store(nil)
+				s.access.Lock()
+				s.ruleCount = 0
+				s.access.Unlock()
 			}
+		} else {
+			failures = 0
+			delay = s.updateInterval
 		}
+		runtime.GC()
+		timer.Reset(delay)
 	}
 }
 
+func (s *RemoteRuleSet) Update(ctx context.Context) error {
+	err := s.fetchOnce(ctx, nil)
+	s.access.Lock()
+	s.lastError = err
+	s.access.Unlock()
+	return err
+}
+
 func (s *RemoteRuleSet) fetchOnce(ctx context.Context, startContext adapter.RuleSetStartContext) error {
+	s.updateAccess.Lock()
+	defer s.updateAccess.Unlock()
 	s.logger.Debug("updating rule-set ", s.options.Tag, " from URL: ", s.options.RemoteOptions.URL)
 	var httpClient *http.Client
 	if startContext != nil {
@@ -192,27 +273,39 @@ func (s *RemoteRuleSet) fetchOnce(ctx context.Context, startContext adapter.Rule
 				},
 			},
 		}
+		defer httpClient.CloseIdleConnections()
 	}
 	request, err := http.NewRequest("GET", s.options.RemoteOptions.URL, nil)
 	if err != nil {
 		return err
 	}
-	if s.lastEtag != "" {
-		request.Header.Set("If-None-Match", s.lastEtag)
+	s.access.Lock()
+	if !s.lastUpdated.IsZero() {
+		if s.lastEtag != "" {
+			request.Header.Set("If-None-Match", s.lastEtag)
+		}
+		if s.lastModified != "" {
+			request.Header.Set("If-Modified-Since", s.lastModified)
+		}
 	}
+	s.access.Unlock()
 	response, err := httpClient.Do(request.WithContext(ctx))
 	if err != nil {
 		return err
 	}
+	defer response.Body.Close()
+	cacheFile := service.FromContext[adapter.CacheFile](s.ctx)
 	switch response.StatusCode {
 	case http.StatusOK:
 	case http.StatusNotModified:
-		s.lastUpdated = time.Now()
-		cacheFile := service.FromContext[adapter.CacheFile](s.ctx)
+		lastUpdated := time.Now()
+		s.access.Lock()
+		s.lastUpdated = lastUpdated
+		s.access.Unlock()
 		if cacheFile != nil {
 			savedRuleSet := cacheFile.LoadRuleSet(s.options.Tag)
 			if savedRuleSet != nil {
-				savedRuleSet.LastUpdated = s.lastUpdated
+				savedRuleSet.LastUpdated = lastUpdated
 				err = cacheFile.SaveRuleSet(s.options.Tag, savedRuleSet)
 				if err != nil {
 					s.logger.Error("save rule-set updated time: ", err)
@@ -227,27 +320,26 @@ func (s *RemoteRuleSet) fetchOnce(ctx context.Context, startContext adapter.Rule
 	}
 	content, err := io.ReadAll(response.Body)
 	if err != nil {
-		response.Body.Close()
 		return err
 	}
 	err = s.loadBytes(content)
 	if err != nil {
-		response.Body.Close()
 		return err
 	}
-	response.Body.Close()
-	eTagHeader := response.Header.Get("Etag")
-	if eTagHeader != "" {
-		s.lastEtag = eTagHeader
+	lastUpdated := time.Now()
+	s.access.Lock()
+	s.lastEtag = response.Header.Get("Etag")
+	s.lastModified = response.Header.Get("Last-Modified")
+	s.lastUpdated = lastUpdated
+	savedSet := &adapter.SavedRuleSet{
+		LastUpdated:  lastUpdated,
+		Content:      content,
+		LastEtag:     s.lastEtag,
+		LastModified: s.lastModified,
 	}
-	s.lastUpdated = time.Now()
-	cacheFile := service.FromContext[adapter.CacheFile](s.ctx)
+	s.access.Unlock()
 	if cacheFile != nil {
-		err = cacheFile.SaveRuleSet(s.options.Tag, &adapter.SavedRuleSet{
-			LastUpdated: s.lastUpdated,
-			Content:     content,
-			LastEtag:    s.lastEtag,
-		})
+		err = cacheFile.SaveRuleSet(s.options.Tag, savedSet)
 		if err != nil {
 			s.logger.Error("save rule-set cache: ", err)
 		}
@@ -257,7 +349,6 @@ func (s *RemoteRuleSet) fetchOnce(ctx context.Context, startContext adapter.Rule
 }
 
 func (s *RemoteRuleSet) Close() error {
-	s.updateTicker.Stop()
 	s.cancel()
 	return nil
 }
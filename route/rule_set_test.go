@@ -0,0 +1,84 @@
+package route
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sagernet/sing-box/common/srs"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/service"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testRuleSet = option.PlainRuleSet{
+	Rules: []option.HeadlessRule{{
+		Type: C.RuleTypeDefault,
+		DefaultOptions: option.DefaultHeadlessRule{
+			Domain:       []string{"example.com", "example.org"},
+			DomainSuffix: []string{"example.net"},
+			IPCIDR:       []string{"10.0.0.0/8", "192.168.0.0/16"},
+			Port:         []uint16{443},
+		},
+	}},
+}
+
+func writeTestRuleSet(t *testing.T, ruleSet option.PlainRuleSet) []byte {
+	var buffer bytes.Buffer
+	require.NoError(t, srs.Write(&buffer, ruleSet))
+	return buffer.Bytes()
+}
+
+func TestRemoteRuleSetCount(t *testing.T) {
+	t.Parallel()
+	ruleSet := NewRemoteRuleSet(service.ContextWithDefaultRegistry(context.Background()), nil, log.NewNOPFactory().Logger(), option.RuleSet{
+		Type:   C.RuleSetTypeRemote,
+		Tag:    "test",
+		Format: C.RuleSetFormatBinary,
+	})
+	defer ruleSet.Close()
+	require.NoError(t, ruleSet.loadBytes(writeTestRuleSet(t, testRuleSet)))
+	// domains and IP CIDRs of binary rule-sets are only kept in matchers
+	require.Equal(t, 6, ruleSet.RuleCount())
+}
+
+func TestLocalRuleSetReload(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "rule-set.srs")
+	require.NoError(t, os.WriteFile(path, writeTestRuleSet(t, testRuleSet), 0o644))
+	ruleSet, err := NewLocalRuleSet(nil, log.NewNOPFactory().Logger(), option.RuleSet{
+		Type:         C.RuleSetTypeLocal,
+		Tag:          "test",
+		Format:       C.RuleSetFormatBinary,
+		LocalOptions: option.LocalRuleSet{Path: path},
+	})
+	require.NoError(t, err)
+	defer ruleSet.Close()
+	require.Equal(t, 6, ruleSet.RuleCount())
+
+	updatedRuleSet := option.PlainRuleSet{
+		Rules: []option.HeadlessRule{{
+			Type: C.RuleTypeDefault,
+			DefaultOptions: option.DefaultHeadlessRule{
+				Domain:       []string{"example.com"},
+				DomainSuffix: []string{"example.net"},
+				IPCIDR:       []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"},
+				Port:         []uint16{443},
+			},
+		}},
+	}
+	content := writeTestRuleSet(t, updatedRuleSet)
+	added, removed := diffHeadlessRuleItems(ruleSetItems(C.RuleSetFormatBinary, ruleSet.content), ruleSetItems(C.RuleSetFormatBinary, content))
+	require.Equal(t, []string{`ip_cidr="172.16.0.0/12"`}, added)
+	require.Equal(t, []string{`domain="example.org"`}, removed)
+
+	require.NoError(t, os.WriteFile(path, content, 0o644))
+	require.NoError(t, ruleSet.Update(context.Background()))
+	require.Equal(t, 6, ruleSet.RuleCount())
+	require.Equal(t, content, ruleSet.content)
+}
@@ -0,0 +1,73 @@
+package obfs
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// HTTPObfsServer is the server side of shadowsocks http simple-obfs
+type HTTPObfsServer struct {
+	net.Conn
+	reader        *bufio.Reader
+	key           string
+	requestRead   bool
+	responseWrote bool
+}
+
+func (hs *HTTPObfsServer) Read(b []byte) (int, error) {
+	if !hs.requestRead {
+		hs.reader = bufio.NewReader(hs.Conn)
+		request, err := http.ReadRequest(hs.reader)
+		if err != nil {
+			return 0, E.Cause(err, "read obfs request")
+		}
+		if !strings.EqualFold(request.Header.Get("Upgrade"), "websocket") {
+			return 0, E.New("bad obfs request")
+		}
+		hs.key = request.Header.Get("Sec-WebSocket-Key")
+		hs.requestRead = true
+	}
+	if hs.reader != nil {
+		if hs.reader.Buffered() > 0 {
+			return hs.reader.Read(b)
+		}
+		hs.reader = nil
+	}
+	return hs.Conn.Read(b)
+}
+
+func (hs *HTTPObfsServer) Write(b []byte) (int, error) {
+	if !hs.responseWrote {
+		hash := sha1.Sum([]byte(hs.key + webSocketGUID))
+		response := fmt.Sprintf("HTTP/1.1 101 Switching Protocols\r\n"+
+			"Server: nginx/1.%d.%d\r\n"+
+			"Date: %s\r\n"+
+			"Upgrade: websocket\r\n"+
+			"Connection: Upgrade\r\n"+
+			"Sec-WebSocket-Accept: %s\r\n\r\n",
+			rand.Int()%11, rand.Int()%12, time.Now().UTC().Format(http.TimeFormat), base64.StdEncoding.EncodeToString(hash[:]))
+		hs.responseWrote = true
+		_, err := hs.Conn.Write(append([]byte(response), b...))
+		if err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	return hs.Conn.Write(b)
+}
+
+// NewHTTPObfsServer return a HTTPObfsServer
+func NewHTTPObfsServer(conn net.Conn) net.Conn {
+	return &HTTPObfsServer{Conn: conn}
+}
@@ -0,0 +1,203 @@
+package obfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"net"
+	"time"
+
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+// TLSObfsServer is the server side of shadowsocks tls simple-obfs
+type TLSObfsServer struct {
+	net.Conn
+	sessionID     []byte
+	pending       []byte
+	remain        int
+	helloRead     bool
+	responseWrote bool
+}
+
+func (ts *TLSObfsServer) Read(b []byte) (int, error) {
+	if !ts.helloRead {
+		err := ts.readClientHello()
+		if err != nil {
+			return 0, err
+		}
+		ts.helloRead = true
+	}
+	if len(ts.pending) > 0 {
+		n := copy(b, ts.pending)
+		ts.pending = ts.pending[n:]
+		return n, nil
+	}
+	for ts.remain == 0 {
+		header := make([]byte, 5)
+		_, err := io.ReadFull(ts.Conn, header)
+		if err != nil {
+			return 0, err
+		}
+		length := int(binary.BigEndian.Uint16(header[3:]))
+		switch header[0] {
+		case 0x17:
+			ts.remain = length
+		case 0x14, 0x16:
+			_, err = io.CopyN(io.Discard, ts.Conn, int64(length))
+			if err != nil {
+				return 0, err
+			}
+		default:
+			return 0, E.New("unexpected record type: ", header[0])
+		}
+	}
+	length := ts.remain
+	if length > len(b) {
+		length = len(b)
+	}
+	n, err := ts.Conn.Read(b[:length])
+	ts.remain -= n
+	return n, err
+}
+
+func (ts *TLSObfsServer) readClientHello() error {
+	header := make([]byte, 5)
+	_, err := io.ReadFull(ts.Conn, header)
+	if err != nil {
+		return err
+	}
+	if header[0] != 0x16 {
+		return E.New("bad obfs client hello")
+	}
+	hello := make([]byte, binary.BigEndian.Uint16(header[3:]))
+	_, err = io.ReadFull(ts.Conn, hello)
+	if err != nil {
+		return err
+	}
+	sessionID, ticket, err := parseClientHello(hello)
+	if err != nil {
+		return E.Cause(err, "bad obfs client hello")
+	}
+	ts.sessionID = sessionID
+	ts.pending = ticket
+	return nil
+}
+
+// parseClientHello returns the session id and the session ticket, which
+// carries the first chunk of data.
+func parseClientHello(hello []byte) (sessionID []byte, ticket []byte, err error) {
+	// handshake type, length, version, random
+	if len(hello) < 39 || hello[0] != 1 {
+		return nil, nil, E.New("not a client hello")
+	}
+	offset := 38
+	sessionIDLen := int(hello[offset])
+	offset++
+	if len(hello) < offset+sessionIDLen+2 {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	sessionID = hello[offset : offset+sessionIDLen]
+	offset += sessionIDLen
+	offset += 2 + int(binary.BigEndian.Uint16(hello[offset:]))
+	if len(hello) < offset+1 {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	offset += 1 + int(hello[offset])
+	if len(hello) < offset+2 {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	offset += 2
+	for len(hello) >= offset+4 {
+		extensionType := binary.BigEndian.Uint16(hello[offset:])
+		extensionLen := int(binary.BigEndian.Uint16(hello[offset+2:]))
+		offset += 4
+		if len(hello) < offset+extensionLen {
+			return nil, nil, io.ErrUnexpectedEOF
+		}
+		if extensionType == 0x0023 {
+			return sessionID, hello[offset : offset+extensionLen], nil
+		}
+		offset += extensionLen
+	}
+	return nil, nil, E.New("missing session ticket")
+}
+
+func (ts *TLSObfsServer) Write(b []byte) (int, error) {
+	length := len(b)
+	for i := 0; i < length; i += chunkSize {
+		end := i + chunkSize
+		if end > length {
+			end = length
+		}
+
+		n, err := ts.write(b[i:end])
+		if err != nil {
+			return n, err
+		}
+	}
+	return length, nil
+}
+
+func (ts *TLSObfsServer) write(b []byte) (int, error) {
+	buf := &bytes.Buffer{}
+	if !ts.responseWrote {
+		writeServerHello(buf, ts.sessionID)
+		// change cipher spec
+		buf.Write([]byte{0x14, 0x03, 0x03, 0x00, 0x01, 0x01})
+		// encrypted handshake
+		buf.Write([]byte{0x16, 0x03, 0x03})
+		ts.responseWrote = true
+	} else {
+		buf.Write([]byte{0x17, 0x03, 0x03})
+	}
+	binary.Write(buf, binary.BigEndian, uint16(len(b)))
+	buf.Write(b)
+	_, err := ts.Conn.Write(buf.Bytes())
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func writeServerHello(buf *bytes.Buffer, sessionID []byte) {
+	if len(sessionID) != 32 {
+		sessionID = make([]byte, 32)
+		rand.Read(sessionID)
+	}
+	random := make([]byte, 28)
+	rand.Read(random)
+
+	// handshake, TLS 1.0 version, length
+	buf.Write([]byte{0x16, 0x03, 0x01, 0x00, 91})
+
+	// serverHello, length, TLS 1.2 version
+	buf.Write([]byte{0x02, 0x00, 0x00, 87, 0x03, 0x03})
+
+	// random with timestamp, sid len, sid
+	binary.Write(buf, binary.BigEndian, uint32(time.Now().Unix()))
+	buf.Write(random)
+	buf.WriteByte(32)
+	buf.Write(sessionID)
+
+	// cipher suite, compression
+	buf.Write([]byte{0xcc, 0xa8, 0x00})
+
+	// extension length
+	buf.Write([]byte{0x00, 15})
+
+	// renegotiation info
+	buf.Write([]byte{0xff, 0x01, 0x00, 0x01, 0x00})
+
+	// extended master secret
+	buf.Write([]byte{0x00, 0x17, 0x00, 0x00})
+
+	// ec_point
+	buf.Write([]byte{0x00, 0x0b, 0x00, 0x02, 0x01, 0x00})
+}
+
+// NewTLSObfsServer return a TLSObfsServer
+func NewTLSObfsServer(conn net.Conn) net.Conn {
+	return &TLSObfsServer{Conn: conn}
+}
@@ -0,0 +1,147 @@
+package sip003
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/sagernet/sing-box/adapter"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+var _ ServerPlugin = (*externalServerPlugin)(nil)
+
+// externalServerPlugin runs a SIP003 plugin binary in server mode. The plugin
+// listens on the address of the inbound and forwards connections to a local
+// listener, from where they are passed to the handler.
+type externalServerPlugin struct {
+	ctx        context.Context
+	logger     logger.ContextLogger
+	path       string
+	pluginArgs string
+	listenAddr M.Socksaddr
+	handler    adapter.V2RayServerTransportHandler
+	access     sync.Mutex
+	listener   net.Listener
+	cmd        *exec.Cmd
+	closed     bool
+}
+
+func newExternalServerPlugin(ctx context.Context, logger logger.ContextLogger, path string, pluginArgs string, listenAddr M.Socksaddr, handler adapter.V2RayServerTransportHandler) *externalServerPlugin {
+	return &externalServerPlugin{
+		ctx:        ctx,
+		logger:     logger,
+		path:       path,
+		pluginArgs: pluginArgs,
+		listenAddr: listenAddr,
+		handler:    handler,
+	}
+}
+
+func (p *externalServerPlugin) Network() []string {
+	return nil
+}
+
+func (p *externalServerPlugin) Start() error {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	localAddr := M.SocksaddrFromNet(listener.Addr())
+	cmd := exec.Command(p.path)
+	cmd.Env = append(os.Environ(),
+		"SS_REMOTE_HOST="+p.listenAddr.AddrString(),
+		"SS_REMOTE_PORT="+F.ToString(p.listenAddr.Port),
+		"SS_LOCAL_HOST="+localAddr.AddrString(),
+		"SS_LOCAL_PORT="+F.ToString(localAddr.Port),
+		"SS_PLUGIN_OPTIONS="+p.pluginArgs,
+	)
+	cmd.Stdout = &pluginLogWriter{logger: p.logger}
+	cmd.Stderr = &pluginLogWriter{logger: p.logger}
+	err = cmd.Start()
+	if err != nil {
+		listener.Close()
+		return E.Cause(err, "start plugin ", p.path)
+	}
+	p.logger.Info("plugin ", p.path, " started, forwarding to ", localAddr)
+	p.access.Lock()
+	p.listener = listener
+	p.cmd = cmd
+	p.access.Unlock()
+	go p.wait()
+	go p.loopIn()
+	return nil
+}
+
+func (p *externalServerPlugin) wait() {
+	err := p.cmd.Wait()
+	p.access.Lock()
+	closed := p.closed
+	p.access.Unlock()
+	if !closed {
+		p.logger.Error(E.Cause(err, "plugin ", p.path, " exited"))
+	}
+}
+
+func (p *externalServerPlugin) loopIn() {
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			hErr := p.handler.NewConnection(p.ctx, conn, M.Metadata{
+				Source: M.SocksaddrFromNet(conn.RemoteAddr()),
+			})
+			if hErr != nil {
+				conn.Close()
+				p.handler.NewError(p.ctx, hErr)
+			}
+		}()
+	}
+}
+
+func (p *externalServerPlugin) Serve(listener net.Listener) error {
+	return os.ErrInvalid
+}
+
+func (p *externalServerPlugin) ServePacket(listener net.PacketConn) error {
+	return os.ErrInvalid
+}
+
+func (p *externalServerPlugin) Close() error {
+	p.access.Lock()
+	defer p.access.Unlock()
+	p.closed = true
+	if p.cmd != nil {
+		p.cmd.Process.Kill()
+	}
+	if p.listener != nil {
+		return p.listener.Close()
+	}
+	return nil
+}
+
+type pluginLogWriter struct {
+	logger logger.ContextLogger
+	line   []byte
+}
+
+func (w *pluginLogWriter) Write(p []byte) (int, error) {
+	w.line = append(w.line, p...)
+	for {
+		index := bytes.IndexByte(w.line, '\n')
+		if index == -1 {
+			break
+		}
+		w.logger.Info("plugin: ", string(bytes.TrimRight(w.line[:index], "\r")))
+		w.line = w.line[index+1:]
+	}
+	return len(p), nil
+}
@@ -0,0 +1,83 @@
+package sip003
+
+import (
+	"context"
+	"net"
+	"os"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/transport/simple-obfs"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+var _ ServerPlugin = (*ObfsServer)(nil)
+
+func init() {
+	RegisterServerPlugin("obfs-server", newObfsServer)
+}
+
+func newObfsServer(ctx context.Context, logger logger.ContextLogger, pluginOpts Args, handler adapter.V2RayServerTransportHandler) (ServerPlugin, error) {
+	plugin := &ObfsServer{
+		ctx:     ctx,
+		handler: handler,
+	}
+	mode := "http"
+	if obfsMode, loaded := pluginOpts.Get("obfs"); loaded {
+		mode = obfsMode
+	}
+	switch mode {
+	case "http":
+	case "tls":
+		plugin.tls = true
+	default:
+		return nil, E.New("unknown obfs mode ", mode)
+	}
+	return plugin, nil
+}
+
+type ObfsServer struct {
+	ctx     context.Context
+	handler adapter.V2RayServerTransportHandler
+	tls     bool
+}
+
+func (o *ObfsServer) Network() []string {
+	return []string{N.NetworkTCP}
+}
+
+func (o *ObfsServer) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go o.newConnection(conn)
+	}
+}
+
+func (o *ObfsServer) newConnection(conn net.Conn) {
+	metadata := M.Metadata{
+		Source: M.SocksaddrFromNet(conn.RemoteAddr()),
+	}
+	if !o.tls {
+		conn = obfs.NewHTTPObfsServer(conn)
+	} else {
+		conn = obfs.NewTLSObfsServer(conn)
+	}
+	err := o.handler.NewConnection(o.ctx, conn, metadata)
+	if err != nil {
+		conn.Close()
+		o.handler.NewError(o.ctx, err)
+	}
+}
+
+func (o *ObfsServer) ServePacket(listener net.PacketConn) error {
+	return os.ErrInvalid
+}
+
+func (o *ObfsServer) Close() error {
+	return nil
+}
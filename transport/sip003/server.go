@@ -0,0 +1,58 @@
+package sip003
+
+import (
+	"context"
+	"net"
+
+	"github.com/sagernet/sing-box/adapter"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+// ExternalServerPlugin is the name of the plugin running an external SIP003
+// plugin binary.
+const ExternalServerPlugin = "external"
+
+type ServerPluginConstructor func(ctx context.Context, logger logger.ContextLogger, pluginOpts Args, handler adapter.V2RayServerTransportHandler) (ServerPlugin, error)
+
+// ServerPlugin accepts plugin connections on the listener of the inbound and
+// passes the unwrapped connections to the handler.
+type ServerPlugin interface {
+	Network() []string
+	Serve(listener net.Listener) error
+	ServePacket(listener net.PacketConn) error
+	Close() error
+}
+
+var serverPlugins map[string]ServerPluginConstructor
+
+func RegisterServerPlugin(name string, constructor ServerPluginConstructor) {
+	if serverPlugins == nil {
+		serverPlugins = make(map[string]ServerPluginConstructor)
+	}
+	serverPlugins[name] = constructor
+}
+
+// CreateServerPlugin creates a built-in server plugin, or runs the binary at
+// pluginPath as an external SIP003 process listening on listenAddr if the name
+// is ExternalServerPlugin.
+func CreateServerPlugin(ctx context.Context, logger logger.ContextLogger, name string, pluginPath string, pluginArgs string, listenAddr M.Socksaddr, handler adapter.V2RayServerTransportHandler) (ServerPlugin, error) {
+	if name == ExternalServerPlugin {
+		if pluginPath == "" {
+			return nil, E.New("missing plugin_path")
+		}
+		return newExternalServerPlugin(ctx, logger, pluginPath, pluginArgs, listenAddr, handler), nil
+	} else if pluginPath != "" {
+		return nil, E.New("plugin_path is only used by the external plugin")
+	}
+	constructor, loaded := serverPlugins[name]
+	if !loaded {
+		return nil, E.New("plugin not found: ", name)
+	}
+	pluginOptions, err := ParsePluginOptions(pluginArgs)
+	if err != nil {
+		return nil, E.Cause(err, "parse plugin_opts")
+	}
+	return constructor(ctx, logger, pluginOptions, handler)
+}
@@ -0,0 +1,169 @@
+package sip003
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/tls"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/transport/v2ray"
+	"github.com/sagernet/sing-vmess"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+func init() {
+	RegisterServerPlugin("v2ray-plugin", newV2RayServerPlugin)
+}
+
+func newV2RayServerPlugin(ctx context.Context, logger logger.ContextLogger, pluginOpts Args, handler adapter.V2RayServerTransportHandler) (ServerPlugin, error) {
+	mode := "websocket"
+	if modeOpt, loaded := pluginOpts.Get("mode"); loaded {
+		mode = modeOpt
+	}
+
+	host := "cloudfront.com"
+	path := "/"
+
+	if hostOpt, loaded := pluginOpts.Get("host"); loaded {
+		host = hostOpt
+	}
+	if pathOpt, loaded := pluginOpts.Get("path"); loaded {
+		path = pathOpt
+	}
+
+	var tlsOptions option.InboundTLSOptions
+	if _, loaded := pluginOpts.Get("tls"); loaded || mode == "quic" {
+		tlsOptions.Enabled = true
+		tlsOptions.ServerName = host
+	}
+	if certPath, loaded := pluginOpts.Get("cert"); loaded {
+		tlsOptions.CertificatePath = certPath
+	}
+	if keyPath, loaded := pluginOpts.Get("key"); loaded {
+		tlsOptions.KeyPath = keyPath
+	}
+	if tlsOptions.Enabled && (tlsOptions.CertificatePath == "" || tlsOptions.KeyPath == "") {
+		return nil, E.New("v2ray-plugin: missing cert or key")
+	}
+
+	var transportOptions option.V2RayTransportOptions
+	switch mode {
+	case "websocket":
+		transportOptions = option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeWebsocket,
+			WebsocketOptions: option.V2RayWebsocketOptions{
+				Path: path,
+			},
+		}
+	case "quic":
+		transportOptions = option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeQUIC,
+		}
+	default:
+		return nil, E.New("v2ray-plugin: unknown mode: " + mode)
+	}
+
+	tlsConfig, err := tls.NewServer(ctx, logger, tlsOptions)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := v2ray.NewServerTransport(ctx, transportOptions, tlsConfig, &v2rayServerHandler{handler})
+	if err != nil {
+		return nil, err
+	}
+	return &v2rayServerPlugin{transport, tlsConfig}, nil
+}
+
+var _ ServerPlugin = (*v2rayServerPlugin)(nil)
+
+type v2rayServerPlugin struct {
+	adapter.V2RayServerTransport
+	tlsConfig tls.ServerConfig
+}
+
+func (p *v2rayServerPlugin) Start() error {
+	return common.Start(p.tlsConfig)
+}
+
+func (p *v2rayServerPlugin) Close() error {
+	return common.Close(p.V2RayServerTransport, p.tlsConfig)
+}
+
+// v2rayServerHandler unwraps mux.cool connections of v2ray-plugin clients with
+// mux enabled.
+type v2rayServerHandler struct {
+	adapter.V2RayServerTransportHandler
+}
+
+func (h *v2rayServerHandler) NewConnection(ctx context.Context, conn net.Conn, metadata M.Metadata) error {
+	header, isMux, err := readMuxHeader(conn)
+	if err != nil {
+		header.Release()
+		conn.Close()
+		h.NewError(ctx, E.Cause(err, "read first frame"))
+		return nil
+	}
+	conn = bufio.NewCachedConn(conn, header)
+	if isMux {
+		err = vmess.HandleMuxConnection(ctx, conn, &v2rayMuxHandler{h.V2RayServerTransportHandler, metadata.Source})
+	} else {
+		err = h.V2RayServerTransportHandler.NewConnection(ctx, conn, metadata)
+	}
+	if err != nil {
+		conn.Close()
+		h.NewError(ctx, err)
+	}
+	return nil
+}
+
+// readMuxHeader reads the first frame if it opens a mux.cool TCP stream,
+// otherwise only the bytes needed to tell.
+func readMuxHeader(conn net.Conn) (header *buf.Buffer, isMux bool, err error) {
+	header = buf.New()
+	_, err = header.ReadFullFrom(conn, 7)
+	if err != nil {
+		return
+	}
+	frame := header.Bytes()
+	length := int(binary.BigEndian.Uint16(frame))
+	if frame[4] != vmess.StatusNew || frame[5]&vmess.OptionData == 0 || frame[6] != vmess.NetworkTCP || length < 12 || length > 5+2+1+1+255 {
+		return
+	}
+	_, err = header.ReadFullFrom(conn, length+2-7)
+	if err != nil {
+		return
+	}
+	frame = header.Bytes()
+	// network, port, address type
+	switch frame[9] {
+	case 0x01:
+		isMux = length == 5+2+1+4
+	case 0x03:
+		isMux = length == 5+2+1+16
+	case 0x02:
+		isMux = length >= 5+2+1+1 && length == 5+2+1+1+int(frame[10])
+	}
+	return
+}
+
+type v2rayMuxHandler struct {
+	adapter.V2RayServerTransportHandler
+	source M.Socksaddr
+}
+
+func (h *v2rayMuxHandler) NewConnection(ctx context.Context, conn net.Conn, metadata M.Metadata) error {
+	return h.V2RayServerTransportHandler.NewConnection(ctx, conn, M.Metadata{Source: h.source})
+}
+
+func (h *v2rayMuxHandler) NewPacketConnection(ctx context.Context, conn N.PacketConn, metadata M.Metadata) error {
+	return E.New("v2ray-plugin: UDP mux streams are not supported")
+}
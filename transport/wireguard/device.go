@@ -1,13 +1,21 @@
 package wireguard
 
 import (
+	"github.com/sagernet/sing-tun"
 	N "github.com/sagernet/sing/common/network"
-	"github.com/sagernet/wireguard-go/tun"
+	wgTun "github.com/sagernet/wireguard-go/tun"
 )
 
 type Device interface {
-	tun.Device
+	wgTun.Device
 	N.Dialer
 	Start() error
 	// NewEndpoint() (stack.LinkEndpoint, error)
 }
+
+// ServerDevice is a WireGuard device of which packets are handled by a
+// sing-tun stack created with Tun.
+type ServerDevice interface {
+	wgTun.Device
+	Tun() tun.Tun
+}
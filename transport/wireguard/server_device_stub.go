@@ -0,0 +1,11 @@
+//go:build !with_gvisor
+
+package wireguard
+
+import (
+	"github.com/sagernet/sing-tun"
+)
+
+func NewServerDevice(mtu uint32) (ServerDevice, error) {
+	return nil, tun.ErrGVisorNotIncluded
+}